@@ -1,16 +1,20 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"math/rand"
+	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
 	"syscall"
 	"time"
 
+	"car-simulator/internal/metrics"
 	"car-simulator/internal/simulator"
+	"shared/tracing"
 )
 
 func main() {
@@ -20,6 +24,13 @@ func main() {
 	}))
 	slog.SetDefault(logger)
 
+	// Export traces to an OTLP collector or stdout when configured
+	shutdownTracing, err := tracing.Setup(context.Background(), "car-simulator", getEnv("OTEL_TRACES_EXPORTER", tracing.ExporterNone))
+	if err != nil {
+		slog.Error("Failed to set up tracing", "error", err)
+		os.Exit(1)
+	}
+
 	// Seed random number generator
 	rand.Seed(time.Now().UnixNano())
 
@@ -41,6 +52,25 @@ func main() {
 		"start_lat", startLat,
 		"start_lng", startLng)
 
+	// Select the routing provider shared by all vehicles
+	router, err := simulator.NewRouterFromEnv()
+	if err != nil {
+		slog.Error("Failed to initialize router", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("Routing configured", "router", getEnv("ROUTER", simulator.RouterOSRM))
+
+	// Expose Prometheus metrics
+	metricsPort := getEnv("METRICS_PORT", "9090")
+	go func() {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		if err := http.ListenAndServe(":"+metricsPort, mux); err != nil {
+			slog.Error("Metrics server failed", "port", metricsPort, "error", err)
+		}
+	}()
+	slog.Info("Metrics server started", "port", metricsPort)
+
 	// Wait for fleet service to be ready after system reset
 	slog.Info("Waiting for fleet service to initialize", "wait_seconds", 45)
 	time.Sleep(45 * time.Second)
@@ -56,6 +86,7 @@ func main() {
 		lng := spawnLocation.Lng
 
 		vehicle := simulator.NewVehicle(vehicleID, region, fleetServiceURL, jobServiceURL, lat, lng)
+		vehicle.SetRouter(router)
 
 		if err := vehicle.Start(); err != nil {
 			slog.Error("Failed to start vehicle", "vehicle_id", vehicleID, "error", err)
@@ -83,6 +114,13 @@ func main() {
 	<-c
 
 	slog.Info("Shutting down car simulators")
+
+	// Flush any spans not yet exported
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Warn("Failed to flush traces", "error", err)
+	}
 }
 
 // getEnv gets environment variable with default value
@@ -6,14 +6,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 // Job represents a job from the job service
 type Job struct {
 	ID                  string           `json:"id"`
 	JobType             string           `json:"job_type"` // "ride", "delivery"
-	Status              string           `json:"status"`   // "pending", "assigned", "in_progress", "completed", "failed"
+	Status              string           `json:"status"`   // "pending", "assigned", "in_progress", "completed", "failed", "cancelled"
 	AssignedVehicleID   *string          `json:"assigned_vehicle_id,omitempty"`
 	PickupLat           float64          `json:"pickup_lat"`
 	PickupLng           float64          `json:"pickup_lng"`
@@ -23,6 +26,38 @@ type Job struct {
 	CustomerID          string           `json:"customer_id"`
 	Region              string           `json:"region"`
 	DeliveryDetails     *DeliveryDetails `json:"delivery_details,omitempty"`
+	CancellationReason  string           `json:"cancellation_reason,omitempty"`
+	Stops               []Stop           `json:"stops,omitempty"`
+	Shared              bool             `json:"shared,omitempty"` // may share the vehicle with other riders
+	Seats               int              `json:"seats,omitempty"`
+	BatchID             string           `json:"batch_id,omitempty"` // deliveries sharing one vehicle trip
+}
+
+// Stop is one place a job calls at, in order
+type Stop struct {
+	Lat       float64    `json:"lat"`
+	Lng       float64    `json:"lng"`
+	Type      string     `json:"type"` // "pickup", "waypoint", "dropoff"
+	Note      string     `json:"note,omitempty"`
+	ArrivedAt *time.Time `json:"arrived_at,omitempty"`
+}
+
+// Itinerary returns the stops to visit in order. Jobs from a job service that
+// predates multi-stop jobs go straight from the pickup to the destination.
+func (j *Job) Itinerary() []Stop {
+	if len(j.Stops) >= 2 {
+		return j.Stops
+	}
+	return []Stop{
+		{Lat: j.PickupLat, Lng: j.PickupLng, Type: "pickup"},
+		{Lat: j.DestinationLat, Lng: j.DestinationLng, Type: "dropoff"},
+	}
+}
+
+// FollowsStopPlan reports whether the job shares its vehicle with other jobs, which
+// visits their stops in the order the fleet service planned
+func (j *Job) FollowsStopPlan() bool {
+	return j.Shared || j.BatchID != ""
 }
 
 // DeliveryDetails contains delivery-specific information
@@ -32,10 +67,19 @@ type DeliveryDetails struct {
 	Instructions   string   `json:"instructions"`
 }
 
+// TripReport is the distance and driving time a vehicle measured from pickup to drop-off,
+// which the job service charges the final fare on
+type TripReport struct {
+	DistanceKm      float64 `json:"actual_distance_km"`
+	DurationMinutes float64 `json:"actual_duration_minutes"`
+}
+
 // Client handles communication with the Job Service
 type Client struct {
 	baseURL    string
 	httpClient *http.Client
+	// streamClient has no overall timeout since event streams stay open indefinitely
+	streamClient *http.Client
 }
 
 // NewClient creates a new job service client
@@ -44,14 +88,43 @@ func NewClient(baseURL string) *Client {
 		baseURL: baseURL,
 		httpClient: &http.Client{
 			Timeout: 10 * time.Second,
+			// Carry the vehicle's trace over to the job service
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
 		},
+		streamClient: &http.Client{},
 	}
 }
 
-// GetAssignedJobs retrieves jobs assigned to a specific vehicle
+// GetAssignedJobs retrieves the jobs currently assigned to a specific vehicle
 func (c *Client) GetAssignedJobs(ctx context.Context, vehicleID string) ([]*Job, error) {
-	// Get all jobs and filter by vehicle ID (in a real system, this would be a dedicated endpoint)
-	url := fmt.Sprintf("%s/jobs", c.baseURL)
+	endpoint := fmt.Sprintf("%s/vehicles/%s/jobs?status=assigned", c.baseURL, url.PathEscape(vehicleID))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("job service returned status %d", resp.StatusCode)
+	}
+
+	var assignedJobs []*Job
+	if err := json.NewDecoder(resp.Body).Decode(&assignedJobs); err != nil {
+		return nil, err
+	}
+
+	return assignedJobs, nil
+}
+
+// GetJob retrieves the current state of a single job
+func (c *Client) GetJob(ctx context.Context, jobID string) (*Job, error) {
+	url := fmt.Sprintf("%s/jobs/%s", c.baseURL, jobID)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
@@ -68,25 +141,39 @@ func (c *Client) GetAssignedJobs(ctx context.Context, vehicleID string) ([]*Job,
 		return nil, fmt.Errorf("job service returned status %d", resp.StatusCode)
 	}
 
-	var allJobs []*Job
-	if err := json.NewDecoder(resp.Body).Decode(&allJobs); err != nil {
+	var job Job
+	if err := json.NewDecoder(resp.Body).Decode(&job); err != nil {
 		return nil, err
 	}
 
-	// Filter jobs assigned to this vehicle
-	var assignedJobs []*Job
-	for _, job := range allJobs {
-		if job.AssignedVehicleID != nil && *job.AssignedVehicleID == vehicleID {
-			assignedJobs = append(assignedJobs, job)
-		}
+	return &job, nil
+}
+
+// ConfirmPickup tells the job service the vehicle has picked up the passenger or order
+func (c *Client) ConfirmPickup(ctx context.Context, jobID string) error {
+	url := fmt.Sprintf("%s/jobs/%s/pickup", c.baseURL, jobID)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, nil)
+	if err != nil {
+		return err
 	}
 
-	return assignedJobs, nil
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to confirm pickup, status: %d", resp.StatusCode)
+	}
+
+	return nil
 }
 
-// CompleteJob marks a job as completed
-func (c *Client) CompleteJob(ctx context.Context, jobID string) error {
-	url := fmt.Sprintf("%s/jobs/%s/complete", c.baseURL, jobID)
+// ArriveAtStop tells the job service the vehicle has reached one of a job's stops
+func (c *Client) ArriveAtStop(ctx context.Context, jobID string, stopIndex int) error {
+	url := fmt.Sprintf("%s/jobs/%s/stops/%d/arrive", c.baseURL, jobID, stopIndex)
 
 	req, err := http.NewRequestWithContext(ctx, "POST", url, nil)
 	if err != nil {
@@ -99,6 +186,68 @@ func (c *Client) CompleteJob(ctx context.Context, jobID string) error {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to report stop arrival, status: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// AbandonJob tells the job service the vehicle can no longer serve a job so it can be reassigned
+func (c *Client) AbandonJob(ctx context.Context, jobID, vehicleID, reason string) error {
+	abandonment := struct {
+		VehicleID string `json:"vehicle_id"`
+		Reason    string `json:"reason"`
+	}{
+		VehicleID: vehicleID,
+		Reason:    reason,
+	}
+
+	jsonData, err := json.Marshal(abandonment)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/jobs/%s/abandon", c.baseURL, jobID)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to abandon job, status: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// CompleteJob marks a job as completed, reporting the trip the vehicle drove
+func (c *Client) CompleteJob(ctx context.Context, jobID string, trip TripReport) error {
+	jsonData, err := json.Marshal(trip)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/jobs/%s/complete", c.baseURL, jobID)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("failed to complete job, status: %d", resp.StatusCode)
 	}
@@ -5,14 +5,22 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"shared/tracing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 func TestClient_GetAssignedJobs(t *testing.T) {
 	// Create mock server
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path != "/jobs" {
-			t.Errorf("Expected path '/jobs', got %s", r.URL.Path)
+		if r.URL.Path != "/vehicles/vehicle-1/jobs" {
+			t.Errorf("Expected path '/vehicles/vehicle-1/jobs', got %s", r.URL.Path)
+		}
+		if status := r.URL.Query().Get("status"); status != "assigned" {
+			t.Errorf("Expected status filter 'assigned', got %s", status)
 		}
 
 		jobs := []*Job{
@@ -28,27 +36,6 @@ func TestClient_GetAssignedJobs(t *testing.T) {
 				CustomerID:        "customer-1",
 				Region:            "us-west-2",
 			},
-			{
-				ID:                "job-2",
-				JobType:           "delivery",
-				Status:            "assigned",
-				AssignedVehicleID: stringPtr("vehicle-2"),
-				PickupLat:         37.7649,
-				PickupLng:         -122.4294,
-				DestinationLat:    37.7749,
-				DestinationLng:    -122.4194,
-				CustomerID:        "customer-2",
-				Region:            "us-west-2",
-			},
-			{
-				ID:         "job-3",
-				JobType:    "ride",
-				Status:     "pending",
-				PickupLat:  37.7549,
-				PickupLng:  -122.4394,
-				CustomerID: "customer-3",
-				Region:     "us-west-2",
-			},
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -65,7 +52,7 @@ func TestClient_GetAssignedJobs(t *testing.T) {
 	}
 
 	if len(jobs) != 1 {
-		t.Errorf("Expected 1 job for vehicle-1, got %d", len(jobs))
+		t.Fatalf("Expected 1 job for vehicle-1, got %d", len(jobs))
 	}
 
 	if jobs[0].ID != "job-1" {
@@ -89,6 +76,14 @@ func TestClient_CompleteJob(t *testing.T) {
 			t.Errorf("Expected POST method, got %s", r.Method)
 		}
 
+		var trip TripReport
+		if err := json.NewDecoder(r.Body).Decode(&trip); err != nil {
+			t.Errorf("Failed to decode trip report: %v", err)
+		}
+		if trip.DistanceKm != 6.2 || trip.DurationMinutes != 14.5 {
+			t.Errorf("Expected 6.2km in 14.5 minutes, got %+v", trip)
+		}
+
 		w.WriteHeader(http.StatusOK)
 	}))
 	defer server.Close()
@@ -96,7 +91,7 @@ func TestClient_CompleteJob(t *testing.T) {
 	client := NewClient(server.URL)
 	ctx := context.Background()
 
-	err := client.CompleteJob(ctx, "job-123")
+	err := client.CompleteJob(ctx, "job-123", TripReport{DistanceKm: 6.2, DurationMinutes: 14.5})
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -112,7 +107,7 @@ func TestClient_CompleteJob_Error(t *testing.T) {
 	client := NewClient(server.URL)
 	ctx := context.Background()
 
-	err := client.CompleteJob(ctx, "job-123")
+	err := client.CompleteJob(ctx, "job-123", TripReport{})
 	if err == nil {
 		t.Fatal("Expected error, got nil")
 	}
@@ -197,3 +192,141 @@ func TestClient_CreateTestRideJob(t *testing.T) {
 func stringPtr(s string) *string {
 	return &s
 }
+
+func TestClient_GetJob(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/jobs/job-123" {
+			t.Errorf("Expected path '/jobs/job-123', got %s", r.URL.Path)
+		}
+
+		json.NewEncoder(w).Encode(&Job{
+			ID:                 "job-123",
+			Status:             "cancelled",
+			CancellationReason: "no_show",
+		})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+
+	job, err := client.GetJob(context.Background(), "job-123")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if job.Status != "cancelled" {
+		t.Errorf("Expected status 'cancelled', got %s", job.Status)
+	}
+	if job.CancellationReason != "no_show" {
+		t.Errorf("Expected reason 'no_show', got %s", job.CancellationReason)
+	}
+}
+
+func TestClient_ConfirmPickup(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		expectedPath := "/jobs/job-123/pickup"
+		if r.URL.Path != expectedPath {
+			t.Errorf("Expected path '%s', got %s", expectedPath, r.URL.Path)
+		}
+
+		if r.Method != "POST" {
+			t.Errorf("Expected POST method, got %s", r.Method)
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+
+	if err := client.ConfirmPickup(context.Background(), "job-123"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+}
+
+func TestClient_AbandonJob(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/jobs/job-123/abandon" {
+			t.Errorf("Expected path '/jobs/job-123/abandon', got %s", r.URL.Path)
+		}
+
+		var body struct {
+			VehicleID string `json:"vehicle_id"`
+			Reason    string `json:"reason"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.VehicleID != "vehicle-1" || body.Reason != "battery depleted" {
+			t.Errorf("Unexpected abandonment body: %+v", body)
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+
+	if err := client.AbandonJob(context.Background(), "job-123", "vehicle-1", "battery depleted"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+}
+
+func TestClient_ArriveAtStop(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		expectedPath := "/jobs/job-123/stops/2/arrive"
+		if r.URL.Path != expectedPath {
+			t.Errorf("Expected path '%s', got %s", expectedPath, r.URL.Path)
+		}
+
+		if r.Method != "POST" {
+			t.Errorf("Expected POST method, got %s", r.Method)
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+
+	if err := client.ArriveAtStop(context.Background(), "job-123", 2); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+}
+
+func TestClient_PropagatesTraceContext(t *testing.T) {
+	if _, err := tracing.Setup(context.Background(), "car-simulator", tracing.ExporterNone); err != nil {
+		t.Fatalf("Failed to set up tracing: %v", err)
+	}
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "Vehicle.ConfirmPickup")
+	defer span.End()
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	if err := NewClient(server.URL).ConfirmPickup(ctx, "job-123"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The job service continues the vehicle's trace
+	if !strings.Contains(traceparent, span.SpanContext().TraceID().String()) {
+		t.Errorf("Expected trace %s in traceparent, got %q", span.SpanContext().TraceID(), traceparent)
+	}
+}
+
+func TestJob_Itinerary_FallsBackToPickupAndDropoff(t *testing.T) {
+	job := &Job{PickupLat: 1, PickupLng: 2, DestinationLat: 3, DestinationLng: 4}
+
+	stops := job.Itinerary()
+	if len(stops) != 2 {
+		t.Fatalf("Expected 2 stops, got %d", len(stops))
+	}
+	if stops[0].Type != "pickup" || stops[0].Lat != 1 || stops[0].Lng != 2 {
+		t.Errorf("Unexpected pickup stop %+v", stops[0])
+	}
+	if stops[1].Type != "dropoff" || stops[1].Lat != 3 || stops[1].Lng != 4 {
+		t.Errorf("Unexpected dropoff stop %+v", stops[1])
+	}
+}
@@ -5,6 +5,11 @@ import "context"
 // JobClient defines the interface for job service operations
 type JobClient interface {
 	GetAssignedJobs(ctx context.Context, vehicleID string) ([]*Job, error)
-	CompleteJob(ctx context.Context, jobID string) error
+	OpenJobStream(ctx context.Context, vehicleID string, lastEventID int64) (*JobStream, error)
+	GetJob(ctx context.Context, jobID string) (*Job, error)
+	ConfirmPickup(ctx context.Context, jobID string) error
+	ArriveAtStop(ctx context.Context, jobID string, stopIndex int) error
+	CompleteJob(ctx context.Context, jobID string, trip TripReport) error
+	AbandonJob(ctx context.Context, jobID, vehicleID, reason string) error
 	CreateTestRideJob(ctx context.Context, customerID, region string, pickupLat, pickupLng, destLat, destLng float64) (*Job, error)
 }
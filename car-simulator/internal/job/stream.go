@@ -0,0 +1,102 @@
+package job
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Job event types pushed by the job service
+const (
+	EventAssigned  = "assigned"
+	EventCancelled = "cancelled"
+	EventUpdated   = "updated"
+	// EventResync means events may have been missed and jobs should be polled
+	EventResync = "resync"
+)
+
+// JobEvent is a change to one of the vehicle's jobs pushed by the job service
+type JobEvent struct {
+	ID        int64  `json:"id"`
+	Type      string `json:"type"`             // assigned, cancelled, updated, resync
+	Change    string `json:"change,omitempty"` // picked_up, completed, failed, requeued for updates
+	VehicleID string `json:"vehicle_id"`
+	Job       *Job   `json:"job,omitempty"`
+}
+
+// JobStream reads job events from an open Server-Sent Events connection
+type JobStream struct {
+	body    io.ReadCloser
+	scanner *bufio.Scanner
+}
+
+// OpenJobStream subscribes to a vehicle's job events, resuming after lastEventID
+// when it is non-zero. The stream stays open until ctx is done or it is closed.
+func (c *Client) OpenJobStream(ctx context.Context, vehicleID string, lastEventID int64) (*JobStream, error) {
+	endpoint := fmt.Sprintf("%s/vehicles/%s/jobs/stream", c.baseURL, url.PathEscape(vehicleID))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if lastEventID > 0 {
+		req.Header.Set("Last-Event-ID", strconv.FormatInt(lastEventID, 10))
+	}
+
+	resp, err := c.streamClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("job service returned status %d", resp.StatusCode)
+	}
+
+	return &JobStream{
+		body:    resp.Body,
+		scanner: bufio.NewScanner(resp.Body),
+	}, nil
+}
+
+// Next blocks until the next event arrives, returning io.EOF when the server closes the stream
+func (s *JobStream) Next() (*JobEvent, error) {
+	var data strings.Builder
+
+	for s.scanner.Scan() {
+		line := s.scanner.Text()
+
+		// A blank line ends an event; comments and retry hints carry no data
+		if line == "" {
+			if data.Len() == 0 {
+				continue
+			}
+			var event JobEvent
+			if err := json.Unmarshal([]byte(data.String()), &event); err != nil {
+				return nil, fmt.Errorf("failed to decode job event: %w", err)
+			}
+			return &event, nil
+		}
+
+		if payload, ok := strings.CutPrefix(line, "data:"); ok {
+			data.WriteString(strings.TrimPrefix(payload, " "))
+		}
+	}
+
+	if err := s.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// Close ends the stream
+func (s *JobStream) Close() error {
+	return s.body.Close()
+}
@@ -0,0 +1,71 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_OpenJobStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/vehicles/vehicle-1/jobs/stream" {
+			t.Errorf("Expected path '/vehicles/vehicle-1/jobs/stream', got %s", r.URL.Path)
+		}
+		if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "41" {
+			t.Errorf("Expected Last-Event-ID '41', got '%s'", lastEventID)
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "retry: 2000\n\n")
+		fmt.Fprint(w, "id: 42\nevent: assigned\ndata: {\"id\":42,\"type\":\"assigned\",\"vehicle_id\":\"vehicle-1\",\"job\":{\"id\":\"job-1\",\"status\":\"assigned\"}}\n\n")
+		fmt.Fprint(w, ": keep-alive\n\n")
+		fmt.Fprint(w, "id: 43\nevent: cancelled\ndata: {\"id\":43,\"type\":\"cancelled\",\"vehicle_id\":\"vehicle-1\",\"job\":{\"id\":\"job-1\",\"status\":\"cancelled\",\"cancellation_reason\":\"no_show\"}}\n\n")
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	stream, err := client.OpenJobStream(context.Background(), "vehicle-1", 41)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer stream.Close()
+
+	event, err := stream.Next()
+	if err != nil {
+		t.Fatalf("Expected an event, got %v", err)
+	}
+	if event.ID != 42 || event.Type != EventAssigned || event.Job == nil || event.Job.ID != "job-1" {
+		t.Errorf("Unexpected first event: %+v", event)
+	}
+
+	event, err = stream.Next()
+	if err != nil {
+		t.Fatalf("Expected an event, got %v", err)
+	}
+	if event.Type != EventCancelled || event.Job.CancellationReason != "no_show" {
+		t.Errorf("Unexpected second event: %+v", event)
+	}
+
+	if _, err := stream.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("Expected io.EOF once the server closes the stream, got %v", err)
+	}
+}
+
+func TestClient_OpenJobStream_Unavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Last-Event-ID") != "" {
+			t.Error("Expected no Last-Event-ID on a fresh stream")
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	if _, err := client.OpenJobStream(context.Background(), "vehicle-1", 0); err == nil {
+		t.Error("Expected an error for a non-200 response")
+	}
+}
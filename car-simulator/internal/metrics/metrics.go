@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "car_simulator"
+
+var (
+	// KinesisPutFailures counts telemetry records that could not be written to Kinesis
+	KinesisPutFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "kinesis_put_failures_total",
+		Help:      "Vehicle telemetry records that could not be written to Kinesis.",
+	})
+
+	// RoutingFallbacks counts OSRM routes replaced with straight lines, by reason
+	RoutingFallbacks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "routing_fallbacks_total",
+		Help:      "OSRM routing requests that failed and fell back to a straight line, by reason.",
+	}, []string{"reason"})
+)
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,252 @@
+package simulator
+
+import (
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+)
+
+// graphDrivingSpeed is the assumed average speed on graph routes, in meters per second (50 km/h)
+const graphDrivingSpeed = 13.89
+
+// coordinatePrecision merges road endpoints closer than ~10cm into a single intersection
+const coordinatePrecision = 1e6
+
+// GraphRouter finds shortest paths over a road network loaded from a GeoJSON file,
+// such as an OpenStreetMap extract converted with osmtogeojson. Roads are the
+// LineString and MultiLineString features; a "oneway" property of "yes", "true"
+// or "1" restricts travel to the drawn direction and "-1" to the reverse.
+type GraphRouter struct {
+	nodes []RoutePoint
+	edges [][]graphEdge // outgoing edges by node index
+}
+
+// graphEdge is a directed road segment
+type graphEdge struct {
+	to     int
+	meters float64
+}
+
+// geoJSONFeatureCollection is the subset of GeoJSON the graph router reads
+type geoJSONFeatureCollection struct {
+	Features []struct {
+		Geometry struct {
+			Type        string          `json:"type"`
+			Coordinates json.RawMessage `json:"coordinates"`
+		} `json:"geometry"`
+		Properties map[string]interface{} `json:"properties"`
+	} `json:"features"`
+}
+
+// LoadGraphRouter builds a router from the road network in a GeoJSON file
+func LoadGraphRouter(path string) (*GraphRouter, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read road graph: %w", err)
+	}
+
+	router, err := NewGraphRouter(data)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("Road graph loaded", "path", path, "nodes", len(router.nodes))
+	return router, nil
+}
+
+// NewGraphRouter builds a router from GeoJSON road network data
+func NewGraphRouter(geoJSON []byte) (*GraphRouter, error) {
+	var collection geoJSONFeatureCollection
+	if err := json.Unmarshal(geoJSON, &collection); err != nil {
+		return nil, fmt.Errorf("failed to parse road graph: %w", err)
+	}
+
+	g := &GraphRouter{}
+	index := make(map[[2]int64]int)
+
+	for _, feature := range collection.Features {
+		var lines [][][]float64
+		switch feature.Geometry.Type {
+		case "LineString":
+			var line [][]float64
+			if err := json.Unmarshal(feature.Geometry.Coordinates, &line); err != nil {
+				return nil, fmt.Errorf("invalid LineString coordinates: %w", err)
+			}
+			lines = append(lines, line)
+		case "MultiLineString":
+			if err := json.Unmarshal(feature.Geometry.Coordinates, &lines); err != nil {
+				return nil, fmt.Errorf("invalid MultiLineString coordinates: %w", err)
+			}
+		default:
+			continue // Points, areas and the like aren't roads
+		}
+
+		forward, backward := roadDirections(feature.Properties)
+		for _, line := range lines {
+			for i := 1; i < len(line); i++ {
+				if len(line[i-1]) < 2 || len(line[i]) < 2 {
+					return nil, fmt.Errorf("road coordinate needs a longitude and latitude")
+				}
+				from := g.nodeFor(index, line[i-1][1], line[i-1][0]) // GeoJSON is [lng, lat]
+				to := g.nodeFor(index, line[i][1], line[i][0])
+				if from == to {
+					continue
+				}
+
+				meters := haversineDistance(g.nodes[from].Lat, g.nodes[from].Lng, g.nodes[to].Lat, g.nodes[to].Lng) * 1000
+				if forward {
+					g.edges[from] = append(g.edges[from], graphEdge{to: to, meters: meters})
+				}
+				if backward {
+					g.edges[to] = append(g.edges[to], graphEdge{to: from, meters: meters})
+				}
+			}
+		}
+	}
+
+	if len(g.nodes) == 0 {
+		return nil, fmt.Errorf("road graph contains no roads")
+	}
+
+	return g, nil
+}
+
+// roadDirections reads which ways a road may be driven from its oneway property
+func roadDirections(properties map[string]interface{}) (forward, backward bool) {
+	switch fmt.Sprint(properties["oneway"]) {
+	case "yes", "true", "1":
+		return true, false
+	case "-1", "reverse":
+		return false, true
+	default:
+		return true, true
+	}
+}
+
+// nodeFor returns the node at a coordinate, adding it if it is new
+func (g *GraphRouter) nodeFor(index map[[2]int64]int, lat, lng float64) int {
+	key := [2]int64{int64(math.Round(lat * coordinatePrecision)), int64(math.Round(lng * coordinatePrecision))}
+	if id, ok := index[key]; ok {
+		return id
+	}
+
+	id := len(g.nodes)
+	g.nodes = append(g.nodes, RoutePoint{Lat: lat, Lng: lng})
+	g.edges = append(g.edges, nil)
+	index[key] = id
+	return id
+}
+
+// nearestNode finds the road node closest to a point
+func (g *GraphRouter) nearestNode(lat, lng float64) int {
+	nearest := 0
+	minDistance := math.MaxFloat64
+	for id, node := range g.nodes {
+		if d := haversineDistance(lat, lng, node.Lat, node.Lng); d < minDistance {
+			minDistance = d
+			nearest = id
+		}
+	}
+	return nearest
+}
+
+// GetRoute drives from the road nearest the start to the road nearest the end along
+// the shortest path, found with A* using straight-line distance as the heuristic
+func (g *GraphRouter) GetRoute(startLat, startLng, endLat, endLng float64) (*Route, error) {
+	start := g.nearestNode(startLat, startLng)
+	goal := g.nearestNode(endLat, endLng)
+
+	path, meters, ok := g.shortestPath(start, goal)
+	if !ok {
+		return nil, fmt.Errorf("no road route from (%f, %f) to (%f, %f)", startLat, startLng, endLat, endLng)
+	}
+
+	// Include the legs on and off the road network
+	points := make([]RoutePoint, 0, len(path)+2)
+	points = append(points, RoutePoint{Lat: startLat, Lng: startLng})
+	for _, id := range path {
+		points = append(points, g.nodes[id])
+	}
+	points = append(points, RoutePoint{Lat: endLat, Lng: endLng})
+
+	meters += haversineDistance(startLat, startLng, g.nodes[start].Lat, g.nodes[start].Lng) * 1000
+	meters += haversineDistance(g.nodes[goal].Lat, g.nodes[goal].Lng, endLat, endLng) * 1000
+
+	return &Route{
+		Points:   points,
+		Distance: meters,
+		Duration: meters / graphDrivingSpeed,
+	}, nil
+}
+
+// shortestPath runs A* from start to goal, returning the nodes along the way and the distance in meters
+func (g *GraphRouter) shortestPath(start, goal int) ([]int, float64, bool) {
+	heuristic := func(id int) float64 {
+		return haversineDistance(g.nodes[id].Lat, g.nodes[id].Lng, g.nodes[goal].Lat, g.nodes[goal].Lng) * 1000
+	}
+
+	distance := map[int]float64{start: 0}
+	previous := make(map[int]int)
+	visited := make(map[int]bool)
+
+	open := &nodeQueue{{id: start, priority: heuristic(start)}}
+	for open.Len() > 0 {
+		current := heap.Pop(open).(queuedNode).id
+		if current == goal {
+			break
+		}
+		if visited[current] {
+			continue
+		}
+		visited[current] = true
+
+		for _, edge := range g.edges[current] {
+			candidate := distance[current] + edge.meters
+			if known, ok := distance[edge.to]; ok && known <= candidate {
+				continue
+			}
+			distance[edge.to] = candidate
+			previous[edge.to] = current
+			heap.Push(open, queuedNode{id: edge.to, priority: candidate + heuristic(edge.to)})
+		}
+	}
+
+	total, ok := distance[goal]
+	if !ok {
+		return nil, 0, false
+	}
+
+	path := []int{goal}
+	for id := goal; id != start; {
+		id = previous[id]
+		path = append(path, id)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+
+	return path, total, true
+}
+
+// queuedNode is a node waiting to be expanded by A*
+type queuedNode struct {
+	id       int
+	priority float64
+}
+
+// nodeQueue is a min-heap of nodes ordered by estimated total distance
+type nodeQueue []queuedNode
+
+func (q nodeQueue) Len() int            { return len(q) }
+func (q nodeQueue) Less(i, j int) bool  { return q[i].priority < q[j].priority }
+func (q nodeQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *nodeQueue) Push(x interface{}) { *q = append(*q, x.(queuedNode)) }
+func (q *nodeQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,88 @@
+package simulator
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func loadTestGraph(t *testing.T) *GraphRouter {
+	t.Helper()
+	router, err := LoadGraphRouter(filepath.Join("testdata", "road_graph.geojson"))
+	if err != nil {
+		t.Fatalf("Failed to load road graph: %v", err)
+	}
+	return router
+}
+
+func TestGraphRouter_ShortestPath(t *testing.T) {
+	router := loadTestGraph(t)
+
+	// Main St is far shorter than the detour over Hill Rd
+	route, err := router.GetRoute(37.7700, -122.4200, 37.7700, -122.4000)
+	if err != nil {
+		t.Fatalf("Expected a route, got %v", err)
+	}
+
+	for _, point := range route.Points {
+		if point.Lat != 37.7700 {
+			t.Errorf("Expected route to stay on Main St, passed through %v", point)
+		}
+	}
+
+	expected := haversineDistance(37.7700, -122.4200, 37.7700, -122.4000) * 1000
+	if route.Distance < expected*0.99 || route.Distance > expected*1.01 {
+		t.Errorf("Expected distance around %.0fm, got %.0fm", expected, route.Distance)
+	}
+	if route.Duration <= 0 {
+		t.Error("Expected positive duration")
+	}
+}
+
+func TestGraphRouter_SnapsToNearestRoad(t *testing.T) {
+	router := loadTestGraph(t)
+
+	// Start and end a little off the road network
+	route, err := router.GetRoute(37.7705, -122.4195, 37.7895, -122.4005)
+	if err != nil {
+		t.Fatalf("Expected a route, got %v", err)
+	}
+
+	first, last := route.Points[0], route.Points[len(route.Points)-1]
+	if first.Lat != 37.7705 || first.Lng != -122.4195 {
+		t.Errorf("Expected route to begin at the start point, got %v", first)
+	}
+	if last.Lat != 37.7895 || last.Lng != -122.4005 {
+		t.Errorf("Expected route to end at the destination, got %v", last)
+	}
+	if len(route.Points) < 4 {
+		t.Errorf("Expected the route to follow roads, got %v", route.Points)
+	}
+}
+
+func TestGraphRouter_RespectsOneWay(t *testing.T) {
+	router := loadTestGraph(t)
+
+	if _, err := router.GetRoute(37.7700, -122.4000, 37.7600, -122.4000); err != nil {
+		t.Errorf("Expected to drive down the one-way street, got %v", err)
+	}
+	if _, err := router.GetRoute(37.7600, -122.4000, 37.7700, -122.4000); err == nil {
+		t.Error("Expected no route against the one-way street")
+	}
+}
+
+func TestGraphRouter_Unreachable(t *testing.T) {
+	router := loadTestGraph(t)
+
+	if _, err := router.GetRoute(37.7700, -122.4200, 37.8000, -122.2900); err == nil {
+		t.Error("Expected no route to a disconnected road")
+	}
+}
+
+func TestNewGraphRouter_Invalid(t *testing.T) {
+	if _, err := NewGraphRouter([]byte(`not json`)); err == nil {
+		t.Error("Expected an error for invalid GeoJSON")
+	}
+	if _, err := NewGraphRouter([]byte(`{"type":"FeatureCollection","features":[]}`)); err == nil {
+		t.Error("Expected an error for a graph without roads")
+	}
+}
@@ -0,0 +1,126 @@
+package simulator
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"car-simulator/internal/job"
+)
+
+const (
+	// jobStreamBaseDelay and jobStreamMaxDelay bound the backoff between stream reconnects
+	jobStreamBaseDelay = 2 * time.Second
+	jobStreamMaxDelay  = 30 * time.Second
+
+	// streamBackstopPollTicks is how many simulation ticks pass between polls while
+	// job events are being pushed
+	streamBackstopPollTicks = 15
+)
+
+// initJobStream enables push delivery of job events unless JOB_DELIVERY_MODE is "poll"
+func (v *Vehicle) initJobStream() {
+	if os.Getenv("JOB_DELIVERY_MODE") == "poll" {
+		return // Polling only
+	}
+
+	v.jobEvents = make(chan *job.JobEvent, 16)
+}
+
+// jobStreamConnected reports whether job events are currently being pushed to the vehicle
+func (v *Vehicle) jobStreamConnected() bool {
+	return v.jobEvents != nil && v.streamConnected.Load()
+}
+
+// streamJobEvents keeps a job event stream open for the vehicle, reconnecting with
+// backoff and resuming after the last event it received. Events are handed to the
+// simulation loop, which polls instead while the stream is down.
+func (v *Vehicle) streamJobEvents() {
+	var lastEventID int64
+	delay := jobStreamBaseDelay
+
+	for {
+		stream, err := v.jobClient.OpenJobStream(context.Background(), v.ID, lastEventID)
+		if err != nil {
+			slog.Warn("Job stream unavailable, polling for jobs",
+				"vehicle_id", v.ID,
+				"retry_in", delay,
+				"error", err)
+			time.Sleep(delay)
+			delay = min(delay*2, jobStreamMaxDelay)
+			continue
+		}
+
+		v.streamConnected.Store(true)
+		delay = jobStreamBaseDelay
+		slog.Info("Job stream connected", "vehicle_id", v.ID, "last_event_id", lastEventID)
+
+		for {
+			event, err := stream.Next()
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					slog.Warn("Job stream interrupted", "vehicle_id", v.ID, "error", err)
+				}
+				break
+			}
+			if event.ID > 0 {
+				lastEventID = event.ID
+			}
+			v.jobEvents <- event
+		}
+
+		stream.Close()
+		v.streamConnected.Store(false)
+		time.Sleep(delay)
+	}
+}
+
+// handleJobEvent applies a pushed job event to the vehicle
+func (v *Vehicle) handleJobEvent(event *job.JobEvent) {
+	switch event.Type {
+	case job.EventResync:
+		// Events may have been missed, so catch up the same way polling does
+		v.checkForCancellation()
+		v.checkForJobs()
+
+	case job.EventAssigned:
+		if event.Job == nil || event.Job.Status != "assigned" {
+			return
+		}
+		if event.Job.FollowsStopPlan() && v.pooling() {
+			// Joined our stop plan; the fleet service's plan says where to pick it up
+			v.poolJobs[event.Job.ID] = event.Job
+			return
+		}
+		if v.Status != "available" || v.currentJob != nil {
+			return
+		}
+		v.startJob(event.Job)
+
+	case job.EventCancelled:
+		if v.inPool(event.Job) {
+			v.leavePool(event.Job.ID, event.Job.CancellationReason)
+		} else if v.isCurrentJob(event.Job) {
+			v.abortCurrentJob(event.Job.CancellationReason)
+		}
+
+	case job.EventUpdated:
+		// The job was taken away from us, e.g. requeued by the job service watchdog
+		if event.Change != "requeued" && event.Change != "failed" {
+			return
+		}
+		if v.inPool(event.Job) {
+			v.leavePool(event.Job.ID, event.Change)
+		} else if v.isCurrentJob(event.Job) {
+			v.abortCurrentJob(event.Change)
+		}
+	}
+}
+
+// isCurrentJob reports whether a job is the one the vehicle is working on
+func (v *Vehicle) isCurrentJob(j *job.Job) bool {
+	return j != nil && v.CurrentJobID != nil && *v.CurrentJobID == j.ID
+}
@@ -0,0 +1,231 @@
+package simulator
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"car-simulator/internal/job"
+)
+
+// defaultSeatCapacity is how many riders' seats a simulated vehicle offers to shared rides
+const defaultSeatCapacity = 4
+
+// PlannedStop is a stop on a route shared by several jobs, in the order the fleet service planned
+type PlannedStop struct {
+	JobID string  `json:"job_id"`
+	Type  string  `json:"type"` // "pickup", "dropoff"
+	Lat   float64 `json:"lat"`
+	Lng   float64 `json:"lng"`
+}
+
+// key identifies the stop within a pool
+func (s PlannedStop) key() string {
+	return s.JobID + "/" + s.Type
+}
+
+// pooling reports whether the vehicle is working through a stop plan for shared rides
+// or batched deliveries
+func (v *Vehicle) pooling() bool {
+	return v.poolJobs != nil
+}
+
+// inPool reports whether a job is one the vehicle still has planned stops for
+func (v *Vehicle) inPool(j *job.Job) bool {
+	if j == nil || !v.pooling() {
+		return false
+	}
+	for _, stop := range v.stopPlan {
+		if stop.JobID == j.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// startPool begins a shared ride or delivery batch. The vehicle follows the fleet
+// service's stop plan, which picks up riders joining later on the way.
+func (v *Vehicle) startPool(first *job.Job) {
+	v.poolJobs = map[string]*job.Job{first.ID: first}
+	v.visitedStops = make(map[string]bool)
+	v.currentJob = first
+	v.CurrentJobID = &first.ID
+	v.Status = "busy"
+	v.jobPhase = ""
+
+	// Serve the job on its own until the fleet service's plan says otherwise
+	v.stopPlan = []PlannedStop{
+		{JobID: first.ID, Type: "pickup", Lat: first.PickupLat, Lng: first.PickupLng},
+		{JobID: first.ID, Type: "dropoff", Lat: first.DestinationLat, Lng: first.DestinationLng},
+	}
+	v.syncWithFleet()
+	v.followStopPlan()
+
+	slog.Info("Vehicle started stop plan",
+		"vehicle_id", v.ID,
+		"job_id", first.ID,
+		"planned_stops", len(v.stopPlan))
+}
+
+// adoptStopPlan takes on the fleet service's stop plan, skipping stops already served
+// that the fleet service may not have caught up with
+func (v *Vehicle) adoptStopPlan(plan []PlannedStop) {
+	var remaining []PlannedStop
+	for _, stop := range plan {
+		if !v.visitedStops[stop.key()] {
+			remaining = append(remaining, stop)
+		}
+	}
+	v.stopPlan = remaining
+	v.followStopPlan()
+}
+
+// followStopPlan heads to the first planned stop, or frees the vehicle once the last
+// job has been dropped off
+func (v *Vehicle) followStopPlan() {
+	if len(v.stopPlan) == 0 {
+		v.finishPool()
+		return
+	}
+
+	next := v.stopPlan[0]
+	if v.isMoving && v.CurrentJobID != nil && *v.CurrentJobID == next.JobID && v.jobPhase == next.Type {
+		return // Already on the way
+	}
+
+	v.currentJob = v.poolJob(next.JobID)
+	v.CurrentJobID = &v.currentJob.ID
+	v.jobPhase = next.Type
+	v.setRouteTarget(next.Lat, next.Lng)
+}
+
+// arriveAtPlannedStop serves the planned stop the vehicle reached: a pickup
+// is confirmed and a drop-off completes its job
+func (v *Vehicle) arriveAtPlannedStop() {
+	stop := v.stopPlan[0]
+	v.stopPlan = v.stopPlan[1:]
+	v.visitedStops[stop.key()] = true
+
+	switch stop.Type {
+	case "pickup":
+		v.confirmPickup()
+	case "dropoff":
+		v.ensurePickupConfirmed(stop.JobID)
+		ctx, done := v.startJobCall("CompleteJob", stop.JobID)
+		err := v.jobClient.CompleteJob(ctx, stop.JobID, v.endTrip(stop.JobID))
+		done(err)
+		if err != nil {
+			slog.Error("Failed to complete planned job",
+				"vehicle_id", v.ID,
+				"job_id", stop.JobID,
+				"error", err)
+		}
+		delete(v.poolJobs, stop.JobID)
+		delete(v.pickupPending, stop.JobID)
+	}
+
+	slog.Info("Vehicle served planned stop",
+		"vehicle_id", v.ID,
+		"job_id", stop.JobID,
+		"stop_type", stop.Type,
+		"remaining_stops", len(v.stopPlan))
+
+	v.isMoving = false
+	v.followStopPlan()
+}
+
+// leavePool drops a planned job that was cancelled or taken away from the vehicle and
+// carries on with the others. The job service has already taken the job's
+// stops off the fleet service's plan.
+func (v *Vehicle) leavePool(jobID, reason string) {
+	slog.Info("Vehicle dropping planned job",
+		"vehicle_id", v.ID,
+		"job_id", jobID,
+		"reason", reason)
+
+	var remaining []PlannedStop
+	for _, stop := range v.stopPlan {
+		if stop.JobID == jobID {
+			v.visitedStops[stop.key()] = true
+			continue
+		}
+		remaining = append(remaining, stop)
+	}
+	v.stopPlan = remaining
+	delete(v.poolJobs, jobID)
+	delete(v.tripMeters, jobID)
+
+	v.followStopPlan()
+}
+
+// abandonPool hands every job the vehicle still has planned stops for back to the
+// job service
+func (v *Vehicle) abandonPool(reason string) {
+	abandoned := make(map[string]bool)
+	for _, stop := range v.stopPlan {
+		if abandoned[stop.JobID] {
+			continue
+		}
+		abandoned[stop.JobID] = true
+
+		ctx, done := v.startJobCall("AbandonJob", stop.JobID)
+		err := v.jobClient.AbandonJob(ctx, stop.JobID, v.ID, reason)
+		done(err)
+		if err != nil {
+			slog.Error("Failed to report abandoned planned job",
+				"vehicle_id", v.ID,
+				"job_id", stop.JobID,
+				"error", err)
+		}
+	}
+
+	v.clearPool()
+	v.currentJob = nil
+	v.CurrentJobID = nil
+}
+
+// finishPool returns the vehicle to service once its planned jobs are done
+func (v *Vehicle) finishPool() {
+	slog.Info("Vehicle finished its stop plan", "vehicle_id", v.ID)
+
+	v.clearPool()
+	v.currentJob = nil
+	v.CurrentJobID = nil
+	v.currentRoute = nil
+	v.routeIndex = 0
+	v.isMoving = false
+	v.Status = "available"
+	v.jobPhase = "idle"
+	v.stopIndex = 0
+}
+
+// clearPool forgets the vehicle's planned jobs
+func (v *Vehicle) clearPool() {
+	v.stopPlan = nil
+	v.poolJobs = nil
+	v.visitedStops = nil
+	v.tripMeters = nil
+}
+
+// poolJob returns the details of a planned job, fetching jobs that joined the plan
+// without the vehicle hearing about them yet
+func (v *Vehicle) poolJob(jobID string) *job.Job {
+	if j, ok := v.poolJobs[jobID]; ok {
+		return j
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	j, err := v.jobClient.GetJob(ctx, jobID)
+	if err != nil {
+		slog.Warn("Failed to fetch planned job details",
+			"vehicle_id", v.ID,
+			"job_id", jobID,
+			"error", err)
+		j = &job.Job{ID: jobID}
+	}
+
+	v.poolJobs[jobID] = j
+	return j
+}
@@ -6,7 +6,11 @@ import (
 	"log/slog"
 	"math"
 	"net/http"
+	"os"
+	"strings"
 	"time"
+
+	"car-simulator/internal/metrics"
 )
 
 // RoutePoint represents a coordinate point in a route
@@ -34,14 +38,57 @@ type OSRMResponse struct {
 	} `json:"routes"`
 }
 
-// RoutingService handles route calculations
-type RoutingService struct {
-	client *http.Client
+// Router calculates the route a vehicle drives between two points
+type Router interface {
+	GetRoute(startLat, startLng, endLat, endLng float64) (*Route, error)
 }
 
-// NewRoutingService creates a new routing service
-func NewRoutingService() *RoutingService {
-	return &RoutingService{
+// Router selections for ROUTER
+const (
+	RouterOSRM         = "osrm"
+	RouterStraightLine = "straight"
+	RouterGraph        = "graph"
+)
+
+// defaultOSRMBaseURL is the public OSRM demo server
+const defaultOSRMBaseURL = "http://router.project-osrm.org"
+
+// NewRouterFromEnv creates the router selected by the ROUTER environment variable:
+// "osrm" (default) uses the server at OSRM_BASE_URL, "straight" drives in straight
+// lines and "graph" finds shortest paths over the GeoJSON road network in ROAD_GRAPH_FILE.
+func NewRouterFromEnv() (Router, error) {
+	switch router := os.Getenv("ROUTER"); router {
+	case "", RouterOSRM:
+		baseURL := os.Getenv("OSRM_BASE_URL")
+		if baseURL == "" {
+			baseURL = defaultOSRMBaseURL
+		}
+		return NewOSRMRouter(baseURL), nil
+	case RouterStraightLine:
+		return StraightLineRouter{}, nil
+	case RouterGraph:
+		path := os.Getenv("ROAD_GRAPH_FILE")
+		if path == "" {
+			return nil, fmt.Errorf("ROAD_GRAPH_FILE must be set for the %q router", RouterGraph)
+		}
+		return LoadGraphRouter(path)
+	default:
+		return nil, fmt.Errorf("unknown router %q", router)
+	}
+}
+
+// OSRMRouter calculates routes with an OSRM server, falling back to a straight
+// line when the server can't be reached or finds no route
+type OSRMRouter struct {
+	baseURL  string
+	client   *http.Client
+	fallback StraightLineRouter
+}
+
+// NewOSRMRouter creates a router for the OSRM server at baseURL
+func NewOSRMRouter(baseURL string) *OSRMRouter {
+	return &OSRMRouter{
+		baseURL: strings.TrimRight(baseURL, "/"),
 		client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
@@ -49,10 +96,9 @@ func NewRoutingService() *RoutingService {
 }
 
 // GetRoute calculates a route between two points using OSRM
-func (r *RoutingService) GetRoute(startLat, startLng, endLat, endLng float64) (*Route, error) {
-	// OSRM API URL - using public demo server
-	url := fmt.Sprintf("http://router.project-osrm.org/route/v1/driving/%f,%f;%f,%f?overview=full&geometries=geojson",
-		startLng, startLat, endLng, endLat)
+func (r *OSRMRouter) GetRoute(startLat, startLng, endLat, endLng float64) (*Route, error) {
+	url := fmt.Sprintf("%s/route/v1/driving/%f,%f;%f,%f?overview=full&geometries=geojson",
+		r.baseURL, startLng, startLat, endLng, endLat)
 
 	resp, err := r.client.Get(url)
 	if err != nil {
@@ -64,7 +110,8 @@ func (r *RoutingService) GetRoute(startLat, startLng, endLat, endLng float64) (*
 			"end_lng", endLng,
 			"url", url)
 		// Fallback to straight line if routing fails
-		return r.createStraightLineRoute(startLat, startLng, endLat, endLng), nil
+		metrics.RoutingFallbacks.WithLabelValues("unreachable").Inc()
+		return r.fallback.GetRoute(startLat, startLng, endLat, endLng)
 	}
 	defer resp.Body.Close()
 
@@ -78,7 +125,8 @@ func (r *RoutingService) GetRoute(startLat, startLng, endLat, endLng float64) (*
 			"end_lat", endLat,
 			"end_lng", endLng)
 		// Fallback to straight line if parsing fails
-		return r.createStraightLineRoute(startLat, startLng, endLat, endLng), nil
+		metrics.RoutingFallbacks.WithLabelValues("invalid_response").Inc()
+		return r.fallback.GetRoute(startLat, startLng, endLat, endLng)
 	}
 
 	if len(osrmResp.Routes) == 0 {
@@ -89,7 +137,8 @@ func (r *RoutingService) GetRoute(startLat, startLng, endLat, endLng float64) (*
 			"end_lat", endLat,
 			"end_lng", endLng)
 		// Fallback to straight line if no routes found
-		return r.createStraightLineRoute(startLat, startLng, endLat, endLng), nil
+		metrics.RoutingFallbacks.WithLabelValues("no_route").Inc()
+		return r.fallback.GetRoute(startLat, startLng, endLat, endLng)
 	}
 
 	slog.Info("OSRM routing successful",
@@ -114,8 +163,12 @@ func (r *RoutingService) GetRoute(startLat, startLng, endLat, endLng float64) (*
 	}, nil
 }
 
-// createStraightLineRoute creates a fallback straight-line route
-func (r *RoutingService) createStraightLineRoute(startLat, startLng, endLat, endLng float64) *Route {
+// StraightLineRouter moves vehicles along the direct line between two points. It
+// needs no map data, so it is also the fallback when other routers fail.
+type StraightLineRouter struct{}
+
+// GetRoute returns a straight-line route between two points
+func (StraightLineRouter) GetRoute(startLat, startLng, endLat, endLng float64) (*Route, error) {
 	// Create 10 intermediate points for smooth movement
 	points := make([]RoutePoint, 11)
 
@@ -134,7 +187,7 @@ func (r *RoutingService) createStraightLineRoute(startLat, startLng, endLat, end
 		Points:   points,
 		Distance: distance,
 		Duration: duration,
-	}
+	}, nil
 }
 
 // haversineDistance calculates distance between two points in kilometers
@@ -1,32 +1,83 @@
 package simulator
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"car-simulator/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
-func TestNewRoutingService(t *testing.T) {
-	service := NewRoutingService()
+func TestNewOSRMRouter(t *testing.T) {
+	router := NewOSRMRouter("http://osrm.internal:5000/")
 
-	if service == nil {
-		t.Error("Expected routing service to be created")
+	if router == nil {
+		t.Error("Expected OSRM router to be created")
 	}
 
-	if service.client == nil {
+	if router.client == nil {
 		t.Error("Expected HTTP client to be initialized")
 	}
+
+	if router.baseURL != "http://osrm.internal:5000" {
+		t.Errorf("Expected trailing slash to be trimmed, got %s", router.baseURL)
+	}
+}
+
+func TestOSRMRouter_GetRoute(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/route/v1/driving/") {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		fmt.Fprint(w, `{"code":"Ok","routes":[{"geometry":{"coordinates":[[-122.6784,45.5152],[-122.64,45.55],[-122.5951,45.5898]]},"distance":12000,"duration":900}]}`)
+	}))
+	defer server.Close()
+
+	route, err := NewOSRMRouter(server.URL).GetRoute(45.5152, -122.6784, 45.5898, -122.5951)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(route.Points) != 3 || route.Points[1].Lat != 45.55 || route.Points[1].Lng != -122.64 {
+		t.Errorf("Expected OSRM geometry as [lng, lat] points, got %v", route.Points)
+	}
+	if route.Distance != 12000 {
+		t.Errorf("Expected distance 12000, got %f", route.Distance)
+	}
 }
 
-func TestRoutingService_CreateStraightLineRoute(t *testing.T) {
-	service := NewRoutingService()
+func TestOSRMRouter_FallsBackToStraightLine(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"code":"NoRoute","routes":[]}`)
+	}))
+	defer server.Close()
 
+	fallbacks := testutil.ToFloat64(metrics.RoutingFallbacks.WithLabelValues("no_route"))
+
+	route, err := NewOSRMRouter(server.URL).GetRoute(45.5152, -122.6784, 45.5898, -122.5951)
+	if err != nil {
+		t.Fatalf("Expected fallback route, got %v", err)
+	}
+	if len(route.Points) != 11 {
+		t.Errorf("Expected 11 straight-line points, got %d", len(route.Points))
+	}
+	if counted := testutil.ToFloat64(metrics.RoutingFallbacks.WithLabelValues("no_route")) - fallbacks; counted != 1 {
+		t.Errorf("Expected the fallback counted, got %v", counted)
+	}
+}
+
+func TestStraightLineRouter_GetRoute(t *testing.T) {
 	// Test route from downtown Portland to airport
 	startLat, startLng := 45.5152, -122.6784
 	endLat, endLng := 45.5898, -122.5951
 
-	route := service.createStraightLineRoute(startLat, startLng, endLat, endLng)
+	route, err := StraightLineRouter{}.GetRoute(startLat, startLng, endLat, endLng)
 
-	if route == nil {
-		t.Fatal("Expected route to be created")
+	if err != nil || route == nil {
+		t.Fatalf("Expected route to be created, got %v", err)
 	}
 
 	if len(route.Points) != 11 {
@@ -86,3 +137,34 @@ func TestVehicle_SetRouteTarget(t *testing.T) {
 		t.Error("Expected vehicle to be moving after setting route target")
 	}
 }
+
+func TestNewRouterFromEnv(t *testing.T) {
+	t.Setenv("ROUTER", "")
+	if router, err := NewRouterFromEnv(); err != nil {
+		t.Errorf("Expected default router, got %v", err)
+	} else if osrm, ok := router.(*OSRMRouter); !ok || osrm.baseURL != defaultOSRMBaseURL {
+		t.Errorf("Expected public OSRM router by default, got %#v", router)
+	}
+
+	t.Setenv("ROUTER", RouterOSRM)
+	t.Setenv("OSRM_BASE_URL", "http://osrm.internal:5000")
+	if router, _ := NewRouterFromEnv(); router.(*OSRMRouter).baseURL != "http://osrm.internal:5000" {
+		t.Errorf("Expected OSRM_BASE_URL to be used")
+	}
+
+	t.Setenv("ROUTER", RouterStraightLine)
+	if router, _ := NewRouterFromEnv(); router != (StraightLineRouter{}) {
+		t.Errorf("Expected straight-line router, got %#v", router)
+	}
+
+	t.Setenv("ROUTER", RouterGraph)
+	t.Setenv("ROAD_GRAPH_FILE", "")
+	if _, err := NewRouterFromEnv(); err == nil {
+		t.Error("Expected an error without a road graph file")
+	}
+
+	t.Setenv("ROUTER", "teleport")
+	if _, err := NewRouterFromEnv(); err == nil {
+		t.Error("Expected an error for an unknown router")
+	}
+}
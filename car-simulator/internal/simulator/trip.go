@@ -0,0 +1,45 @@
+package simulator
+
+import (
+	"time"
+
+	"car-simulator/internal/job"
+)
+
+// tripMeter measures a job's trip from pickup to drop-off for its final fare
+type tripMeter struct {
+	pickedUpAt time.Time
+	distanceKm float64
+}
+
+// startTrip starts metering a job once its passenger or order is on board
+func (v *Vehicle) startTrip(jobID string) {
+	if v.tripMeters == nil {
+		v.tripMeters = make(map[string]*tripMeter)
+	}
+	v.tripMeters[jobID] = &tripMeter{pickedUpAt: time.Now()}
+}
+
+// meterTrips adds distance driven to every job on board, each of which is measured
+// over the whole way the vehicle drove it. Shared riders are charged for no more than
+// their direct trip however far the pool took them.
+func (v *Vehicle) meterTrips(kmTraveled float64) {
+	for _, meter := range v.tripMeters {
+		meter.distanceKm += kmTraveled
+	}
+}
+
+// endTrip stops metering a job and returns what it measured. A job that was never
+// metered reports nothing, leaving the job service to fall back on its estimate.
+func (v *Vehicle) endTrip(jobID string) job.TripReport {
+	meter, ok := v.tripMeters[jobID]
+	if !ok {
+		return job.TripReport{}
+	}
+	delete(v.tripMeters, jobID)
+
+	return job.TripReport{
+		DistanceKm:      meter.distanceKm,
+		DurationMinutes: time.Since(meter.pickedUpAt).Minutes(),
+	}
+}
@@ -11,13 +11,22 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"car-simulator/internal/job"
+	"car-simulator/internal/metrics"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"shared/tracing"
 )
 
+var tracer = otel.Tracer("car-simulator/internal/simulator")
+
 // Vehicle represents a simulated autonomous vehicle
 type Vehicle struct {
 	ID             string  `json:"id"`
@@ -29,6 +38,7 @@ type Vehicle struct {
 	LocationLng    float64 `json:"location_lng"`
 	CurrentJobID   *string `json:"current_job_id,omitempty"`
 	VehicleType    string  `json:"vehicle_type"`
+	SeatCapacity   int     `json:"seat_capacity"`
 
 	// Simulation state
 	fleetServiceURL  string
@@ -39,16 +49,32 @@ type Vehicle struct {
 	isMoving         bool
 	batteryDrainRate float64 // km per battery percent
 	currentJob       *job.Job
-	jobPhase         string // "pickup", "delivery", "idle"
+	stopIndex        int             // stop of the current job being driven to
+	jobPhase         string          // type of the stop being driven to ("pickup", "waypoint", "dropoff"), "idle" or a charging phase
+	pickupPending    map[string]bool // jobs whose pickup confirmation failed and must be sent again
+	fleetVersion     int64           // last vehicle record version acknowledged by the fleet service
+
+	// Stop plan state for shared rides and delivery batches
+	stopPlan     []PlannedStop       // remaining stops of the jobs sharing the vehicle
+	poolJobs     map[string]*job.Job // jobs with stops left, by job ID
+	visitedStops map[string]bool     // stops of the current pool already served
+
+	// Trips of the jobs on board, by job ID
+	tripMeters map[string]*tripMeter
 
 	// Routing state
-	routingService *RoutingService
-	currentRoute   *Route
-	routeIndex     int // current position in route
+	router       Router
+	currentRoute *Route
+	routeIndex   int // current position in route
 
 	// Kinesis streaming (optional)
 	kinesisClient *kinesis.Client
 	streamName    string
+
+	// Pushed job events (nil when polling only)
+	jobEvents       chan *job.JobEvent
+	streamConnected atomic.Bool
+	ticks           int
 }
 
 // NewVehicle creates a new simulated vehicle
@@ -65,20 +91,28 @@ func NewVehicle(id, region, fleetServiceURL, jobServiceURL string, startLat, sta
 		LocationLat:      startLat,
 		LocationLng:      startLng,
 		VehicleType:      "sedan",
+		SeatCapacity:     defaultSeatCapacity,
 		fleetServiceURL:  fleetServiceURL,
 		jobServiceURL:    jobServiceURL,
 		jobClient:        job.NewClient(jobServiceURL),
 		batteryDrainRate: batteryDrainRate,
 		jobPhase:         "idle",
-		routingService:   NewRoutingService(),
+		pickupPending:    make(map[string]bool),
+		router:           NewOSRMRouter(defaultOSRMBaseURL),
 		routeIndex:       0,
 	}
 
 	// Initialize Kinesis client if stream name is provided
 	v.initKinesis()
+	v.initJobStream()
 	return v
 }
 
+// SetRouter sets how the vehicle plans its routes
+func (v *Vehicle) SetRouter(router Router) {
+	v.router = router
+}
+
 // Start begins the vehicle simulation loop
 func (v *Vehicle) Start() error {
 	// Register with fleet service with retry logic
@@ -86,6 +120,11 @@ func (v *Vehicle) Start() error {
 		return fmt.Errorf("failed to register with fleet after retries: %v", err)
 	}
 
+	// Receive job events as they happen, falling back to polling while disconnected
+	if v.jobEvents != nil {
+		go v.streamJobEvents()
+	}
+
 	// Start simulation loop
 	go v.simulationLoop()
 	return nil
@@ -96,35 +135,54 @@ func (v *Vehicle) simulationLoop() {
 	ticker := time.NewTicker(2 * time.Second) // Update every 2 seconds
 	defer ticker.Stop()
 
-	for range ticker.C {
-		// Log current vehicle status
-		v.logVehicleStatus()
+	for {
+		select {
+		case event := <-v.jobEvents:
+			v.handleJobEvent(event)
+		case <-ticker.C:
+			v.step()
+		}
+	}
+}
+
+// step advances the simulation by one tick
+func (v *Vehicle) step() {
+	// Log current vehicle status
+	v.logVehicleStatus()
+
+	// Poll for job changes unless they are being pushed to us. While streaming we
+	// still poll occasionally, since events raised on another job service instance
+	// never reach this vehicle's stream.
+	v.ticks++
+	if !v.jobStreamConnected() || v.ticks%streamBackstopPollTicks == 0 {
+		// Drop the current job if the customer cancelled it
+		v.checkForCancellation()
 
 		// Check for new job assignments
 		v.checkForJobs()
+	}
 
-		switch v.Status {
-		case "available":
-			v.simulateIdleBehavior()
-		case "busy":
-			v.simulateJobExecution()
-		case "charging":
-			v.simulateCharging()
-		case "maintenance":
-			v.simulateMaintenance()
-		}
+	switch v.Status {
+	case "available":
+		v.simulateIdleBehavior()
+	case "busy":
+		v.simulateJobExecution()
+	case "charging":
+		v.simulateCharging()
+	case "maintenance":
+		v.simulateMaintenance()
+	}
 
-		// Update location and status with fleet service
-		v.reportToFleet()
+	// Update location and status with fleet service
+	v.reportToFleet()
 
-		// Check if battery is low and not busy
-		if v.BatteryLevel <= 30 && v.Status == "available" {
-			slog.Warn("Vehicle battery low, initiating charging",
-				"vehicle_id", v.ID,
-				"battery_level", v.BatteryLevel,
-				"threshold", 30)
-			v.goToCharge()
-		}
+	// Check if battery is low and not busy
+	if v.BatteryLevel <= 30 && v.Status == "available" {
+		slog.Warn("Vehicle battery low, initiating charging",
+			"vehicle_id", v.ID,
+			"battery_level", v.BatteryLevel,
+			"threshold", 30)
+		v.goToCharge()
 	}
 }
 
@@ -153,21 +211,92 @@ func (v *Vehicle) checkForJobs() {
 	}
 }
 
+// checkForCancellation polls the job service for the state of the vehicle's job
+// and aborts it if it was cancelled
+func (v *Vehicle) checkForCancellation() {
+	if v.CurrentJobID == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	current, err := v.jobClient.GetJob(ctx, *v.CurrentJobID)
+	if err != nil {
+		slog.Error("Failed to check job status", "vehicle_id", v.ID, "job_id", *v.CurrentJobID, "error", err)
+		return
+	}
+
+	if current.Status == "cancelled" {
+		v.abortCurrentJob(current.CancellationReason)
+	}
+}
+
+// abortCurrentJob stops working on a job that was cancelled or taken away and returns
+// the vehicle to the pool. The job service has already released the vehicle in the
+// fleet service.
+func (v *Vehicle) abortCurrentJob(reason string) {
+	jobID := ""
+	if v.CurrentJobID != nil {
+		jobID = *v.CurrentJobID
+	}
+
+	slog.Info("Vehicle aborting job",
+		"vehicle_id", v.ID,
+		"job_id", jobID,
+		"reason", reason,
+		"job_phase", v.jobPhase)
+
+	if v.pooling() {
+		v.leavePool(jobID, reason)
+		return
+	}
+
+	delete(v.tripMeters, jobID)
+
+	// A job that was never started only needs its pending assignment cleared
+	if v.currentJob != nil {
+		v.currentRoute = nil
+		v.routeIndex = 0
+		v.isMoving = false
+		v.Status = "available"
+		v.jobPhase = "idle"
+		v.stopIndex = 0
+	}
+
+	v.currentJob = nil
+	v.CurrentJobID = nil
+}
+
 // startJob begins executing a job
 func (v *Vehicle) startJob(job *job.Job) {
+	if job.FollowsStopPlan() {
+		v.startPool(job)
+		return
+	}
+
 	v.currentJob = job
+	v.CurrentJobID = &job.ID
 	v.Status = "busy"
-	v.jobPhase = "pickup"
-	v.setRouteTarget(job.PickupLat, job.PickupLng)
+	v.headToStop(0)
 
 	slog.Info("Vehicle started job",
 		"vehicle_id", v.ID,
 		"job_type", job.JobType,
 		"job_id", job.ID,
+		"stops", len(job.Itinerary()),
 		"pickup_lat", job.PickupLat,
 		"pickup_lng", job.PickupLng)
 }
 
+// headToStop routes the vehicle to one of the current job's stops
+func (v *Vehicle) headToStop(index int) {
+	stop := v.currentJob.Itinerary()[index]
+	v.stopIndex = index
+	v.jobPhase = stop.Type
+	v.setRouteTarget(stop.Lat, stop.Lng)
+}
+
 // simulateIdleBehavior makes the vehicle move randomly when idle
 func (v *Vehicle) simulateIdleBehavior() {
 	if !v.isMoving {
@@ -181,7 +310,7 @@ func (v *Vehicle) simulateIdleBehavior() {
 	}
 }
 
-// simulateJobExecution moves vehicle through job phases
+// simulateJobExecution drives the vehicle through the current job's stops
 func (v *Vehicle) simulateJobExecution() {
 	if v.currentJob == nil {
 		v.Status = "available"
@@ -197,8 +326,7 @@ func (v *Vehicle) simulateJobExecution() {
 			"job_id", v.currentJob.ID)
 
 		// Abandon current job
-		v.currentJob = nil
-		v.CurrentJobID = nil
+		v.abandonJob("battery critically low")
 		v.jobPhase = "idle"
 
 		// Go to charge immediately
@@ -211,23 +339,94 @@ func (v *Vehicle) simulateJobExecution() {
 
 		// Check if reached current target
 		if v.distanceToTarget() < 0.001 { // ~100m
-			switch v.jobPhase {
-			case "pickup":
-				// Reached pickup location, now go to destination
-				v.jobPhase = "delivery"
-				v.setRouteTarget(v.currentJob.DestinationLat, v.currentJob.DestinationLng)
-				slog.Info("Vehicle reached pickup, going to destination",
-					"vehicle_id", v.ID,
-					"destination_lat", v.currentJob.DestinationLat,
-					"destination_lng", v.currentJob.DestinationLng)
-			case "delivery":
-				// Reached destination, complete job
-				v.completeCurrentJob()
-			}
+			v.arriveAtStop()
 		}
 	}
 }
 
+// arriveAtStop reports reaching the current stop and heads on to the next one. The
+// first stop confirms the pickup and the last completes the job.
+func (v *Vehicle) arriveAtStop() {
+	if v.pooling() {
+		v.arriveAtPlannedStop()
+		return
+	}
+
+	stops := v.currentJob.Itinerary()
+
+	switch v.stopIndex {
+	case len(stops) - 1:
+		// Reached destination, complete job
+		v.completeCurrentJob()
+		return
+	case 0:
+		// Reached pickup location, let the job service know before heading on
+		v.confirmPickup()
+	default:
+		v.reportStopArrival()
+	}
+
+	v.headToStop(v.stopIndex + 1)
+	next := stops[v.stopIndex]
+	slog.Info("Vehicle reached stop, heading to next",
+		"vehicle_id", v.ID,
+		"job_id", v.currentJob.ID,
+		"stop", v.stopIndex,
+		"stop_type", next.Type,
+		"stop_lat", next.Lat,
+		"stop_lng", next.Lng)
+}
+
+// reportStopArrival tells the job service the vehicle reached an intermediate stop
+func (v *Vehicle) reportStopArrival() {
+	v.ensurePickupConfirmed(v.currentJob.ID)
+	ctx, done := v.startJobCall("ArriveAtStop", v.currentJob.ID)
+	err := v.jobClient.ArriveAtStop(ctx, v.currentJob.ID, v.stopIndex)
+	done(err)
+	if err != nil {
+		slog.Error("Failed to report stop arrival",
+			"vehicle_id", v.ID,
+			"job_id", v.currentJob.ID,
+			"stop", v.stopIndex,
+			"error", err)
+	}
+}
+
+// confirmPickup reports that the vehicle has collected the current job's passenger or
+// order. A confirmation that fails is sent again before the job's next stop or its
+// completion, which the job service refuses for a job it thinks is still waiting.
+func (v *Vehicle) confirmPickup() {
+	v.startTrip(v.currentJob.ID)
+	v.sendPickup(v.currentJob.ID)
+}
+
+// sendPickup confirms a job's pickup with the job service, remembering the job until the
+// job service has it
+func (v *Vehicle) sendPickup(jobID string) {
+	ctx, done := v.startJobCall("ConfirmPickup", jobID)
+	err := v.jobClient.ConfirmPickup(ctx, jobID)
+	done(err)
+	if err != nil {
+		slog.Error("Failed to confirm pickup",
+			"vehicle_id", v.ID,
+			"job_id", jobID,
+			"error", err)
+		v.pickupPending[jobID] = true
+		return
+	}
+	delete(v.pickupPending, jobID)
+}
+
+// ensurePickupConfirmed sends a job's pickup confirmation again if it failed earlier
+func (v *Vehicle) ensurePickupConfirmed(jobID string) {
+	if v.pickupPending[jobID] {
+		slog.Info("Retrying pickup confirmation",
+			"vehicle_id", v.ID,
+			"job_id", jobID)
+		v.sendPickup(jobID)
+	}
+}
+
 // completeCurrentJob finishes the current job
 func (v *Vehicle) completeCurrentJob() {
 	if v.currentJob == nil {
@@ -240,19 +439,22 @@ func (v *Vehicle) completeCurrentJob() {
 		"job_id", v.currentJob.ID)
 
 	// Notify job service
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	if err := v.jobClient.CompleteJob(ctx, v.currentJob.ID); err != nil {
+	v.ensurePickupConfirmed(v.currentJob.ID)
+	ctx, done := v.startJobCall("CompleteJob", v.currentJob.ID)
+	err := v.jobClient.CompleteJob(ctx, v.currentJob.ID, v.endTrip(v.currentJob.ID))
+	done(err)
+	if err != nil {
 		fmt.Printf("Failed to complete job %s: %v\n", v.currentJob.ID, err)
 	}
 
 	// Reset vehicle state
+	delete(v.pickupPending, v.currentJob.ID)
 	v.currentJob = nil
 	v.CurrentJobID = nil
 	v.Status = "available"
 	v.isMoving = false
 	v.jobPhase = "idle"
+	v.stopIndex = 0
 }
 
 // simulateMaintenance handles vehicle in maintenance state
@@ -321,8 +523,8 @@ func (v *Vehicle) setRouteTarget(targetLat, targetLng float64) {
 	v.targetLat = targetLat
 	v.targetLng = targetLng
 
-	// Get route from routing service
-	route, err := v.routingService.GetRoute(v.LocationLat, v.LocationLng, targetLat, targetLng)
+	// Get route from the configured router
+	route, err := v.router.GetRoute(v.LocationLat, v.LocationLng, targetLat, targetLng)
 	if err != nil {
 		fmt.Printf("Failed to get route for vehicle %s: %v\n", v.ID, err)
 		// Fallback to direct movement
@@ -392,6 +594,7 @@ func (v *Vehicle) moveAlongRoute() {
 	// Drain battery based on actual distance moved using haversine
 	kmTraveled := haversineDistance(prevLat, prevLng, v.LocationLat, v.LocationLng)
 	v.drainBattery(kmTraveled)
+	v.meterTrips(kmTraveled)
 }
 
 // moveTowardsTarget moves the vehicle towards its target location
@@ -429,6 +632,7 @@ func (v *Vehicle) moveTowardsTarget() {
 	// Drain battery based on actual distance moved using haversine
 	kmTraveled := haversineDistance(prevLat, prevLng, v.LocationLat, v.LocationLng)
 	v.drainBattery(kmTraveled)
+	v.meterTrips(kmTraveled)
 }
 
 // setRandomTarget sets a random target within the specified radius
@@ -508,17 +712,50 @@ func (v *Vehicle) handleBatteryDepletion() {
 	v.jobPhase = "stranded"
 
 	// If had a job, abandon it
-	if v.currentJob != nil {
+	if v.CurrentJobID != nil {
 		slog.Warn("Abandoning job due to battery depletion",
 			"vehicle_id", v.ID,
-			"job_id", v.currentJob.ID)
-		v.currentJob = nil
-		v.CurrentJobID = nil
+			"job_id", *v.CurrentJobID)
+		v.abandonJob("battery depleted")
+	}
+}
+
+// abandonJob drops the vehicle's job and tells the job service so it can be
+// handed to another vehicle instead of waiting on this one forever
+func (v *Vehicle) abandonJob(reason string) {
+	if v.CurrentJobID == nil {
+		return
+	}
+	jobID := *v.CurrentJobID
+
+	if v.pooling() {
+		v.abandonPool(reason)
+		return
+	}
+
+	ctx, done := v.startJobCall("AbandonJob", jobID)
+	err := v.jobClient.AbandonJob(ctx, jobID, v.ID, reason)
+	done(err)
+	if err != nil {
+		// The job service watchdog will notice we are charging or stranded and requeue it
+		slog.Error("Failed to report abandoned job",
+			"vehicle_id", v.ID,
+			"job_id", jobID,
+			"error", err)
 	}
+
+	delete(v.tripMeters, jobID)
+	v.currentJob = nil
+	v.CurrentJobID = nil
 }
 
 // goToCharge sets vehicle to charging status and moves to charging station
 func (v *Vehicle) goToCharge() {
+	// Hand back any job we were assigned but had not started yet
+	if v.CurrentJobID != nil {
+		v.abandonJob("going to charge")
+	}
+
 	// Find nearest charging station
 	chargingStation := FindNearestChargingStation(v.LocationLat, v.LocationLng, v.Region)
 
@@ -628,12 +865,39 @@ func (v *Vehicle) registerWithFleet() error {
 		return fmt.Errorf("failed to register vehicle, status: %d", resp.StatusCode)
 	}
 
+	v.recordFleetVersion(resp.Header.Get("ETag"))
 	slog.Info("Vehicle registered with fleet service", "vehicle_id", v.ID)
 	return nil
 }
 
-// reportToFleet sends location update to fleet service
+// reportToFleet sends location update to fleet service. A report rejected because the
+// fleet record changed since the last one is resent once the record has been re-read.
 func (v *Vehicle) reportToFleet() {
+	if v.putLocation() == http.StatusPreconditionFailed {
+		slog.Warn("Fleet record changed since last report, resyncing",
+			"vehicle_id", v.ID,
+			"known_version", v.fleetVersion)
+		if v.syncWithFleet() && v.putLocation() == http.StatusPreconditionFailed {
+			slog.Warn("Fleet record changed again, dropping location report",
+				"vehicle_id", v.ID,
+				"known_version", v.fleetVersion)
+		}
+	}
+
+	// NEW: Also stream to Kinesis (supplemental analytics)
+	v.streamVehicleData()
+}
+
+// putLocation sends the vehicle's location to the fleet service, returning the response
+// status, or zero when the request failed
+func (v *Vehicle) putLocation() int {
+	status := v.Status
+	if v.currentJob == nil && v.CurrentJobID != nil {
+		// Fleet service has assigned us a job we haven't picked up yet,
+		// so only report position and leave its status alone
+		status = ""
+	}
+
 	locationUpdate := struct {
 		Lat    float64 `json:"lat"`
 		Lng    float64 `json:"lng"`
@@ -641,7 +905,7 @@ func (v *Vehicle) reportToFleet() {
 	}{
 		Lat:    v.LocationLat,
 		Lng:    v.LocationLng,
-		Status: v.Status,
+		Status: status,
 	}
 
 	jsonData, _ := json.Marshal(locationUpdate)
@@ -649,6 +913,10 @@ func (v *Vehicle) reportToFleet() {
 
 	req, _ := http.NewRequest("PUT", url, bytes.NewBuffer(jsonData))
 	req.Header.Set("Content-Type", "application/json")
+	if v.fleetVersion > 0 {
+		// Reject the update if someone else (e.g. an assignment) wrote the record since our last report
+		req.Header.Set("If-Match", fmt.Sprintf("\"%d\"", v.fleetVersion))
+	}
 
 	client := &http.Client{Timeout: 5 * time.Second}
 	resp, err := client.Do(req)
@@ -657,24 +925,90 @@ func (v *Vehicle) reportToFleet() {
 			"vehicle_id", v.ID,
 			"fleet_url", url,
 			"error", err)
-		return
+		return 0
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
+	if resp.StatusCode == http.StatusOK {
+		v.recordFleetVersion(resp.Header.Get("ETag"))
+		slog.Debug("Successfully reported location to fleet service",
+			"vehicle_id", v.ID,
+			"lat", v.LocationLat,
+			"lng", v.LocationLng)
+	} else if resp.StatusCode != http.StatusPreconditionFailed {
 		slog.Warn("Fleet service location update returned non-OK status",
 			"vehicle_id", v.ID,
 			"status_code", resp.StatusCode,
 			"url", url)
-	} else {
-		slog.Debug("Successfully reported location to fleet service",
+	}
+	return resp.StatusCode
+}
+
+// syncWithFleet refreshes the vehicle's view of its fleet record, e.g. after a rejected
+// update, returning whether it could be read
+func (v *Vehicle) syncWithFleet() bool {
+	url := fmt.Sprintf("%s/vehicles/%s", v.fleetServiceURL, v.ID)
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		slog.Error("Failed to fetch vehicle record from fleet service",
 			"vehicle_id", v.ID,
-			"lat", v.LocationLat,
-			"lng", v.LocationLng)
+			"error", err)
+		return false
 	}
+	defer resp.Body.Close()
 
-	// NEW: Also stream to Kinesis (supplemental analytics)
-	v.streamVehicleData()
+	if resp.StatusCode != http.StatusOK {
+		slog.Warn("Fleet service vehicle lookup returned non-OK status",
+			"vehicle_id", v.ID,
+			"status_code", resp.StatusCode)
+		return false
+	}
+
+	var remote struct {
+		Status       string        `json:"status"`
+		CurrentJobID *string       `json:"current_job_id,omitempty"`
+		StopPlan     []PlannedStop `json:"stop_plan,omitempty"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&remote); err != nil {
+		slog.Error("Failed to decode vehicle record", "vehicle_id", v.ID, "error", err)
+		return false
+	}
+
+	v.recordFleetVersion(resp.Header.Get("ETag"))
+
+	// Jobs may have joined or left the vehicle's stop plan since we last heard. A fleet
+	// service that doesn't plan stops leaves us to serve the ride on our own.
+	if v.pooling() && (len(remote.StopPlan) > 0 || remote.CurrentJobID == nil) {
+		v.adoptStopPlan(remote.StopPlan)
+		return true
+	}
+
+	// An assignment raced our report; hold the job until checkForJobs picks it up
+	if v.currentJob == nil && remote.CurrentJobID != nil {
+		slog.Info("Fleet service assigned a job, awaiting job details",
+			"vehicle_id", v.ID,
+			"job_id", *remote.CurrentJobID,
+			"fleet_status", remote.Status)
+		v.CurrentJobID = remote.CurrentJobID
+	}
+	return true
+}
+
+// recordFleetVersion remembers the vehicle record version from a fleet service ETag
+func (v *Vehicle) recordFleetVersion(etag string) {
+	if etag == "" {
+		return
+	}
+
+	version, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(etag, "W/"), "\""), 10, 64)
+	if err != nil {
+		slog.Warn("Ignoring unparseable fleet ETag", "vehicle_id", v.ID, "etag", etag)
+		return
+	}
+
+	v.fleetVersion = version
 }
 
 // getMovementSpeed returns the movement speed based on environment configuration
@@ -755,6 +1089,18 @@ func (v *Vehicle) initKinesis() {
 	slog.Info("Kinesis streaming enabled", "vehicle_id", v.ID, "stream", streamName)
 }
 
+// startJobCall starts a span for a call to the job service about jobID, which it
+// bounds with the usual timeout. The returned function ends both, recording err.
+func (v *Vehicle) startJobCall(operation, jobID string) (context.Context, func(err error)) {
+	ctx, span := tracer.Start(context.Background(), "Vehicle."+operation,
+		trace.WithAttributes(tracing.JobID(jobID), tracing.VehicleID(v.ID)))
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	return ctx, func(err error) {
+		cancel()
+		tracing.End(span, err)
+	}
+}
+
 // streamVehicleData sends vehicle telemetry to Kinesis (supplemental to HTTP API)
 func (v *Vehicle) streamVehicleData() {
 	if v.kinesisClient == nil {
@@ -780,13 +1126,21 @@ func (v *Vehicle) streamVehicleData() {
 		return
 	}
 
-	_, err = v.kinesisClient.PutRecord(context.TODO(), &kinesis.PutRecordInput{
+	ctx, span := tracer.Start(context.Background(), "Kinesis.PutRecord",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(tracing.VehicleID(v.ID), attribute.String("messaging.destination.name", v.streamName)))
+	if v.CurrentJobID != nil {
+		span.SetAttributes(tracing.JobID(*v.CurrentJobID))
+	}
+	_, err = v.kinesisClient.PutRecord(ctx, &kinesis.PutRecordInput{
 		StreamName:   &v.streamName,
 		Data:         data,
 		PartitionKey: &v.ID,
 	})
+	tracing.End(span, err)
 
 	if err != nil {
+		metrics.KinesisPutFailures.Inc()
 		slog.Error("Failed to send data to Kinesis", "vehicle_id", v.ID, "error", err)
 	}
 }
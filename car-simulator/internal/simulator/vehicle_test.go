@@ -1,8 +1,14 @@
 package simulator
 
 import (
+	"encoding/json"
+	"fmt"
 	"math"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+
+	"car-simulator/internal/job"
 )
 
 func TestNewVehicle(t *testing.T) {
@@ -219,3 +225,464 @@ func TestBatteryDrainPrecision(t *testing.T) {
 		t.Errorf("Battery level rounded to integer: %f", vehicle.BatteryLevel)
 	}
 }
+
+func TestVehicle_ReportToFleet_ResyncsOnVersionConflict(t *testing.T) {
+	var ifMatches []string
+	var lastStatus string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "PUT":
+			var update struct {
+				Status string `json:"status"`
+			}
+			json.NewDecoder(r.Body).Decode(&update)
+			ifMatches = append(ifMatches, r.Header.Get("If-Match"))
+			lastStatus = update.Status
+
+			if r.Header.Get("If-Match") != `"7"` {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				return
+			}
+			w.Header().Set("ETag", `"8"`)
+			w.WriteHeader(http.StatusOK)
+		case "GET":
+			// An assignment happened since the vehicle last reported
+			w.Header().Set("ETag", `"7"`)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"id":             "test-vehicle-1",
+				"status":         "busy",
+				"current_job_id": "job-42",
+			})
+		}
+	}))
+	defer server.Close()
+
+	vehicle := NewVehicle("test-vehicle-1", "us-west-2", server.URL, "http://localhost:8081", 45.5, -122.6)
+	vehicle.fleetVersion = 5
+
+	vehicle.reportToFleet()
+
+	// Rejected at version 5, then resent at the version read back
+	if len(ifMatches) != 2 || ifMatches[0] != `"5"` || ifMatches[1] != `"7"` {
+		t.Errorf("Expected the report sent at \"5\" then \"7\", got %v", ifMatches)
+	}
+	if vehicle.CurrentJobID == nil || *vehicle.CurrentJobID != "job-42" {
+		t.Fatalf("Expected pending job 'job-42', got %v", vehicle.CurrentJobID)
+	}
+	// The resent report must not overwrite the assignment with "available"
+	if lastStatus != "" {
+		t.Errorf("Expected location-only update, got status '%s'", lastStatus)
+	}
+	if vehicle.fleetVersion != 8 {
+		t.Errorf("Expected version 8, got %d", vehicle.fleetVersion)
+	}
+}
+
+func TestVehicle_ReportToFleet_RetriesOnlyOnce(t *testing.T) {
+	puts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PUT" {
+			// Something else writes the record every time
+			puts++
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		w.Header().Set("ETag", fmt.Sprintf(`"%d"`, 5+puts))
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": "test-vehicle-1", "status": "available"})
+	}))
+	defer server.Close()
+
+	vehicle := NewVehicle("test-vehicle-1", "us-west-2", server.URL, "http://localhost:8081", 45.5, -122.6)
+	vehicle.fleetVersion = 5
+
+	vehicle.reportToFleet()
+
+	if puts != 2 {
+		t.Errorf("Expected the report sent twice, got %d", puts)
+	}
+}
+
+func TestVehicle_CompleteJob_RetriesFailedPickup(t *testing.T) {
+	var paths []string
+	pickupAttempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if r.URL.Path == "/jobs/job-42/pickup" {
+			pickupAttempts++
+			if pickupAttempts == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	vehicle := NewVehicle("test-vehicle-1", "us-west-2", "http://localhost:8080", server.URL, 45.5, -122.6)
+	vehicle.currentJob = &job.Job{ID: "job-42", Status: "assigned"}
+
+	vehicle.confirmPickup()
+	vehicle.completeCurrentJob()
+
+	expected := []string{"/jobs/job-42/pickup", "/jobs/job-42/pickup", "/jobs/job-42/complete"}
+	if len(paths) != len(expected) {
+		t.Fatalf("Expected calls %v, got %v", expected, paths)
+	}
+	for i := range expected {
+		if paths[i] != expected[i] {
+			t.Errorf("Expected call %d to be '%s', got '%s'", i, expected[i], paths[i])
+		}
+	}
+}
+
+func TestVehicle_CheckForCancellation_AbortsRoute(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/jobs/job-42" {
+			t.Errorf("Expected path '/jobs/job-42', got %s", r.URL.Path)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":                  "job-42",
+			"status":              "cancelled",
+			"cancellation_reason": "customer_cancelled",
+		})
+	}))
+	defer server.Close()
+
+	vehicle := NewVehicle("test-vehicle-1", "us-west-2", "http://localhost:8080", server.URL, 45.5, -122.6)
+	vehicle.startJob(&job.Job{
+		ID:             "job-42",
+		Status:         "assigned",
+		PickupLat:      45.51,
+		PickupLng:      -122.61,
+		DestinationLat: 45.52,
+		DestinationLng: -122.62,
+	})
+
+	vehicle.checkForCancellation()
+
+	if vehicle.currentJob != nil || vehicle.CurrentJobID != nil {
+		t.Errorf("Expected job to be dropped, got %v", vehicle.CurrentJobID)
+	}
+	if vehicle.Status != "available" {
+		t.Errorf("Expected status 'available', got '%s'", vehicle.Status)
+	}
+	if vehicle.isMoving || vehicle.currentRoute != nil {
+		t.Error("Expected vehicle to stop following its route")
+	}
+	if vehicle.jobPhase != "idle" {
+		t.Errorf("Expected job phase 'idle', got '%s'", vehicle.jobPhase)
+	}
+}
+
+func TestVehicle_HandleBatteryDepletion_ReportsAbandonment(t *testing.T) {
+	var abandonedPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		abandonedPath = r.URL.Path
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	vehicle := NewVehicle("test-vehicle-1", "us-west-2", "http://localhost:8080", server.URL, 45.5, -122.6)
+	jobID := "job-42"
+	vehicle.currentJob = &job.Job{ID: jobID}
+	vehicle.CurrentJobID = &jobID
+	vehicle.Status = "busy"
+	vehicle.BatteryLevel = 0
+
+	vehicle.handleBatteryDepletion()
+
+	if abandonedPath != "/jobs/job-42/abandon" {
+		t.Errorf("Expected abandonment to be reported, got path '%s'", abandonedPath)
+	}
+	if vehicle.currentJob != nil || vehicle.CurrentJobID != nil {
+		t.Error("Expected job to be dropped")
+	}
+	if vehicle.Status != "maintenance" {
+		t.Errorf("Expected status 'maintenance', got '%s'", vehicle.Status)
+	}
+}
+
+func TestVehicle_HandleJobEvent(t *testing.T) {
+	vehicle := NewVehicle("test-vehicle-1", "us-west-2", "http://localhost:8080", "http://localhost:8081", 45.5, -122.6)
+
+	// Assignments for the vehicle start the job straight away
+	vehicle.handleJobEvent(&job.JobEvent{
+		Type: job.EventAssigned,
+		Job: &job.Job{
+			ID:        "job-42",
+			Status:    "assigned",
+			PickupLat: 45.51,
+			PickupLng: -122.61,
+		},
+	})
+	if vehicle.CurrentJobID == nil || *vehicle.CurrentJobID != "job-42" {
+		t.Fatalf("Expected vehicle to start job-42, got %v", vehicle.CurrentJobID)
+	}
+	if vehicle.Status != "busy" {
+		t.Errorf("Expected status 'busy', got '%s'", vehicle.Status)
+	}
+
+	// Events about other jobs are ignored
+	vehicle.handleJobEvent(&job.JobEvent{Type: job.EventCancelled, Job: &job.Job{ID: "job-7", Status: "cancelled"}})
+	if vehicle.CurrentJobID == nil {
+		t.Fatal("Expected cancellation of another job to be ignored")
+	}
+
+	vehicle.handleJobEvent(&job.JobEvent{
+		Type: job.EventCancelled,
+		Job:  &job.Job{ID: "job-42", Status: "cancelled", CancellationReason: "customer_cancelled"},
+	})
+	if vehicle.CurrentJobID != nil || vehicle.currentJob != nil {
+		t.Errorf("Expected job to be dropped, got %v", vehicle.CurrentJobID)
+	}
+	if vehicle.Status != "available" {
+		t.Errorf("Expected status 'available', got '%s'", vehicle.Status)
+	}
+}
+
+func TestVehicle_HandleJobEvent_RequeuedJobIsDropped(t *testing.T) {
+	vehicle := NewVehicle("test-vehicle-1", "us-west-2", "http://localhost:8080", "http://localhost:8081", 45.5, -122.6)
+	vehicle.startJob(&job.Job{ID: "job-42", Status: "assigned", PickupLat: 45.51, PickupLng: -122.61})
+
+	vehicle.handleJobEvent(&job.JobEvent{
+		Type:   job.EventUpdated,
+		Change: "requeued",
+		Job:    &job.Job{ID: "job-42", Status: "pending"},
+	})
+	if vehicle.CurrentJobID != nil {
+		t.Errorf("Expected requeued job to be dropped, got %v", vehicle.CurrentJobID)
+	}
+}
+
+func TestVehicle_HandleJobEvent_ResyncPolls(t *testing.T) {
+	var polled bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/vehicles/test-vehicle-1/jobs" {
+			polled = true
+		}
+		json.NewEncoder(w).Encode([]map[string]interface{}{
+			{"id": "job-42", "status": "assigned", "pickup_lat": 45.51, "pickup_lng": -122.61},
+		})
+	}))
+	defer server.Close()
+
+	vehicle := NewVehicle("test-vehicle-1", "us-west-2", "http://localhost:8080", server.URL, 45.5, -122.6)
+	vehicle.handleJobEvent(&job.JobEvent{Type: job.EventResync})
+
+	if !polled {
+		t.Error("Expected a resync to poll for assigned jobs")
+	}
+	if vehicle.CurrentJobID == nil || *vehicle.CurrentJobID != "job-42" {
+		t.Errorf("Expected vehicle to pick up job-42, got %v", vehicle.CurrentJobID)
+	}
+}
+
+func TestVehicle_ArriveAtStop_WorksThroughItinerary(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	vehicle := NewVehicle("test-vehicle-1", "us-west-2", "http://localhost:8080", server.URL, 45.5, -122.6)
+	vehicle.startJob(&job.Job{
+		ID:     "job-42",
+		Status: "assigned",
+		Stops: []job.Stop{
+			{Lat: 45.51, Lng: -122.61, Type: "pickup"},
+			{Lat: 45.52, Lng: -122.62, Type: "dropoff"},
+			{Lat: 45.53, Lng: -122.63, Type: "dropoff"},
+		},
+	})
+
+	if vehicle.jobPhase != "pickup" {
+		t.Errorf("Expected job phase 'pickup', got '%s'", vehicle.jobPhase)
+	}
+
+	vehicle.arriveAtStop()
+	vehicle.arriveAtStop()
+	if vehicle.stopIndex != 2 {
+		t.Errorf("Expected to be heading to stop 2, got %d", vehicle.stopIndex)
+	}
+	vehicle.arriveAtStop()
+
+	expected := []string{"/jobs/job-42/pickup", "/jobs/job-42/stops/1/arrive", "/jobs/job-42/complete"}
+	if len(paths) != len(expected) {
+		t.Fatalf("Expected calls %v, got %v", expected, paths)
+	}
+	for i := range expected {
+		if paths[i] != expected[i] {
+			t.Errorf("Expected call %d to be '%s', got '%s'", i, expected[i], paths[i])
+		}
+	}
+	if vehicle.currentJob != nil || vehicle.Status != "available" {
+		t.Error("Expected the job to be completed")
+	}
+}
+
+func TestVehicle_CompleteJob_ReportsMeteredTrip(t *testing.T) {
+	var trip job.TripReport
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/jobs/job-42/complete" {
+			json.NewDecoder(r.Body).Decode(&trip)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	vehicle := NewVehicle("test-vehicle-1", "us-west-2", "http://localhost:8080", server.URL, 45.5, -122.6)
+	vehicle.startJob(&job.Job{ID: "job-42", Status: "assigned", PickupLat: 45.51, PickupLng: -122.61, DestinationLat: 45.52, DestinationLng: -122.62})
+
+	// Driving to the pickup isn't part of the trip
+	vehicle.meterTrips(1.5)
+	vehicle.arriveAtStop()
+	vehicle.meterTrips(2.0)
+	vehicle.meterTrips(1.2)
+	vehicle.arriveAtStop()
+
+	if math.Abs(trip.DistanceKm-3.2) > 1e-9 {
+		t.Errorf("Expected a 3.2km trip reported, got %.2fkm", trip.DistanceKm)
+	}
+	if len(vehicle.tripMeters) != 0 {
+		t.Errorf("Expected the trip meter cleared, got %d", len(vehicle.tripMeters))
+	}
+}
+
+func TestVehicle_SharedRide_FollowsFleetStopPlan(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/vehicles/test-vehicle-1":
+			// A second rider joined on the way
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"id":             "test-vehicle-1",
+				"status":         "busy",
+				"current_job_id": "job-a",
+				"stop_plan": []map[string]interface{}{
+					{"job_id": "job-a", "type": "pickup", "lat": 45.50, "lng": -122.60},
+					{"job_id": "job-b", "type": "pickup", "lat": 45.51, "lng": -122.60},
+					{"job_id": "job-b", "type": "dropoff", "lat": 45.53, "lng": -122.60},
+					{"job_id": "job-a", "type": "dropoff", "lat": 45.54, "lng": -122.60},
+				},
+			})
+		case "/jobs/job-b":
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": "job-b", "status": "assigned", "shared": true})
+		default:
+			paths = append(paths, r.URL.Path)
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+
+	vehicle := NewVehicle("test-vehicle-1", "us-west-2", server.URL, server.URL, 45.5, -122.6)
+	vehicle.SetRouter(StraightLineRouter{})
+	vehicle.startJob(&job.Job{
+		ID:             "job-a",
+		Status:         "assigned",
+		Shared:         true,
+		PickupLat:      45.50,
+		PickupLng:      -122.60,
+		DestinationLat: 45.54,
+		DestinationLng: -122.60,
+	})
+
+	if len(vehicle.stopPlan) != 4 {
+		t.Fatalf("Expected the fleet service's 4 stops, got %+v", vehicle.stopPlan)
+	}
+
+	for i := 0; i < 3; i++ {
+		vehicle.arriveAtStop()
+	}
+	if vehicle.CurrentJobID == nil || *vehicle.CurrentJobID != "job-a" || vehicle.jobPhase != "dropoff" {
+		t.Errorf("Expected to be heading to drop off job-a, got %v %s", vehicle.CurrentJobID, vehicle.jobPhase)
+	}
+	vehicle.arriveAtStop()
+
+	expected := []string{"/jobs/job-a/pickup", "/jobs/job-b/pickup", "/jobs/job-b/complete", "/jobs/job-a/complete"}
+	if len(paths) != len(expected) {
+		t.Fatalf("Expected calls %v, got %v", expected, paths)
+	}
+	for i := range expected {
+		if paths[i] != expected[i] {
+			t.Errorf("Expected call %d to be '%s', got '%s'", i, expected[i], paths[i])
+		}
+	}
+	if vehicle.currentJob != nil || vehicle.Status != "available" {
+		t.Error("Expected the vehicle to be free once both riders were dropped off")
+	}
+}
+
+func TestVehicle_SharedRide_CancelledRiderLeavesPool(t *testing.T) {
+	vehicle := NewVehicle("test-vehicle-1", "us-west-2", "http://localhost:8080", "http://localhost:8081", 45.5, -122.6)
+	vehicle.SetRouter(StraightLineRouter{})
+	vehicle.startJob(&job.Job{ID: "job-a", Status: "assigned", Shared: true, PickupLat: 45.50, PickupLng: -122.60, DestinationLat: 45.54, DestinationLng: -122.60})
+	vehicle.poolJobs["job-b"] = &job.Job{ID: "job-b", Shared: true}
+	vehicle.adoptStopPlan([]PlannedStop{
+		{JobID: "job-b", Type: "pickup", Lat: 45.51, Lng: -122.60},
+		{JobID: "job-a", Type: "pickup", Lat: 45.50, Lng: -122.60},
+		{JobID: "job-b", Type: "dropoff", Lat: 45.53, Lng: -122.60},
+		{JobID: "job-a", Type: "dropoff", Lat: 45.54, Lng: -122.60},
+	})
+	if *vehicle.CurrentJobID != "job-b" {
+		t.Fatalf("Expected to head for job-b's pickup first, got %s", *vehicle.CurrentJobID)
+	}
+
+	vehicle.handleJobEvent(&job.JobEvent{
+		Type: job.EventCancelled,
+		Job:  &job.Job{ID: "job-b", Status: "cancelled", CancellationReason: "customer_cancelled"},
+	})
+
+	if vehicle.CurrentJobID == nil || *vehicle.CurrentJobID != "job-a" || vehicle.Status != "busy" {
+		t.Errorf("Expected to carry on with job-a, got %v %s", vehicle.CurrentJobID, vehicle.Status)
+	}
+	if len(vehicle.stopPlan) != 2 {
+		t.Errorf("Expected only job-a's stops left, got %+v", vehicle.stopPlan)
+	}
+}
+
+func TestVehicle_DeliveryBatch_FollowsFleetStopPlan(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/vehicles/test-vehicle-1":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"id":             "test-vehicle-1",
+				"status":         "busy",
+				"current_job_id": "order-1",
+				"stop_plan": []map[string]interface{}{
+					{"job_id": "order-1", "type": "pickup", "lat": 45.52, "lng": -122.68},
+					{"job_id": "order-2", "type": "pickup", "lat": 45.52, "lng": -122.68},
+					{"job_id": "order-2", "type": "dropoff", "lat": 45.53, "lng": -122.67},
+					{"job_id": "order-1", "type": "dropoff", "lat": 45.54, "lng": -122.66},
+				},
+			})
+		default:
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": "order-2", "status": "assigned", "batch_id": "batch-1"})
+		}
+	}))
+	defer server.Close()
+
+	vehicle := NewVehicle("test-vehicle-1", "us-west-2", server.URL, server.URL, 45.5, -122.6)
+	vehicle.SetRouter(StraightLineRouter{})
+	vehicle.startJob(&job.Job{
+		ID:             "order-1",
+		JobType:        "delivery",
+		Status:         "assigned",
+		BatchID:        "batch-1",
+		PickupLat:      45.52,
+		PickupLng:      -122.68,
+		DestinationLat: 45.54,
+		DestinationLng: -122.66,
+	})
+
+	if len(vehicle.stopPlan) != 4 {
+		t.Fatalf("Expected the batch's 4 stops, got %+v", vehicle.stopPlan)
+	}
+
+	// Collecting the first order heads on to collect the second
+	vehicle.arriveAtStop()
+	if vehicle.CurrentJobID == nil || *vehicle.CurrentJobID != "order-2" || vehicle.jobPhase != "pickup" {
+		t.Errorf("Expected to be collecting order-2, got %v %s", vehicle.CurrentJobID, vehicle.jobPhase)
+	}
+}
@@ -21,13 +21,19 @@ interface Vehicle {
 interface Job {
     id: string;
     job_type: 'ride' | 'delivery';
-    status: 'pending' | 'assigned' | 'in_progress' | 'completed';
+    status: 'scheduled' | 'pending' | 'assigned' | 'in_progress' | 'completed' | 'failed' | 'cancelled';
     customer_id: string;
     pickup_lat: number;
     pickup_lng: number;
     destination_lat?: number;
     destination_lng?: number;
     assigned_vehicle_id?: string;
+    scheduled_for?: string;
+    stops?: { lat: number; lng: number; type: string; arrived_at?: string }[];
+    shared?: boolean;
+    seats?: number;
+    batch_id?: string;
+    surge_multiplier?: number;
 }
 
 // Dashboard Class
@@ -232,8 +238,9 @@ class FleetDashboard {
         });
         this.jobMarkers.clear();
 
-        // Add markers for active jobs (not completed)
-        jobs.filter(job => job.status !== 'completed').forEach(job => {
+        // Add markers for active jobs (not yet finished)
+        const activeStatuses = ['pending', 'assigned', 'in_progress'];
+        jobs.filter(job => activeStatuses.includes(job.status)).forEach(job => {
             const markers: L.Layer[] = [];
 
             // Pickup marker - different icons for ride vs delivery
@@ -303,11 +310,11 @@ class FleetDashboard {
         // Update job count
         jobCount.textContent = jobs.length.toString();
         
-        // Sort jobs by status priority: pending > assigned > in_progress > completed > failed
-        const statusOrder = { 'pending': 0, 'assigned': 1, 'in_progress': 2, 'completed': 3, 'failed': 4 };
+        // Sort jobs by status priority: pending > assigned > in_progress > scheduled > completed > failed > cancelled
+        const statusOrder = { 'pending': 0, 'assigned': 1, 'in_progress': 2, 'scheduled': 3, 'completed': 4, 'failed': 5, 'cancelled': 6 };
         const sortedJobs = jobs.sort((a, b) => {
-            const aOrder = statusOrder[a.status as keyof typeof statusOrder] ?? 5;
-            const bOrder = statusOrder[b.status as keyof typeof statusOrder] ?? 5;
+            const aOrder = statusOrder[a.status as keyof typeof statusOrder] ?? 7;
+            const bOrder = statusOrder[b.status as keyof typeof statusOrder] ?? 7;
             if (aOrder !== bOrder) return aOrder - bOrder;
             return a.id.localeCompare(b.id); // Secondary sort by ID
         });
@@ -315,7 +322,12 @@ class FleetDashboard {
         jobList.innerHTML = sortedJobs.map(job => `
             <div class="job-item ${job.status}" onclick="dashboard.focusOnJob('${job.id}')">
                 <strong>${job.job_type.toUpperCase()}</strong> - ${job.id}<br>
-                Status: ${job.status}<br>
+                Status: ${job.status}<br>${job.scheduled_for ? `
+                Pickup: ${new Date(job.scheduled_for).toLocaleString()}<br>` : ''}${job.stops && job.stops.length > 2 ? `
+                Stops: ${job.stops.filter(stop => stop.arrived_at).length}/${job.stops.length}<br>` : ''}${job.shared ? `
+                Shared: ${job.seats || 1} seat(s)<br>` : ''}${job.batch_id ? `
+                Batch: ${job.batch_id}<br>` : ''}${job.surge_multiplier && job.surge_multiplier > 1 ? `
+                Surge: ${job.surge_multiplier.toFixed(2)}x<br>` : ''}
                 Customer: ${job.customer_id}
             </div>
         `).join('');
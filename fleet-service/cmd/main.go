@@ -5,16 +5,23 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"fleet-service/internal/handlers"
 	"fleet-service/internal/kinesis"
+	"fleet-service/internal/metrics"
 	"fleet-service/internal/service"
 	"fleet-service/internal/storage"
+	"shared/routing"
+	"shared/tracing"
 
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	kinesisService "github.com/aws/aws-sdk-go-v2/service/kinesis"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 func main() {
@@ -24,6 +31,13 @@ func main() {
 	}))
 	slog.SetDefault(logger)
 
+	// Export traces to an OTLP collector or stdout when configured
+	shutdownTracing, err := tracing.Setup(context.Background(), "fleet-service", os.Getenv("OTEL_TRACES_EXPORTER"))
+	if err != nil {
+		slog.Error("Failed to set up tracing", "error", err)
+		os.Exit(1)
+	}
+
 	// Load AWS config
 	cfg, err := config.LoadDefaultConfig(context.Background())
 	if err != nil {
@@ -33,6 +47,7 @@ func main() {
 
 	// Initialize storage based on environment
 	var vehicleStorage storage.VehicleStorage
+	var kpiStorage storage.KPIStorage
 	storageType := os.Getenv("STORAGE_TYPE")
 
 	if storageType == "dynamodb" {
@@ -45,14 +60,30 @@ func main() {
 		}
 
 		vehicleStorage = storage.NewDynamoDBVehicleStorage(dynamoClient, tableName)
-		slog.Info("Using DynamoDB storage", "table", tableName)
+
+		// Every instance adds to and reports from the same KPI tables
+		kpiTable := getEnv("DYNAMODB_KPI_TABLE", "fleet-kpis")
+		kpiStateTable := getEnv("DYNAMODB_KPI_STATE_TABLE", "fleet-kpi-states")
+		kpiStorage = storage.NewDynamoDBKPIStorage(dynamoClient, kpiTable, kpiStateTable)
+		slog.Info("Using DynamoDB storage", "table", tableName, "kpi_table", kpiTable, "kpi_state_table", kpiStateTable)
 	} else {
 		vehicleStorage = storage.NewMemoryVehicleStorage()
+		kpiStorage = storage.NewMemoryKPIStorage()
 		slog.Info("Using in-memory storage")
 	}
 
-	// Initialize service
-	fleetService := service.NewFleetService(vehicleStorage)
+	// Initialize service, with a span for every storage call
+	fleetService := service.NewFleetService(storage.NewTracedVehicleStorage(vehicleStorage))
+	fleetService.SetKPIStorage(storage.NewTracedKPIStorage(kpiStorage))
+
+	// Rank vehicles by driving time over roads when an OSRM server is configured
+	if osrmURL := os.Getenv("ROUTING_OSRM_URL"); osrmURL != "" {
+		fleetService.SetRouter(routing.NewRouter(osrmURL, metrics.RoutingFallbacks.Inc))
+		slog.Info("Road routing enabled", "osrm_url", osrmURL)
+	}
+
+	// Limit how far shared riders are taken out of their way for others
+	fleetService.SetMaxPoolDetour(getEnvDuration("POOL_MAX_DETOUR", "10m"))
 
 	// Start Kinesis consumer if stream name is provided
 	if streamName := os.Getenv("KINESIS_VEHICLE_TELEMETRY_STREAM"); streamName != "" {
@@ -61,6 +92,20 @@ func main() {
 		go consumer.Start(context.Background())
 	}
 
+	// Publish status changes made by the fleet service (e.g. vehicles going offline)
+	if streamName := os.Getenv("KINESIS_VEHICLE_EVENTS_STREAM"); streamName != "" {
+		kinesisClient := kinesisService.NewFromConfig(cfg)
+		fleetService.SetStatusEventPublisher(kinesis.NewStreamer(kinesisClient, streamName))
+		slog.Info("Kinesis vehicle status events enabled", "stream", streamName)
+	}
+
+	// Mark vehicles that stop reporting as offline
+	heartbeatReaper := service.NewHeartbeatReaper(fleetService,
+		getEnvDuration("HEARTBEAT_CHECK_INTERVAL", "10s"),
+		getEnvDuration("HEARTBEAT_TTL", "30s"))
+	heartbeatReaper.Start()
+	defer heartbeatReaper.Stop()
+
 	// Initialize HTTP handlers
 	httpHandler := handlers.NewHTTPHandler(fleetService)
 
@@ -76,6 +121,14 @@ func main() {
 		httpHandler.RegisterRoutes(router)
 	}
 
+	// Continue traces started by callers, with a span per request named by route
+	router.Use(tracing.Middleware("fleet-service"))
+
+	// Expose Prometheus metrics, including the fleet's state, and time every request by route
+	prometheus.MustRegister(metrics.NewFleetCollector(fleetService))
+	router.Handle("/metrics", metrics.Handler()).Methods("GET")
+	router.Use(metrics.Middleware)
+
 	// Add CORS middleware for frontend
 	router.Use(corsMiddleware)
 
@@ -85,11 +138,53 @@ func main() {
 		port = "8080"
 	}
 
-	slog.Info("Fleet Service starting", "port", port)
-	if err := http.ListenAndServe(":"+port, router); err != nil {
-		slog.Error("Fleet Service failed to start", "error", err)
-		os.Exit(1)
+	// Setup graceful shutdown
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+
+	// Start server in a goroutine
+	go func() {
+		slog.Info("Fleet Service starting", "port", port)
+		if err := http.ListenAndServe(":"+port, router); err != nil {
+			slog.Error("Fleet Service failed to start", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	// Wait for interrupt signal, then write the KPI updates still queued and flush any
+	// spans not yet exported
+	<-c
+	slog.Info("Fleet Service shutting down")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := fleetService.FlushKPIs(ctx); err != nil {
+		slog.Warn("Failed to write queued KPI updates", "error", err)
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Warn("Failed to flush traces", "error", err)
+	}
+}
+
+// getEnv gets environment variable with default value
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvDuration gets duration from environment variable
+func getEnvDuration(key, defaultValue string) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		value = defaultValue
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		slog.Warn("Invalid duration, using default", "provided", value, "default", defaultValue, "error", err)
+		duration, _ = time.ParseDuration(defaultValue)
 	}
+	return duration
 }
 
 // corsMiddleware adds CORS headers for frontend access
@@ -97,7 +192,8 @@ func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, If-Match")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
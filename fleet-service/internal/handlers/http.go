@@ -2,9 +2,14 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"fleet-service/internal/service"
 	"fleet-service/internal/storage"
@@ -32,7 +37,14 @@ func (h *HTTPHandler) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("/vehicles/{id}/location", h.UpdateVehicleLocation).Methods("PUT")
 	router.HandleFunc("/vehicles/{id}/assign", h.AssignJob).Methods("POST")
 	router.HandleFunc("/vehicles/{id}/complete", h.CompleteJob).Methods("POST")
+	router.HandleFunc("/vehicles/{id}/release", h.ReleaseVehicle).Methods("POST")
+	router.HandleFunc("/vehicles/{id}/pickup", h.RecordPickup).Methods("POST")
+	router.HandleFunc("/vehicles/{id}/pool", h.StartPool).Methods("POST")
+	router.HandleFunc("/vehicles/pool", h.JoinPool).Methods("POST")
+	router.HandleFunc("/vehicles/{id}/batch", h.StartBatch).Methods("POST")
 	router.HandleFunc("/vehicles/find", h.FindNearestVehicle).Methods("GET")
+	router.HandleFunc("/vehicles/{id}", h.GetVehicle).Methods("GET")
+	router.HandleFunc("/metrics/fleet", h.GetFleetKPIs).Methods("GET")
 }
 
 // Health returns service health status
@@ -53,6 +65,22 @@ func (h *HTTPHandler) GetAllVehicles(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(vehicles)
 }
 
+// GetVehicle returns a single vehicle with its version as the ETag
+func (h *HTTPHandler) GetVehicle(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	vehicleID := vars["id"]
+
+	vehicle, err := h.fleetService.GetVehicle(r.Context(), vehicleID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", formatETag(vehicle.Version))
+	json.NewEncoder(w).Encode(vehicle)
+}
+
 // RegisterVehicle adds a new vehicle to the fleet
 func (h *HTTPHandler) RegisterVehicle(w http.ResponseWriter, r *http.Request) {
 	var vehicle storage.Vehicle
@@ -77,11 +105,13 @@ func (h *HTTPHandler) RegisterVehicle(w http.ResponseWriter, r *http.Request) {
 	}
 
 	slog.Info("Vehicle registration successful", "vehicle_id", vehicle.ID)
+	w.Header().Set("ETag", formatETag(vehicle.Version))
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(vehicle)
 }
 
-// UpdateVehicleLocation updates a vehicle's position
+// UpdateVehicleLocation updates a vehicle's position. With an If-Match header the
+// update only applies if the vehicle is unchanged since that version.
 func (h *HTTPHandler) UpdateVehicleLocation(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	vehicleID := vars["id"]
@@ -97,7 +127,35 @@ func (h *HTTPHandler) UpdateVehicleLocation(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	if err := h.fleetService.UpdateVehicleLocationAndStatus(r.Context(), vehicleID, locationUpdate.Lat, locationUpdate.Lng, locationUpdate.Status); err != nil {
+	expectedVersion, conditional, err := parseIfMatch(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if conditional {
+		vehicle, err := h.fleetService.UpdateVehicleLocationAndStatusIfMatch(r.Context(), vehicleID, locationUpdate.Lat, locationUpdate.Lng, locationUpdate.Status, expectedVersion)
+		if err != nil {
+			if errors.Is(err, storage.ErrVersionConflict) {
+				http.Error(w, err.Error(), http.StatusPreconditionFailed)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("ETag", formatETag(vehicle.Version))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// An empty status is a location-only update
+	if locationUpdate.Status == "" {
+		err = h.fleetService.UpdateVehicleLocation(r.Context(), vehicleID, locationUpdate.Lat, locationUpdate.Lng)
+	} else {
+		err = h.fleetService.UpdateVehicleLocationAndStatus(r.Context(), vehicleID, locationUpdate.Lat, locationUpdate.Lng, locationUpdate.Status)
+	}
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -120,6 +178,13 @@ func (h *HTTPHandler) AssignJob(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.fleetService.AssignJob(r.Context(), vehicleID, jobAssignment.JobID); err != nil {
+		if errors.Is(err, storage.ErrVehicleNotAvailable) {
+			slog.Info("Vehicle assignment conflict",
+				"vehicle_id", vehicleID,
+				"job_id", jobAssignment.JobID)
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -127,19 +192,180 @@ func (h *HTTPHandler) AssignJob(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-// CompleteJob marks a job as completed
+// CompleteJob marks a job as completed. With a job ID in the body only that job is
+// dropped, so a vehicle still carrying other shared riders stays busy.
 func (h *HTTPHandler) CompleteJob(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	vehicleID := vars["id"]
 
-	if err := h.fleetService.CompleteJob(r.Context(), vehicleID); err != nil {
+	var completion struct {
+		JobID string `json:"job_id"`
+	}
+
+	// The body is optional; without one the vehicle is released from all its work
+	if err := json.NewDecoder(r.Body).Decode(&completion); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	if completion.JobID != "" {
+		err = h.fleetService.CompleteVehicleJob(r.Context(), vehicleID, completion.JobID)
+	} else {
+		err = h.fleetService.CompleteJob(r.Context(), vehicleID)
+	}
+	if err != nil {
+		if errors.Is(err, service.ErrVehicleNotOnJob) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// ReleaseVehicle frees a vehicle whose job was cancelled
+func (h *HTTPHandler) ReleaseVehicle(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	vehicleID := vars["id"]
+
+	var release struct {
+		JobID string `json:"job_id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&release); err != nil || release.JobID == "" {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.fleetService.ReleaseVehicle(r.Context(), vehicleID, release.JobID); err != nil {
+		if errors.Is(err, service.ErrVehicleNotOnJob) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	slog.Info("Vehicle released from cancelled job",
+		"vehicle_id", vehicleID,
+		"job_id", release.JobID)
 	w.WriteHeader(http.StatusOK)
 }
 
+// RecordPickup notes a job's rider or order is on board, taking a shared ride's pickup
+// off the vehicle's stop plan
+func (h *HTTPHandler) RecordPickup(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	vehicleID := vars["id"]
+
+	var pickup struct {
+		JobID string `json:"job_id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&pickup); err != nil || pickup.JobID == "" {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.fleetService.RecordPickup(r.Context(), vehicleID, pickup.JobID); err != nil {
+		if errors.Is(err, service.ErrVehicleNotOnJob) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// StartPool claims an available vehicle for a shared ride, starting its stop plan
+func (h *HTTPHandler) StartPool(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	vehicleID := vars["id"]
+
+	var ride service.SharedRide
+	if err := json.NewDecoder(r.Body).Decode(&ride); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	vehicle, err := h.fleetService.StartPool(r.Context(), vehicleID, ride)
+	if err != nil {
+		if errors.Is(err, storage.ErrVehicleNotAvailable) {
+			slog.Info("Vehicle assignment conflict",
+				"vehicle_id", vehicleID,
+				"job_id", ride.JobID)
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(vehicle)
+}
+
+// JoinPool adds a shared ride to the busy vehicle it fits best
+func (h *HTTPHandler) JoinPool(w http.ResponseWriter, r *http.Request) {
+	var ride service.SharedRide
+	if err := json.NewDecoder(r.Body).Decode(&ride); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	vehicle, err := h.fleetService.JoinPool(r.Context(), ride)
+	if err != nil {
+		if errors.Is(err, service.ErrNoPoolMatch) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, service.ErrInvalidSharedRide) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(vehicle)
+}
+
+// StartBatch claims an available vehicle for a batch of deliveries
+func (h *HTTPHandler) StartBatch(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	vehicleID := vars["id"]
+
+	var batch struct {
+		Stops []storage.PlannedStop `json:"stops"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	vehicle, err := h.fleetService.StartBatch(r.Context(), vehicleID, batch.Stops)
+	if err != nil {
+		if errors.Is(err, storage.ErrVehicleNotAvailable) {
+			slog.Info("Vehicle assignment conflict",
+				"vehicle_id", vehicleID,
+				"stops", len(batch.Stops))
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(vehicle)
+}
+
 // FindNearestVehicle finds the nearest available vehicle
 func (h *HTTPHandler) FindNearestVehicle(w http.ResponseWriter, r *http.Request) {
 	region := r.URL.Query().Get("region")
@@ -170,7 +396,10 @@ func (h *HTTPHandler) FindNearestVehicle(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	vehicle, err := h.fleetService.FindNearestAvailableVehicle(r.Context(), region, lat, lng, distance)
+	// Vehicles the caller already lost an assignment race for
+	excludeIDs := r.URL.Query()["exclude"]
+
+	vehicle, err := h.fleetService.FindNearestAvailableVehicle(r.Context(), region, lat, lng, distance, excludeIDs...)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
@@ -179,3 +408,79 @@ func (h *HTTPHandler) FindNearestVehicle(w http.ResponseWriter, r *http.Request)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(vehicle)
 }
+
+// formatETag renders a record version as a strong ETag
+func formatETag(version int64) string {
+	return fmt.Sprintf("\"%d\"", version)
+}
+
+// parseIfMatch extracts the expected version from an If-Match header, reporting
+// whether the request is conditional at all
+func parseIfMatch(r *http.Request) (int64, bool, error) {
+	header := r.Header.Get("If-Match")
+	if header == "" || header == "*" {
+		return 0, false, nil
+	}
+
+	value := strings.Trim(strings.TrimPrefix(header, "W/"), "\"")
+	version, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid If-Match header: %s", header)
+	}
+
+	return version, true, nil
+}
+
+// GetFleetKPIs reports how the fleet spent a window, per vehicle and in total. The
+// window is given by from and to (RFC 3339 times or dates), or by a window duration
+// ending at to, and defaults to the last day.
+func (h *HTTPHandler) GetFleetKPIs(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := service.KPIQuery{Region: params.Get("region")}
+
+	var err error
+	if query.From, err = parseTimeParam(params.Get("from")); err != nil {
+		http.Error(w, "Invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if query.To, err = parseTimeParam(params.Get("to")); err != nil {
+		http.Error(w, "Invalid to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if window := params.Get("window"); window != "" {
+		duration, err := time.ParseDuration(window)
+		if err != nil || duration <= 0 {
+			http.Error(w, "Invalid window", http.StatusBadRequest)
+			return
+		}
+		if query.To.IsZero() {
+			query.To = time.Now()
+		}
+		query.From = query.To.Add(-duration)
+	}
+
+	report, err := h.fleetService.GetFleetKPIs(r.Context(), query)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidKPIWindow) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// parseTimeParam parses a query parameter given as an RFC 3339 time or a date, returning
+// the zero time when it is empty
+func parseTimeParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
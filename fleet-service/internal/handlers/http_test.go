@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"fleet-service/internal/service"
 	"fleet-service/internal/storage"
@@ -244,3 +245,285 @@ func TestHTTPHandler_FindNearestVehicle_MissingParams(t *testing.T) {
 		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
 	}
 }
+
+func TestHTTPHandler_AssignJob_Conflict(t *testing.T) {
+	handler, vehicleStorage := setupTestHandler()
+
+	// Vehicle already serving another job
+	existingJobID := "job-existing"
+	vehicle := &storage.Vehicle{
+		ID:             "test-vehicle-1",
+		Region:         "us-west-2",
+		Status:         "busy",
+		BatteryLevel:   80,
+		BatteryRangeKm: 200.0,
+		LocationLat:    37.7749,
+		LocationLng:    -122.4194,
+		CurrentJobID:   &existingJobID,
+		VehicleType:    "sedan",
+	}
+	vehicleStorage.CreateVehicle(nil, vehicle)
+
+	jsonData, _ := json.Marshal(map[string]string{"job_id": "job-123"})
+	req := httptest.NewRequest("POST", "/vehicles/test-vehicle-1/assign", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected status %d, got %d", http.StatusConflict, rr.Code)
+	}
+
+	updated, _ := vehicleStorage.GetVehicle(nil, "test-vehicle-1")
+	if updated.CurrentJobID == nil || *updated.CurrentJobID != existingJobID {
+		t.Errorf("Expected job ID '%s' to be preserved, got %v", existingJobID, updated.CurrentJobID)
+	}
+}
+
+func TestHTTPHandler_UpdateVehicleLocation_IfMatch(t *testing.T) {
+	handler, vehicleStorage := setupTestHandler()
+
+	vehicle := &storage.Vehicle{
+		ID:             "test-vehicle-1",
+		Region:         "us-west-2",
+		Status:         "available",
+		BatteryLevel:   80,
+		BatteryRangeKm: 200.0,
+		LocationLat:    37.7749,
+		LocationLng:    -122.4194,
+		VehicleType:    "sedan",
+	}
+	vehicleStorage.CreateVehicle(nil, vehicle)
+
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	// Fetch the current version
+	req := httptest.NewRequest("GET", "/vehicles/test-vehicle-1", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	etag := rr.Header().Get("ETag")
+	if etag != `"1"` {
+		t.Fatalf("Expected ETag \"1\", got %s", etag)
+	}
+
+	// A job gets assigned behind the client's back
+	jobID := "job-123"
+	vehicleStorage.UpdateVehicleStatus(nil, "test-vehicle-1", "busy", &jobID)
+
+	// The stale location report must not reset the vehicle to available
+	jsonData, _ := json.Marshal(map[string]interface{}{"lat": 37.78, "lng": -122.41, "status": "available"})
+	req = httptest.NewRequest("PUT", "/vehicles/test-vehicle-1/location", bytes.NewBuffer(jsonData))
+	req.Header.Set("If-Match", etag)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected status %d, got %d", http.StatusPreconditionFailed, rr.Code)
+	}
+
+	updated, _ := vehicleStorage.GetVehicle(nil, "test-vehicle-1")
+	if updated.Status != "busy" {
+		t.Errorf("Expected status 'busy', got '%s'", updated.Status)
+	}
+
+	// Retrying with the current version succeeds and returns the next ETag
+	req = httptest.NewRequest("PUT", "/vehicles/test-vehicle-1/location", bytes.NewBuffer(jsonData))
+	req.Header.Set("If-Match", `"2"`)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if rr.Header().Get("ETag") != `"3"` {
+		t.Errorf("Expected ETag \"3\", got %s", rr.Header().Get("ETag"))
+	}
+}
+
+func TestHTTPHandler_ReleaseVehicle(t *testing.T) {
+	handler, vehicleStorage := setupTestHandler()
+
+	jobID := "job-123"
+	vehicle := &storage.Vehicle{
+		ID:             "test-vehicle-1",
+		Region:         "us-west-2",
+		Status:         "busy",
+		BatteryLevel:   80,
+		BatteryRangeKm: 200.0,
+		LocationLat:    37.7749,
+		LocationLng:    -122.4194,
+		CurrentJobID:   &jobID,
+		VehicleType:    "sedan",
+	}
+	vehicleStorage.CreateVehicle(nil, vehicle)
+
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	// A stale cancellation for some other job is rejected
+	jsonData, _ := json.Marshal(map[string]string{"job_id": "job-other"})
+	req := httptest.NewRequest("POST", "/vehicles/test-vehicle-1/release", bytes.NewBuffer(jsonData))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected status %d, got %d", http.StatusConflict, rr.Code)
+	}
+
+	jsonData, _ = json.Marshal(map[string]string{"job_id": jobID})
+	req = httptest.NewRequest("POST", "/vehicles/test-vehicle-1/release", bytes.NewBuffer(jsonData))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	updated, _ := vehicleStorage.GetVehicle(nil, "test-vehicle-1")
+	if updated.Status != "available" {
+		t.Errorf("Expected status 'available', got '%s'", updated.Status)
+	}
+	if updated.CurrentJobID != nil {
+		t.Errorf("Expected no job ID, got %v", updated.CurrentJobID)
+	}
+}
+
+func TestHTTPHandler_SharedRides(t *testing.T) {
+	handler, vehicleStorage := setupTestHandler()
+
+	vehicle := &storage.Vehicle{
+		ID:             "test-vehicle-1",
+		Region:         "us-west-2",
+		Status:         "available",
+		BatteryLevel:   80,
+		BatteryRangeKm: 200.0,
+		LocationLat:    45.5,
+		LocationLng:    -122.6,
+		VehicleType:    "sedan",
+	}
+	vehicleStorage.CreateVehicle(nil, vehicle)
+
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	post := func(path string, body interface{}) *httptest.ResponseRecorder {
+		jsonData, _ := json.Marshal(body)
+		req := httptest.NewRequest("POST", path, bytes.NewBuffer(jsonData))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Nobody is sharing yet, so there is no pool to join
+	second := service.SharedRide{JobID: "job-2", Region: "us-west-2", PickupLat: 45.51, PickupLng: -122.6, DropoffLat: 45.53, DropoffLng: -122.6, Seats: 1}
+	if rr := post("/vehicles/pool", second); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, rr.Code)
+	}
+
+	first := service.SharedRide{JobID: "job-1", Region: "us-west-2", PickupLat: 45.5, PickupLng: -122.6, DropoffLat: 45.54, DropoffLng: -122.6, Seats: 1}
+	if rr := post("/vehicles/test-vehicle-1/pool", first); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	rr := post("/vehicles/pool", second)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var joined storage.Vehicle
+	json.NewDecoder(rr.Body).Decode(&joined)
+	if joined.ID != "test-vehicle-1" || len(joined.StopPlan) != 4 {
+		t.Errorf("Expected both rides planned on test-vehicle-1, got %s with %+v", joined.ID, joined.StopPlan)
+	}
+
+	// Completing one rider keeps the vehicle busy for the other
+	if rr := post("/vehicles/test-vehicle-1/complete", map[string]string{"job_id": "job-1"}); rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	updated, _ := vehicleStorage.GetVehicle(nil, "test-vehicle-1")
+	if updated.Status != "busy" || len(updated.StopPlan) != 2 {
+		t.Errorf("Expected vehicle busy with job-2's stops, got %s %+v", updated.Status, updated.StopPlan)
+	}
+}
+
+func TestHTTPHandler_StartBatch(t *testing.T) {
+	handler, vehicleStorage := setupTestHandler()
+
+	vehicleStorage.CreateVehicle(nil, &storage.Vehicle{
+		ID:             "test-vehicle-1",
+		Region:         "us-west-2",
+		Status:         "available",
+		BatteryLevel:   80,
+		BatteryRangeKm: 200.0,
+		LocationLat:    45.5,
+		LocationLng:    -122.6,
+		VehicleType:    "sedan",
+	})
+
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	post := func(stops []storage.PlannedStop) *httptest.ResponseRecorder {
+		jsonData, _ := json.Marshal(map[string]interface{}{"stops": stops})
+		req := httptest.NewRequest("POST", "/vehicles/test-vehicle-1/batch", bytes.NewBuffer(jsonData))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// An order that is never dropped off can't be driven
+	if rr := post([]storage.PlannedStop{{JobID: "order-1", Type: "pickup", Lat: 45.5, Lng: -122.6}}); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	stops := []storage.PlannedStop{
+		{JobID: "order-1", Type: "pickup", Lat: 45.5, Lng: -122.6},
+		{JobID: "order-1", Type: "dropoff", Lat: 45.52, Lng: -122.6},
+	}
+	if rr := post(stops); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	// The vehicle is now out on the batch
+	if rr := post(stops); rr.Code != http.StatusConflict {
+		t.Errorf("Expected status %d, got %d", http.StatusConflict, rr.Code)
+	}
+}
+
+func TestHTTPHandler_GetFleetKPIs(t *testing.T) {
+	handler, _ := setupTestHandler()
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	vehicle := storage.Vehicle{ID: "test-vehicle-1", Region: "us-west-2", Status: "available", BatteryRangeKm: 200.0, LocationLat: 45.5, LocationLng: -122.6, VehicleType: "sedan"}
+	jsonData, _ := json.Marshal(vehicle)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/vehicles", bytes.NewBuffer(jsonData)))
+
+	req := httptest.NewRequest("GET", "/metrics/fleet?window=6h&region=us-west-2", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var report service.FleetKPIReport
+	json.NewDecoder(rr.Body).Decode(&report)
+	if report.Vehicles != 1 || report.ByVehicle[0].VehicleID != "test-vehicle-1" || report.To.Sub(report.From) < 6*time.Hour {
+		t.Errorf("Expected the vehicle over the last 6 hours, got %+v", report)
+	}
+
+	for _, query := range []string{"window=soon", "from=yesterday", "from=2026-10-02&to=2026-10-01"} {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics/fleet?"+query, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for %s, got %d", http.StatusBadRequest, query, rr.Code)
+		}
+	}
+}
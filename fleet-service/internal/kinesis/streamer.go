@@ -0,0 +1,96 @@
+package kinesis
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"fleet-service/internal/metrics"
+	"fleet-service/internal/storage"
+	"shared/tracing"
+
+	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("fleet-service/internal/kinesis")
+
+// Streamer publishes vehicle status change events
+type Streamer struct {
+	client     *kinesis.Client
+	streamName string
+}
+
+// VehicleStatusEvent records a status change the fleet service made on its own,
+// such as marking a silent vehicle offline
+type VehicleStatusEvent struct {
+	VehicleID      string    `json:"vehicle_id"`
+	EventType      string    `json:"event_type"` // status_changed
+	Timestamp      time.Time `json:"timestamp"`
+	PreviousStatus string    `json:"previous_status"`
+	Status         string    `json:"status"`
+	Reason         string    `json:"reason"`
+	Region         string    `json:"region"`
+	Latitude       float64   `json:"latitude"`
+	Longitude      float64   `json:"longitude"`
+	JobID          *string   `json:"job_id,omitempty"`
+	LastUpdated    time.Time `json:"last_updated"`
+}
+
+func NewStreamer(client *kinesis.Client, streamName string) *Streamer {
+	return &Streamer{
+		client:     client,
+		streamName: streamName,
+	}
+}
+
+// PublishStatusChange streams a vehicle status change event
+func (s *Streamer) PublishStatusChange(ctx context.Context, vehicle *storage.Vehicle, previousStatus, reason string) {
+	if s.client == nil {
+		return // Kinesis not enabled
+	}
+
+	event := VehicleStatusEvent{
+		VehicleID:      vehicle.ID,
+		EventType:      "status_changed",
+		Timestamp:      time.Now().UTC(),
+		PreviousStatus: previousStatus,
+		Status:         vehicle.Status,
+		Reason:         reason,
+		Region:         vehicle.Region,
+		Latitude:       vehicle.LocationLat,
+		Longitude:      vehicle.LocationLng,
+		JobID:          vehicle.CurrentJobID,
+		LastUpdated:    vehicle.LastUpdated,
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("Failed to marshal vehicle status event", "vehicle_id", vehicle.ID, "error", err)
+		return
+	}
+
+	// Publishing outlives the request that caused the change, so isn't cut short with it
+	ctx, span := tracer.Start(context.WithoutCancel(ctx), "Kinesis.PutRecord",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(tracing.VehicleID(vehicle.ID), attribute.String("messaging.destination.name", s.streamName)))
+	if vehicle.CurrentJobID != nil {
+		span.SetAttributes(tracing.JobID(*vehicle.CurrentJobID))
+	}
+	_, err = s.client.PutRecord(ctx, &kinesis.PutRecordInput{
+		StreamName:   &s.streamName,
+		Data:         data,
+		PartitionKey: &vehicle.ID,
+	})
+	tracing.End(span, err)
+
+	if err != nil {
+		metrics.KinesisPutFailures.Inc()
+		slog.Error("Failed to stream vehicle status event", "vehicle_id", vehicle.ID, "status", vehicle.Status, "error", err)
+	} else {
+		slog.Debug("Streamed vehicle status event", "vehicle_id", vehicle.ID, "status", vehicle.Status)
+	}
+}
@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"fleet-service/internal/storage"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// fleetScrapeTimeout bounds how long a scrape waits to read the fleet
+const fleetScrapeTimeout = 5 * time.Second
+
+// batteryBuckets are the upper bounds of the battery level histogram, in percent
+var batteryBuckets = []float64{10, 20, 30, 40, 50, 60, 70, 80, 90, 100}
+
+// VehicleLister reads every vehicle in the fleet
+type VehicleLister interface {
+	GetAllVehicles(ctx context.Context) ([]*storage.Vehicle, error)
+}
+
+// FleetCollector reports the state of the fleet, read afresh on every scrape
+type FleetCollector struct {
+	vehicles VehicleLister
+
+	vehiclesDesc *prometheus.Desc
+	batteryDesc  *prometheus.Desc
+	upDesc       *prometheus.Desc
+}
+
+// NewFleetCollector creates a collector for the fleet vehicles lists
+func NewFleetCollector(vehicles VehicleLister) *FleetCollector {
+	return &FleetCollector{
+		vehicles: vehicles,
+		vehiclesDesc: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "vehicles"),
+			"Vehicles in the fleet, by region and status.", []string{"region", "status"}, nil),
+		batteryDesc: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "vehicle_battery_level_percent"),
+			"Distribution of vehicle battery levels, by region.", []string{"region"}, nil),
+		upDesc: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "fleet_scrape_success"),
+			"Whether the fleet could be read for this scrape.", nil, nil),
+	}
+}
+
+// Describe sends the descriptors of the fleet metrics
+func (c *FleetCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.vehiclesDesc
+	ch <- c.batteryDesc
+	ch <- c.upDesc
+}
+
+// Collect reads the fleet and sends its vehicle counts and battery distribution
+func (c *FleetCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), fleetScrapeTimeout)
+	defer cancel()
+
+	vehicles, err := c.vehicles.GetAllVehicles(ctx)
+	if err != nil {
+		slog.Error("Failed to read fleet for metrics", "error", err)
+		ch <- prometheus.MustNewConstMetric(c.upDesc, prometheus.GaugeValue, 0)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.upDesc, prometheus.GaugeValue, 1)
+
+	type regionStatus struct{ region, status string }
+	type batteryHistogram struct {
+		count   uint64
+		sum     float64
+		buckets map[float64]uint64
+	}
+	counts := make(map[regionStatus]int)
+	batteries := make(map[string]*batteryHistogram)
+
+	for _, vehicle := range vehicles {
+		counts[regionStatus{vehicle.Region, vehicle.Status}]++
+
+		histogram, exists := batteries[vehicle.Region]
+		if !exists {
+			histogram = &batteryHistogram{buckets: make(map[float64]uint64, len(batteryBuckets))}
+			for _, bound := range batteryBuckets {
+				histogram.buckets[bound] = 0
+			}
+			batteries[vehicle.Region] = histogram
+		}
+		histogram.count++
+		histogram.sum += float64(vehicle.BatteryLevel)
+		for _, bound := range batteryBuckets {
+			if float64(vehicle.BatteryLevel) <= bound {
+				histogram.buckets[bound]++
+			}
+		}
+	}
+
+	for key, count := range counts {
+		ch <- prometheus.MustNewConstMetric(c.vehiclesDesc, prometheus.GaugeValue, float64(count), key.region, key.status)
+	}
+	for region, histogram := range batteries {
+		ch <- prometheus.MustNewConstHistogram(c.batteryDesc, histogram.count, histogram.sum, histogram.buckets, region)
+	}
+}
@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"fleet-service/internal/storage"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// fakeFleet lists a fixed set of vehicles
+type fakeFleet struct {
+	vehicles []*storage.Vehicle
+	err      error
+}
+
+func (f *fakeFleet) GetAllVehicles(ctx context.Context) ([]*storage.Vehicle, error) {
+	return f.vehicles, f.err
+}
+
+func TestFleetCollector(t *testing.T) {
+	collector := NewFleetCollector(&fakeFleet{vehicles: []*storage.Vehicle{
+		{ID: "v1", Region: "us-west-2", Status: "available", BatteryLevel: 95},
+		{ID: "v2", Region: "us-west-2", Status: "available", BatteryLevel: 15},
+		{ID: "v3", Region: "us-west-2", Status: "busy", BatteryLevel: 60},
+	}})
+
+	expected := `
+# HELP fleet_service_vehicles Vehicles in the fleet, by region and status.
+# TYPE fleet_service_vehicles gauge
+fleet_service_vehicles{region="us-west-2",status="available"} 2
+fleet_service_vehicles{region="us-west-2",status="busy"} 1
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected), "fleet_service_vehicles"); err != nil {
+		t.Error(err)
+	}
+
+	expected = `
+# HELP fleet_service_vehicle_battery_level_percent Distribution of vehicle battery levels, by region.
+# TYPE fleet_service_vehicle_battery_level_percent histogram
+fleet_service_vehicle_battery_level_percent_bucket{region="us-west-2",le="10"} 0
+fleet_service_vehicle_battery_level_percent_bucket{region="us-west-2",le="20"} 1
+fleet_service_vehicle_battery_level_percent_bucket{region="us-west-2",le="30"} 1
+fleet_service_vehicle_battery_level_percent_bucket{region="us-west-2",le="40"} 1
+fleet_service_vehicle_battery_level_percent_bucket{region="us-west-2",le="50"} 1
+fleet_service_vehicle_battery_level_percent_bucket{region="us-west-2",le="60"} 2
+fleet_service_vehicle_battery_level_percent_bucket{region="us-west-2",le="70"} 2
+fleet_service_vehicle_battery_level_percent_bucket{region="us-west-2",le="80"} 2
+fleet_service_vehicle_battery_level_percent_bucket{region="us-west-2",le="90"} 2
+fleet_service_vehicle_battery_level_percent_bucket{region="us-west-2",le="100"} 3
+fleet_service_vehicle_battery_level_percent_bucket{region="us-west-2",le="+Inf"} 3
+fleet_service_vehicle_battery_level_percent_sum{region="us-west-2"} 170
+fleet_service_vehicle_battery_level_percent_count{region="us-west-2"} 3
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected), "fleet_service_vehicle_battery_level_percent"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFleetCollector_ReadFailure(t *testing.T) {
+	collector := NewFleetCollector(&fakeFleet{err: errors.New("table unavailable")})
+
+	expected := `
+# HELP fleet_service_fleet_scrape_success Whether the fleet could be read for this scrape.
+# TYPE fleet_service_fleet_scrape_success gauge
+fleet_service_fleet_scrape_success 0
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
@@ -2,22 +2,72 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"math"
 	"sort"
+	"time"
 
+	"fleet-service/internal/metrics"
 	"fleet-service/internal/storage"
+	"shared/routing"
+	"shared/tracing"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
+// ErrVehicleNotOnJob is returned when releasing a vehicle that is no longer serving the given job
+var ErrVehicleNotOnJob = errors.New("vehicle is not assigned to this job")
+
+// maxUpdateAttempts bounds how often a read-modify-write re-reads a vehicle that keeps changing underneath it
+const maxUpdateAttempts = 3
+
+// StatusEventPublisher receives vehicle status changes made by the fleet service itself
+type StatusEventPublisher interface {
+	PublishStatusChange(ctx context.Context, vehicle *storage.Vehicle, previousStatus, reason string)
+}
+
 // FleetService handles fleet management operations
 type FleetService struct {
-	storage storage.VehicleStorage
+	storage       storage.VehicleStorage
+	publisher     StatusEventPublisher
+	router        routing.Router
+	maxPoolDetour time.Duration // how much later shared riders may arrive because of others
+	kpis          *KPITracker
 }
 
 // NewFleetService creates a new fleet service instance
-func NewFleetService(storage storage.VehicleStorage) *FleetService {
+func NewFleetService(vehicleStorage storage.VehicleStorage) *FleetService {
+	kpis := NewKPITracker(storage.NewMemoryKPIStorage())
 	return &FleetService{
-		storage: storage,
+		storage:       &kpiStorage{VehicleStorage: vehicleStorage, kpis: kpis},
+		router:        routing.NewHaversineRouter(),
+		maxPoolDetour: DefaultMaxPoolDetour,
+		kpis:          kpis,
+	}
+}
+
+// SetRouter sets how driving distances and times to pickups are estimated
+func (f *FleetService) SetRouter(router routing.Router) {
+	f.router = router
+}
+
+// SetKPIStorage sets where fleet KPIs are kept. Instances of the service sharing a
+// store report the same KPIs.
+func (f *FleetService) SetKPIStorage(store storage.KPIStorage) {
+	f.kpis.store = store
+}
+
+// SetStatusEventPublisher sets where vehicle status change events are sent
+func (f *FleetService) SetStatusEventPublisher(publisher StatusEventPublisher) {
+	f.publisher = publisher
+}
+
+// publishStatusChange emits a status change event if a publisher is configured
+func (f *FleetService) publishStatusChange(ctx context.Context, vehicle *storage.Vehicle, previousStatus, reason string) {
+	if f.publisher != nil {
+		f.publisher.PublishStatusChange(ctx, vehicle, previousStatus, reason)
 	}
 }
 
@@ -28,57 +78,330 @@ func (f *FleetService) RegisterVehicle(ctx context.Context, vehicle *storage.Veh
 
 // UpdateVehicleLocationAndStatus updates a vehicle's position and status
 func (f *FleetService) UpdateVehicleLocationAndStatus(ctx context.Context, vehicleID string, lat, lng float64, status string) error {
-	return f.storage.UpdateVehicleLocationAndStatus(ctx, vehicleID, lat, lng, status)
+	current, err := f.storage.GetVehicle(ctx, vehicleID)
+	if err != nil {
+		return err
+	}
+	previous := *current
+
+	if err := f.storage.UpdateVehicleLocationAndStatus(ctx, vehicleID, lat, lng, status); err != nil {
+		return err
+	}
+
+	if previous.Status == StatusOffline && status != StatusOffline {
+		f.publishOnline(ctx, &previous, lat, lng, status)
+	}
+	return nil
+}
+
+// UpdateVehicleLocationAndStatusIfMatch updates a vehicle's position and status only if the
+// stored record is still at expectedVersion, returning the updated vehicle. An empty status
+// keeps the current one.
+func (f *FleetService) UpdateVehicleLocationAndStatusIfMatch(ctx context.Context, vehicleID string, lat, lng float64, status string, expectedVersion int64) (*storage.Vehicle, error) {
+	current, err := f.storage.GetVehicle(ctx, vehicleID)
+	if err != nil {
+		return nil, err
+	}
+
+	if current.Version != expectedVersion {
+		return nil, storage.ErrVersionConflict
+	}
+
+	// Work on a copy so a rejected write never leaks into the stored record
+	previousStatus := current.Status
+	updated := *current
+	updated.LocationLat = lat
+	updated.LocationLng = lng
+	if status != "" {
+		updated.Status = status
+	} else if current.Status == StatusOffline {
+		updated.Status = onlineStatus(current)
+	}
+
+	if err := f.storage.UpdateVehicle(ctx, &updated); err != nil {
+		return nil, err
+	}
+
+	if previousStatus == StatusOffline && updated.Status != StatusOffline {
+		f.publishStatusChange(ctx, &updated, StatusOffline, "heartbeat resumed")
+	}
+
+	return &updated, nil
 }
 
-// UpdateVehicleLocation updates a vehicle's position
+// UpdateVehicleLocation updates a vehicle's position, bringing an offline vehicle back online
 func (f *FleetService) UpdateVehicleLocation(ctx context.Context, vehicleID string, lat, lng float64) error {
-	return f.storage.UpdateVehicleLocation(ctx, vehicleID, lat, lng)
+	current, err := f.storage.GetVehicle(ctx, vehicleID)
+	if err != nil {
+		return err
+	}
+
+	if current.Status != StatusOffline {
+		return f.storage.UpdateVehicleLocation(ctx, vehicleID, lat, lng)
+	}
+
+	previous := *current
+	status := onlineStatus(&previous)
+	if err := f.storage.UpdateVehicleLocationAndStatus(ctx, vehicleID, lat, lng, status); err != nil {
+		return err
+	}
+
+	f.publishOnline(ctx, &previous, lat, lng, status)
+	return nil
+}
+
+// publishOnline emits the event for a vehicle that reported again after being marked offline
+func (f *FleetService) publishOnline(ctx context.Context, previous *storage.Vehicle, lat, lng float64, status string) {
+	restored := *previous
+	restored.LocationLat = lat
+	restored.LocationLng = lng
+	restored.Status = status
+	f.publishStatusChange(ctx, &restored, StatusOffline, "heartbeat resumed")
 }
 
-// AssignJob assigns a job to a vehicle and updates its status
+// AssignJob atomically assigns a job to a vehicle, failing with
+// storage.ErrVehicleNotAvailable if the vehicle was already claimed
 func (f *FleetService) AssignJob(ctx context.Context, vehicleID, jobID string) error {
-	return f.storage.UpdateVehicleStatus(ctx, vehicleID, "busy", &jobID)
+	trace.SpanFromContext(ctx).SetAttributes(tracing.VehicleID(vehicleID), tracing.JobID(jobID))
+	return f.storage.AssignJobIfAvailable(ctx, vehicleID, jobID)
 }
 
 // CompleteJob marks a vehicle as available after job completion
 func (f *FleetService) CompleteJob(ctx context.Context, vehicleID string) error {
-	return f.storage.UpdateVehicleStatus(ctx, vehicleID, "available", nil)
+	if err := f.storage.UpdateVehicleStatus(ctx, vehicleID, "available", nil); err != nil {
+		return err
+	}
+	f.recordDropoff(ctx, vehicleID, "", true)
+	return nil
 }
 
-// FindNearestAvailableVehicle finds the closest available vehicle with sufficient battery
-func (f *FleetService) FindNearestAvailableVehicle(ctx context.Context, region string, pickupLat, pickupLng, tripDistanceKm float64) (*storage.Vehicle, error) {
-	vehicles, err := f.storage.GetVehiclesByRegionAndStatus(ctx, region, "available")
+// CompleteVehicleJob releases a vehicle from a job it completed, counting the job
+// towards its KPIs. It fails like ReleaseVehicle.
+func (f *FleetService) CompleteVehicleJob(ctx context.Context, vehicleID, jobID string) error {
+	trace.SpanFromContext(ctx).SetAttributes(tracing.VehicleID(vehicleID), tracing.JobID(jobID))
+	if err := f.releaseVehicle(ctx, vehicleID, jobID); err != nil {
+		return err
+	}
+	f.recordDropoff(ctx, vehicleID, jobID, true)
+	return nil
+}
+
+// ReleaseVehicle detaches a vehicle from a job that was completed, cancelled, failed or
+// abandoned. A vehicle left with no work returns from busy to the available pool, while
+// one still carrying other shared riders stays busy with the job's stops dropped from its
+// plan. A vehicle that is charging or in maintenance keeps its status. The release only
+// applies while the vehicle still holds jobID, so a late cancellation never frees a
+// vehicle that has moved on to another job.
+func (f *FleetService) ReleaseVehicle(ctx context.Context, vehicleID, jobID string) error {
+	trace.SpanFromContext(ctx).SetAttributes(tracing.VehicleID(vehicleID), tracing.JobID(jobID))
+	if err := f.releaseVehicle(ctx, vehicleID, jobID); err != nil {
+		return err
+	}
+	f.recordDropoff(ctx, vehicleID, jobID, false)
+	return nil
+}
+
+// recordDropoff shows the KPI tracker that a vehicle finished with a job
+func (f *FleetService) recordDropoff(ctx context.Context, vehicleID, jobID string, completed bool) {
+	at := time.Now()
+	f.kpis.enqueue(ctx, vehicleID, func(ctx context.Context) {
+		if err := f.kpis.recordDropoff(ctx, vehicleID, jobID, completed, at); err != nil {
+			slog.Warn("Failed to record drop-off for KPIs", "vehicle_id", vehicleID, "job_id", jobID, "error", err)
+		}
+	})
+}
+
+// releaseVehicle detaches a vehicle from a job, as described for ReleaseVehicle
+func (f *FleetService) releaseVehicle(ctx context.Context, vehicleID, jobID string) error {
+	return f.updateVehicle(ctx, vehicleID, func(vehicle *storage.Vehicle) error {
+		if !holdsJob(vehicle, jobID) {
+			return ErrVehicleNotOnJob
+		}
+
+		plan := make([]storage.PlannedStop, 0, len(vehicle.StopPlan))
+		for _, stop := range vehicle.StopPlan {
+			if stop.JobID != jobID {
+				plan = append(plan, stop)
+			}
+		}
+		vehicle.StopPlan = plan
+
+		if len(plan) > 0 {
+			// Carry on with the shared ride whose stop is next
+			next := plan[0].JobID
+			vehicle.CurrentJobID = &next
+			return nil
+		}
+
+		vehicle.CurrentJobID = nil
+		vehicle.StopPlan = nil
+		if vehicle.Status == "busy" {
+			vehicle.Status = "available"
+		}
+		return nil
+	})
+}
+
+// updateVehicle applies a change to a copy of a vehicle and writes it back, re-reading
+// and reapplying the change if a location report landed in between
+func (f *FleetService) updateVehicle(ctx context.Context, vehicleID string, change func(vehicle *storage.Vehicle) error) error {
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		current, err := f.storage.GetVehicle(ctx, vehicleID)
+		if err != nil {
+			return err
+		}
+
+		updated := *current
+		if err := change(&updated); err != nil {
+			return err
+		}
+
+		err = f.storage.UpdateVehicle(ctx, &updated)
+		if errors.Is(err, storage.ErrVersionConflict) {
+			continue
+		}
+		return err
+	}
+
+	return fmt.Errorf("failed to update vehicle %s after %d attempts: %w", vehicleID, maxUpdateAttempts, storage.ErrVersionConflict)
+}
+
+// maxRoutedCandidates bounds how many vehicles are routed to a pickup in one request
+const maxRoutedCandidates = 25
+
+const (
+	// maxSearchRings bounds the cell search around a pickup before falling back to
+	// scanning the whole region
+	maxSearchRings = 6
+	// roadDetourFactor widens the search past the nearest vehicle, since one a little
+	// further away as the crow flies may be the quicker drive
+	roadDetourFactor = 2.0
+)
+
+// FindNearestAvailableVehicle finds the available vehicle with the shortest driving time
+// to the pickup that has enough battery for the drive there plus the trip, skipping any
+// vehicles listed in excludeIDs
+func (f *FleetService) FindNearestAvailableVehicle(ctx context.Context, region string, pickupLat, pickupLng, tripDistanceKm float64, excludeIDs ...string) (*storage.Vehicle, error) {
+	candidates, straightLine, err := f.nearbyCandidates(ctx, region, pickupLat, pickupLng, tripDistanceKm, excludeIDs)
 	if err != nil {
 		return nil, err
 	}
 
-	var bestVehicle *storage.Vehicle
-	var minDistance float64 = math.MaxFloat64
+	// Route the closest vehicles first; further ones are only needed if none of them can make it
+	sort.Slice(candidates, func(i, j int) bool {
+		return straightLine[candidates[i].ID] < straightLine[candidates[j].ID]
+	})
+
+	pickup := routing.Point{Lat: pickupLat, Lng: pickupLng}
+	for start := 0; start < len(candidates); start += maxRoutedCandidates {
+		batch := candidates[start:min(start+maxRoutedCandidates, len(candidates))]
+
+		origins := make([]routing.Point, len(batch))
+		for i, vehicle := range batch {
+			origins[i] = routing.Point{Lat: vehicle.LocationLat, Lng: vehicle.LocationLng}
+		}
+
+		estimates, err := f.router.Matrix(ctx, origins, []routing.Point{pickup})
+		if err != nil {
+			slog.Warn("Failed to route vehicles to pickup, using straight-line estimates", "error", err)
+			metrics.RoutingFallbacks.Inc()
+			estimates, _ = routing.NewHaversineRouter().Matrix(ctx, origins, []routing.Point{pickup})
+		}
+
+		var bestVehicle *storage.Vehicle
+		var bestETA time.Duration
+		for i, vehicle := range batch {
+			estimate := estimates[i][0]
+
+			// Check if vehicle has sufficient battery for the road journey
+			if !hasRange(vehicle, estimate.DistanceKm, tripDistanceKm) {
+				continue
+			}
+
+			if bestVehicle == nil || estimate.Duration < bestETA {
+				bestETA = estimate.Duration
+				bestVehicle = vehicle
+			}
+		}
+
+		if bestVehicle != nil {
+			return bestVehicle, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no available vehicle found with sufficient battery for trip")
+}
 
-	for _, vehicle := range vehicles {
-		// Calculate distance to pickup location
-		distanceToPickup := calculateDistance(vehicle.LocationLat, vehicle.LocationLng, pickupLat, pickupLng)
+// nearbyCandidates gathers available vehicles that could reach the pickup as the crow
+// flies, searching rings of cells outwards from it so the cost depends on how many
+// vehicles are nearby rather than on the size of the fleet. The search stops once it
+// covers roadDetourFactor times the distance to the nearest candidate; if no candidate
+// turns up within maxSearchRings the whole region is scanned instead.
+func (f *FleetService) nearbyCandidates(ctx context.Context, region string, pickupLat, pickupLng, tripDistanceKm float64, excludeIDs []string) ([]*storage.Vehicle, map[string]float64, error) {
+	excluded := make(map[string]bool, len(excludeIDs))
+	for _, id := range excludeIDs {
+		excluded[id] = true
+	}
 
-		// Total distance = distance to pickup + trip distance + 20% safety buffer
-		totalDistance := (distanceToPickup + tripDistanceKm) * 1.2
+	// Roads are never shorter than the straight line, so a vehicle that can't make the
+	// journey as the crow flies is ruled out without routing it
+	var candidates []*storage.Vehicle
+	straightLine := make(map[string]float64)
+	nearestKm := math.Inf(1)
+	consider := func(vehicles []*storage.Vehicle) {
+		for _, vehicle := range vehicles {
+			if excluded[vehicle.ID] {
+				continue
+			}
+			if _, seen := straightLine[vehicle.ID]; seen {
+				continue
+			}
 
-		// Check if vehicle has sufficient battery for total journey
-		if vehicle.BatteryRangeKm < totalDistance {
-			continue
+			distanceToPickup := calculateDistance(vehicle.LocationLat, vehicle.LocationLng, pickupLat, pickupLng)
+			if !hasRange(vehicle, distanceToPickup, tripDistanceKm) {
+				continue
+			}
+
+			straightLine[vehicle.ID] = distanceToPickup
+			nearestKm = math.Min(nearestKm, distanceToPickup)
+			candidates = append(candidates, vehicle)
 		}
+	}
 
-		if distanceToPickup < minDistance {
-			minDistance = distanceToPickup
-			bestVehicle = vehicle
+	for ring := 0; ring <= maxSearchRings; ring++ {
+		vehicles, err := f.storage.GetVehiclesByCells(ctx, region, "available", storage.CellRing(pickupLat, pickupLng, ring))
+		if err != nil {
+			return nil, nil, err
+		}
+		consider(vehicles)
+
+		if len(candidates) > 0 && storage.CellRingReachKm(pickupLat, ring) >= nearestKm*roadDetourFactor {
+			return candidates, straightLine, nil
 		}
 	}
 
-	if bestVehicle == nil {
-		return nil, fmt.Errorf("no available vehicle found with sufficient battery for trip")
+	if len(candidates) > 0 {
+		return candidates, straightLine, nil
 	}
 
-	return bestVehicle, nil
+	vehicles, err := f.storage.GetVehiclesByRegionAndStatus(ctx, region, "available")
+	if err != nil {
+		return nil, nil, err
+	}
+	consider(vehicles)
+
+	return candidates, straightLine, nil
+}
+
+// hasRange reports whether a vehicle can drive to the pickup and complete the trip
+// with a 20% safety buffer
+func hasRange(vehicle *storage.Vehicle, distanceToPickupKm, tripDistanceKm float64) bool {
+	return vehicle.BatteryRangeKm >= (distanceToPickupKm+tripDistanceKm)*1.2
+}
+
+// GetVehicle retrieves a single vehicle by ID
+func (f *FleetService) GetVehicle(ctx context.Context, vehicleID string) (*storage.Vehicle, error) {
+	return f.storage.GetVehicle(ctx, vehicleID)
 }
 
 // GetAllVehicles returns all vehicles for dashboard display
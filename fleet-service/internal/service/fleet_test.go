@@ -2,9 +2,14 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
 	"testing"
+	"time"
 
 	"fleet-service/internal/storage"
+	"shared/routing"
 )
 
 func TestFleetService_FindNearestAvailableVehicle(t *testing.T) {
@@ -209,3 +214,212 @@ func TestCalculateDistance(t *testing.T) {
 		t.Errorf("Expected distance 0, got %f", sameDistance)
 	}
 }
+
+func TestFleetService_FindNearestAvailableVehicle_Exclude(t *testing.T) {
+	vehicleStorage := storage.NewMemoryVehicleStorage()
+	fleetService := NewFleetService(vehicleStorage)
+	ctx := context.Background()
+
+	vehicles := []*storage.Vehicle{
+		{ID: "v1", Region: "us-west-2", Status: "available", BatteryRangeKm: 200.0, LocationLat: 37.7749, LocationLng: -122.4194},
+		{ID: "v2", Region: "us-west-2", Status: "available", BatteryRangeKm: 200.0, LocationLat: 37.8049, LocationLng: -122.4394},
+	}
+	for _, v := range vehicles {
+		fleetService.RegisterVehicle(ctx, v)
+	}
+
+	// v1 is nearest, but the caller already lost the race for it
+	vehicle, err := fleetService.FindNearestAvailableVehicle(ctx, "us-west-2", 37.7649, -122.4294, 10.0, "v1")
+	if err != nil {
+		t.Fatalf("Expected to find a vehicle, got error: %v", err)
+	}
+
+	if vehicle.ID != "v2" {
+		t.Errorf("Expected vehicle v2, got %s", vehicle.ID)
+	}
+}
+
+func TestFleetService_ReleaseVehicle(t *testing.T) {
+	vehicleStorage := storage.NewMemoryVehicleStorage()
+	fleetService := NewFleetService(vehicleStorage)
+	ctx := context.Background()
+
+	vehicle := &storage.Vehicle{
+		ID:             "v1",
+		Region:         "us-west-2",
+		Status:         "available",
+		BatteryLevel:   80,
+		BatteryRangeKm: 200.0,
+		LocationLat:    37.7749,
+		LocationLng:    -122.4194,
+		VehicleType:    "sedan",
+	}
+	fleetService.RegisterVehicle(ctx, vehicle)
+
+	if err := fleetService.AssignJob(ctx, "v1", "job-123"); err != nil {
+		t.Fatalf("Failed to assign job: %v", err)
+	}
+
+	// Releasing for a job the vehicle isn't serving must be a no-op
+	if err := fleetService.ReleaseVehicle(ctx, "v1", "job-other"); !errors.Is(err, ErrVehicleNotOnJob) {
+		t.Errorf("Expected ErrVehicleNotOnJob, got %v", err)
+	}
+
+	busy, _ := vehicleStorage.GetVehicle(ctx, "v1")
+	if busy.Status != "busy" {
+		t.Errorf("Expected status 'busy', got '%s'", busy.Status)
+	}
+
+	if err := fleetService.ReleaseVehicle(ctx, "v1", "job-123"); err != nil {
+		t.Fatalf("Failed to release vehicle: %v", err)
+	}
+
+	released, _ := vehicleStorage.GetVehicle(ctx, "v1")
+	if released.Status != "available" {
+		t.Errorf("Expected status 'available', got '%s'", released.Status)
+	}
+	if released.CurrentJobID != nil {
+		t.Errorf("Expected no job ID, got %v", released.CurrentJobID)
+	}
+}
+
+func TestFleetService_ReleaseVehicle_KeepsChargingStatus(t *testing.T) {
+	vehicleStorage := storage.NewMemoryVehicleStorage()
+	fleetService := NewFleetService(vehicleStorage)
+	ctx := context.Background()
+
+	jobID := "job-123"
+	vehicle := &storage.Vehicle{
+		ID:             "v1",
+		Region:         "us-west-2",
+		Status:         "charging",
+		BatteryLevel:   12,
+		BatteryRangeKm: 48.0,
+		CurrentJobID:   &jobID,
+		VehicleType:    "sedan",
+	}
+	fleetService.RegisterVehicle(ctx, vehicle)
+
+	if err := fleetService.ReleaseVehicle(ctx, "v1", jobID); err != nil {
+		t.Fatalf("Failed to release vehicle: %v", err)
+	}
+
+	released, _ := vehicleStorage.GetVehicle(ctx, "v1")
+	if released.Status != "charging" {
+		t.Errorf("Expected status 'charging', got '%s'", released.Status)
+	}
+	if released.CurrentJobID != nil {
+		t.Errorf("Expected no job ID, got %v", released.CurrentJobID)
+	}
+}
+
+// bridgeRouter simulates a river crossing: trips starting west of the river take a long
+// detour to reach the east bank
+type bridgeRouter struct {
+	riverLng float64
+}
+
+func (b bridgeRouter) Route(ctx context.Context, from, to routing.Point) (routing.Estimate, error) {
+	distance := routing.Haversine(from, to)
+	if from.Lng < b.riverLng && to.Lng >= b.riverLng {
+		distance += 10 // detour to the nearest bridge
+	}
+	return routing.Estimate{DistanceKm: distance, Duration: time.Duration(distance * float64(2*time.Minute))}, nil
+}
+
+func (b bridgeRouter) Matrix(ctx context.Context, origins, destinations []routing.Point) ([][]routing.Estimate, error) {
+	matrix := make([][]routing.Estimate, len(origins))
+	for i, origin := range origins {
+		matrix[i] = make([]routing.Estimate, len(destinations))
+		for k, destination := range destinations {
+			matrix[i][k], _ = b.Route(ctx, origin, destination)
+		}
+	}
+	return matrix, nil
+}
+
+func TestFleetService_FindNearestAvailableVehicle_UsesRoadETA(t *testing.T) {
+	vehicleStorage := storage.NewMemoryVehicleStorage()
+	fleetService := NewFleetService(vehicleStorage)
+	fleetService.SetRouter(bridgeRouter{riverLng: -122.67})
+	ctx := context.Background()
+
+	// Across the river from the pickup, but closest as the crow flies
+	fleetService.RegisterVehicle(ctx, &storage.Vehicle{
+		ID: "west-bank", Region: "us-west-2", Status: "available", BatteryRangeKm: 200,
+		LocationLat: 45.52, LocationLng: -122.675, VehicleType: "sedan",
+	})
+	// Further away but on the same side
+	fleetService.RegisterVehicle(ctx, &storage.Vehicle{
+		ID: "east-bank", Region: "us-west-2", Status: "available", BatteryRangeKm: 200,
+		LocationLat: 45.52, LocationLng: -122.64, VehicleType: "sedan",
+	})
+
+	vehicle, err := fleetService.FindNearestAvailableVehicle(ctx, "us-west-2", 45.52, -122.66, 5.0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if vehicle.ID != "east-bank" {
+		t.Errorf("Expected 'east-bank' with the shorter drive, got '%s'", vehicle.ID)
+	}
+
+	// Battery is checked against the road distance, not the straight line
+	east, _ := vehicleStorage.GetVehicle(ctx, "east-bank")
+	east.BatteryRangeKm = 1
+	west, _ := vehicleStorage.GetVehicle(ctx, "west-bank")
+	west.BatteryRangeKm = 12 // enough for the straight line, not the detour
+
+	if _, err := fleetService.FindNearestAvailableVehicle(ctx, "us-west-2", 45.52, -122.66, 5.0); err == nil {
+		t.Error("Expected no vehicle with enough range for the road journey")
+	}
+}
+
+func TestFleetService_FindNearestAvailableVehicle_BeyondNearbyCells(t *testing.T) {
+	vehicleStorage := storage.NewMemoryVehicleStorage()
+	fleetService := NewFleetService(vehicleStorage)
+	ctx := context.Background()
+
+	// Oakland is well outside the cells searched around a San Francisco pickup
+	fleetService.RegisterVehicle(ctx, &storage.Vehicle{
+		ID: "oakland", Region: "us-west-2", Status: "available", BatteryRangeKm: 200,
+		LocationLat: 37.8044, LocationLng: -122.2712,
+	})
+
+	vehicle, err := fleetService.FindNearestAvailableVehicle(ctx, "us-west-2", 37.7749, -122.4194, 5.0)
+	if err != nil {
+		t.Fatalf("Expected the region to be scanned when no vehicle is nearby, got %v", err)
+	}
+	if vehicle.ID != "oakland" {
+		t.Errorf("Expected 'oakland', got '%s'", vehicle.ID)
+	}
+}
+
+// BenchmarkFindNearestAvailableVehicle looks up a vehicle in fleets of growing size
+// at the same density, roughly one vehicle every 250m
+func BenchmarkFindNearestAvailableVehicle(b *testing.B) {
+	ctx := context.Background()
+	for _, size := range []int{1000, 10000, 100000} {
+		vehicleStorage := storage.NewMemoryVehicleStorage()
+		fleetService := NewFleetService(vehicleStorage)
+
+		side := int(math.Ceil(math.Sqrt(float64(size))))
+		for i := 0; i < size; i++ {
+			fleetService.RegisterVehicle(ctx, &storage.Vehicle{
+				ID:             fmt.Sprintf("vehicle-%d", i),
+				Region:         "us-west-2",
+				Status:         "available",
+				BatteryRangeKm: 200,
+				LocationLat:    37.0 + float64(i/side)*0.00225,
+				LocationLng:    -122.0 + float64(i%side)*0.0028,
+			})
+		}
+
+		b.Run(fmt.Sprintf("fleet=%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := fleetService.FindNearestAvailableVehicle(ctx, "us-west-2", 37.011, -121.989, 5.0); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
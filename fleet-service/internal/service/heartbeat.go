@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"fleet-service/internal/storage"
+)
+
+// StatusOffline marks a vehicle that has stopped reporting its location
+const StatusOffline = "offline"
+
+// onlineStatus is the status a vehicle returns to when it reports again after being offline
+func onlineStatus(vehicle *storage.Vehicle) string {
+	if vehicle.CurrentJobID != nil && *vehicle.CurrentJobID != "" {
+		return "busy"
+	}
+	return "available"
+}
+
+// MarkStaleVehiclesOffline marks vehicles that have not reported within ttl as offline
+// so they are no longer matched to jobs, returning the vehicles it changed
+func (f *FleetService) MarkStaleVehiclesOffline(ctx context.Context, ttl time.Duration) ([]*storage.Vehicle, error) {
+	vehicles, err := f.storage.GetAllVehicles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var marked []*storage.Vehicle
+	for _, vehicle := range vehicles {
+		if vehicle.Status == StatusOffline || now.Sub(vehicle.LastUpdated) <= ttl {
+			continue
+		}
+
+		// Versioned write, so a report that lands in the meantime wins
+		previousStatus := vehicle.Status
+		offline := *vehicle
+		offline.Status = StatusOffline
+		if err := f.storage.UpdateVehicle(ctx, &offline); err != nil {
+			if !errors.Is(err, storage.ErrVersionConflict) {
+				slog.Error("Failed to mark vehicle offline", "vehicle_id", vehicle.ID, "error", err)
+			}
+			continue
+		}
+
+		slog.Warn("Vehicle missed heartbeat, marked offline",
+			"vehicle_id", vehicle.ID,
+			"previous_status", previousStatus,
+			"last_updated", vehicle.LastUpdated)
+		f.publishStatusChange(ctx, &offline, previousStatus, "heartbeat timeout")
+		marked = append(marked, &offline)
+	}
+
+	return marked, nil
+}
+
+// HeartbeatReaper periodically marks vehicles that stopped reporting as offline
+type HeartbeatReaper struct {
+	fleetService *FleetService
+	interval     time.Duration
+	ttl          time.Duration
+	stopChan     chan struct{}
+}
+
+// NewHeartbeatReaper creates a reaper that checks every interval for vehicles silent for longer than ttl
+func NewHeartbeatReaper(fleetService *FleetService, interval, ttl time.Duration) *HeartbeatReaper {
+	return &HeartbeatReaper{
+		fleetService: fleetService,
+		interval:     interval,
+		ttl:          ttl,
+		stopChan:     make(chan struct{}),
+	}
+}
+
+// Start begins the background heartbeat checks
+func (r *HeartbeatReaper) Start() {
+	go r.reapLoop()
+	slog.Info("Heartbeat reaper started", "interval", r.interval, "ttl", r.ttl)
+}
+
+// Stop stops the background heartbeat checks
+func (r *HeartbeatReaper) Stop() {
+	close(r.stopChan)
+}
+
+// reapLoop runs the heartbeat check on every tick
+func (r *HeartbeatReaper) reapLoop() {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := r.fleetService.MarkStaleVehiclesOffline(context.Background(), r.ttl); err != nil {
+				slog.Error("Heartbeat check failed", "error", err)
+			}
+		case <-r.stopChan:
+			return
+		}
+	}
+}
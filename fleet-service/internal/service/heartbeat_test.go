@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"fleet-service/internal/storage"
+)
+
+// recordingPublisher captures status change events for assertions
+type recordingPublisher struct {
+	events []string
+}
+
+func (p *recordingPublisher) PublishStatusChange(ctx context.Context, vehicle *storage.Vehicle, previousStatus, reason string) {
+	p.events = append(p.events, vehicle.ID+":"+previousStatus+"->"+vehicle.Status)
+}
+
+func TestFleetService_MarkStaleVehiclesOffline(t *testing.T) {
+	vehicleStorage := storage.NewMemoryVehicleStorage()
+	fleetService := NewFleetService(vehicleStorage)
+	publisher := &recordingPublisher{}
+	fleetService.SetStatusEventPublisher(publisher)
+	ctx := context.Background()
+
+	for _, id := range []string{"silent", "healthy"} {
+		fleetService.RegisterVehicle(ctx, &storage.Vehicle{
+			ID:             id,
+			Region:         "us-west-2",
+			Status:         "available",
+			BatteryLevel:   80,
+			BatteryRangeKm: 200.0,
+			LocationLat:    37.7749,
+			LocationLng:    -122.4194,
+			VehicleType:    "sedan",
+		})
+	}
+
+	// The silent vehicle last reported long ago
+	silent, _ := vehicleStorage.GetVehicle(ctx, "silent")
+	silent.LastUpdated = time.Now().Add(-5 * time.Minute)
+
+	marked, err := fleetService.MarkStaleVehiclesOffline(ctx, time.Minute)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(marked) != 1 || marked[0].ID != "silent" {
+		t.Fatalf("Expected only 'silent' to be marked offline, got %v", marked)
+	}
+
+	offline, _ := vehicleStorage.GetVehicle(ctx, "silent")
+	if offline.Status != StatusOffline {
+		t.Errorf("Expected status 'offline', got '%s'", offline.Status)
+	}
+
+	// Offline vehicles are no longer matched
+	vehicle, err := fleetService.FindNearestAvailableVehicle(ctx, "us-west-2", 37.7749, -122.4194, 5.0)
+	if err != nil {
+		t.Fatalf("Expected healthy vehicle to be found, got %v", err)
+	}
+	if vehicle.ID != "healthy" {
+		t.Errorf("Expected 'healthy', got '%s'", vehicle.ID)
+	}
+	if err := fleetService.AssignJob(ctx, "silent", "job-123"); err == nil {
+		t.Error("Expected assignment to an offline vehicle to fail")
+	}
+
+	if len(publisher.events) != 1 || publisher.events[0] != "silent:available->offline" {
+		t.Errorf("Expected one offline event, got %v", publisher.events)
+	}
+
+	// Running again doesn't re-mark it
+	marked, _ = fleetService.MarkStaleVehiclesOffline(ctx, time.Minute)
+	if len(marked) != 0 {
+		t.Errorf("Expected no newly offline vehicles, got %d", len(marked))
+	}
+}
+
+func TestFleetService_LocationUpdateRestoresOfflineVehicle(t *testing.T) {
+	vehicleStorage := storage.NewMemoryVehicleStorage()
+	fleetService := NewFleetService(vehicleStorage)
+	publisher := &recordingPublisher{}
+	fleetService.SetStatusEventPublisher(publisher)
+	ctx := context.Background()
+
+	jobID := "job-123"
+	fleetService.RegisterVehicle(ctx, &storage.Vehicle{ID: "idle", Region: "us-west-2", Status: StatusOffline, VehicleType: "sedan"})
+	fleetService.RegisterVehicle(ctx, &storage.Vehicle{ID: "working", Region: "us-west-2", Status: StatusOffline, CurrentJobID: &jobID, VehicleType: "sedan"})
+	fleetService.RegisterVehicle(ctx, &storage.Vehicle{ID: "versioned", Region: "us-west-2", Status: StatusOffline, VehicleType: "sedan"})
+
+	// Location-only reports bring vehicles back with the status their job implies
+	if err := fleetService.UpdateVehicleLocation(ctx, "idle", 37.78, -122.41); err != nil {
+		t.Fatalf("Failed to update location: %v", err)
+	}
+	if err := fleetService.UpdateVehicleLocation(ctx, "working", 37.78, -122.41); err != nil {
+		t.Fatalf("Failed to update location: %v", err)
+	}
+
+	idle, _ := vehicleStorage.GetVehicle(ctx, "idle")
+	if idle.Status != "available" {
+		t.Errorf("Expected idle vehicle to be 'available', got '%s'", idle.Status)
+	}
+	working, _ := vehicleStorage.GetVehicle(ctx, "working")
+	if working.Status != "busy" {
+		t.Errorf("Expected working vehicle to be 'busy', got '%s'", working.Status)
+	}
+
+	// Conditional location-only updates restore the status too
+	versioned, _ := vehicleStorage.GetVehicle(ctx, "versioned")
+	updated, err := fleetService.UpdateVehicleLocationAndStatusIfMatch(ctx, "versioned", 37.78, -122.41, "", versioned.Version)
+	if err != nil {
+		t.Fatalf("Failed to update location: %v", err)
+	}
+	if updated.Status != "available" {
+		t.Errorf("Expected 'available', got '%s'", updated.Status)
+	}
+
+	if len(publisher.events) != 3 {
+		t.Errorf("Expected 3 back-online events, got %v", publisher.events)
+	}
+}
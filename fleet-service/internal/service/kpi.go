@@ -0,0 +1,539 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"fleet-service/internal/metrics"
+	"fleet-service/internal/storage"
+)
+
+// DefaultKPIWindow is how far back fleet KPIs go when no start is given
+const DefaultKPIWindow = 24 * time.Hour
+
+// kpiQueueSize is how many KPI updates can wait to be written before more are dropped
+const kpiQueueSize = 1024
+
+// ErrInvalidKPIWindow is returned for KPI windows that end before they start
+var ErrInvalidKPIWindow = errors.New("invalid KPI window")
+
+// errKPIUntracked is returned when a partial write is seen for a vehicle the tracker
+// has no state for yet
+var errKPIUntracked = errors.New("vehicle KPIs not tracked yet")
+
+// KPIQuery selects the window fleet KPIs cover. Buckets are hourly, so the window is
+// widened to whole hours.
+type KPIQuery struct {
+	From   time.Time // zero for DefaultKPIWindow before To
+	To     time.Time // zero for now
+	Region string    // empty for every region
+}
+
+// KPISummary measures how a vehicle, or the whole fleet, spent a window
+type KPISummary struct {
+	// Hours spent in each status: available, busy, charging, maintenance, offline
+	StatusHours map[string]float64 `json:"status_hours"`
+	OnlineHours float64            `json:"online_hours"` // hours in any status but offline
+	// Share of online hours spent busy with jobs
+	Utilization float64 `json:"utilization"`
+
+	// Kilometres driven with riders or orders on board, and without
+	RevenueKm     float64 `json:"revenue_km"`
+	DeadheadKm    float64 `json:"deadhead_km"`
+	DeadheadRatio float64 `json:"deadhead_ratio"` // share of all kilometres driven empty
+
+	CompletedJobs     int     `json:"completed_jobs"`
+	JobsPerHour       float64 `json:"jobs_per_hour"`        // per hour of the window so far
+	JobsPerOnlineHour float64 `json:"jobs_per_online_hour"` // per hour vehicles were online
+}
+
+// VehicleKPIs are the KPIs of one vehicle
+type VehicleKPIs struct {
+	VehicleID string `json:"vehicle_id"`
+	Region    string `json:"region"`
+	KPISummary
+}
+
+// FleetKPIReport is the KPIs of the fleet over a window, in total and per vehicle
+type FleetKPIReport struct {
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Region   string    `json:"region,omitempty"`
+	Vehicles int       `json:"vehicles"`
+	KPISummary
+	ByVehicle []VehicleKPIs `json:"by_vehicle"`
+}
+
+// kpiObservation is what a write to storage revealed about a vehicle
+type kpiObservation struct {
+	region      string // empty for a partial write
+	status      string // empty when unchanged
+	hasLocation bool
+	lat, lng    float64
+	hasJob      *bool // nil when unchanged
+}
+
+// kpiBucketKey identifies one vehicle's hour of KPIs
+type kpiBucketKey struct {
+	vehicleID string
+	hour      int64 // Unix time of the start of the hour
+}
+
+// kpiDeltas collects the amounts a change adds to a vehicle's hourly buckets
+type kpiDeltas map[kpiBucketKey]*storage.KPIBucket
+
+// bucket returns the delta for the hour at falls in, creating it if needed
+func (d kpiDeltas) bucket(vehicleID, region string, at time.Time) *storage.KPIBucket {
+	hour := at.Truncate(time.Hour)
+	key := kpiBucketKey{vehicleID: vehicleID, hour: hour.Unix()}
+	bucket, exists := d[key]
+	if !exists {
+		bucket = &storage.KPIBucket{Hour: hour, VehicleID: vehicleID, StatusSeconds: make(map[string]float64)}
+		d[key] = bucket
+	}
+	if region != "" {
+		bucket.Region = region
+	}
+	return bucket
+}
+
+// newest lists the deltas for the latest limit hours. A vehicle unseen for days accrues
+// more hours than one update can add to, and the oldest are dropped.
+func (d kpiDeltas) newest(limit int) []*storage.KPIBucket {
+	list := make([]*storage.KPIBucket, 0, len(d))
+	for _, delta := range d {
+		list = append(list, delta)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Hour.After(list[j].Hour)
+	})
+	if len(list) > limit {
+		list = list[:limit]
+	}
+	return list
+}
+
+// kpiUpdate is a KPI update waiting to be written
+type kpiUpdate struct {
+	ctx       context.Context
+	vehicleID string
+	apply     func(ctx context.Context)
+}
+
+// KPITracker derives fleet KPIs from the vehicle writes, pickups and drop-offs the fleet
+// service handles. Each vehicle's state and hourly buckets are kept in shared storage,
+// so every instance of the service adds to, and reports, the same KPIs. Updates are
+// written by a worker of the tracker's own, in the order they were made, so requests
+// never wait on KPI storage.
+type KPITracker struct {
+	store storage.KPIStorage
+	queue chan kpiUpdate
+}
+
+// NewKPITracker creates a tracker keeping KPIs in store, and starts its worker
+func NewKPITracker(store storage.KPIStorage) *KPITracker {
+	t := &KPITracker{
+		store: store,
+		queue: make(chan kpiUpdate, kpiQueueSize),
+	}
+	go t.run()
+	return t
+}
+
+// enqueue hands apply to the worker. It runs with ctx's values, such as the request's
+// trace, but not its cancellation. When the worker has fallen kpiQueueSize updates
+// behind, the update is dropped and counted instead of holding up the request.
+func (t *KPITracker) enqueue(ctx context.Context, vehicleID string, apply func(ctx context.Context)) {
+	select {
+	case t.queue <- kpiUpdate{ctx: context.WithoutCancel(ctx), vehicleID: vehicleID, apply: apply}:
+	default:
+		metrics.KPIUpdatesDropped.Inc()
+		slog.Warn("Too many KPI updates waiting, dropping update", "vehicle_id", vehicleID)
+	}
+}
+
+// run writes queued updates until the process exits
+func (t *KPITracker) run() {
+	for update := range t.queue {
+		update.apply(update.ctx)
+	}
+}
+
+// flush waits until the updates queued so far have been written
+func (t *KPITracker) flush(ctx context.Context) error {
+	done := make(chan struct{})
+	select {
+	case t.queue <- kpiUpdate{ctx: ctx, apply: func(context.Context) { close(done) }}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// update applies change to a vehicle's KPI state, writing it together with the amounts
+// it accrued to the vehicle's buckets. The state is re-read and change applied again
+// when another instance wrote it first. Vehicles without a state get a new one if create is set, and
+// fail with errKPIUntracked otherwise.
+func (t *KPITracker) update(ctx context.Context, vehicleID string, create bool, change func(state *storage.KPIVehicleState, deltas kpiDeltas)) error {
+	for attempt := 1; ; attempt++ {
+		state, err := t.store.GetKPIState(ctx, vehicleID)
+		if errors.Is(err, storage.ErrKPIStateNotFound) {
+			if !create {
+				return errKPIUntracked
+			}
+			state = &storage.KPIVehicleState{VehicleID: vehicleID}
+		} else if err != nil {
+			return err
+		}
+
+		deltas := make(kpiDeltas)
+		change(state, deltas)
+
+		err = t.store.UpdateKPIs(ctx, state, deltas.newest(storage.MaxKPIBucketsPerUpdate))
+		if errors.Is(err, storage.ErrVersionConflict) && attempt < maxUpdateAttempts {
+			continue
+		}
+		return err
+	}
+}
+
+// observe applies what a write revealed about a vehicle, accruing the time it spent in
+// its previous status and the distance it drove since it was last seen. Only a whole
+// record, with its region, starts tracking a vehicle.
+func (t *KPITracker) observe(ctx context.Context, vehicleID string, obs kpiObservation, at time.Time) error {
+	return t.update(ctx, vehicleID, obs.region != "", func(state *storage.KPIVehicleState, deltas kpiDeltas) {
+		if state.Since.IsZero() {
+			state.Since = at
+		}
+		if obs.region != "" {
+			state.Region = obs.region
+		}
+
+		accrue(state, deltas, at)
+
+		if obs.hasLocation {
+			if state.HasLocation {
+				km := calculateDistance(state.Lat, state.Lng, obs.lat, obs.lng)
+				bucket := deltas.bucket(vehicleID, state.Region, at)
+				if len(state.OnBoard) > 0 {
+					bucket.RevenueKm += km
+				} else {
+					bucket.DeadheadKm += km
+				}
+			}
+			state.HasLocation = true
+			state.Lat, state.Lng = obs.lat, obs.lng
+		}
+		if obs.status != "" {
+			state.Status = obs.status
+		}
+		if obs.hasJob != nil && !*obs.hasJob {
+			state.OnBoard = nil
+		}
+	})
+}
+
+// recordPickup notes that a job's rider or order is on board a vehicle, so the distance
+// it drives until the drop-off earns revenue
+func (t *KPITracker) recordPickup(ctx context.Context, vehicleID, jobID string) error {
+	err := t.update(ctx, vehicleID, false, func(state *storage.KPIVehicleState, deltas kpiDeltas) {
+		for _, onBoard := range state.OnBoard {
+			if onBoard == jobID {
+				return
+			}
+		}
+		state.OnBoard = append(state.OnBoard, jobID)
+	})
+	if errors.Is(err, errKPIUntracked) {
+		return nil
+	}
+	return err
+}
+
+// recordDropoff notes that a vehicle finished with a job, counting it if it was
+// completed. An empty jobID finishes all of the vehicle's jobs.
+func (t *KPITracker) recordDropoff(ctx context.Context, vehicleID, jobID string, completed bool, at time.Time) error {
+	err := t.update(ctx, vehicleID, false, func(state *storage.KPIVehicleState, deltas kpiDeltas) {
+		onBoard := state.OnBoard[:0]
+		for _, id := range state.OnBoard {
+			if jobID != "" && id != jobID {
+				onBoard = append(onBoard, id)
+			}
+		}
+		state.OnBoard = onBoard
+		if completed {
+			deltas.bucket(vehicleID, state.Region, at).CompletedJobs++
+		}
+	})
+	if errors.Is(err, errKPIUntracked) {
+		return nil
+	}
+	return err
+}
+
+// accrue adds the time a vehicle spent in its status up to at to deltas, split across
+// the hours it spanned
+func accrue(state *storage.KPIVehicleState, deltas kpiDeltas, at time.Time) {
+	if state.Status != "" {
+		for start := state.Since; start.Before(at); {
+			end := start.Truncate(time.Hour).Add(time.Hour)
+			if end.After(at) {
+				end = at
+			}
+			deltas.bucket(state.VehicleID, state.Region, start).StatusSeconds[state.Status] += end.Sub(start).Seconds()
+			start = end
+		}
+	}
+	if at.After(state.Since) {
+		state.Since = at
+	}
+}
+
+// report totals the KPIs of the hours in [from, to), in a region if one is given
+func (t *KPITracker) report(ctx context.Context, from, to time.Time, region string, now time.Time) (*FleetKPIReport, error) {
+	stored, err := t.store.GetKPIBuckets(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	states, err := t.store.GetKPIStates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make(kpiDeltas, len(stored))
+	for _, bucket := range stored {
+		buckets.bucket(bucket.VehicleID, bucket.Region, bucket.Hour).Add(bucket)
+	}
+	// Count the time vehicles have spent in their current status so far, without
+	// storing it
+	current := make(kpiDeltas)
+	for _, state := range states {
+		accrue(state, current, now)
+	}
+	for _, bucket := range current {
+		if !bucket.Hour.Before(from) && bucket.Hour.Before(to) {
+			buckets.bucket(bucket.VehicleID, bucket.Region, bucket.Hour).Add(bucket)
+		}
+	}
+
+	byVehicle := make(map[string]*VehicleKPIs)
+	fleet := newKPISummary()
+	for _, bucket := range buckets {
+		if region != "" && bucket.Region != region {
+			continue
+		}
+
+		vehicle, exists := byVehicle[bucket.VehicleID]
+		if !exists {
+			vehicle = &VehicleKPIs{VehicleID: bucket.VehicleID, Region: bucket.Region, KPISummary: newKPISummary()}
+			byVehicle[bucket.VehicleID] = vehicle
+		}
+		vehicle.add(bucket)
+		fleet.add(bucket)
+	}
+
+	// Jobs per hour only counts the part of the window that has passed
+	elapsed := to
+	if now.Before(elapsed) {
+		elapsed = now
+	}
+	windowHours := elapsed.Sub(from).Hours()
+
+	report := &FleetKPIReport{
+		From:       from,
+		To:         to,
+		Region:     region,
+		Vehicles:   len(byVehicle),
+		KPISummary: fleet,
+		ByVehicle:  make([]VehicleKPIs, 0, len(byVehicle)),
+	}
+	report.finish(windowHours)
+	for _, vehicle := range byVehicle {
+		vehicle.finish(windowHours)
+		report.ByVehicle = append(report.ByVehicle, *vehicle)
+	}
+	sort.Slice(report.ByVehicle, func(i, j int) bool {
+		return report.ByVehicle[i].VehicleID < report.ByVehicle[j].VehicleID
+	})
+
+	return report, nil
+}
+
+// newKPISummary creates an empty summary
+func newKPISummary() KPISummary {
+	return KPISummary{StatusHours: make(map[string]float64)}
+}
+
+// add adds an hour's bucket to the summary's totals
+func (s *KPISummary) add(bucket *storage.KPIBucket) {
+	for status, seconds := range bucket.StatusSeconds {
+		s.StatusHours[status] += seconds / 3600
+	}
+	s.RevenueKm += bucket.RevenueKm
+	s.DeadheadKm += bucket.DeadheadKm
+	s.CompletedJobs += bucket.CompletedJobs
+}
+
+// finish works out the summary's ratios from its totals
+func (s *KPISummary) finish(windowHours float64) {
+	for status, hours := range s.StatusHours {
+		if status != StatusOffline {
+			s.OnlineHours += hours
+		}
+	}
+	if s.OnlineHours > 0 {
+		s.Utilization = s.StatusHours["busy"] / s.OnlineHours
+		s.JobsPerOnlineHour = float64(s.CompletedJobs) / s.OnlineHours
+	}
+	if totalKm := s.RevenueKm + s.DeadheadKm; totalKm > 0 {
+		s.DeadheadRatio = s.DeadheadKm / totalKm
+	}
+	if windowHours > 0 {
+		s.JobsPerHour = float64(s.CompletedJobs) / windowHours
+	}
+}
+
+// GetFleetKPIs reports how the fleet spent a window, per vehicle and in total
+func (f *FleetService) GetFleetKPIs(ctx context.Context, query KPIQuery) (*FleetKPIReport, error) {
+	now := time.Now()
+	to := query.To
+	if to.IsZero() {
+		to = now
+	}
+	from := query.From
+	if from.IsZero() {
+		from = to.Add(-DefaultKPIWindow)
+	}
+
+	// Widen the window to whole hours
+	from = from.Truncate(time.Hour)
+	if rounded := to.Truncate(time.Hour); rounded.Before(to) {
+		to = rounded.Add(time.Hour)
+	} else {
+		to = rounded
+	}
+	if !from.Before(to) {
+		return nil, fmt.Errorf("%w: window ends before it starts", ErrInvalidKPIWindow)
+	}
+
+	// Include what this instance has seen but not yet written
+	if err := f.kpis.flush(ctx); err != nil {
+		return nil, err
+	}
+
+	return f.kpis.report(ctx, from, to, query.Region, now)
+}
+
+// FlushKPIs waits until the KPI updates made so far have been written, such as before
+// the service stops
+func (f *FleetService) FlushKPIs(ctx context.Context) error {
+	return f.kpis.flush(ctx)
+}
+
+// kpiStorage passes vehicle writes on to the underlying storage, showing the KPI
+// tracker each one that succeeds
+type kpiStorage struct {
+	storage.VehicleStorage
+	kpis *KPITracker
+}
+
+func (s *kpiStorage) CreateVehicle(ctx context.Context, vehicle *storage.Vehicle) error {
+	if err := s.VehicleStorage.CreateVehicle(ctx, vehicle); err != nil {
+		return err
+	}
+	s.observeVehicle(ctx, vehicle)
+	return nil
+}
+
+func (s *kpiStorage) UpdateVehicle(ctx context.Context, vehicle *storage.Vehicle) error {
+	if err := s.VehicleStorage.UpdateVehicle(ctx, vehicle); err != nil {
+		return err
+	}
+	s.observeVehicle(ctx, vehicle)
+	return nil
+}
+
+func (s *kpiStorage) UpdateVehicleLocationAndStatus(ctx context.Context, vehicleID string, lat, lng float64, status string) error {
+	if err := s.VehicleStorage.UpdateVehicleLocationAndStatus(ctx, vehicleID, lat, lng, status); err != nil {
+		return err
+	}
+	s.observe(ctx, vehicleID, kpiObservation{status: status, hasLocation: true, lat: lat, lng: lng})
+	return nil
+}
+
+func (s *kpiStorage) UpdateVehicleLocation(ctx context.Context, vehicleID string, lat, lng float64) error {
+	if err := s.VehicleStorage.UpdateVehicleLocation(ctx, vehicleID, lat, lng); err != nil {
+		return err
+	}
+	s.observe(ctx, vehicleID, kpiObservation{hasLocation: true, lat: lat, lng: lng})
+	return nil
+}
+
+func (s *kpiStorage) UpdateVehicleStatus(ctx context.Context, vehicleID string, status string, jobID *string) error {
+	if err := s.VehicleStorage.UpdateVehicleStatus(ctx, vehicleID, status, jobID); err != nil {
+		return err
+	}
+	hasJob := jobID != nil
+	s.observe(ctx, vehicleID, kpiObservation{status: status, hasJob: &hasJob})
+	return nil
+}
+
+func (s *kpiStorage) AssignJobIfAvailable(ctx context.Context, vehicleID, jobID string) error {
+	if err := s.VehicleStorage.AssignJobIfAvailable(ctx, vehicleID, jobID); err != nil {
+		return err
+	}
+	hasJob := true
+	s.observe(ctx, vehicleID, kpiObservation{status: "busy", hasJob: &hasJob})
+	return nil
+}
+
+// observe shows the tracker a partial write. A vehicle the tracker hasn't seen yet,
+// such as one registered before KPIs were tracked, is read back whole instead.
+func (s *kpiStorage) observe(ctx context.Context, vehicleID string, obs kpiObservation) {
+	at := time.Now()
+	s.kpis.enqueue(ctx, vehicleID, func(ctx context.Context) {
+		err := s.kpis.observe(ctx, vehicleID, obs, at)
+		if errors.Is(err, errKPIUntracked) {
+			var vehicle *storage.Vehicle
+			if vehicle, err = s.VehicleStorage.GetVehicle(ctx, vehicleID); err == nil {
+				err = s.kpis.observe(ctx, vehicleID, vehicleObservation(vehicle), at)
+			}
+		}
+		if err != nil {
+			slog.Warn("Failed to update vehicle KPIs", "vehicle_id", vehicleID, "error", err)
+		}
+	})
+}
+
+// observeVehicle shows the tracker a vehicle's whole record
+func (s *kpiStorage) observeVehicle(ctx context.Context, vehicle *storage.Vehicle) {
+	vehicleID, obs, at := vehicle.ID, vehicleObservation(vehicle), time.Now()
+	s.kpis.enqueue(ctx, vehicleID, func(ctx context.Context) {
+		if err := s.kpis.observe(ctx, vehicleID, obs, at); err != nil {
+			slog.Warn("Failed to update vehicle KPIs", "vehicle_id", vehicleID, "error", err)
+		}
+	})
+}
+
+// vehicleObservation is what a vehicle's whole record shows the tracker
+func vehicleObservation(vehicle *storage.Vehicle) kpiObservation {
+	hasJob := vehicle.CurrentJobID != nil && *vehicle.CurrentJobID != ""
+	return kpiObservation{
+		region:      vehicle.Region,
+		status:      vehicle.Status,
+		hasLocation: vehicle.LocationLat != 0 || vehicle.LocationLng != 0, // unset until it reports
+		lat:         vehicle.LocationLat,
+		lng:         vehicle.LocationLng,
+		hasJob:      &hasJob,
+	}
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"fleet-service/internal/storage"
+)
+
+func TestKPITracker_StatusHoursAcrossHours(t *testing.T) {
+	tracker := NewKPITracker(storage.NewMemoryKPIStorage())
+	ctx := context.Background()
+	start := time.Date(2026, 10, 1, 9, 30, 0, 0, time.UTC)
+	busy, idle := true, false
+
+	tracker.observe(ctx, "v1", kpiObservation{region: "us-west-2", status: "available", hasJob: &idle}, start)
+	tracker.observe(ctx, "v1", kpiObservation{status: "busy", hasJob: &busy}, start.Add(45*time.Minute))
+	tracker.observe(ctx, "v1", kpiObservation{status: "charging", hasJob: &idle}, start.Add(105*time.Minute))
+
+	report, err := tracker.report(ctx, start.Truncate(time.Hour), start.Add(3*time.Hour), "", start.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// 45 minutes available, an hour busy from 10:15, then 15 minutes charging until now
+	if len(report.ByVehicle) != 1 {
+		t.Fatalf("Expected one vehicle, got %+v", report.ByVehicle)
+	}
+	hours := report.ByVehicle[0].StatusHours
+	if hours["available"] != 0.75 || hours["busy"] != 1 || hours["charging"] != 0.25 {
+		t.Errorf("Expected 0.75h available, 1h busy and 0.25h charging, got %v", hours)
+	}
+	if report.OnlineHours != 2 || report.Utilization != 0.5 {
+		t.Errorf("Expected 2 online hours half busy, got %.2f at %.2f", report.OnlineHours, report.Utilization)
+	}
+
+	// Only the 10:00 hour
+	later, _ := tracker.report(ctx, start.Add(30*time.Minute), start.Add(90*time.Minute), "", start.Add(2*time.Hour))
+	if later.StatusHours["available"] != 0.25 || later.StatusHours["busy"] != 0.75 {
+		t.Errorf("Expected 0.25h available and 0.75h busy from 10:00, got %v", later.StatusHours)
+	}
+}
+
+func TestKPITracker_RevenueAndDeadheadKm(t *testing.T) {
+	tracker := NewKPITracker(storage.NewMemoryKPIStorage())
+	ctx := context.Background()
+	at := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	busy, idle := true, false
+	move := func(lat float64) {
+		at = at.Add(time.Minute)
+		tracker.observe(ctx, "v1", kpiObservation{hasLocation: true, lat: lat, lng: -122.60}, at)
+	}
+
+	tracker.observe(ctx, "v1", kpiObservation{region: "us-west-2", status: "available", hasLocation: true, lat: 45.50, lng: -122.60, hasJob: &idle}, at)
+	// Repositioning, then driving to the pickup
+	move(45.51)
+	tracker.observe(ctx, "v1", kpiObservation{status: "busy", hasJob: &busy}, at)
+	move(45.52)
+	// Carrying the rider to the drop-off
+	tracker.recordPickup(ctx, "v1", "job-1")
+	move(45.54)
+	tracker.recordDropoff(ctx, "v1", "job-1", true, at)
+	tracker.observe(ctx, "v1", kpiObservation{status: "available", hasJob: &idle}, at)
+	move(45.55)
+
+	report, err := tracker.report(ctx, at.Truncate(time.Hour), at.Truncate(time.Hour).Add(time.Hour), "", at)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	perDegree := calculateDistance(45.50, -122.60, 45.51, -122.60)
+	if math.Abs(report.RevenueKm-2*perDegree) > 1e-6 || math.Abs(report.DeadheadKm-3*perDegree) > 1e-6 {
+		t.Errorf("Expected %.2fkm loaded and %.2fkm empty, got %.2f and %.2f", 2*perDegree, 3*perDegree, report.RevenueKm, report.DeadheadKm)
+	}
+	if math.Abs(report.DeadheadRatio-0.6) > 1e-6 {
+		t.Errorf("Expected a 0.6 deadhead ratio, got %.2f", report.DeadheadRatio)
+	}
+	if report.CompletedJobs != 1 || report.JobsPerHour <= 0 {
+		t.Errorf("Expected one completed job, got %d at %.2f an hour", report.CompletedJobs, report.JobsPerHour)
+	}
+}
+
+func TestKPITracker_SharedStoreAcrossInstances(t *testing.T) {
+	store := storage.NewMemoryKPIStorage()
+	first, second := NewKPITracker(store), NewKPITracker(store)
+	ctx := context.Background()
+	at := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	busy, idle := true, false
+
+	// Each replica behind the load balancer handles some of the vehicle's reports
+	first.observe(ctx, "v1", kpiObservation{region: "us-west-2", status: "available", hasLocation: true, lat: 45.50, lng: -122.60, hasJob: &idle}, at)
+	second.observe(ctx, "v1", kpiObservation{hasLocation: true, lat: 45.51, lng: -122.60}, at.Add(10*time.Minute))
+	first.observe(ctx, "v1", kpiObservation{status: "busy", hasJob: &busy}, at.Add(15*time.Minute))
+	second.recordPickup(ctx, "v1", "job-1")
+	first.observe(ctx, "v1", kpiObservation{hasLocation: true, lat: 45.53, lng: -122.60}, at.Add(30*time.Minute))
+	second.recordDropoff(ctx, "v1", "job-1", true, at.Add(30*time.Minute))
+	second.observe(ctx, "v1", kpiObservation{status: "available", hasJob: &idle}, at.Add(30*time.Minute))
+
+	perDegree := calculateDistance(45.50, -122.60, 45.51, -122.60)
+	for name, tracker := range map[string]*KPITracker{"first": first, "second": second} {
+		report, err := tracker.report(ctx, at, at.Add(time.Hour), "", at.Add(time.Hour))
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", name, err)
+		}
+		hours := report.StatusHours
+		if math.Abs(hours["available"]-0.75) > 1e-9 || math.Abs(hours["busy"]-0.25) > 1e-9 {
+			t.Errorf("%s: expected 0.75h available and 0.25h busy, got %v", name, hours)
+		}
+		if math.Abs(report.RevenueKm-2*perDegree) > 1e-6 || math.Abs(report.DeadheadKm-perDegree) > 1e-6 {
+			t.Errorf("%s: expected %.2fkm loaded and %.2fkm empty, got %.2f and %.2f", name, 2*perDegree, perDegree, report.RevenueKm, report.DeadheadKm)
+		}
+		if report.CompletedJobs != 1 {
+			t.Errorf("%s: expected one completed job, got %d", name, report.CompletedJobs)
+		}
+	}
+}
+
+func TestFleetService_GetFleetKPIs(t *testing.T) {
+	vehicleStorage := storage.NewMemoryVehicleStorage()
+	fleetService := NewFleetService(vehicleStorage)
+	ctx := context.Background()
+
+	for _, vehicle := range []*storage.Vehicle{
+		{ID: "v1", Region: "us-west-2", Status: "available", BatteryRangeKm: 200, LocationLat: 45.50, LocationLng: -122.60, VehicleType: "sedan"},
+		{ID: "v2", Region: "eu-west-1", Status: "available", BatteryRangeKm: 200, LocationLat: 53.35, LocationLng: -6.26, VehicleType: "sedan"},
+	} {
+		if err := fleetService.RegisterVehicle(ctx, vehicle); err != nil {
+			t.Fatalf("Failed to register vehicle: %v", err)
+		}
+	}
+
+	// v1 drives to a pickup, then carries the rider to the drop-off
+	if err := fleetService.AssignJob(ctx, "v1", "job-1"); err != nil {
+		t.Fatalf("Failed to assign job: %v", err)
+	}
+	fleetService.UpdateVehicleLocation(ctx, "v1", 45.51, -122.60)
+	if err := fleetService.RecordPickup(ctx, "v1", "job-1"); err != nil {
+		t.Fatalf("Failed to record pickup: %v", err)
+	}
+	fleetService.UpdateVehicleLocation(ctx, "v1", 45.53, -122.60)
+	if err := fleetService.CompleteVehicleJob(ctx, "v1", "job-1"); err != nil {
+		t.Fatalf("Failed to complete job: %v", err)
+	}
+
+	report, err := fleetService.GetFleetKPIs(ctx, KPIQuery{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if report.Vehicles != 2 || report.CompletedJobs != 1 {
+		t.Fatalf("Expected 2 vehicles and 1 completed job, got %d and %d", report.Vehicles, report.CompletedJobs)
+	}
+	v1 := report.ByVehicle[0]
+	if v1.VehicleID != "v1" || v1.RevenueKm <= v1.DeadheadKm || v1.DeadheadKm <= 0 {
+		t.Errorf("Expected v1 to drive further loaded than empty, got %+v", v1)
+	}
+	if v1.StatusHours["busy"] <= 0 {
+		t.Errorf("Expected time busy recorded, got %v", v1.StatusHours)
+	}
+
+	regional, _ := fleetService.GetFleetKPIs(ctx, KPIQuery{Region: "eu-west-1"})
+	if regional.Vehicles != 1 || regional.ByVehicle[0].VehicleID != "v2" || regional.CompletedJobs != 0 {
+		t.Errorf("Expected just v2 in eu-west-1, got %+v", regional.ByVehicle)
+	}
+
+	if _, err := fleetService.GetFleetKPIs(ctx, KPIQuery{From: time.Now(), To: time.Now().Add(-2 * time.Hour)}); !errors.Is(err, ErrInvalidKPIWindow) {
+		t.Errorf("Expected ErrInvalidKPIWindow, got %v", err)
+	}
+}
+
+func TestKPITracker_VehicleUnseenForDays(t *testing.T) {
+	tracker := NewKPITracker(storage.NewMemoryKPIStorage())
+	ctx := context.Background()
+	start := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	back := start.Add(5 * 24 * time.Hour)
+
+	tracker.observe(ctx, "v1", kpiObservation{region: "us-west-2", status: "charging"}, start)
+	// More hours than one update can add to
+	if err := tracker.observe(ctx, "v1", kpiObservation{status: "available"}, back); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	report, _ := tracker.report(ctx, back.Add(-time.Hour), back, "", back)
+	if report.StatusHours["charging"] != 1 {
+		t.Errorf("Expected the last hour charging, got %v", report.StatusHours)
+	}
+}
+
+// blockingKPIStorage holds up every update until release is closed
+type blockingKPIStorage struct {
+	storage.KPIStorage
+	release chan struct{}
+}
+
+func (b *blockingKPIStorage) UpdateKPIs(ctx context.Context, state *storage.KPIVehicleState, deltas []*storage.KPIBucket) error {
+	<-b.release
+	return b.KPIStorage.UpdateKPIs(ctx, state, deltas)
+}
+
+func TestFleetService_KPIsWrittenOffRequestPath(t *testing.T) {
+	fleetService := NewFleetService(storage.NewMemoryVehicleStorage())
+	store := &blockingKPIStorage{KPIStorage: storage.NewMemoryKPIStorage(), release: make(chan struct{})}
+	fleetService.SetKPIStorage(store)
+	ctx := context.Background()
+
+	registered := make(chan error, 1)
+	go func() {
+		registered <- fleetService.RegisterVehicle(ctx, &storage.Vehicle{ID: "v1", Region: "us-west-2", Status: "available", BatteryRangeKm: 200, VehicleType: "sedan"})
+	}()
+	select {
+	case err := <-registered:
+		if err != nil {
+			t.Fatalf("Failed to register vehicle: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected registering not to wait on KPI storage")
+	}
+
+	// Reports wait for what was already seen
+	close(store.release)
+	report, err := fleetService.GetFleetKPIs(ctx, KPIQuery{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if report.Vehicles != 1 {
+		t.Errorf("Expected the registered vehicle, got %+v", report.ByVehicle)
+	}
+}
@@ -0,0 +1,463 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"fleet-service/internal/storage"
+	"shared/routing"
+	"shared/tracing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Stop types in a vehicle's stop plan
+const (
+	StopTypePickup  = "pickup"
+	StopTypeDropoff = "dropoff"
+)
+
+const (
+	// DefaultSeatCapacity is assumed for vehicles that did not register a seat count
+	DefaultSeatCapacity = 4
+	// DefaultMaxPoolDetour is how much later a shared ride may reach its drop-off
+	// because of other riders picked up or dropped off on the way
+	DefaultMaxPoolDetour = 10 * time.Minute
+
+	// maxPoolPickupWait bounds how long a rider joining a shared vehicle waits for it
+	maxPoolPickupWait = 15 * time.Minute
+	// poolSearchRings bounds the cell search for shared vehicles around a pickup; a
+	// vehicle further away would rarely be worth the detour for its riders
+	poolSearchRings = 3
+	// maxPoolAttempts bounds how often JoinPool searches again after losing a race
+	maxPoolAttempts = 3
+)
+
+var (
+	// ErrNoPoolMatch is returned when no shared vehicle can take a ride within the detour limit
+	ErrNoPoolMatch = errors.New("no shared vehicle can take the ride")
+	// ErrInvalidSharedRide is returned for shared ride requests that cannot be planned
+	ErrInvalidSharedRide = errors.New("invalid shared ride")
+	// ErrInvalidBatch is returned for delivery batches whose stop plan cannot be driven
+	ErrInvalidBatch = errors.New("invalid delivery batch")
+)
+
+// SharedRide is a ride whose rider agreed to share the vehicle with others
+type SharedRide struct {
+	JobID      string  `json:"job_id"`
+	Region     string  `json:"region"`
+	PickupLat  float64 `json:"pickup_lat"`
+	PickupLng  float64 `json:"pickup_lng"`
+	DropoffLat float64 `json:"dropoff_lat"`
+	DropoffLng float64 `json:"dropoff_lng"`
+	Seats      int     `json:"seats"`
+}
+
+// stops returns the ride's pickup and drop-off as plan entries
+func (r SharedRide) stops() (pickup, dropoff storage.PlannedStop) {
+	pickup = storage.PlannedStop{JobID: r.JobID, Type: StopTypePickup, Lat: r.PickupLat, Lng: r.PickupLng, Seats: r.Seats}
+	dropoff = storage.PlannedStop{JobID: r.JobID, Type: StopTypeDropoff, Lat: r.DropoffLat, Lng: r.DropoffLng, Seats: r.Seats}
+	return pickup, dropoff
+}
+
+// validate checks a shared ride request before planning it
+func (r SharedRide) validate() error {
+	if r.JobID == "" || r.Region == "" {
+		return fmt.Errorf("%w: job ID and region are required", ErrInvalidSharedRide)
+	}
+	if r.Seats < 1 {
+		return fmt.Errorf("%w: %d seats requested", ErrInvalidSharedRide, r.Seats)
+	}
+	return nil
+}
+
+// SetMaxPoolDetour sets how much later a shared ride may arrive because of other riders
+func (f *FleetService) SetMaxPoolDetour(maxDetour time.Duration) {
+	f.maxPoolDetour = maxDetour
+}
+
+// StartPool claims an available vehicle for the first ride of a new pool, starting its
+// stop plan so later shared rides can join it. It fails with storage.ErrVehicleNotAvailable
+// if the vehicle was already claimed.
+func (f *FleetService) StartPool(ctx context.Context, vehicleID string, ride SharedRide) (*storage.Vehicle, error) {
+	trace.SpanFromContext(ctx).SetAttributes(tracing.VehicleID(vehicleID), tracing.JobID(ride.JobID))
+	if err := ride.validate(); err != nil {
+		return nil, err
+	}
+
+	current, err := f.storage.GetVehicle(ctx, vehicleID)
+	if err != nil {
+		return nil, err
+	}
+
+	if current.Status != "available" || (current.CurrentJobID != nil && *current.CurrentJobID != "") || ride.Seats > seatCapacity(current) {
+		return nil, storage.ErrVehicleNotAvailable
+	}
+
+	pickup, dropoff := ride.stops()
+	claimed := *current
+	claimed.Status = "busy"
+	claimed.CurrentJobID = &ride.JobID
+	claimed.StopPlan = []storage.PlannedStop{pickup, dropoff}
+
+	if err := f.storage.UpdateVehicle(ctx, &claimed); err != nil {
+		if errors.Is(err, storage.ErrVersionConflict) {
+			// Someone else wrote the vehicle since we read it, most likely another dispatcher
+			return nil, storage.ErrVehicleNotAvailable
+		}
+		return nil, err
+	}
+
+	return &claimed, nil
+}
+
+// StartBatch claims an available vehicle for a batch of deliveries, visiting the stops
+// in the order given. Orders take no seats, so shared rides never join a batch. It fails
+// with storage.ErrVehicleNotAvailable if the vehicle was already claimed.
+func (f *FleetService) StartBatch(ctx context.Context, vehicleID string, stops []storage.PlannedStop) (*storage.Vehicle, error) {
+	if err := validateBatch(stops); err != nil {
+		return nil, err
+	}
+
+	current, err := f.storage.GetVehicle(ctx, vehicleID)
+	if err != nil {
+		return nil, err
+	}
+
+	if current.Status != "available" || (current.CurrentJobID != nil && *current.CurrentJobID != "") {
+		return nil, storage.ErrVehicleNotAvailable
+	}
+
+	claimed := *current
+	claimed.Status = "busy"
+	claimed.CurrentJobID = &stops[0].JobID
+	claimed.StopPlan = make([]storage.PlannedStop, len(stops))
+	for i, stop := range stops {
+		stop.Seats = 0
+		claimed.StopPlan[i] = stop
+	}
+
+	if err := f.storage.UpdateVehicle(ctx, &claimed); err != nil {
+		if errors.Is(err, storage.ErrVersionConflict) {
+			return nil, storage.ErrVehicleNotAvailable
+		}
+		return nil, err
+	}
+
+	slog.Info("Delivery batch started",
+		"vehicle_id", vehicleID,
+		"stops", len(claimed.StopPlan))
+	return &claimed, nil
+}
+
+// validateBatch checks every order in a batch is picked up once and then dropped off once
+func validateBatch(stops []storage.PlannedStop) error {
+	if len(stops) == 0 {
+		return fmt.Errorf("%w: no stops", ErrInvalidBatch)
+	}
+
+	pickedUp := make(map[string]bool)
+	droppedOff := make(map[string]bool)
+	for _, stop := range stops {
+		switch {
+		case stop.JobID == "":
+			return fmt.Errorf("%w: stop without a job ID", ErrInvalidBatch)
+		case stop.Type == StopTypePickup && !pickedUp[stop.JobID] && !droppedOff[stop.JobID]:
+			pickedUp[stop.JobID] = true
+		case stop.Type == StopTypeDropoff && pickedUp[stop.JobID] && !droppedOff[stop.JobID]:
+			droppedOff[stop.JobID] = true
+		default:
+			return fmt.Errorf("%w: unexpected %s stop for job %s", ErrInvalidBatch, stop.Type, stop.JobID)
+		}
+	}
+
+	if len(droppedOff) != len(pickedUp) {
+		return fmt.Errorf("%w: every order needs a drop-off", ErrInvalidBatch)
+	}
+	return nil
+}
+
+// JoinPool adds a shared ride to the busy vehicle whose stop plan it fits into at the
+// lowest extra driving time, without any rider already on the plan arriving more than
+// the detour limit later than planned and without exceeding the vehicle's seats
+func (f *FleetService) JoinPool(ctx context.Context, ride SharedRide) (*storage.Vehicle, error) {
+	trace.SpanFromContext(ctx).SetAttributes(tracing.JobID(ride.JobID))
+	if err := ride.validate(); err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; attempt < maxPoolAttempts; attempt++ {
+		vehicles, err := f.poolCandidates(ctx, ride)
+		if err != nil {
+			return nil, err
+		}
+
+		var best *storage.Vehicle
+		var bestPlan []storage.PlannedStop
+		var bestCost time.Duration
+		for _, vehicle := range vehicles {
+			plan, cost, ok := f.planInsertion(ctx, vehicle, ride)
+			if ok && (best == nil || cost < bestCost) {
+				best, bestPlan, bestCost = vehicle, plan, cost
+			}
+		}
+
+		if best == nil {
+			return nil, ErrNoPoolMatch
+		}
+
+		joined := *best
+		joined.StopPlan = bestPlan
+		err = f.storage.UpdateVehicle(ctx, &joined)
+		if errors.Is(err, storage.ErrVersionConflict) {
+			// The vehicle moved on since we planned around it; plan again
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		slog.Info("Shared ride joined vehicle",
+			"vehicle_id", joined.ID,
+			"job_id", ride.JobID,
+			"stops", len(joined.StopPlan),
+			"added_minutes", bestCost.Minutes())
+		return &joined, nil
+	}
+
+	return nil, fmt.Errorf("failed to join a shared vehicle after %d attempts: %w", maxPoolAttempts, storage.ErrVersionConflict)
+}
+
+// RecordPickup removes a shared ride's pickup from its vehicle's stop plan once the
+// rider is on board. Rides that are not on a plan are left as they are, but every
+// pickup counts towards the vehicle's revenue kilometres.
+func (f *FleetService) RecordPickup(ctx context.Context, vehicleID, jobID string) error {
+	trace.SpanFromContext(ctx).SetAttributes(tracing.VehicleID(vehicleID), tracing.JobID(jobID))
+	err := f.updateVehicle(ctx, vehicleID, func(vehicle *storage.Vehicle) error {
+		if !holdsJob(vehicle, jobID) {
+			return ErrVehicleNotOnJob
+		}
+
+		plan := make([]storage.PlannedStop, 0, len(vehicle.StopPlan))
+		for _, stop := range vehicle.StopPlan {
+			if stop.JobID != jobID || stop.Type != StopTypePickup {
+				plan = append(plan, stop)
+			}
+		}
+		vehicle.StopPlan = plan
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Distance driven from here to the drop-off earns revenue
+	f.kpis.enqueue(ctx, vehicleID, func(ctx context.Context) {
+		if err := f.kpis.recordPickup(ctx, vehicleID, jobID); err != nil {
+			slog.Warn("Failed to record pickup for KPIs", "vehicle_id", vehicleID, "job_id", jobID, "error", err)
+		}
+	})
+	return nil
+}
+
+// poolCandidates finds busy vehicles near the pickup that are running a stop plan
+func (f *FleetService) poolCandidates(ctx context.Context, ride SharedRide) ([]*storage.Vehicle, error) {
+	var cells []string
+	for ring := 0; ring <= poolSearchRings; ring++ {
+		cells = append(cells, storage.CellRing(ride.PickupLat, ride.PickupLng, ring)...)
+	}
+
+	vehicles, err := f.storage.GetVehiclesByCells(ctx, ride.Region, "busy", cells)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []*storage.Vehicle
+	for _, vehicle := range vehicles {
+		// A vehicle without a plan is on a private ride, and one whose plan takes no
+		// seats is out on a delivery batch
+		if len(vehicle.StopPlan) > 0 && vehicle.StopPlan[0].Seats > 0 && !holdsJob(vehicle, ride.JobID) {
+			candidates = append(candidates, vehicle)
+		}
+	}
+	return candidates, nil
+}
+
+// planInsertion finds where in a vehicle's stop plan a ride's pickup and drop-off can go
+// at the least extra driving time, returning the new plan and the time it adds
+func (f *FleetService) planInsertion(ctx context.Context, vehicle *storage.Vehicle, ride SharedRide) ([]storage.PlannedStop, time.Duration, bool) {
+	pickup, dropoff := ride.stops()
+	existing := vehicle.StopPlan
+	n := len(existing)
+
+	// Point 0 is the vehicle, 1..n its planned stops, then the new pickup and drop-off
+	points := make([]routing.Point, 0, n+3)
+	points = append(points, routing.Point{Lat: vehicle.LocationLat, Lng: vehicle.LocationLng})
+	for _, stop := range existing {
+		points = append(points, routing.Point{Lat: stop.Lat, Lng: stop.Lng})
+	}
+	points = append(points, routing.Point{Lat: pickup.Lat, Lng: pickup.Lng}, routing.Point{Lat: dropoff.Lat, Lng: dropoff.Lng})
+
+	legs, err := f.router.Matrix(ctx, points, points)
+	if err != nil {
+		slog.Warn("Failed to route stop plan, using straight-line estimates", "vehicle_id", vehicle.ID, "error", err)
+		legs, _ = routing.NewHaversineRouter().Matrix(ctx, points, points)
+	}
+
+	planned := make([]int, n)
+	for i := range planned {
+		planned[i] = i + 1
+	}
+	before := walkPlan(legs, planned)
+
+	maxDetour := f.maxPoolDetour
+	direct := legs[n+1][n+2].Duration
+
+	var best []int
+	var bestCost time.Duration
+	for p := 0; p <= n; p++ {
+		for d := p; d <= n; d++ {
+			order := make([]int, 0, n+2)
+			order = append(order, planned[:p]...)
+			order = append(order, n+1)
+			order = append(order, planned[p:d]...)
+			order = append(order, n+2)
+			order = append(order, planned[d:]...)
+
+			if !fitsSeats(vehicle, existing, ride, order) {
+				continue
+			}
+
+			after := walkPlan(legs, order)
+			if !hasRange(vehicle, after.distanceKm, 0) {
+				continue
+			}
+
+			// The new rider neither waits too long nor rides far out of their way
+			if after.arrival[n+1] > maxPoolPickupWait || after.arrival[n+2]-after.arrival[n+1]-direct > maxDetour {
+				continue
+			}
+
+			// Nobody already on the plan arrives much later than they were told
+			acceptable := true
+			for i, stop := range existing {
+				if stop.Type == StopTypeDropoff && after.arrival[i+1]-before.arrival[i+1] > maxDetour {
+					acceptable = false
+					break
+				}
+			}
+			if !acceptable {
+				continue
+			}
+
+			if cost := after.duration - before.duration; best == nil || cost < bestCost {
+				best, bestCost = order, cost
+			}
+		}
+	}
+
+	if best == nil {
+		return nil, 0, false
+	}
+
+	plan := make([]storage.PlannedStop, len(best))
+	for i, point := range best {
+		switch point {
+		case n + 1:
+			plan[i] = pickup
+		case n + 2:
+			plan[i] = dropoff
+		default:
+			plan[i] = existing[point-1]
+		}
+	}
+	return plan, bestCost, true
+}
+
+// planWalk is the outcome of driving a stop plan from the vehicle's position
+type planWalk struct {
+	arrival    map[int]time.Duration // arrival time at each point, from now
+	duration   time.Duration
+	distanceKm float64
+}
+
+// walkPlan drives through the points in order, starting at point 0
+func walkPlan(legs [][]routing.Estimate, order []int) planWalk {
+	walk := planWalk{arrival: make(map[int]time.Duration, len(order))}
+	from := 0
+	for _, to := range order {
+		walk.duration += legs[from][to].Duration
+		walk.distanceKm += legs[from][to].DistanceKm
+		walk.arrival[to] = walk.duration
+		from = to
+	}
+	return walk
+}
+
+// fitsSeats reports whether the vehicle has a seat for every rider throughout a plan
+// of its existing stops plus the new ride, visiting points in order
+func fitsSeats(vehicle *storage.Vehicle, existing []storage.PlannedStop, ride SharedRide, order []int) bool {
+	n := len(existing)
+
+	// Riders with only their drop-off left are already on board
+	waiting := make(map[string]bool)
+	for _, stop := range existing {
+		if stop.Type == StopTypePickup {
+			waiting[stop.JobID] = true
+		}
+	}
+	load := 0
+	for _, stop := range existing {
+		if stop.Type == StopTypeDropoff && !waiting[stop.JobID] {
+			load += stop.Seats
+		}
+	}
+
+	capacity := seatCapacity(vehicle)
+	for _, point := range order {
+		var stopType string
+		var seats int
+		switch point {
+		case n + 1:
+			stopType, seats = StopTypePickup, ride.Seats
+		case n + 2:
+			stopType, seats = StopTypeDropoff, ride.Seats
+		default:
+			stopType, seats = existing[point-1].Type, existing[point-1].Seats
+		}
+
+		if stopType == StopTypePickup {
+			load += seats
+			if load > capacity {
+				return false
+			}
+		} else {
+			load -= seats
+		}
+	}
+	return true
+}
+
+// seatCapacity is how many riders a vehicle can carry at once
+func seatCapacity(vehicle *storage.Vehicle) int {
+	if vehicle.SeatCapacity > 0 {
+		return vehicle.SeatCapacity
+	}
+	return DefaultSeatCapacity
+}
+
+// holdsJob reports whether a vehicle is serving a job, alone or as one of its shared rides
+func holdsJob(vehicle *storage.Vehicle, jobID string) bool {
+	if vehicle.CurrentJobID != nil && *vehicle.CurrentJobID == jobID {
+		return true
+	}
+	for _, stop := range vehicle.StopPlan {
+		if stop.JobID == jobID {
+			return true
+		}
+	}
+	return false
+}
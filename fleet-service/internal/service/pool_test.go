@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"fleet-service/internal/storage"
+)
+
+// setupPool registers a vehicle heading north on a shared ride that is already on board
+func setupPool(t *testing.T, seatCapacity int) (*FleetService, *storage.MemoryVehicleStorage) {
+	vehicleStorage := storage.NewMemoryVehicleStorage()
+	fleetService := NewFleetService(vehicleStorage)
+	ctx := context.Background()
+
+	fleetService.RegisterVehicle(ctx, &storage.Vehicle{
+		ID:             "v1",
+		Region:         "us-west-2",
+		Status:         "available",
+		BatteryLevel:   80,
+		BatteryRangeKm: 200.0,
+		LocationLat:    45.500,
+		LocationLng:    -122.600,
+		VehicleType:    "sedan",
+		SeatCapacity:   seatCapacity,
+	})
+
+	ride := SharedRide{JobID: "job-a", Region: "us-west-2", PickupLat: 45.500, PickupLng: -122.600, DropoffLat: 45.540, DropoffLng: -122.600, Seats: 2}
+	if _, err := fleetService.StartPool(ctx, "v1", ride); err != nil {
+		t.Fatalf("Failed to start pool: %v", err)
+	}
+	if err := fleetService.RecordPickup(ctx, "v1", "job-a"); err != nil {
+		t.Fatalf("Failed to record pickup: %v", err)
+	}
+
+	return fleetService, vehicleStorage
+}
+
+func TestFleetService_StartPool(t *testing.T) {
+	fleetService, vehicleStorage := setupPool(t, 4)
+	ctx := context.Background()
+
+	vehicle, _ := vehicleStorage.GetVehicle(ctx, "v1")
+	if vehicle.Status != "busy" || vehicle.CurrentJobID == nil || *vehicle.CurrentJobID != "job-a" {
+		t.Errorf("Expected vehicle busy with job-a, got %s %v", vehicle.Status, vehicle.CurrentJobID)
+	}
+	if len(vehicle.StopPlan) != 1 || vehicle.StopPlan[0].Type != StopTypeDropoff {
+		t.Errorf("Expected only the drop-off left after pickup, got %+v", vehicle.StopPlan)
+	}
+
+	// A claimed vehicle can't start another pool
+	ride := SharedRide{JobID: "job-b", Region: "us-west-2", PickupLat: 45.5, PickupLng: -122.6, DropoffLat: 45.51, DropoffLng: -122.6, Seats: 1}
+	if _, err := fleetService.StartPool(ctx, "v1", ride); !errors.Is(err, storage.ErrVehicleNotAvailable) {
+		t.Errorf("Expected ErrVehicleNotAvailable, got %v", err)
+	}
+}
+
+func TestFleetService_JoinPool_InsertsRideOnTheWay(t *testing.T) {
+	fleetService, _ := setupPool(t, 4)
+	ctx := context.Background()
+
+	ride := SharedRide{JobID: "job-b", Region: "us-west-2", PickupLat: 45.510, PickupLng: -122.600, DropoffLat: 45.530, DropoffLng: -122.600, Seats: 1}
+	vehicle, err := fleetService.JoinPool(ctx, ride)
+	if err != nil {
+		t.Fatalf("Expected ride to join the pool, got %v", err)
+	}
+
+	expected := []struct{ jobID, stopType string }{
+		{"job-b", StopTypePickup},
+		{"job-b", StopTypeDropoff},
+		{"job-a", StopTypeDropoff},
+	}
+	if len(vehicle.StopPlan) != len(expected) {
+		t.Fatalf("Expected %d planned stops, got %+v", len(expected), vehicle.StopPlan)
+	}
+	for i, stop := range vehicle.StopPlan {
+		if stop.JobID != expected[i].jobID || stop.Type != expected[i].stopType {
+			t.Errorf("Expected stop %d to be %s %s, got %s %s", i, expected[i].jobID, expected[i].stopType, stop.JobID, stop.Type)
+		}
+	}
+}
+
+func TestFleetService_JoinPool_RejectsLongDetour(t *testing.T) {
+	fleetService, _ := setupPool(t, 4)
+	ctx := context.Background()
+
+	// Heading the other way would hold up the rider on board, or this one, far too long
+	ride := SharedRide{JobID: "job-b", Region: "us-west-2", PickupLat: 45.500, PickupLng: -122.600, DropoffLat: 45.400, DropoffLng: -122.600, Seats: 1}
+	if _, err := fleetService.JoinPool(ctx, ride); !errors.Is(err, ErrNoPoolMatch) {
+		t.Errorf("Expected ErrNoPoolMatch, got %v", err)
+	}
+
+	// A generous limit lets the ride in
+	fleetService.SetMaxPoolDetour(time.Hour)
+	if _, err := fleetService.JoinPool(ctx, ride); err != nil {
+		t.Errorf("Expected ride to join with a generous detour limit, got %v", err)
+	}
+}
+
+func TestFleetService_JoinPool_RespectsSeatCapacity(t *testing.T) {
+	ride := SharedRide{JobID: "job-b", Region: "us-west-2", PickupLat: 45.510, PickupLng: -122.600, DropoffLat: 45.530, DropoffLng: -122.600, Seats: 1}
+
+	fleetService, _ := setupPool(t, 2)
+	if _, err := fleetService.JoinPool(context.Background(), ride); !errors.Is(err, ErrNoPoolMatch) {
+		t.Errorf("Expected ErrNoPoolMatch with both seats taken, got %v", err)
+	}
+
+	fleetService, _ = setupPool(t, 3)
+	if _, err := fleetService.JoinPool(context.Background(), ride); err != nil {
+		t.Errorf("Expected ride to join with a seat free, got %v", err)
+	}
+}
+
+func TestFleetService_ReleaseVehicle_KeepsOtherSharedRiders(t *testing.T) {
+	fleetService, vehicleStorage := setupPool(t, 4)
+	ctx := context.Background()
+
+	ride := SharedRide{JobID: "job-b", Region: "us-west-2", PickupLat: 45.510, PickupLng: -122.600, DropoffLat: 45.530, DropoffLng: -122.600, Seats: 1}
+	if _, err := fleetService.JoinPool(ctx, ride); err != nil {
+		t.Fatalf("Failed to join pool: %v", err)
+	}
+
+	// Dropping off the first rider leaves the vehicle busy with the second
+	if err := fleetService.ReleaseVehicle(ctx, "v1", "job-a"); err != nil {
+		t.Fatalf("Failed to release job-a: %v", err)
+	}
+
+	vehicle, _ := vehicleStorage.GetVehicle(ctx, "v1")
+	if vehicle.Status != "busy" {
+		t.Errorf("Expected status 'busy', got '%s'", vehicle.Status)
+	}
+	if vehicle.CurrentJobID == nil || *vehicle.CurrentJobID != "job-b" {
+		t.Errorf("Expected current job 'job-b', got %v", vehicle.CurrentJobID)
+	}
+	for _, stop := range vehicle.StopPlan {
+		if stop.JobID != "job-b" {
+			t.Errorf("Expected only job-b stops, got %+v", vehicle.StopPlan)
+		}
+	}
+
+	if err := fleetService.ReleaseVehicle(ctx, "v1", "job-b"); err != nil {
+		t.Fatalf("Failed to release job-b: %v", err)
+	}
+
+	vehicle, _ = vehicleStorage.GetVehicle(ctx, "v1")
+	if vehicle.Status != "available" || vehicle.CurrentJobID != nil || len(vehicle.StopPlan) != 0 {
+		t.Errorf("Expected an empty available vehicle, got %s %v %+v", vehicle.Status, vehicle.CurrentJobID, vehicle.StopPlan)
+	}
+}
+
+func TestFleetService_StartBatch(t *testing.T) {
+	vehicleStorage := storage.NewMemoryVehicleStorage()
+	fleetService := NewFleetService(vehicleStorage)
+	ctx := context.Background()
+
+	fleetService.RegisterVehicle(ctx, &storage.Vehicle{
+		ID:             "v1",
+		Region:         "us-west-2",
+		Status:         "available",
+		BatteryLevel:   80,
+		BatteryRangeKm: 200.0,
+		LocationLat:    45.500,
+		LocationLng:    -122.600,
+		VehicleType:    "sedan",
+	})
+
+	invalid := [][]storage.PlannedStop{
+		nil,
+		{{JobID: "order-1", Type: StopTypeDropoff, Lat: 45.52, Lng: -122.6}},
+		{{JobID: "order-1", Type: StopTypePickup, Lat: 45.50, Lng: -122.6}},
+	}
+	for i, stops := range invalid {
+		if _, err := fleetService.StartBatch(ctx, "v1", stops); !errors.Is(err, ErrInvalidBatch) {
+			t.Errorf("Case %d: expected ErrInvalidBatch, got %v", i, err)
+		}
+	}
+
+	stops := []storage.PlannedStop{
+		{JobID: "order-1", Type: StopTypePickup, Lat: 45.500, Lng: -122.600},
+		{JobID: "order-2", Type: StopTypePickup, Lat: 45.500, Lng: -122.600},
+		{JobID: "order-1", Type: StopTypeDropoff, Lat: 45.520, Lng: -122.600},
+		{JobID: "order-2", Type: StopTypeDropoff, Lat: 45.530, Lng: -122.600},
+	}
+	vehicle, err := fleetService.StartBatch(ctx, "v1", stops)
+	if err != nil {
+		t.Fatalf("Failed to start batch: %v", err)
+	}
+	if vehicle.Status != "busy" || *vehicle.CurrentJobID != "order-1" || len(vehicle.StopPlan) != 4 {
+		t.Errorf("Expected vehicle busy with the batch, got %s %v %+v", vehicle.Status, vehicle.CurrentJobID, vehicle.StopPlan)
+	}
+
+	// Riders don't share a vehicle out on deliveries
+	ride := SharedRide{JobID: "job-b", Region: "us-west-2", PickupLat: 45.510, PickupLng: -122.600, DropoffLat: 45.520, DropoffLng: -122.600, Seats: 1}
+	if _, err := fleetService.JoinPool(ctx, ride); !errors.Is(err, ErrNoPoolMatch) {
+		t.Errorf("Expected ErrNoPoolMatch, got %v", err)
+	}
+
+	// Each order is released on its own
+	if err := fleetService.ReleaseVehicle(ctx, "v1", "order-1"); err != nil {
+		t.Fatalf("Failed to release order-1: %v", err)
+	}
+	updated, _ := vehicleStorage.GetVehicle(ctx, "v1")
+	if updated.Status != "busy" || *updated.CurrentJobID != "order-2" || len(updated.StopPlan) != 2 {
+		t.Errorf("Expected vehicle busy with order-2, got %s %v %+v", updated.Status, updated.CurrentJobID, updated.StopPlan)
+	}
+}
@@ -2,8 +2,10 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -33,7 +35,16 @@ func NewDynamoDBVehicleStorage(client DynamoDBAPI, tableName string) *DynamoDBVe
 	}
 }
 
+// cellIndexName is the GSI that finds vehicles by geohash cell and status
+const cellIndexName = "cell-status-index"
+
+// cellQueryConcurrency bounds how many cells GetVehiclesByCells queries at once, so a
+// wide search costs a few round trips of latency without flooding the table
+const cellQueryConcurrency = 16
+
 func (d *DynamoDBVehicleStorage) CreateVehicle(ctx context.Context, vehicle *Vehicle) error {
+	vehicle.Version = 1
+	vehicle.Cell = CellFor(vehicle.LocationLat, vehicle.LocationLng)
 	item, err := attributevalue.MarshalMap(vehicle)
 	if err != nil {
 		return fmt.Errorf("failed to marshal vehicle: %w", err)
@@ -75,19 +86,44 @@ func (d *DynamoDBVehicleStorage) GetVehicle(ctx context.Context, vehicleID strin
 }
 
 func (d *DynamoDBVehicleStorage) UpdateVehicle(ctx context.Context, vehicle *Vehicle) error {
-	item, err := attributevalue.MarshalMap(vehicle)
+	expectedVersion := vehicle.Version
+
+	updated := *vehicle
+	updated.Version = expectedVersion + 1
+	updated.LastUpdated = time.Now()
+	updated.Cell = CellFor(updated.LocationLat, updated.LocationLng)
+
+	item, err := attributevalue.MarshalMap(&updated)
 	if err != nil {
 		return fmt.Errorf("failed to marshal vehicle: %w", err)
 	}
 
+	// Records written before versioning have no version attribute yet
+	condition := "#version = :expected"
+	if expectedVersion == 0 {
+		condition = "attribute_not_exists(#version) OR #version = :expected"
+	}
+
 	_, err = d.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(d.tableName),
-		Item:      item,
+		TableName:           aws.String(d.tableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_exists(id) AND (" + condition + ")"),
+		ExpressionAttributeNames: map[string]string{
+			"#version": "version",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":expected": &types.AttributeValueMemberN{Value: strconv.FormatInt(expectedVersion, 10)},
+		},
 	})
 	if err != nil {
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return ErrVersionConflict
+		}
 		return fmt.Errorf("failed to update vehicle: %w", err)
 	}
 
+	*vehicle = updated
 	return nil
 }
 
@@ -97,15 +133,19 @@ func (d *DynamoDBVehicleStorage) UpdateVehicleLocationAndStatus(ctx context.Cont
 		Key: map[string]types.AttributeValue{
 			"id": &types.AttributeValueMemberS{Value: vehicleID},
 		},
-		UpdateExpression: aws.String("SET location_lat = :lat, location_lng = :lng, #status = :status, last_updated = :timestamp"),
+		UpdateExpression: aws.String("SET location_lat = :lat, location_lng = :lng, #cell = :cell, #status = :status, last_updated = :timestamp ADD #version :one"),
 		ExpressionAttributeNames: map[string]string{
-			"#status": "status",
+			"#cell":    "cell",
+			"#status":  "status",
+			"#version": "version",
 		},
 		ExpressionAttributeValues: map[string]types.AttributeValue{
 			":lat":       &types.AttributeValueMemberN{Value: fmt.Sprintf("%f", lat)},
 			":lng":       &types.AttributeValueMemberN{Value: fmt.Sprintf("%f", lng)},
+			":cell":      &types.AttributeValueMemberS{Value: CellFor(lat, lng)},
 			":status":    &types.AttributeValueMemberS{Value: status},
 			":timestamp": &types.AttributeValueMemberS{Value: time.Now().Format(time.RFC3339)},
+			":one":       &types.AttributeValueMemberN{Value: "1"},
 		},
 	})
 	return err
@@ -117,11 +157,17 @@ func (d *DynamoDBVehicleStorage) UpdateVehicleLocation(ctx context.Context, vehi
 		Key: map[string]types.AttributeValue{
 			"id": &types.AttributeValueMemberS{Value: vehicleID},
 		},
-		UpdateExpression: aws.String("SET location_lat = :lat, location_lng = :lng, last_updated = :timestamp"),
+		UpdateExpression: aws.String("SET location_lat = :lat, location_lng = :lng, #cell = :cell, last_updated = :timestamp ADD #version :one"),
+		ExpressionAttributeNames: map[string]string{
+			"#cell":    "cell",
+			"#version": "version",
+		},
 		ExpressionAttributeValues: map[string]types.AttributeValue{
 			":lat":       &types.AttributeValueMemberN{Value: strconv.FormatFloat(lat, 'f', -1, 64)},
 			":lng":       &types.AttributeValueMemberN{Value: strconv.FormatFloat(lng, 'f', -1, 64)},
-			":timestamp": &types.AttributeValueMemberS{Value: "2024-01-01T00:00:00Z"}, // TODO: use actual timestamp
+			":cell":      &types.AttributeValueMemberS{Value: CellFor(lat, lng)},
+			":timestamp": &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339)},
+			":one":       &types.AttributeValueMemberN{Value: "1"},
 		},
 	})
 	if err != nil {
@@ -135,7 +181,8 @@ func (d *DynamoDBVehicleStorage) UpdateVehicleStatus(ctx context.Context, vehicl
 	updateExpression := "SET #status = :status, last_updated = :timestamp"
 	expressionAttributeValues := map[string]types.AttributeValue{
 		":status":    &types.AttributeValueMemberS{Value: status},
-		":timestamp": &types.AttributeValueMemberS{Value: "2024-01-01T00:00:00Z"}, // TODO: use actual timestamp
+		":timestamp": &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339)},
+		":one":       &types.AttributeValueMemberN{Value: "1"},
 	}
 
 	if jobID != nil {
@@ -144,6 +191,7 @@ func (d *DynamoDBVehicleStorage) UpdateVehicleStatus(ctx context.Context, vehicl
 	} else {
 		updateExpression += " REMOVE current_job_id"
 	}
+	updateExpression += " ADD #version :one"
 
 	_, err := d.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
 		TableName: aws.String(d.tableName),
@@ -152,7 +200,8 @@ func (d *DynamoDBVehicleStorage) UpdateVehicleStatus(ctx context.Context, vehicl
 		},
 		UpdateExpression: aws.String(updateExpression),
 		ExpressionAttributeNames: map[string]string{
-			"#status": "status",
+			"#status":  "status",
+			"#version": "version",
 		},
 		ExpressionAttributeValues: expressionAttributeValues,
 	})
@@ -163,6 +212,39 @@ func (d *DynamoDBVehicleStorage) UpdateVehicleStatus(ctx context.Context, vehicl
 	return nil
 }
 
+func (d *DynamoDBVehicleStorage) AssignJobIfAvailable(ctx context.Context, vehicleID, jobID string) error {
+	_, err := d.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(d.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: vehicleID},
+		},
+		UpdateExpression: aws.String("SET #status = :busy, current_job_id = :jobID, last_updated = :timestamp ADD #version :one"),
+		// Only claim the vehicle if no other dispatcher got there first
+		ConditionExpression: aws.String("attribute_exists(id) AND #status = :available AND (attribute_not_exists(current_job_id) OR current_job_id = :empty)"),
+		ExpressionAttributeNames: map[string]string{
+			"#status":  "status",
+			"#version": "version",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":busy":      &types.AttributeValueMemberS{Value: "busy"},
+			":available": &types.AttributeValueMemberS{Value: "available"},
+			":jobID":     &types.AttributeValueMemberS{Value: jobID},
+			":empty":     &types.AttributeValueMemberS{Value: ""},
+			":timestamp": &types.AttributeValueMemberS{Value: time.Now().Format(time.RFC3339)},
+			":one":       &types.AttributeValueMemberN{Value: "1"},
+		},
+	})
+	if err != nil {
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return ErrVehicleNotAvailable
+		}
+		return fmt.Errorf("failed to assign job to vehicle: %w", err)
+	}
+
+	return nil
+}
+
 func (d *DynamoDBVehicleStorage) GetVehiclesByRegionAndStatus(ctx context.Context, region, status string) ([]*Vehicle, error) {
 	result, err := d.client.Query(ctx, &dynamodb.QueryInput{
 		TableName:              aws.String(d.tableName),
@@ -194,6 +276,87 @@ func (d *DynamoDBVehicleStorage) GetVehiclesByRegionAndStatus(ctx context.Contex
 	return vehicles, nil
 }
 
+// GetVehiclesByCells queries the cell index once per cell. Vehicles written before
+// the index existed are picked up once they next report their location.
+func (d *DynamoDBVehicleStorage) GetVehiclesByCells(ctx context.Context, region, status string, cells []string) ([]*Vehicle, error) {
+	// The first failure stops the queries still to run
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	found := make([][]*Vehicle, len(cells))
+	var firstErr error
+	var errOnce sync.Once
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, cellQueryConcurrency)
+	for i, cell := range cells {
+		slots <- struct{}{}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, cell string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			vehicles, err := d.queryCell(ctx, region, status, cell)
+			if err != nil {
+				errOnce.Do(func() { firstErr = err })
+				cancel()
+				return
+			}
+			found[i] = vehicles
+		}(i, cell)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	// Keep the vehicles in the order of the cells they were found in
+	var vehicles []*Vehicle
+	for _, cellVehicles := range found {
+		vehicles = append(vehicles, cellVehicles...)
+	}
+	return vehicles, nil
+}
+
+// queryCell finds vehicles by region and status located in one cell
+func (d *DynamoDBVehicleStorage) queryCell(ctx context.Context, region, status, cell string) ([]*Vehicle, error) {
+	result, err := d.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(d.tableName),
+		IndexName:              aws.String(cellIndexName),
+		KeyConditionExpression: aws.String("#cell = :cell AND #status = :status"),
+		FilterExpression:       aws.String("#region = :region"),
+		ExpressionAttributeNames: map[string]string{
+			"#cell":   "cell",
+			"#region": "region",
+			"#status": "status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":cell":   &types.AttributeValueMemberS{Value: cell},
+			":region": &types.AttributeValueMemberS{Value: region},
+			":status": &types.AttributeValueMemberS{Value: status},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query vehicles in cell %s: %w", cell, err)
+	}
+
+	var vehicles []*Vehicle
+	for _, item := range result.Items {
+		var vehicle Vehicle
+		err = attributevalue.UnmarshalMap(item, &vehicle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal vehicle: %w", err)
+		}
+		vehicles = append(vehicles, &vehicle)
+	}
+
+	return vehicles, nil
+}
+
 func (d *DynamoDBVehicleStorage) GetAllVehicles(ctx context.Context) ([]*Vehicle, error) {
 	result, err := d.client.Scan(ctx, &dynamodb.ScanInput{
 		TableName: aws.String(d.tableName),
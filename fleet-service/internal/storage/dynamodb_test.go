@@ -2,9 +2,12 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/stretchr/testify/assert"
@@ -41,6 +44,11 @@ func (m *MockDynamoDBClient) Scan(ctx context.Context, params *dynamodb.ScanInpu
 	return args.Get(0).(*dynamodb.ScanOutput), args.Error(1)
 }
 
+func (m *MockDynamoDBClient) TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(*dynamodb.TransactWriteItemsOutput), args.Error(1)
+}
+
 func TestDynamoDBVehicleStorage_CreateVehicle(t *testing.T) {
 	mockClient := new(MockDynamoDBClient)
 	storage := &DynamoDBVehicleStorage{
@@ -278,3 +286,227 @@ func TestDynamoDBVehicleStorage_GetAllVehicles(t *testing.T) {
 	assert.Equal(t, "test-vehicle-2", vehicles[1].ID)
 	mockClient.AssertExpectations(t)
 }
+
+func TestDynamoDBVehicleStorage_AssignJobIfAvailable(t *testing.T) {
+	mockClient := new(MockDynamoDBClient)
+	storage := &DynamoDBVehicleStorage{
+		client:    mockClient,
+		tableName: "test-vehicles",
+	}
+
+	mockClient.On("UpdateItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.UpdateItemInput) bool {
+		return *input.TableName == "test-vehicles" &&
+			input.ConditionExpression != nil &&
+			input.ExpressionAttributeValues[":available"].(*types.AttributeValueMemberS).Value == "available" &&
+			input.ExpressionAttributeValues[":jobID"].(*types.AttributeValueMemberS).Value == "job-123"
+	})).Return(&dynamodb.UpdateItemOutput{}, nil)
+
+	err := storage.AssignJobIfAvailable(context.Background(), "test-vehicle-1", "job-123")
+
+	assert.NoError(t, err)
+	mockClient.AssertExpectations(t)
+}
+
+func TestDynamoDBVehicleStorage_AssignJobIfAvailable_Conflict(t *testing.T) {
+	mockClient := new(MockDynamoDBClient)
+	storage := &DynamoDBVehicleStorage{
+		client:    mockClient,
+		tableName: "test-vehicles",
+	}
+
+	mockClient.On("UpdateItem", mock.Anything, mock.Anything).
+		Return((*dynamodb.UpdateItemOutput)(nil), &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")})
+
+	err := storage.AssignJobIfAvailable(context.Background(), "test-vehicle-1", "job-123")
+
+	assert.ErrorIs(t, err, ErrVehicleNotAvailable)
+	mockClient.AssertExpectations(t)
+}
+
+func TestDynamoDBVehicleStorage_UpdateVehicle_Versioned(t *testing.T) {
+	mockClient := new(MockDynamoDBClient)
+	storage := &DynamoDBVehicleStorage{
+		client:    mockClient,
+		tableName: "test-vehicles",
+	}
+
+	mockClient.On("PutItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+		return input.ConditionExpression != nil &&
+			input.ExpressionAttributeValues[":expected"].(*types.AttributeValueMemberN).Value == "3" &&
+			input.Item["version"].(*types.AttributeValueMemberN).Value == "4"
+	})).Return(&dynamodb.PutItemOutput{}, nil)
+
+	vehicle := &Vehicle{ID: "test-vehicle-1", Status: "busy", Version: 3}
+	err := storage.UpdateVehicle(context.Background(), vehicle)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), vehicle.Version)
+	mockClient.AssertExpectations(t)
+}
+
+func TestDynamoDBVehicleStorage_UpdateVehicle_VersionConflict(t *testing.T) {
+	mockClient := new(MockDynamoDBClient)
+	storage := &DynamoDBVehicleStorage{
+		client:    mockClient,
+		tableName: "test-vehicles",
+	}
+
+	mockClient.On("PutItem", mock.Anything, mock.Anything).
+		Return((*dynamodb.PutItemOutput)(nil), &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")})
+
+	vehicle := &Vehicle{ID: "test-vehicle-1", Status: "available", Version: 3}
+	err := storage.UpdateVehicle(context.Background(), vehicle)
+
+	assert.ErrorIs(t, err, ErrVersionConflict)
+	assert.Equal(t, int64(3), vehicle.Version)
+	mockClient.AssertExpectations(t)
+}
+
+func TestDynamoDBVehicleStorage_GetVehiclesByCells(t *testing.T) {
+	mockClient := new(MockDynamoDBClient)
+	storage := &DynamoDBVehicleStorage{
+		client:    mockClient,
+		tableName: "test-vehicles",
+	}
+
+	cellQuery := func(cell string) interface{} {
+		return mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+			return *input.IndexName == "cell-status-index" &&
+				*input.KeyConditionExpression == "#cell = :cell AND #status = :status" &&
+				*input.FilterExpression == "#region = :region" &&
+				input.ExpressionAttributeValues[":cell"].(*types.AttributeValueMemberS).Value == cell
+		})
+	}
+	mockClient.On("Query", mock.Anything, cellQuery("9q8yyk")).Return(&dynamodb.QueryOutput{
+		Items: []map[string]types.AttributeValue{
+			{
+				"id":     &types.AttributeValueMemberS{Value: "test-vehicle-1"},
+				"region": &types.AttributeValueMemberS{Value: "us-west-2"},
+				"status": &types.AttributeValueMemberS{Value: "available"},
+				"cell":   &types.AttributeValueMemberS{Value: "9q8yyk"},
+			},
+		},
+	}, nil)
+	mockClient.On("Query", mock.Anything, cellQuery("9q8yym")).Return(&dynamodb.QueryOutput{}, nil)
+
+	vehicles, err := storage.GetVehiclesByCells(context.Background(), "us-west-2", "available", []string{"9q8yyk", "9q8yym"})
+
+	assert.NoError(t, err)
+	assert.Len(t, vehicles, 1)
+	assert.Equal(t, "9q8yyk", vehicles[0].Cell)
+	mockClient.AssertExpectations(t)
+}
+
+// slowCellClient answers cell queries after a pause with one vehicle in the cell,
+// tracking how many queries run at once
+type slowCellClient struct {
+	MockDynamoDBClient
+	failCell string
+
+	mu          sync.Mutex
+	queries     int
+	inFlight    int
+	maxInFlight int
+}
+
+func (c *slowCellClient) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	c.mu.Lock()
+	c.queries++
+	c.inFlight++
+	c.maxInFlight = max(c.maxInFlight, c.inFlight)
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.inFlight--
+		c.mu.Unlock()
+	}()
+
+	time.Sleep(5 * time.Millisecond)
+	cell := params.ExpressionAttributeValues[":cell"].(*types.AttributeValueMemberS).Value
+	if cell == c.failCell {
+		return nil, errors.New("throughput exceeded")
+	}
+	return &dynamodb.QueryOutput{Items: []map[string]types.AttributeValue{{
+		"id":   &types.AttributeValueMemberS{Value: "vehicle-" + cell},
+		"cell": &types.AttributeValueMemberS{Value: cell},
+	}}}, nil
+}
+
+func TestDynamoDBVehicleStorage_GetVehiclesByCells_BoundedConcurrency(t *testing.T) {
+	var cells []string
+	for ring := 0; ring <= 6; ring++ {
+		cells = append(cells, CellRing(37.7749, -122.4194, ring)...)
+	}
+	client := &slowCellClient{}
+	storage := NewDynamoDBVehicleStorage(client, "test-vehicles")
+
+	vehicles, err := storage.GetVehiclesByCells(context.Background(), "us-west-2", "available", cells)
+
+	assert.NoError(t, err)
+	if assert.Len(t, vehicles, len(cells)) {
+		for i, vehicle := range vehicles {
+			assert.Equal(t, cells[i], vehicle.Cell)
+		}
+	}
+	assert.Greater(t, client.maxInFlight, 1)
+	assert.LessOrEqual(t, client.maxInFlight, cellQueryConcurrency)
+}
+
+func TestDynamoDBVehicleStorage_GetVehiclesByCells_StopsOnError(t *testing.T) {
+	var cells []string
+	for ring := 0; ring <= 6; ring++ {
+		cells = append(cells, CellRing(37.7749, -122.4194, ring)...)
+	}
+	client := &slowCellClient{failCell: cells[0]}
+	storage := NewDynamoDBVehicleStorage(client, "test-vehicles")
+
+	vehicles, err := storage.GetVehiclesByCells(context.Background(), "us-west-2", "available", cells)
+
+	assert.ErrorContains(t, err, "throughput exceeded")
+	assert.Nil(t, vehicles)
+	assert.Less(t, client.queries, len(cells))
+}
+
+func TestDynamoDBVehicleStorage_UpdateVehicleLocation_SetsCell(t *testing.T) {
+	mockClient := new(MockDynamoDBClient)
+	storage := &DynamoDBVehicleStorage{
+		client:    mockClient,
+		tableName: "test-vehicles",
+	}
+
+	mockClient.On("UpdateItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.UpdateItemInput) bool {
+		return input.ExpressionAttributeNames["#cell"] == "cell" &&
+			input.ExpressionAttributeValues[":cell"].(*types.AttributeValueMemberS).Value == "9q8yyk"
+	})).Return(&dynamodb.UpdateItemOutput{}, nil).Twice()
+
+	assert.NoError(t, storage.UpdateVehicleLocation(context.Background(), "test-vehicle-1", 37.7749, -122.4194))
+	assert.NoError(t, storage.UpdateVehicleLocationAndStatus(context.Background(), "test-vehicle-1", 37.7749, -122.4194, "available"))
+	mockClient.AssertExpectations(t)
+}
+
+func TestDynamoDBVehicleStorage_UpdatesStampLastUpdated(t *testing.T) {
+	mockClient := new(MockDynamoDBClient)
+	storage := &DynamoDBVehicleStorage{
+		client:    mockClient,
+		tableName: "test-vehicles",
+	}
+
+	before := time.Now().Add(-time.Second)
+	var stamps []string
+	mockClient.On("UpdateItem", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		input := args.Get(1).(*dynamodb.UpdateItemInput)
+		stamps = append(stamps, input.ExpressionAttributeValues[":timestamp"].(*types.AttributeValueMemberS).Value)
+	}).Return(&dynamodb.UpdateItemOutput{}, nil)
+
+	jobID := "job-123"
+	assert.NoError(t, storage.UpdateVehicleLocation(context.Background(), "test-vehicle-1", 37.7749, -122.4194))
+	assert.NoError(t, storage.UpdateVehicleStatus(context.Background(), "test-vehicle-1", "busy", &jobID))
+
+	// The heartbeat reaper reads last_updated, so a write must never look stale
+	assert.Len(t, stamps, 2)
+	for _, stamp := range stamps {
+		updated, err := time.Parse(time.RFC3339, stamp)
+		assert.NoError(t, err)
+		assert.False(t, updated.Before(before.Truncate(time.Second)), "last_updated %s is before the write", stamp)
+	}
+}
@@ -0,0 +1,92 @@
+package storage
+
+import "math"
+
+// CellPrecision is the geohash length vehicles are indexed at. Six characters
+// gives cells of roughly 0.6km north-south by 1.2km east-west at the equator,
+// narrowing east-west towards the poles.
+const CellPrecision = 6
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+const (
+	// cellLngBits and cellLatBits split a cell's 5 bits per character between
+	// longitude and latitude, longitude taking the extra bit when odd
+	cellLngBits = (CellPrecision*5 + 1) / 2
+	cellLatBits = CellPrecision * 5 / 2
+
+	kmPerDegreeLat = 110.57
+	kmPerDegreeLng = 111.32
+)
+
+// CellFor returns the geohash cell containing a coordinate
+func CellFor(lat, lng float64) string {
+	x, y := cellCoordinates(lat, lng)
+	return encodeCell(x, y)
+}
+
+// CellRing returns the cells exactly ring steps away from the cell containing a
+// coordinate: ring 0 is the cell itself, ring 1 its eight neighbours, and so on.
+// Rings wrap around the antimeridian and stop at the poles.
+func CellRing(lat, lng float64, ring int) []string {
+	x, y := cellCoordinates(lat, lng)
+	if ring == 0 {
+		return []string{encodeCell(x, y)}
+	}
+
+	cells := make([]string, 0, 8*ring)
+	for dy := -ring; dy <= ring; dy++ {
+		ny := y + dy
+		if ny < 0 || ny >= 1<<cellLatBits {
+			continue
+		}
+
+		// Inner rows only contribute their two ends to the ring
+		step := 2 * ring
+		if dy == -ring || dy == ring {
+			step = 1
+		}
+		for dx := -ring; dx <= ring; dx += step {
+			nx := (x + dx + 1<<cellLngBits) % (1 << cellLngBits)
+			cells = append(cells, encodeCell(nx, ny))
+		}
+	}
+
+	return cells
+}
+
+// CellRingReachKm is how far from a coordinate rings 0 through ring are guaranteed
+// to cover in every direction. A vehicle closer than this is always inside them.
+func CellRingReachKm(lat float64, ring int) float64 {
+	heightKm := 180.0 / (1 << cellLatBits) * kmPerDegreeLat
+	widthKm := 360.0 / (1 << cellLngBits) * kmPerDegreeLng * math.Cos(lat*math.Pi/180)
+	return float64(ring) * math.Min(heightKm, widthKm)
+}
+
+// cellCoordinates locates the column and row of the cell containing a coordinate
+func cellCoordinates(lat, lng float64) (x, y int) {
+	x = int((lng + 180) / 360 * (1 << cellLngBits))
+	y = int((lat + 90) / 180 * (1 << cellLatBits))
+	return min(max(x, 0), 1<<cellLngBits-1), min(max(y, 0), 1<<cellLatBits-1)
+}
+
+// encodeCell interleaves a cell's column and row bits, longitude first, into a geohash
+func encodeCell(x, y int) string {
+	hash := make([]byte, CellPrecision)
+	lngBit, latBit := cellLngBits-1, cellLatBits-1
+	for i := range hash {
+		var char int
+		for bit := 0; bit < 5; bit++ {
+			char <<= 1
+			if (i*5+bit)%2 == 0 {
+				char |= (x >> lngBit) & 1
+				lngBit--
+			} else {
+				char |= (y >> latBit) & 1
+				latBit--
+			}
+		}
+		hash[i] = geohashAlphabet[char]
+	}
+	return string(hash)
+}
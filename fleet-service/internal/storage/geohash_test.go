@@ -0,0 +1,64 @@
+package storage
+
+import "testing"
+
+func TestCellFor(t *testing.T) {
+	tests := []struct {
+		lat, lng float64
+		expected string
+	}{
+		{37.7749, -122.4194, "9q8yyk"},
+		{51.5074, -0.1278, "gcpvj0"},
+		{-33.8688, 151.2093, "r3gx2f"},
+	}
+
+	for _, tt := range tests {
+		if cell := CellFor(tt.lat, tt.lng); cell != tt.expected {
+			t.Errorf("CellFor(%f, %f) = %s, expected %s", tt.lat, tt.lng, cell, tt.expected)
+		}
+	}
+}
+
+func TestCellRing(t *testing.T) {
+	lat, lng := 37.7749, -122.4194
+
+	if ring := CellRing(lat, lng, 0); len(ring) != 1 || ring[0] != CellFor(lat, lng) {
+		t.Errorf("Expected ring 0 to be the containing cell, got %v", ring)
+	}
+
+	seen := map[string]int{}
+	for ring := 0; ring <= 3; ring++ {
+		cells := CellRing(lat, lng, ring)
+		if ring > 0 && len(cells) != 8*ring {
+			t.Errorf("Expected %d cells in ring %d, got %d", 8*ring, ring, len(cells))
+		}
+		for _, cell := range cells {
+			if previous, ok := seen[cell]; ok {
+				t.Errorf("Cell %s appears in ring %d and ring %d", cell, previous, ring)
+			}
+			seen[cell] = ring
+		}
+	}
+
+	// A point just under a ring's reach away is always inside it
+	reach := CellRingReachKm(lat, 2)
+	north := lat + reach*0.99/kmPerDegreeLat
+	if ring, ok := seen[CellFor(north, lng)]; !ok || ring > 2 {
+		t.Errorf("Expected a point %.2fkm north to be within ring 2, found in ring %d", reach*0.99, ring)
+	}
+}
+
+func TestCellRing_Antimeridian(t *testing.T) {
+	cells := CellRing(0.1, 179.999, 1)
+	west := CellFor(0.1, -179.999)
+
+	found := false
+	for _, cell := range cells {
+		if cell == west {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected ring around 179.999 to wrap to %s, got %v", west, cells)
+	}
+}
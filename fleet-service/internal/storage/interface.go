@@ -2,9 +2,17 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
+// ErrVehicleNotAvailable is returned when a conditional assignment finds the
+// vehicle already busy or holding another job
+var ErrVehicleNotAvailable = errors.New("vehicle not available for assignment")
+
+// ErrVersionConflict is returned when a versioned update was based on a stale copy of the vehicle
+var ErrVersionConflict = errors.New("vehicle was modified concurrently")
+
 // Vehicle represents a vehicle in the fleet
 type Vehicle struct {
 	ID             string    `json:"id" dynamodbav:"id"`
@@ -14,9 +22,26 @@ type Vehicle struct {
 	BatteryRangeKm float64   `json:"battery_range_km" dynamodbav:"battery_range_km"`
 	LocationLat    float64   `json:"location_lat" dynamodbav:"location_lat"`
 	LocationLng    float64   `json:"location_lng" dynamodbav:"location_lng"`
+	Cell           string    `json:"cell,omitempty" dynamodbav:"cell,omitempty"` // geohash of the location, kept in step by storage
 	CurrentJobID   *string   `json:"current_job_id,omitempty" dynamodbav:"current_job_id,omitempty"`
 	LastUpdated    time.Time `json:"last_updated" dynamodbav:"last_updated"`
 	VehicleType    string    `json:"vehicle_type" dynamodbav:"vehicle_type"`
+	Version        int64     `json:"version" dynamodbav:"version"` // incremented on every write
+
+	// Seats for passengers; zero means the fleet default
+	SeatCapacity int `json:"seat_capacity,omitempty" dynamodbav:"seat_capacity,omitempty"`
+	// Remaining stops of the shared rides or batched deliveries the vehicle is serving,
+	// in the order they are visited. Empty for a vehicle on a single private job.
+	StopPlan []PlannedStop `json:"stop_plan,omitempty" dynamodbav:"stop_plan,omitempty"`
+}
+
+// PlannedStop is a pickup or drop-off of one shared ride in a vehicle's stop plan
+type PlannedStop struct {
+	JobID string  `json:"job_id" dynamodbav:"job_id"`
+	Type  string  `json:"type" dynamodbav:"type"` // pickup, dropoff
+	Lat   float64 `json:"lat" dynamodbav:"lat"`
+	Lng   float64 `json:"lng" dynamodbav:"lng"`
+	Seats int     `json:"seats" dynamodbav:"seats"` // seats the ride occupies between its pickup and drop-off; none for deliveries
 }
 
 // VehicleStorage defines the interface for vehicle data operations
@@ -27,12 +52,16 @@ type VehicleStorage interface {
 	// GetVehicle retrieves a vehicle by ID
 	GetVehicle(ctx context.Context, vehicleID string) (*Vehicle, error)
 
-	// UpdateVehicle updates an existing vehicle
+	// UpdateVehicle replaces an existing vehicle if its Version still matches the stored
+	// record, returning ErrVersionConflict otherwise; on success Version is incremented
 	UpdateVehicle(ctx context.Context, vehicle *Vehicle) error
 
 	// GetVehiclesByRegionAndStatus finds vehicles by region and status
 	GetVehiclesByRegionAndStatus(ctx context.Context, region, status string) ([]*Vehicle, error)
 
+	// GetVehiclesByCells finds vehicles by region and status located in any of the given cells
+	GetVehiclesByCells(ctx context.Context, region, status string, cells []string) ([]*Vehicle, error)
+
 	// GetAllVehicles returns all vehicles (for dashboard)
 	GetAllVehicles(ctx context.Context) ([]*Vehicle, error)
 
@@ -44,4 +73,8 @@ type VehicleStorage interface {
 
 	// UpdateVehicleStatus updates status and clears/sets job ID
 	UpdateVehicleStatus(ctx context.Context, vehicleID string, status string, jobID *string) error
+
+	// AssignJobIfAvailable marks the vehicle busy with the given job only if it is
+	// currently available and has no job, returning ErrVehicleNotAvailable otherwise
+	AssignJobIfAvailable(ctx context.Context, vehicleID, jobID string) error
 }
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// KPIRetention is how long hourly KPI buckets are kept
+const KPIRetention = 7 * 24 * time.Hour
+
+// MaxKPIBucketsPerUpdate is how many buckets one KPI update can add to. DynamoDB
+// transactions hold up to 100 writes, and one of them is the vehicle's state.
+const MaxKPIBucketsPerUpdate = 99
+
+// ErrKPIStateNotFound is returned for vehicles whose KPIs have not been tracked yet
+var ErrKPIStateNotFound = errors.New("vehicle KPI state not found")
+
+// KPIBucket is one vehicle's KPIs over one hour. Buckets are added to as vehicles
+// report, so every instance of the fleet service sees the same totals.
+type KPIBucket struct {
+	Hour      time.Time `json:"hour" dynamodbav:"hour_start"`
+	VehicleID string    `json:"vehicle_id" dynamodbav:"vehicle_id"`
+	Region    string    `json:"region" dynamodbav:"region"`
+
+	// Seconds spent in each status: available, busy, charging, maintenance, offline
+	StatusSeconds map[string]float64 `json:"status_seconds,omitempty" dynamodbav:"-"`
+	// Kilometres driven with riders or orders on board, and without
+	RevenueKm     float64 `json:"revenue_km" dynamodbav:"revenue_km"`
+	DeadheadKm    float64 `json:"deadhead_km" dynamodbav:"deadhead_km"`
+	CompletedJobs int     `json:"completed_jobs" dynamodbav:"completed_jobs"`
+}
+
+// Add merges another bucket's amounts into this one
+func (b *KPIBucket) Add(other *KPIBucket) {
+	b.RevenueKm += other.RevenueKm
+	b.DeadheadKm += other.DeadheadKm
+	b.CompletedJobs += other.CompletedJobs
+
+	if len(other.StatusSeconds) > 0 && b.StatusSeconds == nil {
+		b.StatusSeconds = make(map[string]float64)
+	}
+	for status, seconds := range other.StatusSeconds {
+		b.StatusSeconds[status] += seconds
+	}
+}
+
+// KPIVehicleState is where a vehicle was last seen and what it has been doing since,
+// which the next report from the vehicle is measured against
+type KPIVehicleState struct {
+	VehicleID   string    `json:"vehicle_id" dynamodbav:"vehicle_id"`
+	Region      string    `json:"region" dynamodbav:"region"`
+	Status      string    `json:"status" dynamodbav:"status"`
+	Since       time.Time `json:"since" dynamodbav:"since"` // when the time in status was last accrued to
+	HasLocation bool      `json:"has_location" dynamodbav:"has_location"`
+	Lat         float64   `json:"lat" dynamodbav:"lat"`
+	Lng         float64   `json:"lng" dynamodbav:"lng"`
+	OnBoard     []string  `json:"on_board,omitempty" dynamodbav:"on_board,omitempty"` // jobs whose riders or orders are in the vehicle
+	Version     int64     `json:"version" dynamodbav:"version"`                       // incremented on every write
+}
+
+// KPIStorage defines the interface for fleet KPI operations
+type KPIStorage interface {
+	// GetKPIState returns a vehicle's KPI state, or ErrKPIStateNotFound
+	GetKPIState(ctx context.Context, vehicleID string) (*KPIVehicleState, error)
+
+	// UpdateKPIs writes a vehicle's KPI state and adds the amounts it accrued to the
+	// vehicle's buckets, creating them if needed, as one write: either all of it happens
+	// or none does. The write is made only if the state's Version still matches the
+	// stored state, zero for a vehicle without one, returning ErrVersionConflict
+	// otherwise; on success Version is incremented. Each delta's Hour must be truncated
+	// to the hour, and there can be at most MaxKPIBucketsPerUpdate of them.
+	UpdateKPIs(ctx context.Context, state *KPIVehicleState, deltas []*KPIBucket) error
+
+	// GetKPIStates returns the KPI state of every tracked vehicle
+	GetKPIStates(ctx context.Context) ([]*KPIVehicleState, error)
+
+	// GetKPIBuckets returns the buckets for hours in [from, to)
+	GetKPIBuckets(ctx context.Context, from, to time.Time) ([]*KPIBucket, error)
+}
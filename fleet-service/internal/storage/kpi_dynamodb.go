@@ -0,0 +1,276 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// statusSecondsPrefix names the attributes holding a bucket's time in each status.
+// DynamoDB can only add to top-level numbers, so each status is an attribute of its own.
+const statusSecondsPrefix = "seconds_"
+
+// DynamoDBKPIAPI is the DynamoDB API KPI storage needs, which writes each vehicle's
+// state and the amounts it accrued atomically
+type DynamoDBKPIAPI interface {
+	DynamoDBAPI
+	TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+}
+
+// DynamoDBKPIStorage keeps hourly KPI buckets partitioned by day, sorted by hour within
+// the day, so a report reads one partition per day it covers. Buckets expire through
+// the table's TTL on expires_at once KPIRetention has passed. Vehicle KPI states live
+// in a table of their own, keyed by vehicle.
+type DynamoDBKPIStorage struct {
+	client      DynamoDBKPIAPI
+	bucketTable string
+	stateTable  string
+}
+
+func NewDynamoDBKPIStorage(client DynamoDBKPIAPI, bucketTable, stateTable string) *DynamoDBKPIStorage {
+	return &DynamoDBKPIStorage{
+		client:      client,
+		bucketTable: bucketTable,
+		stateTable:  stateTable,
+	}
+}
+
+// kpiKey returns the partition and sort key of a bucket
+func kpiKey(hour time.Time, vehicleID string) map[string]types.AttributeValue {
+	hour = hour.UTC()
+	return map[string]types.AttributeValue{
+		"day":    &types.AttributeValueMemberS{Value: hour.Format(time.DateOnly)},
+		"bucket": &types.AttributeValueMemberS{Value: fmt.Sprintf("%02d#%s", hour.Hour(), vehicleID)},
+	}
+}
+
+// numberValue renders a number attribute value
+func numberValue(value float64) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: strconv.FormatFloat(value, 'f', -1, 64)}
+}
+
+func (d *DynamoDBKPIStorage) GetKPIState(ctx context.Context, vehicleID string) (*KPIVehicleState, error) {
+	result, err := d.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(d.stateTable),
+		Key: map[string]types.AttributeValue{
+			"vehicle_id": &types.AttributeValueMemberS{Value: vehicleID},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get vehicle KPI state: %w", err)
+	}
+	if result.Item == nil {
+		return nil, ErrKPIStateNotFound
+	}
+
+	var state KPIVehicleState
+	if err := attributevalue.UnmarshalMap(result.Item, &state); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal vehicle KPI state: %w", err)
+	}
+	return &state, nil
+}
+
+func (d *DynamoDBKPIStorage) UpdateKPIs(ctx context.Context, state *KPIVehicleState, deltas []*KPIBucket) error {
+	if len(deltas) > MaxKPIBucketsPerUpdate {
+		return fmt.Errorf("cannot add to %d KPI buckets in one update", len(deltas))
+	}
+
+	put, err := d.statePut(state)
+	if err != nil {
+		return err
+	}
+	items := []types.TransactWriteItem{{Put: put}}
+	for _, delta := range deltas {
+		items = append(items, types.TransactWriteItem{Update: d.bucketUpdate(delta)})
+	}
+
+	_, err = d.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: items,
+	})
+	if err != nil {
+		// The state is the first write; another instance writing the same vehicle at
+		// once cancels the transaction with a conflict rather than a failed condition
+		var cancelled *types.TransactionCanceledException
+		if errors.As(err, &cancelled) && len(cancelled.CancellationReasons) > 0 {
+			switch aws.ToString(cancelled.CancellationReasons[0].Code) {
+			case "ConditionalCheckFailed", "TransactionConflict":
+				return ErrVersionConflict
+			}
+		}
+		return fmt.Errorf("failed to update vehicle KPIs: %w", err)
+	}
+
+	state.Version++
+	return nil
+}
+
+// statePut writes a vehicle's KPI state at the next version, if the stored state is
+// still at its current one
+func (d *DynamoDBKPIStorage) statePut(state *KPIVehicleState) (*types.Put, error) {
+	expectedVersion := state.Version
+
+	updated := *state
+	updated.Version = expectedVersion + 1
+
+	item, err := attributevalue.MarshalMap(&updated)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal vehicle KPI state: %w", err)
+	}
+
+	put := &types.Put{
+		TableName:           aws.String(d.stateTable),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(vehicle_id)"),
+	}
+	if expectedVersion != 0 {
+		put.ConditionExpression = aws.String("#version = :expected")
+		put.ExpressionAttributeNames = map[string]string{
+			"#version": "version",
+		}
+		put.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":expected": &types.AttributeValueMemberN{Value: strconv.FormatInt(expectedVersion, 10)},
+		}
+	}
+	return put, nil
+}
+
+func (d *DynamoDBKPIStorage) GetKPIStates(ctx context.Context) ([]*KPIVehicleState, error) {
+	var states []*KPIVehicleState
+
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(d.stateTable),
+	}
+	for {
+		result, err := d.client.Scan(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan vehicle KPI states: %w", err)
+		}
+
+		for _, item := range result.Items {
+			var state KPIVehicleState
+			if err := attributevalue.UnmarshalMap(item, &state); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal vehicle KPI state: %w", err)
+			}
+			states = append(states, &state)
+		}
+
+		if result.LastEvaluatedKey == nil {
+			break
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	return states, nil
+}
+
+// bucketUpdate adds amounts to a vehicle's bucket for an hour, creating the bucket if
+// needed
+func (d *DynamoDBKPIStorage) bucketUpdate(delta *KPIBucket) *types.Update {
+	names := make(map[string]string)
+	values := map[string]types.AttributeValue{
+		":hour":          &types.AttributeValueMemberS{Value: delta.Hour.UTC().Format(time.RFC3339)},
+		":vehicleID":     &types.AttributeValueMemberS{Value: delta.VehicleID},
+		":expiresAt":     numberValue(float64(delta.Hour.Add(KPIRetention).Unix())),
+		":revenueKm":     numberValue(delta.RevenueKm),
+		":deadheadKm":    numberValue(delta.DeadheadKm),
+		":completedJobs": numberValue(float64(delta.CompletedJobs)),
+	}
+
+	update := "SET hour_start = :hour, vehicle_id = :vehicleID, expires_at = :expiresAt"
+	if delta.Region != "" {
+		names["#region"] = "region"
+		values[":region"] = &types.AttributeValueMemberS{Value: delta.Region}
+		update += ", #region = :region"
+	}
+	update += " ADD revenue_km :revenueKm, deadhead_km :deadheadKm, completed_jobs :completedJobs"
+	for status, seconds := range delta.StatusSeconds {
+		name := fmt.Sprintf("#status%d", len(values))
+		value := fmt.Sprintf(":status%d", len(values))
+		names[name] = statusSecondsPrefix + status
+		values[value] = numberValue(seconds)
+		update += fmt.Sprintf(", %s %s", name, value)
+	}
+
+	input := &types.Update{
+		TableName:                 aws.String(d.bucketTable),
+		Key:                       kpiKey(delta.Hour, delta.VehicleID),
+		UpdateExpression:          aws.String(update),
+		ExpressionAttributeValues: values,
+	}
+	if len(names) > 0 {
+		input.ExpressionAttributeNames = names
+	}
+	return input
+}
+
+func (d *DynamoDBKPIStorage) GetKPIBuckets(ctx context.Context, from, to time.Time) ([]*KPIBucket, error) {
+	var buckets []*KPIBucket
+
+	for day := from.UTC().Truncate(24 * time.Hour); day.Before(to); day = day.Add(24 * time.Hour) {
+		input := &dynamodb.QueryInput{
+			TableName:              aws.String(d.bucketTable),
+			KeyConditionExpression: aws.String("#day = :day"),
+			ExpressionAttributeNames: map[string]string{
+				"#day": "day",
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":day": &types.AttributeValueMemberS{Value: day.Format(time.DateOnly)},
+			},
+		}
+
+		for {
+			result, err := d.client.Query(ctx, input)
+			if err != nil {
+				return nil, fmt.Errorf("failed to query KPI buckets: %w", err)
+			}
+
+			for _, item := range result.Items {
+				bucket, err := unmarshalKPIBucket(item)
+				if err != nil {
+					return nil, err
+				}
+				if !bucket.Hour.Before(from) && bucket.Hour.Before(to) {
+					buckets = append(buckets, bucket)
+				}
+			}
+
+			if result.LastEvaluatedKey == nil {
+				break
+			}
+			input.ExclusiveStartKey = result.LastEvaluatedKey
+		}
+	}
+
+	return buckets, nil
+}
+
+// unmarshalKPIBucket decodes a bucket item, collecting its time in each status
+func unmarshalKPIBucket(item map[string]types.AttributeValue) (*KPIBucket, error) {
+	var bucket KPIBucket
+	if err := attributevalue.UnmarshalMap(item, &bucket); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal KPI bucket: %w", err)
+	}
+
+	bucket.StatusSeconds = make(map[string]float64)
+	for name, value := range item {
+		if !strings.HasPrefix(name, statusSecondsPrefix) {
+			continue
+		}
+		var seconds float64
+		if err := attributevalue.Unmarshal(value, &seconds); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal KPI bucket: %w", err)
+		}
+		bucket.StatusSeconds[strings.TrimPrefix(name, statusSecondsPrefix)] = seconds
+	}
+
+	return &bucket, nil
+}
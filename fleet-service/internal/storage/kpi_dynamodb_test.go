@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDynamoDBKPIStorage_UpdateKPIs(t *testing.T) {
+	mockClient := new(MockDynamoDBClient)
+	storage := NewDynamoDBKPIStorage(mockClient, "test-kpis", "test-kpi-states")
+	hour := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+
+	mockClient.On("TransactWriteItems", mock.Anything, mock.MatchedBy(func(input *dynamodb.TransactWriteItemsInput) bool {
+		if len(input.TransactItems) != 2 {
+			return false
+		}
+		state := input.TransactItems[0].Put
+		version := state.Item["version"].(*types.AttributeValueMemberN).Value
+		if *state.TableName != "test-kpi-states" || *state.ConditionExpression != "attribute_not_exists(vehicle_id)" || version != "1" {
+			return false
+		}
+
+		update := input.TransactItems[1].Update
+		day := update.Key["day"].(*types.AttributeValueMemberS).Value
+		bucket := update.Key["bucket"].(*types.AttributeValueMemberS).Value
+		expiresAt := update.ExpressionAttributeValues[":expiresAt"].(*types.AttributeValueMemberN).Value
+		var statusName, statusSeconds string
+		for name, attribute := range update.ExpressionAttributeNames {
+			if strings.HasPrefix(name, "#status") {
+				statusName = attribute
+				statusSeconds = update.ExpressionAttributeValues[":"+strings.TrimPrefix(name, "#")].(*types.AttributeValueMemberN).Value
+			}
+		}
+		return *update.TableName == "test-kpis" && day == "2026-10-01" && bucket == "09#vehicle-1" &&
+			strings.Contains(*update.UpdateExpression, "ADD revenue_km :revenueKm") &&
+			expiresAt == "1791450000" && statusName == "seconds_busy" && statusSeconds == "900"
+	})).Return(&dynamodb.TransactWriteItemsOutput{}, nil)
+
+	state := &KPIVehicleState{VehicleID: "vehicle-1", Region: "us-west-2", Status: "busy"}
+	err := storage.UpdateKPIs(context.Background(), state, []*KPIBucket{{
+		Hour:          hour,
+		VehicleID:     "vehicle-1",
+		Region:        "us-west-2",
+		StatusSeconds: map[string]float64{"busy": 900},
+		RevenueKm:     3.5,
+	}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), state.Version)
+	mockClient.AssertExpectations(t)
+}
+
+func TestDynamoDBKPIStorage_GetKPIBuckets(t *testing.T) {
+	mockClient := new(MockDynamoDBClient)
+	storage := NewDynamoDBKPIStorage(mockClient, "test-kpis", "test-kpi-states")
+	hour := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+
+	mockClient.On("Query", mock.Anything, mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+		return input.ExpressionAttributeValues[":day"].(*types.AttributeValueMemberS).Value == "2026-10-01"
+	})).Return(&dynamodb.QueryOutput{
+		Items: []map[string]types.AttributeValue{{
+			"day":               &types.AttributeValueMemberS{Value: "2026-10-01"},
+			"hour_start":        &types.AttributeValueMemberS{Value: hour.Format(time.RFC3339)},
+			"vehicle_id":        &types.AttributeValueMemberS{Value: "vehicle-1"},
+			"region":            &types.AttributeValueMemberS{Value: "us-west-2"},
+			"revenue_km":        &types.AttributeValueMemberN{Value: "3.5"},
+			"seconds_available": &types.AttributeValueMemberN{Value: "1200"},
+		}},
+	}, nil)
+
+	buckets, err := storage.GetKPIBuckets(context.Background(), hour, hour.Add(time.Hour))
+
+	assert.NoError(t, err)
+	if assert.Len(t, buckets, 1) {
+		assert.Equal(t, "vehicle-1", buckets[0].VehicleID)
+		assert.Equal(t, 3.5, buckets[0].RevenueKm)
+		assert.Equal(t, map[string]float64{"available": 1200}, buckets[0].StatusSeconds)
+	}
+	mockClient.AssertExpectations(t)
+}
+
+func TestDynamoDBKPIStorage_UpdateKPIs_VersionConflict(t *testing.T) {
+	mockClient := new(MockDynamoDBClient)
+	storage := NewDynamoDBKPIStorage(mockClient, "test-kpis", "test-kpi-states")
+
+	mockClient.On("TransactWriteItems", mock.Anything, mock.MatchedBy(func(input *dynamodb.TransactWriteItemsInput) bool {
+		state := input.TransactItems[0].Put
+		return *state.ConditionExpression == "#version = :expected" &&
+			state.ExpressionAttributeValues[":expected"].(*types.AttributeValueMemberN).Value == "4"
+	})).Return((*dynamodb.TransactWriteItemsOutput)(nil), &types.TransactionCanceledException{
+		CancellationReasons: []types.CancellationReason{{Code: aws.String("ConditionalCheckFailed")}, {Code: aws.String("None")}},
+	})
+
+	state := &KPIVehicleState{VehicleID: "vehicle-1", Version: 4}
+	err := storage.UpdateKPIs(context.Background(), state, []*KPIBucket{{
+		Hour:      time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC),
+		VehicleID: "vehicle-1",
+		RevenueKm: 1,
+	}})
+
+	assert.ErrorIs(t, err, ErrVersionConflict)
+	assert.Equal(t, int64(4), state.Version)
+	mockClient.AssertExpectations(t)
+}
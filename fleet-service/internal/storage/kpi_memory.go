@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// kpiBucketKey identifies a vehicle's bucket for an hour
+type kpiBucketKey struct {
+	hour      int64 // Unix time of the start of the hour
+	vehicleID string
+}
+
+// MemoryKPIStorage implements KPIStorage using in-memory maps
+type MemoryKPIStorage struct {
+	states   map[string]*KPIVehicleState
+	buckets  map[kpiBucketKey]*KPIBucket
+	prunedAt time.Time
+	mu       sync.RWMutex
+}
+
+// NewMemoryKPIStorage creates a new in-memory KPI store keeping KPIRetention of buckets
+func NewMemoryKPIStorage() *MemoryKPIStorage {
+	return &MemoryKPIStorage{
+		states:  make(map[string]*KPIVehicleState),
+		buckets: make(map[kpiBucketKey]*KPIBucket),
+	}
+}
+
+// copyKPIState returns a copy of a state that shares nothing with it
+func copyKPIState(state *KPIVehicleState) *KPIVehicleState {
+	copied := *state
+	copied.OnBoard = append([]string(nil), state.OnBoard...)
+	return &copied
+}
+
+func (m *MemoryKPIStorage) GetKPIState(ctx context.Context, vehicleID string) (*KPIVehicleState, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	state, exists := m.states[vehicleID]
+	if !exists {
+		return nil, ErrKPIStateNotFound
+	}
+	return copyKPIState(state), nil
+}
+
+func (m *MemoryKPIStorage) UpdateKPIs(ctx context.Context, state *KPIVehicleState, deltas []*KPIBucket) error {
+	if len(deltas) > MaxKPIBucketsPerUpdate {
+		return fmt.Errorf("cannot add to %d KPI buckets in one update", len(deltas))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var storedVersion int64
+	if existing, exists := m.states[state.VehicleID]; exists {
+		storedVersion = existing.Version
+	}
+	if storedVersion != state.Version {
+		return ErrVersionConflict
+	}
+
+	state.Version++
+	m.states[state.VehicleID] = copyKPIState(state)
+	for _, delta := range deltas {
+		m.addKPIs(delta)
+	}
+	return nil
+}
+
+func (m *MemoryKPIStorage) GetKPIStates(ctx context.Context) ([]*KPIVehicleState, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	states := make([]*KPIVehicleState, 0, len(m.states))
+	for _, state := range m.states {
+		states = append(states, copyKPIState(state))
+	}
+	return states, nil
+}
+
+// addKPIs adds amounts to a vehicle's bucket for an hour, creating the bucket if
+// needed. Must be called with the lock held.
+func (m *MemoryKPIStorage) addKPIs(delta *KPIBucket) {
+	key := kpiBucketKey{hour: delta.Hour.Unix(), vehicleID: delta.VehicleID}
+	bucket, exists := m.buckets[key]
+	if !exists {
+		bucket = &KPIBucket{Hour: delta.Hour.UTC(), VehicleID: delta.VehicleID}
+		m.buckets[key] = bucket
+	}
+	if delta.Region != "" {
+		bucket.Region = delta.Region
+	}
+	bucket.Add(delta)
+
+	m.prune(delta.Hour)
+}
+
+// prune drops buckets more than KPIRetention older than the hour last added to, at most
+// once an hour. Must be called with the lock held.
+func (m *MemoryKPIStorage) prune(latest time.Time) {
+	if latest.Sub(m.prunedAt) < time.Hour {
+		return
+	}
+	m.prunedAt = latest
+
+	cutoff := latest.Add(-KPIRetention).Unix()
+	for key := range m.buckets {
+		if key.hour < cutoff {
+			delete(m.buckets, key)
+		}
+	}
+}
+
+func (m *MemoryKPIStorage) GetKPIBuckets(ctx context.Context, from, to time.Time) ([]*KPIBucket, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result []*KPIBucket
+	for _, bucket := range m.buckets {
+		if !bucket.Hour.Before(from) && bucket.Hour.Before(to) {
+			found := *bucket
+			found.StatusSeconds = make(map[string]float64, len(bucket.StatusSeconds))
+			for status, seconds := range bucket.StatusSeconds {
+				found.StatusSeconds[status] = seconds
+			}
+			result = append(result, &found)
+		}
+	}
+
+	return result, nil
+}
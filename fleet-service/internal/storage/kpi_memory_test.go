@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryKPIStorage_UpdateKPIs_Accumulates(t *testing.T) {
+	storage := NewMemoryKPIStorage()
+	ctx := context.Background()
+	hour := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	state := &KPIVehicleState{VehicleID: "v1", Region: "us-west-2"}
+
+	assert.NoError(t, storage.UpdateKPIs(ctx, state, []*KPIBucket{{Hour: hour, VehicleID: "v1", Region: "us-west-2",
+		StatusSeconds: map[string]float64{"available": 600}, DeadheadKm: 1.5}}))
+	assert.NoError(t, storage.UpdateKPIs(ctx, state, []*KPIBucket{
+		{Hour: hour, VehicleID: "v1", StatusSeconds: map[string]float64{"available": 300, "busy": 900}, RevenueKm: 4, CompletedJobs: 1},
+		{Hour: hour.Add(time.Hour), VehicleID: "v1", DeadheadKm: 2},
+	}))
+
+	buckets, err := storage.GetKPIBuckets(ctx, hour, hour.Add(time.Hour))
+
+	assert.NoError(t, err)
+	if assert.Len(t, buckets, 1) {
+		assert.Equal(t, "us-west-2", buckets[0].Region)
+		assert.Equal(t, map[string]float64{"available": 900, "busy": 900}, buckets[0].StatusSeconds)
+		assert.Equal(t, 4.0, buckets[0].RevenueKm)
+		assert.Equal(t, 1.5, buckets[0].DeadheadKm)
+		assert.Equal(t, 1, buckets[0].CompletedJobs)
+
+		// Buckets are copies
+		buckets[0].StatusSeconds["busy"] = 0
+		again, _ := storage.GetKPIBuckets(ctx, hour, hour.Add(time.Hour))
+		assert.Equal(t, 900.0, again[0].StatusSeconds["busy"])
+	}
+}
+
+func TestMemoryKPIStorage_UpdateKPIs_VersionConflict(t *testing.T) {
+	storage := NewMemoryKPIStorage()
+	ctx := context.Background()
+	hour := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+
+	_, err := storage.GetKPIState(ctx, "v1")
+	assert.ErrorIs(t, err, ErrKPIStateNotFound)
+
+	state := &KPIVehicleState{VehicleID: "v1", Status: "available"}
+	assert.NoError(t, storage.UpdateKPIs(ctx, state, nil))
+	assert.Equal(t, int64(1), state.Version)
+
+	// Another instance read the state before the write above, so nothing it accrued is added
+	stale := &KPIVehicleState{VehicleID: "v1", Status: "busy"}
+	assert.ErrorIs(t, storage.UpdateKPIs(ctx, stale, []*KPIBucket{{Hour: hour, VehicleID: "v1", RevenueKm: 5}}), ErrVersionConflict)
+	buckets, _ := storage.GetKPIBuckets(ctx, hour, hour.Add(time.Hour))
+	assert.Empty(t, buckets)
+
+	current, err := storage.GetKPIState(ctx, "v1")
+	assert.NoError(t, err)
+	current.OnBoard = append(current.OnBoard, "job-1")
+	assert.NoError(t, storage.UpdateKPIs(ctx, current, nil))
+
+	states, err := storage.GetKPIStates(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, states, 1) {
+		assert.Equal(t, []string{"job-1"}, states[0].OnBoard)
+		assert.Equal(t, int64(2), states[0].Version)
+	}
+}
@@ -10,6 +10,7 @@ import (
 // MemoryVehicleStorage implements VehicleStorage using in-memory maps
 type MemoryVehicleStorage struct {
 	vehicles map[string]*Vehicle
+	cells    map[string]map[string]struct{} // vehicle IDs by geohash cell
 	mu       sync.RWMutex
 }
 
@@ -17,9 +18,29 @@ type MemoryVehicleStorage struct {
 func NewMemoryVehicleStorage() *MemoryVehicleStorage {
 	return &MemoryVehicleStorage{
 		vehicles: make(map[string]*Vehicle),
+		cells:    make(map[string]map[string]struct{}),
 	}
 }
 
+// moveToCell re-files a vehicle under the cell of its current location
+func (m *MemoryVehicleStorage) moveToCell(vehicle *Vehicle, previousCell string) {
+	vehicle.Cell = CellFor(vehicle.LocationLat, vehicle.LocationLng)
+	if vehicle.Cell == previousCell {
+		return
+	}
+
+	if members, ok := m.cells[previousCell]; ok {
+		delete(members, vehicle.ID)
+		if len(members) == 0 {
+			delete(m.cells, previousCell)
+		}
+	}
+	if m.cells[vehicle.Cell] == nil {
+		m.cells[vehicle.Cell] = make(map[string]struct{})
+	}
+	m.cells[vehicle.Cell][vehicle.ID] = struct{}{}
+}
+
 func (m *MemoryVehicleStorage) CreateVehicle(ctx context.Context, vehicle *Vehicle) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -29,7 +50,9 @@ func (m *MemoryVehicleStorage) CreateVehicle(ctx context.Context, vehicle *Vehic
 	}
 
 	vehicle.LastUpdated = time.Now()
+	vehicle.Version = 1
 	m.vehicles[vehicle.ID] = vehicle
+	m.moveToCell(vehicle, "")
 	return nil
 }
 
@@ -49,12 +72,19 @@ func (m *MemoryVehicleStorage) UpdateVehicle(ctx context.Context, vehicle *Vehic
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if _, exists := m.vehicles[vehicle.ID]; !exists {
+	existing, exists := m.vehicles[vehicle.ID]
+	if !exists {
 		return fmt.Errorf("vehicle %s not found", vehicle.ID)
 	}
 
+	if existing.Version != vehicle.Version {
+		return ErrVersionConflict
+	}
+
 	vehicle.LastUpdated = time.Now()
+	vehicle.Version++
 	m.vehicles[vehicle.ID] = vehicle
+	m.moveToCell(vehicle, existing.Cell)
 	return nil
 }
 
@@ -72,6 +102,23 @@ func (m *MemoryVehicleStorage) GetVehiclesByRegionAndStatus(ctx context.Context,
 	return result, nil
 }
 
+func (m *MemoryVehicleStorage) GetVehiclesByCells(ctx context.Context, region, status string, cells []string) ([]*Vehicle, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result []*Vehicle
+	for _, cell := range cells {
+		for id := range m.cells[cell] {
+			vehicle := m.vehicles[id]
+			if vehicle.Region == region && vehicle.Status == status {
+				result = append(result, vehicle)
+			}
+		}
+	}
+
+	return result, nil
+}
+
 func (m *MemoryVehicleStorage) GetAllVehicles(ctx context.Context) ([]*Vehicle, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -97,6 +144,8 @@ func (m *MemoryVehicleStorage) UpdateVehicleLocationAndStatus(ctx context.Contex
 	vehicle.LocationLng = lng
 	vehicle.Status = status
 	vehicle.LastUpdated = time.Now()
+	vehicle.Version++
+	m.moveToCell(vehicle, vehicle.Cell)
 	return nil
 }
 
@@ -112,6 +161,8 @@ func (m *MemoryVehicleStorage) UpdateVehicleLocation(ctx context.Context, vehicl
 	vehicle.LocationLat = lat
 	vehicle.LocationLng = lng
 	vehicle.LastUpdated = time.Now()
+	vehicle.Version++
+	m.moveToCell(vehicle, vehicle.Cell)
 
 	return nil
 }
@@ -128,6 +179,28 @@ func (m *MemoryVehicleStorage) UpdateVehicleStatus(ctx context.Context, vehicleI
 	vehicle.Status = status
 	vehicle.CurrentJobID = jobID
 	vehicle.LastUpdated = time.Now()
+	vehicle.Version++
+
+	return nil
+}
+
+func (m *MemoryVehicleStorage) AssignJobIfAvailable(ctx context.Context, vehicleID, jobID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	vehicle, exists := m.vehicles[vehicleID]
+	if !exists {
+		return fmt.Errorf("vehicle %s not found", vehicleID)
+	}
+
+	if vehicle.Status != "available" || (vehicle.CurrentJobID != nil && *vehicle.CurrentJobID != "") {
+		return ErrVehicleNotAvailable
+	}
+
+	vehicle.Status = "busy"
+	vehicle.CurrentJobID = &jobID
+	vehicle.LastUpdated = time.Now()
+	vehicle.Version++
 
 	return nil
 }
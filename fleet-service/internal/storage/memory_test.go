@@ -2,6 +2,10 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
 	"testing"
 )
 
@@ -163,3 +167,207 @@ func TestMemoryVehicleStorage_GetVehiclesByRegionAndStatus(t *testing.T) {
 		t.Error("Expected vehicles v1 and v4 to be returned")
 	}
 }
+
+func TestMemoryVehicleStorage_AssignJobIfAvailable(t *testing.T) {
+	storage := NewMemoryVehicleStorage()
+	ctx := context.Background()
+
+	vehicle := &Vehicle{
+		ID:             "test-vehicle-1",
+		Region:         "us-west-2",
+		Status:         "available",
+		BatteryLevel:   80,
+		BatteryRangeKm: 200.0,
+		LocationLat:    37.7749,
+		LocationLng:    -122.4194,
+		VehicleType:    "sedan",
+	}
+
+	storage.CreateVehicle(ctx, vehicle)
+
+	err := storage.AssignJobIfAvailable(ctx, "test-vehicle-1", "job-1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	updated, _ := storage.GetVehicle(ctx, "test-vehicle-1")
+	if updated.Status != "busy" {
+		t.Errorf("Expected status 'busy', got '%s'", updated.Status)
+	}
+	if updated.CurrentJobID == nil || *updated.CurrentJobID != "job-1" {
+		t.Errorf("Expected job ID 'job-1', got %v", updated.CurrentJobID)
+	}
+
+	// A second assignment must not overwrite the first
+	err = storage.AssignJobIfAvailable(ctx, "test-vehicle-1", "job-2")
+	if !errors.Is(err, ErrVehicleNotAvailable) {
+		t.Fatalf("Expected ErrVehicleNotAvailable, got %v", err)
+	}
+	if *updated.CurrentJobID != "job-1" {
+		t.Errorf("Expected job ID to remain 'job-1', got %s", *updated.CurrentJobID)
+	}
+}
+
+func TestMemoryVehicleStorage_AssignJobIfAvailable_Concurrent(t *testing.T) {
+	storage := NewMemoryVehicleStorage()
+	ctx := context.Background()
+
+	storage.CreateVehicle(ctx, &Vehicle{
+		ID:             "test-vehicle-1",
+		Region:         "us-west-2",
+		Status:         "available",
+		BatteryRangeKm: 200.0,
+	})
+
+	const dispatchers = 10
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	successes := 0
+
+	for i := 0; i < dispatchers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := storage.AssignJobIfAvailable(ctx, "test-vehicle-1", fmt.Sprintf("job-%d", i)); err == nil {
+				mu.Lock()
+				successes++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if successes != 1 {
+		t.Errorf("Expected exactly 1 successful assignment, got %d", successes)
+	}
+}
+
+func TestMemoryVehicleStorage_UpdateVehicle_VersionConflict(t *testing.T) {
+	storage := NewMemoryVehicleStorage()
+	ctx := context.Background()
+
+	storage.CreateVehicle(ctx, &Vehicle{
+		ID:             "test-vehicle-1",
+		Region:         "us-west-2",
+		Status:         "available",
+		BatteryRangeKm: 200.0,
+	})
+
+	// Two writers read the same version
+	stored, _ := storage.GetVehicle(ctx, "test-vehicle-1")
+	if stored.Version != 1 {
+		t.Fatalf("Expected new vehicle at version 1, got %d", stored.Version)
+	}
+	first := *stored
+	second := *stored
+
+	first.Status = "busy"
+	if err := storage.UpdateVehicle(ctx, &first); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if first.Version != 2 {
+		t.Errorf("Expected version 2 after update, got %d", first.Version)
+	}
+
+	// The stale writer must be rejected instead of clobbering the first update
+	second.LocationLat = 45.0
+	if err := storage.UpdateVehicle(ctx, &second); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("Expected ErrVersionConflict, got %v", err)
+	}
+
+	current, _ := storage.GetVehicle(ctx, "test-vehicle-1")
+	if current.Status != "busy" {
+		t.Errorf("Expected status 'busy' to survive, got '%s'", current.Status)
+	}
+
+	// Partial updates also advance the version
+	storage.UpdateVehicleLocation(ctx, "test-vehicle-1", 45.1, -122.6)
+	current, _ = storage.GetVehicle(ctx, "test-vehicle-1")
+	if current.Version != 3 {
+		t.Errorf("Expected version 3 after location update, got %d", current.Version)
+	}
+}
+
+func TestMemoryVehicleStorage_GetVehiclesByCells(t *testing.T) {
+	storage := NewMemoryVehicleStorage()
+	ctx := context.Background()
+
+	storage.CreateVehicle(ctx, &Vehicle{ID: "downtown", Region: "us-west-2", Status: "available", LocationLat: 37.7749, LocationLng: -122.4194})
+	storage.CreateVehicle(ctx, &Vehicle{ID: "busy", Region: "us-west-2", Status: "busy", LocationLat: 37.7749, LocationLng: -122.4194})
+	storage.CreateVehicle(ctx, &Vehicle{ID: "oakland", Region: "us-west-2", Status: "available", LocationLat: 37.8044, LocationLng: -122.2712})
+
+	downtown := CellFor(37.7749, -122.4194)
+	vehicles, _ := storage.GetVehiclesByCells(ctx, "us-west-2", "available", []string{downtown})
+	if len(vehicles) != 1 || vehicles[0].ID != "downtown" {
+		t.Fatalf("Expected only 'downtown' in its cell, got %v", vehicles)
+	}
+
+	// Moving keeps the index in step with the location
+	storage.UpdateVehicleLocation(ctx, "oakland", 37.7749, -122.4194)
+	vehicles, _ = storage.GetVehiclesByCells(ctx, "us-west-2", "available", []string{downtown})
+	if len(vehicles) != 2 {
+		t.Errorf("Expected 2 vehicles after moving into the cell, got %d", len(vehicles))
+	}
+	vehicles, _ = storage.GetVehiclesByCells(ctx, "us-west-2", "available", []string{CellFor(37.8044, -122.2712)})
+	if len(vehicles) != 0 {
+		t.Errorf("Expected the old cell to be empty, got %d vehicles", len(vehicles))
+	}
+
+	// Full updates move the vehicle too
+	stored, _ := storage.GetVehicle(ctx, "downtown")
+	moved := *stored
+	moved.LocationLat, moved.LocationLng = 37.8044, -122.2712
+	if err := storage.UpdateVehicle(ctx, &moved); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if moved.Cell != CellFor(37.8044, -122.2712) {
+		t.Errorf("Expected cell %s after update, got %s", CellFor(37.8044, -122.2712), moved.Cell)
+	}
+	vehicles, _ = storage.GetVehiclesByCells(ctx, "us-west-2", "available", []string{downtown})
+	if len(vehicles) != 1 || vehicles[0].ID != "oakland" {
+		t.Errorf("Expected only 'oakland' left downtown, got %v", vehicles)
+	}
+}
+
+// seedFleet spreads size available vehicles over a square grid roughly 250m apart,
+// so the density around any point stays the same as the fleet grows
+func seedFleet(b *testing.B, storage *MemoryVehicleStorage, size int) {
+	ctx := context.Background()
+	side := int(math.Ceil(math.Sqrt(float64(size))))
+	for i := 0; i < size; i++ {
+		storage.CreateVehicle(ctx, &Vehicle{
+			ID:             fmt.Sprintf("vehicle-%d", i),
+			Region:         "us-west-2",
+			Status:         "available",
+			BatteryRangeKm: 200,
+			LocationLat:    37.0 + float64(i/side)*0.00225,
+			LocationLng:    -122.0 + float64(i%side)*0.0028,
+		})
+	}
+}
+
+// BenchmarkMemoryVehicleStorage_NearbyLookup compares reading the vehicles around a
+// point through the cell index with scanning the region, across fleet sizes
+func BenchmarkMemoryVehicleStorage_NearbyLookup(b *testing.B) {
+	ctx := context.Background()
+	for _, size := range []int{1000, 10000, 100000} {
+		storage := NewMemoryVehicleStorage()
+		seedFleet(b, storage, size)
+
+		var cells []string
+		for ring := 0; ring <= 2; ring++ {
+			cells = append(cells, CellRing(37.01, -121.99, ring)...)
+		}
+
+		b.Run(fmt.Sprintf("cells/fleet=%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				storage.GetVehiclesByCells(ctx, "us-west-2", "available", cells)
+			}
+		})
+		b.Run(fmt.Sprintf("region/fleet=%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				storage.GetVehiclesByRegionAndStatus(ctx, "us-west-2", "available")
+			}
+		})
+	}
+}
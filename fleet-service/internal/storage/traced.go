@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"shared/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("fleet-service/internal/storage")
+
+// TracedVehicleStorage records a span for every call to the storage it wraps
+type TracedVehicleStorage struct {
+	next VehicleStorage
+}
+
+// NewTracedVehicleStorage wraps next so its calls show up in request traces
+func NewTracedVehicleStorage(next VehicleStorage) *TracedVehicleStorage {
+	return &TracedVehicleStorage{next: next}
+}
+
+// startSpan starts a client span for a storage operation
+func startSpan(ctx context.Context, store, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, store+"."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(append(attrs, attribute.String("db.operation.name", operation))...))
+}
+
+// endListSpan ends a span for an operation returning count items
+func endListSpan(span trace.Span, count int, err error) {
+	span.SetAttributes(attribute.Int("db.response.returned_rows", count))
+	tracing.End(span, err)
+}
+
+func (s *TracedVehicleStorage) CreateVehicle(ctx context.Context, vehicle *Vehicle) (err error) {
+	ctx, span := startSpan(ctx, "VehicleStorage", "CreateVehicle", tracing.VehicleID(vehicle.ID))
+	defer func() { tracing.End(span, err) }()
+	return s.next.CreateVehicle(ctx, vehicle)
+}
+
+func (s *TracedVehicleStorage) GetVehicle(ctx context.Context, vehicleID string) (vehicle *Vehicle, err error) {
+	ctx, span := startSpan(ctx, "VehicleStorage", "GetVehicle", tracing.VehicleID(vehicleID))
+	defer func() { tracing.End(span, err) }()
+	return s.next.GetVehicle(ctx, vehicleID)
+}
+
+func (s *TracedVehicleStorage) UpdateVehicle(ctx context.Context, vehicle *Vehicle) (err error) {
+	ctx, span := startSpan(ctx, "VehicleStorage", "UpdateVehicle", tracing.VehicleID(vehicle.ID))
+	defer func() { tracing.End(span, err) }()
+	return s.next.UpdateVehicle(ctx, vehicle)
+}
+
+func (s *TracedVehicleStorage) GetVehiclesByRegionAndStatus(ctx context.Context, region, status string) (vehicles []*Vehicle, err error) {
+	ctx, span := startSpan(ctx, "VehicleStorage", "GetVehiclesByRegionAndStatus", attribute.String("region", region), attribute.String("vehicle.status", status))
+	defer func() { endListSpan(span, len(vehicles), err) }()
+	return s.next.GetVehiclesByRegionAndStatus(ctx, region, status)
+}
+
+func (s *TracedVehicleStorage) GetVehiclesByCells(ctx context.Context, region, status string, cells []string) (vehicles []*Vehicle, err error) {
+	ctx, span := startSpan(ctx, "VehicleStorage", "GetVehiclesByCells", attribute.String("region", region), attribute.String("vehicle.status", status), attribute.Int("cell.count", len(cells)))
+	defer func() { endListSpan(span, len(vehicles), err) }()
+	return s.next.GetVehiclesByCells(ctx, region, status, cells)
+}
+
+func (s *TracedVehicleStorage) GetAllVehicles(ctx context.Context) (vehicles []*Vehicle, err error) {
+	ctx, span := startSpan(ctx, "VehicleStorage", "GetAllVehicles")
+	defer func() { endListSpan(span, len(vehicles), err) }()
+	return s.next.GetAllVehicles(ctx)
+}
+
+func (s *TracedVehicleStorage) UpdateVehicleLocationAndStatus(ctx context.Context, vehicleID string, lat, lng float64, status string) (err error) {
+	ctx, span := startSpan(ctx, "VehicleStorage", "UpdateVehicleLocationAndStatus", tracing.VehicleID(vehicleID), attribute.String("vehicle.status", status))
+	defer func() { tracing.End(span, err) }()
+	return s.next.UpdateVehicleLocationAndStatus(ctx, vehicleID, lat, lng, status)
+}
+
+func (s *TracedVehicleStorage) UpdateVehicleLocation(ctx context.Context, vehicleID string, lat, lng float64) (err error) {
+	ctx, span := startSpan(ctx, "VehicleStorage", "UpdateVehicleLocation", tracing.VehicleID(vehicleID))
+	defer func() { tracing.End(span, err) }()
+	return s.next.UpdateVehicleLocation(ctx, vehicleID, lat, lng)
+}
+
+func (s *TracedVehicleStorage) UpdateVehicleStatus(ctx context.Context, vehicleID string, status string, jobID *string) (err error) {
+	attrs := []attribute.KeyValue{tracing.VehicleID(vehicleID), attribute.String("vehicle.status", status)}
+	if jobID != nil {
+		attrs = append(attrs, tracing.JobID(*jobID))
+	}
+	ctx, span := startSpan(ctx, "VehicleStorage", "UpdateVehicleStatus", attrs...)
+	defer func() { tracing.End(span, err) }()
+	return s.next.UpdateVehicleStatus(ctx, vehicleID, status, jobID)
+}
+
+func (s *TracedVehicleStorage) AssignJobIfAvailable(ctx context.Context, vehicleID, jobID string) (err error) {
+	ctx, span := startSpan(ctx, "VehicleStorage", "AssignJobIfAvailable", tracing.VehicleID(vehicleID), tracing.JobID(jobID))
+	defer func() { tracing.End(span, err) }()
+	return s.next.AssignJobIfAvailable(ctx, vehicleID, jobID)
+}
+
+// TracedKPIStorage records a span for every call to the KPI storage it wraps
+type TracedKPIStorage struct {
+	next KPIStorage
+}
+
+// NewTracedKPIStorage wraps next so its calls show up in request traces
+func NewTracedKPIStorage(next KPIStorage) *TracedKPIStorage {
+	return &TracedKPIStorage{next: next}
+}
+
+func (s *TracedKPIStorage) GetKPIState(ctx context.Context, vehicleID string) (state *KPIVehicleState, err error) {
+	ctx, span := startSpan(ctx, "KPIStorage", "GetKPIState", tracing.VehicleID(vehicleID))
+	defer func() { tracing.End(span, err) }()
+	return s.next.GetKPIState(ctx, vehicleID)
+}
+
+func (s *TracedKPIStorage) UpdateKPIs(ctx context.Context, state *KPIVehicleState, deltas []*KPIBucket) (err error) {
+	ctx, span := startSpan(ctx, "KPIStorage", "UpdateKPIs", tracing.VehicleID(state.VehicleID))
+	defer func() { tracing.End(span, err) }()
+	return s.next.UpdateKPIs(ctx, state, deltas)
+}
+
+func (s *TracedKPIStorage) GetKPIStates(ctx context.Context) (states []*KPIVehicleState, err error) {
+	ctx, span := startSpan(ctx, "KPIStorage", "GetKPIStates")
+	defer func() { endListSpan(span, len(states), err) }()
+	return s.next.GetKPIStates(ctx)
+}
+
+func (s *TracedKPIStorage) GetKPIBuckets(ctx context.Context, from, to time.Time) (buckets []*KPIBucket, err error) {
+	ctx, span := startSpan(ctx, "KPIStorage", "GetKPIBuckets")
+	defer func() { endListSpan(span, len(buckets), err) }()
+	return s.next.GetKPIBuckets(ctx, from, to)
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"shared/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracedVehicleStorage_RecordsSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	storage := NewTracedVehicleStorage(NewMemoryVehicleStorage())
+	ctx := context.Background()
+
+	if err := storage.CreateVehicle(ctx, &Vehicle{ID: "v1", Region: "us-west-2", Status: "available"}); err != nil {
+		t.Fatalf("Failed to create vehicle: %v", err)
+	}
+	if err := storage.AssignJobIfAvailable(ctx, "v1", "job-1"); err != nil {
+		t.Fatalf("Failed to assign job: %v", err)
+	}
+	if err := storage.AssignJobIfAvailable(ctx, "v1", "job-2"); !errors.Is(err, ErrVehicleNotAvailable) {
+		t.Fatalf("Expected ErrVehicleNotAvailable, got %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("Expected 3 spans, got %d", len(spans))
+	}
+
+	assigned := spans[1]
+	if assigned.Name() != "VehicleStorage.AssignJobIfAvailable" || assigned.Status().Code != codes.Unset {
+		t.Errorf("Expected a successful assignment span, got %s with %v", assigned.Name(), assigned.Status())
+	}
+	if !hasAttribute(assigned.Attributes(), tracing.JobID("job-1")) || !hasAttribute(assigned.Attributes(), tracing.VehicleID("v1")) {
+		t.Errorf("Expected the job and vehicle IDs on the span, got %v", assigned.Attributes())
+	}
+
+	// The vehicle was already taken, so the second assignment's span is marked failed
+	if rejected := spans[2]; rejected.Status().Code != codes.Error || !hasAttribute(rejected.Attributes(), tracing.JobID("job-2")) {
+		t.Errorf("Expected a failed span for job-2, got %v with %v", rejected.Status(), rejected.Attributes())
+	}
+}
+
+func hasAttribute(attrs []attribute.KeyValue, want attribute.KeyValue) bool {
+	for _, attr := range attrs {
+		if attr == want {
+			return true
+		}
+	}
+	return false
+}
@@ -188,6 +188,53 @@ func (c *HTTPClient) GetJob(jobID string) (*Job, error) {
 	return &job, nil
 }
 
+// RegisterVehicle registers a vehicle directly with the fleet service
+func (c *HTTPClient) RegisterVehicle(vehicle *Vehicle) error {
+	jsonData, err := json.Marshal(vehicle)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.Post("http://localhost:8080/vehicles", "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("fleet service returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
+
+// GetVehicleJobs retrieves the jobs assigned to a vehicle with the given status,
+// along with the size of the response body in bytes
+func (c *HTTPClient) GetVehicleJobs(vehicleID, status string) ([]*Job, int, error) {
+	resp, err := c.client.Get(fmt.Sprintf("http://localhost:8081/vehicles/%s/jobs?status=%s", vehicleID, status))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("job service returned status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var jobs []*Job
+	if err := json.Unmarshal(body, &jobs); err != nil {
+		return nil, 0, err
+	}
+
+	return jobs, len(body), nil
+}
+
 // parseJSONResponse is a helper function to parse JSON responses
 func parseJSONResponse(resp *http.Response, v interface{}) error {
 	defer resp.Body.Close()
@@ -41,6 +41,7 @@ func (sm *ServiceManager) StartServices() error {
 	sm.jobCmd.Env = append(os.Environ(), 
 		"PORT=8081",
 		"FLEET_SERVICE_URL=http://localhost:8080",
+		"QUOTE_SIGNING_KEY=integration-test-quote-key",
 	)
 	if err := sm.jobCmd.Start(); err != nil {
 		sm.StopServices()
@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"integration-tests/internal/testhelpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVehicleJobPollLoad(t *testing.T) {
+	// Setup: Start Fleet and Job services only; vehicles are registered directly
+	sm := testhelpers.NewServiceManager()
+	defer sm.StopServices()
+
+	err := sm.StartServices()
+	require.NoError(t, err, "Failed to start services")
+
+	client := testhelpers.NewHTTPClient()
+
+	err = client.RegisterVehicle(&testhelpers.Vehicle{
+		ID:             "poll-vehicle",
+		Region:         "us-west-2",
+		Status:         "available",
+		BatteryLevel:   90,
+		BatteryRangeKm: 300.0,
+		LocationLat:    37.7749,
+		LocationLng:    -122.4194,
+		VehicleType:    "sedan",
+	})
+	require.NoError(t, err)
+
+	// The only vehicle takes the first job; everything after it stays pending
+	assignedJob, err := client.CreateRideJob("customer-poll", "us-west-2", 37.7749, -122.4194, 37.7849, -122.4094)
+	require.NoError(t, err)
+	require.Equal(t, "assigned", assignedJob.Status)
+
+	jobs, baselineSize, err := client.GetVehicleJobs("poll-vehicle", "assigned")
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, assignedJob.ID, jobs[0].ID)
+
+	// Grow the job history and check the poll response doesn't grow with it
+	const historyBatches = 4
+	const jobsPerBatch = 50
+	for batch := 1; batch <= historyBatches; batch++ {
+		for i := 0; i < jobsPerBatch; i++ {
+			_, err := client.CreateRideJob(fmt.Sprintf("customer-history-%d-%d", batch, i), "us-west-2",
+				37.7749, -122.4194, 37.7849, -122.4094)
+			require.NoError(t, err)
+		}
+
+		allJobs, err := client.GetJobs()
+		require.NoError(t, err)
+		assert.Equal(t, 1+batch*jobsPerBatch, len(allJobs))
+
+		jobs, size, err := client.GetVehicleJobs("poll-vehicle", "assigned")
+		require.NoError(t, err)
+		assert.Len(t, jobs, 1)
+		assert.Equal(t, baselineSize, size, "poll response size changed after %d jobs", len(allJobs))
+	}
+
+	// Simulate a fleet of vehicles polling at once
+	t.Run("ConcurrentPolls", func(t *testing.T) {
+		const pollers = 100
+
+		var wg sync.WaitGroup
+		sizes := make([]int, pollers)
+		errs := make([]error, pollers)
+		for i := 0; i < pollers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, sizes[i], errs[i] = client.GetVehicleJobs("poll-vehicle", "assigned")
+			}(i)
+		}
+		wg.Wait()
+
+		for i := 0; i < pollers; i++ {
+			require.NoError(t, errs[i])
+			assert.Equal(t, baselineSize, sizes[i])
+		}
+	})
+
+	// A vehicle with no jobs gets an empty list rather than an error
+	t.Run("IdleVehicle", func(t *testing.T) {
+		jobs, _, err := client.GetVehicleJobs("idle-vehicle", "assigned")
+		require.NoError(t, err)
+		assert.Empty(t, jobs)
+	})
+}
@@ -8,12 +8,17 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata" // pricing rule time zones, which the runtime image has no database for
 
 	"job-service/internal/fleet"
 	"job-service/internal/handlers"
 	"job-service/internal/kinesis"
+	"job-service/internal/metrics"
+	"job-service/internal/payments"
 	"job-service/internal/service"
 	"job-service/internal/storage"
+	"shared/routing"
+	"shared/tracing"
 
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
@@ -28,6 +33,13 @@ func main() {
 	}))
 	slog.SetDefault(logger)
 
+	// Export traces to an OTLP collector or stdout when configured
+	shutdownTracing, err := tracing.Setup(context.Background(), "job-service", getEnv("OTEL_TRACES_EXPORTER", tracing.ExporterNone))
+	if err != nil {
+		slog.Error("Failed to set up tracing", "error", err)
+		os.Exit(1)
+	}
+
 	// Get configuration from environment
 	fleetServiceURL := getEnv("FLEET_SERVICE_URL", "http://localhost:8080")
 	port := getEnv("PORT", "8081")
@@ -37,6 +49,8 @@ func main() {
 
 	// Initialize storage based on configuration
 	var jobStorage storage.JobStorage
+	var ledgerStorage storage.LedgerStorage
+	var revenueStorage storage.RevenueStorage
 	switch storageType {
 	case "dynamodb":
 		tableName := getEnv("DYNAMODB_JOBS_TABLE", "fleet-jobs")
@@ -50,17 +64,71 @@ func main() {
 
 		dynamoClient := dynamodb.NewFromConfig(cfg)
 		jobStorage = storage.NewDynamoDBJobStorage(dynamoClient, tableName)
-		slog.Info("Using DynamoDB storage", "table_name", tableName)
+
+		customersTable := getEnv("DYNAMODB_CUSTOMERS_TABLE", "fleet-customers")
+		ledgerTable := getEnv("DYNAMODB_LEDGER_TABLE", "fleet-ledger")
+		ledgerStorage = storage.NewDynamoDBLedgerStorage(dynamoClient, customersTable, ledgerTable)
+
+		revenueTable := getEnv("DYNAMODB_REVENUE_TABLE", "fleet-revenue")
+		revenueStorage = storage.NewDynamoDBRevenueStorage(dynamoClient, revenueTable)
+		slog.Info("Using DynamoDB storage", "table_name", tableName, "customers_table", customersTable, "ledger_table", ledgerTable, "revenue_table", revenueTable)
 	default:
 		jobStorage = storage.NewMemoryJobStorage()
+		ledgerStorage = storage.NewMemoryLedgerStorage()
+		revenueStorage = storage.NewMemoryRevenueStorage()
 		slog.Info("Using in-memory storage")
 	}
 
 	// Initialize fleet client
 	fleetClient := fleet.NewClient(fleetServiceURL)
 
-	// Initialize service
-	jobService := service.NewJobService(jobStorage, fleetClient)
+	// Initialize service, with a span for every storage call
+	jobService := service.NewJobService(storage.NewTracedJobStorage(jobStorage), fleetClient)
+	jobService.SetLedger(storage.NewTracedLedgerStorage(ledgerStorage))
+	jobService.SetRevenueStorage(storage.NewTracedRevenueStorage(revenueStorage))
+
+	// Payments are collected by a fake provider until a real one is integrated
+	jobService.SetPaymentProvider(payments.NewFakeProvider())
+
+	// Select dispatch strategy (greedy nearest-vehicle or batch matching)
+	if dispatchMode, err := service.ParseDispatchMode(getEnv("DISPATCH_MODE", "greedy")); err != nil {
+		slog.Warn("Invalid dispatch mode, falling back to greedy", "error", err)
+	} else {
+		jobService.SetDispatchMode(dispatchMode)
+		slog.Info("Dispatch mode configured", "mode", dispatchMode)
+	}
+
+	// Release scheduled bookings this long before pickup, plus the pickup drive
+	jobService.SetScheduleLeadTime(getEnvDuration("SCHEDULE_LEAD_TIME", "10m"))
+
+	// Hold new deliveries this long so orders going the same way share a vehicle
+	jobService.SetDeliveryBatchWindow(getEnvDuration("DELIVERY_BATCH_WINDOW", "30s"))
+
+	// Price jobs from a rule set file, reloadable through the admin API
+	if pricingFile := getEnv("PRICING_RULES_FILE", ""); pricingFile != "" {
+		rules, err := jobService.LoadPricingFile(pricingFile)
+		if err != nil {
+			slog.Error("Failed to load pricing rules", "file", pricingFile, "error", err)
+			os.Exit(1)
+		}
+		slog.Info("Pricing rules loaded", "file", pricingFile, "version", rules.Version, "rules", len(rules.Rules))
+	}
+
+	// Sign quotes with a key every instance shares, so a quote issued by one is honoured
+	// by the others and across deploys
+	quoteKey := getEnv("QUOTE_SIGNING_KEY", "")
+	if quoteKey == "" {
+		slog.Error("QUOTE_SIGNING_KEY environment variable not set")
+		os.Exit(1)
+	}
+	jobService.SetQuoteSigningKey([]byte(quoteKey))
+	jobService.SetQuoteTTL(getEnvDuration("QUOTE_TTL", "5m"))
+
+	// Estimate trip distances over roads when an OSRM server is configured
+	if osrmURL := getEnv("ROUTING_OSRM_URL", ""); osrmURL != "" {
+		jobService.SetRouter(routing.NewRouter(osrmURL, metrics.RoutingFallbacks.Inc))
+		slog.Info("Road routing enabled", "osrm_url", osrmURL)
+	}
 
 	// Initialize Kinesis streamer if stream name is provided
 	if streamName := getEnv("KINESIS_JOB_EVENTS_STREAM", ""); streamName != "" {
@@ -80,6 +148,13 @@ func main() {
 	jobProcessor.Start()
 	defer jobProcessor.Stop()
 
+	// Initialize watchdog for jobs abandoned by their vehicles
+	jobWatchdog := service.NewJobWatchdog(jobService,
+		getEnvDuration("JOB_WATCHDOG_INTERVAL", "15s"),
+		getEnvDuration("VEHICLE_STALE_AFTER", "60s"))
+	jobWatchdog.Start()
+	defer jobWatchdog.Stop()
+
 	// Initialize demo job generator
 	var demoGenerator *service.DemoJobGenerator
 	var demoHandler *handlers.DemoHandler
@@ -113,6 +188,13 @@ func main() {
 		router.HandleFunc("/demo/status", demoHandler.GetDemoStatus).Methods("GET")
 	}
 
+	// Continue traces started by callers, with a span per request named by route
+	router.Use(tracing.Middleware("job-service"))
+
+	// Expose Prometheus metrics and time every request by route
+	router.Handle("/metrics", metrics.Handler()).Methods("GET")
+	router.Use(metrics.Middleware)
+
 	// Add CORS middleware for frontend
 	router.Use(corsMiddleware)
 
@@ -135,6 +217,13 @@ func main() {
 	if demoGenerator != nil {
 		demoGenerator.Stop()
 	}
+
+	// Flush any spans not yet exported
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Warn("Failed to flush traces", "error", err)
+	}
 }
 
 // getEnv gets environment variable with default value
@@ -161,7 +250,8 @@ func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, If-Match")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
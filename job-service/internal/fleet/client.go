@@ -10,25 +10,50 @@ import (
 	"net/url"
 	"strconv"
 	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 // Common errors
 var (
 	ErrNoVehicleAvailable = errors.New("no vehicle available")
 	ErrVehicleNotFound    = errors.New("vehicle not found")
+	ErrVehicleUnavailable = errors.New("vehicle already assigned")
+	ErrVehicleReassigned  = errors.New("vehicle no longer assigned to job")
+	ErrNoPoolMatch        = errors.New("no shared vehicle can take the ride")
 )
 
 // Vehicle represents a vehicle from the fleet service
 type Vehicle struct {
-	ID             string  `json:"id"`
-	Region         string  `json:"region"`
-	Status         string  `json:"status"`
-	BatteryLevel   int     `json:"battery_level"`
-	BatteryRangeKm float64 `json:"battery_range_km"`
-	LocationLat    float64 `json:"location_lat"`
-	LocationLng    float64 `json:"location_lng"`
-	CurrentJobID   *string `json:"current_job_id,omitempty"`
-	VehicleType    string  `json:"vehicle_type"`
+	ID             string    `json:"id"`
+	Region         string    `json:"region"`
+	Status         string    `json:"status"`
+	BatteryLevel   int       `json:"battery_level"`
+	BatteryRangeKm float64   `json:"battery_range_km"`
+	LocationLat    float64   `json:"location_lat"`
+	LocationLng    float64   `json:"location_lng"`
+	CurrentJobID   *string   `json:"current_job_id,omitempty"`
+	VehicleType    string    `json:"vehicle_type"`
+	LastUpdated    time.Time `json:"last_updated"`
+}
+
+// SharedRide is a ride that may share its vehicle with other riders
+type SharedRide struct {
+	JobID      string  `json:"job_id"`
+	Region     string  `json:"region"`
+	PickupLat  float64 `json:"pickup_lat"`
+	PickupLng  float64 `json:"pickup_lng"`
+	DropoffLat float64 `json:"dropoff_lat"`
+	DropoffLng float64 `json:"dropoff_lng"`
+	Seats      int     `json:"seats"`
+}
+
+// PlannedStop is a stop on a vehicle's route serving several jobs, in visiting order
+type PlannedStop struct {
+	JobID string  `json:"job_id"`
+	Type  string  `json:"type"` // pickup, dropoff
+	Lat   float64 `json:"lat"`
+	Lng   float64 `json:"lng"`
 }
 
 // Client handles communication with the Fleet Service
@@ -43,17 +68,22 @@ func NewClient(baseURL string) *Client {
 		baseURL: baseURL,
 		httpClient: &http.Client{
 			Timeout: 10 * time.Second,
+			// Carry the caller's trace over to the fleet service
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
 		},
 	}
 }
 
-// FindNearestVehicle finds the nearest available vehicle for a job
-func (c *Client) FindNearestVehicle(ctx context.Context, region string, pickupLat, pickupLng, tripDistanceKm float64) (*Vehicle, error) {
+// FindNearestVehicle finds the nearest available vehicle for a job, skipping excludeVehicleIDs
+func (c *Client) FindNearestVehicle(ctx context.Context, region string, pickupLat, pickupLng, tripDistanceKm float64, excludeVehicleIDs ...string) (*Vehicle, error) {
 	params := url.Values{}
 	params.Add("region", region)
 	params.Add("pickup_lat", strconv.FormatFloat(pickupLat, 'f', 6, 64))
 	params.Add("pickup_lng", strconv.FormatFloat(pickupLng, 'f', 6, 64))
 	params.Add("trip_distance_km", strconv.FormatFloat(tripDistanceKm, 'f', 2, 64))
+	for _, vehicleID := range excludeVehicleIDs {
+		params.Add("exclude", vehicleID)
+	}
 
 	url := fmt.Sprintf("%s/vehicles/find?%s", c.baseURL, params.Encode())
 
@@ -113,12 +143,171 @@ func (c *Client) AssignJob(ctx context.Context, vehicleID, jobID string) error {
 		if resp.StatusCode == http.StatusNotFound {
 			return ErrVehicleNotFound
 		}
+		if resp.StatusCode == http.StatusConflict {
+			return ErrVehicleUnavailable
+		}
 		return fmt.Errorf("failed to assign job, status: %d", resp.StatusCode)
 	}
 
 	return nil
 }
 
+// ReleaseVehicle returns a vehicle to the available pool after its job was cancelled
+func (c *Client) ReleaseVehicle(ctx context.Context, vehicleID, jobID string) error {
+	release := struct {
+		JobID string `json:"job_id"`
+	}{
+		JobID: jobID,
+	}
+
+	jsonData, err := json.Marshal(release)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/vehicles/%s/release", c.baseURL, vehicleID)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusConflict {
+			return ErrVehicleReassigned
+		}
+		return fmt.Errorf("failed to release vehicle, status: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// StartBatch claims an available vehicle for a batch of deliveries visiting stops in order
+func (c *Client) StartBatch(ctx context.Context, vehicleID string, stops []PlannedStop) error {
+	resp, err := c.postJSON(ctx, fmt.Sprintf("/vehicles/%s/batch", vehicleID), struct {
+		Stops []PlannedStop `json:"stops"`
+	}{stops})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusNotFound {
+			return ErrVehicleNotFound
+		}
+		if resp.StatusCode == http.StatusConflict {
+			return ErrVehicleUnavailable
+		}
+		return fmt.Errorf("failed to start delivery batch, status: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// StartPool claims an available vehicle for a shared ride, starting a stop plan other
+// shared rides can join
+func (c *Client) StartPool(ctx context.Context, vehicleID string, ride SharedRide) error {
+	resp, err := c.postJSON(ctx, fmt.Sprintf("/vehicles/%s/pool", vehicleID), ride)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusNotFound {
+			return ErrVehicleNotFound
+		}
+		if resp.StatusCode == http.StatusConflict {
+			return ErrVehicleUnavailable
+		}
+		return fmt.Errorf("failed to start shared ride, status: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// JoinPool adds a shared ride to a busy vehicle already serving other shared rides,
+// returning the vehicle it joined
+func (c *Client) JoinPool(ctx context.Context, ride SharedRide) (*Vehicle, error) {
+	resp, err := c.postJSON(ctx, "/vehicles/pool", ride)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, ErrNoPoolMatch
+		}
+		return nil, fmt.Errorf("failed to join shared ride, status: %d", resp.StatusCode)
+	}
+
+	var vehicle Vehicle
+	if err := json.NewDecoder(resp.Body).Decode(&vehicle); err != nil {
+		return nil, err
+	}
+
+	return &vehicle, nil
+}
+
+// RecordPickup tells the fleet service a job's rider or order is on board
+func (c *Client) RecordPickup(ctx context.Context, vehicleID, jobID string) error {
+	return c.postJobAction(ctx, vehicleID, "pickup", jobID)
+}
+
+// CompleteJob detaches a finished job from its vehicle, which stays busy while it
+// still carries other shared riders
+func (c *Client) CompleteJob(ctx context.Context, vehicleID, jobID string) error {
+	return c.postJobAction(ctx, vehicleID, "complete", jobID)
+}
+
+// postJobAction reports something that happened to one of a vehicle's jobs
+func (c *Client) postJobAction(ctx context.Context, vehicleID, action, jobID string) error {
+	body := struct {
+		JobID string `json:"job_id"`
+	}{
+		JobID: jobID,
+	}
+
+	resp, err := c.postJSON(ctx, fmt.Sprintf("/vehicles/%s/%s", vehicleID, action), body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusConflict {
+			return ErrVehicleReassigned
+		}
+		return fmt.Errorf("failed to %s job on vehicle, status: %d", action, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// postJSON sends a JSON body to the fleet service
+func (c *Client) postJSON(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+path, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return c.httpClient.Do(req)
+}
+
 // GetAllVehicles retrieves all vehicles from the fleet service
 func (c *Client) GetAllVehicles(ctx context.Context) ([]*Vehicle, error) {
 	url := fmt.Sprintf("%s/vehicles", c.baseURL)
@@ -4,7 +4,13 @@ import "context"
 
 // FleetClient defines the interface for fleet service operations
 type FleetClient interface {
-	FindNearestVehicle(ctx context.Context, region string, pickupLat, pickupLng, tripDistanceKm float64) (*Vehicle, error)
+	FindNearestVehicle(ctx context.Context, region string, pickupLat, pickupLng, tripDistanceKm float64, excludeVehicleIDs ...string) (*Vehicle, error)
 	AssignJob(ctx context.Context, vehicleID, jobID string) error
+	ReleaseVehicle(ctx context.Context, vehicleID, jobID string) error
+	StartPool(ctx context.Context, vehicleID string, ride SharedRide) error
+	JoinPool(ctx context.Context, ride SharedRide) (*Vehicle, error)
+	StartBatch(ctx context.Context, vehicleID string, stops []PlannedStop) error
+	RecordPickup(ctx context.Context, vehicleID, jobID string) error
+	CompleteJob(ctx context.Context, vehicleID, jobID string) error
 	GetAllVehicles(ctx context.Context) ([]*Vehicle, error)
 }
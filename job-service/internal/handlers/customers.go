@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"job-service/internal/service"
+	"job-service/internal/storage"
+
+	"github.com/gorilla/mux"
+)
+
+// CreateCustomerRequest represents a customer opening an account
+type CreateCustomerRequest struct {
+	ID            string `json:"id,omitempty"` // generated if empty
+	Name          string `json:"name"`
+	Email         string `json:"email"`
+	PaymentMethod string `json:"payment_method,omitempty"` // payment provider token
+}
+
+// CreateCustomer opens a customer account
+func (h *HTTPHandler) CreateCustomer(w http.ResponseWriter, r *http.Request) {
+	var req CreateCustomerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if req.Name == "" {
+		http.Error(w, "Missing required fields", http.StatusBadRequest)
+		return
+	}
+
+	customer, err := h.jobService.CreateCustomer(r.Context(), &storage.Customer{
+		ID:            req.ID,
+		Name:          req.Name,
+		Email:         req.Email,
+		PaymentMethod: req.PaymentMethod,
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrCustomerExists) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(customer)
+}
+
+// GetCustomer returns a customer's account
+func (h *HTTPHandler) GetCustomer(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	customerID := vars["id"]
+
+	customer, err := h.jobService.GetCustomer(r.Context(), customerID)
+	if err != nil {
+		writeCustomerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(customer)
+}
+
+// GetCustomerStatement returns a customer's account activity between the optional from
+// and to query parameters, RFC 3339 times or YYYY-MM-DD dates. The period defaults to
+// the current calendar month so far.
+func (h *HTTPHandler) GetCustomerStatement(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	customerID := vars["id"]
+
+	now := time.Now().UTC()
+	from, err := parseTimeParam(r.URL.Query().Get("from"), time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		http.Error(w, "Invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseTimeParam(r.URL.Query().Get("to"), now)
+	if err != nil {
+		http.Error(w, "Invalid to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	statement, err := h.jobService.GetStatement(r.Context(), customerID, from, to)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidStatementPeriod) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeCustomerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statement)
+}
+
+// RefundJobRequest represents giving a customer money back for a job
+type RefundJobRequest struct {
+	Amount float64 `json:"amount,omitempty"` // everything not yet refunded if zero
+	Reason string  `json:"reason"`
+}
+
+// RefundJob credits the customer with some or all of what a job was charged
+func (h *HTTPHandler) RefundJob(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	jobID := vars["id"]
+
+	var req RefundJobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Reason == "" {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	refund, err := h.jobService.RefundJob(r.Context(), jobID, req.Amount, req.Reason)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidRefund) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(refund)
+}
+
+// writeCustomerError maps customer account errors to HTTP status codes
+func writeCustomerError(w http.ResponseWriter, err error) {
+	if errors.Is(err, storage.ErrCustomerNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// parseTimeParam parses a query parameter given as an RFC 3339 time or a date,
+// returning fallback when it is empty
+func parseTimeParam(value string, fallback time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
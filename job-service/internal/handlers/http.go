@@ -2,7 +2,13 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"job-service/internal/service"
 	"job-service/internal/storage"
@@ -28,10 +34,28 @@ func (h *HTTPHandler) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("/jobs", h.GetAllJobs).Methods("GET")
 	router.HandleFunc("/jobs", h.CreateJob).Methods("POST")
 	router.HandleFunc("/jobs/{id}", h.GetJob).Methods("GET")
+	router.HandleFunc("/jobs/{id}/pickup", h.ConfirmPickup).Methods("POST")
+	router.HandleFunc("/jobs/{id}/stops/{index}/arrive", h.ArriveAtStop).Methods("POST")
 	router.HandleFunc("/jobs/{id}/complete", h.CompleteJob).Methods("POST")
+	router.HandleFunc("/jobs/{id}/fail", h.FailJob).Methods("POST")
+	router.HandleFunc("/jobs/{id}/abandon", h.AbandonJob).Methods("POST")
+	router.HandleFunc("/jobs/{id}/cancel", h.CancelJob).Methods("POST")
+	router.HandleFunc("/jobs/{id}/refund", h.RefundJob).Methods("POST")
 	router.HandleFunc("/jobs/status/{status}", h.GetJobsByStatus).Methods("GET")
 	router.HandleFunc("/jobs/process-pending", h.ProcessPendingJobs).Methods("POST")
+	router.HandleFunc("/vehicles/{id}/jobs", h.GetVehicleJobs).Methods("GET")
+	router.HandleFunc("/vehicles/{id}/jobs/stream", h.StreamVehicleJobs).Methods("GET")
+	router.HandleFunc("/customers", h.CreateCustomer).Methods("POST")
+	router.HandleFunc("/customers/{id}", h.GetCustomer).Methods("GET")
+	router.HandleFunc("/customers/{id}/statement", h.GetCustomerStatement).Methods("GET")
+	router.HandleFunc("/customers/{id}/bookings", h.GetCustomerBookings).Methods("GET")
+	router.HandleFunc("/customers/{id}/bookings/{job_id}/cancel", h.CancelCustomerBooking).Methods("POST")
+	router.HandleFunc("/batches/{id}", h.GetBatch).Methods("GET")
+	router.HandleFunc("/quotes", h.CreateQuote).Methods("POST")
 	router.HandleFunc("/revenue", h.GetRevenue).Methods("GET")
+	router.HandleFunc("/admin/pricing", h.GetPricingRules).Methods("GET")
+	router.HandleFunc("/admin/pricing/reload", h.ReloadPricingRules).Methods("POST")
+	router.HandleFunc("/admin/revenue/backfill", h.BackfillRevenue).Methods("POST")
 }
 
 // Health returns service health status
@@ -51,6 +75,15 @@ type CreateJobRequest struct {
 	DestinationLat  float64                  `json:"destination_lat"`
 	DestinationLng  float64                  `json:"destination_lng"`
 	DeliveryDetails *storage.DeliveryDetails `json:"delivery_details,omitempty"`
+	ScheduledFor    *time.Time               `json:"scheduled_for,omitempty"` // book ahead for this pickup time
+	// Stops replaces the pickup and destination for jobs calling at several places in order
+	Stops []storage.Stop `json:"stops,omitempty"`
+	// Shared rides may pool with other riders going the same way
+	Shared bool `json:"shared,omitempty"`
+	Seats  int  `json:"seats,omitempty"`
+	// QuoteID books the trip from POST /quotes at its quoted fare; the quote fixes the
+	// job type, region, pickup, destination and seats
+	QuoteID string `json:"quote_id,omitempty"`
 }
 
 // GetAllJobs returns all jobs
@@ -74,7 +107,7 @@ func (h *HTTPHandler) CreateJob(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate required fields
-	if req.JobType == "" || req.CustomerID == "" || req.Region == "" {
+	if req.CustomerID == "" || (req.QuoteID == "" && (req.JobType == "" || req.Region == "")) {
 		http.Error(w, "Missing required fields", http.StatusBadRequest)
 		return
 	}
@@ -82,8 +115,68 @@ func (h *HTTPHandler) CreateJob(w http.ResponseWriter, r *http.Request) {
 	var job *storage.Job
 	var err error
 
-	switch req.JobType {
-	case "ride":
+	switch {
+	case req.QuoteID != "":
+		if len(req.Stops) > 0 || req.ScheduledFor != nil {
+			http.Error(w, "Quotes are for immediate trips without extra stops", http.StatusBadRequest)
+			return
+		}
+		job, err = h.jobService.CreateQuotedJob(
+			r.Context(),
+			req.CustomerID,
+			req.QuoteID,
+			req.DeliveryDetails,
+		)
+	case len(req.Stops) > 0:
+		job, err = h.jobService.CreateMultiStopJob(
+			r.Context(),
+			req.JobType,
+			req.CustomerID,
+			req.Region,
+			req.Stops,
+			req.DeliveryDetails,
+			req.ScheduledFor,
+		)
+	case req.Shared:
+		if req.JobType != "ride" {
+			http.Error(w, "Only rides can be shared", http.StatusBadRequest)
+			return
+		}
+		job, err = h.jobService.CreateSharedRideJob(
+			r.Context(),
+			req.CustomerID,
+			req.Region,
+			req.PickupLat,
+			req.PickupLng,
+			req.DestinationLat,
+			req.DestinationLng,
+			req.Seats,
+			req.ScheduledFor,
+		)
+	case req.JobType == "ride" && req.ScheduledFor != nil:
+		job, err = h.jobService.ScheduleRideJob(
+			r.Context(),
+			req.CustomerID,
+			req.Region,
+			req.PickupLat,
+			req.PickupLng,
+			req.DestinationLat,
+			req.DestinationLng,
+			*req.ScheduledFor,
+		)
+	case req.JobType == "delivery" && req.ScheduledFor != nil:
+		job, err = h.jobService.ScheduleDeliveryJob(
+			r.Context(),
+			req.CustomerID,
+			req.Region,
+			req.PickupLat,
+			req.PickupLng,
+			req.DestinationLat,
+			req.DestinationLng,
+			req.DeliveryDetails,
+			*req.ScheduledFor,
+		)
+	case req.JobType == "ride":
 		job, err = h.jobService.CreateRideJob(
 			r.Context(),
 			req.CustomerID,
@@ -93,7 +186,7 @@ func (h *HTTPHandler) CreateJob(w http.ResponseWriter, r *http.Request) {
 			req.DestinationLat,
 			req.DestinationLng,
 		)
-	case "delivery":
+	case req.JobType == "delivery":
 		job, err = h.jobService.CreateDeliveryJob(
 			r.Context(),
 			req.CustomerID,
@@ -110,10 +203,15 @@ func (h *HTTPHandler) CreateJob(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err != nil {
+		if errors.Is(err, service.ErrQuoteExpired) {
+			http.Error(w, err.Error(), http.StatusGone)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	w.Header().Set("ETag", formatETag(job.Version))
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(job)
 }
@@ -130,15 +228,42 @@ func (h *HTTPHandler) GetJob(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", formatETag(job.Version))
 	json.NewEncoder(w).Encode(job)
 }
 
-// CompleteJob marks a job as completed
+// CompleteJob marks a job as completed and charges its final fare. With an If-Match
+// header the job is only completed if it is unchanged since that version.
 func (h *HTTPHandler) CompleteJob(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	jobID := vars["id"]
 
-	if err := h.jobService.CompleteJob(r.Context(), jobID); err != nil {
+	expectedVersion, conditional, err := parseIfMatch(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Vehicles may report the distance and time they drove the job for, which its final fare is charged on
+	var trip service.TripReport
+	if err := json.NewDecoder(r.Body).Decode(&trip); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	var guard *int64
+	if conditional {
+		guard = &expectedVersion
+	}
+	if err := h.jobService.CompleteJobWithTrip(r.Context(), jobID, trip, guard); err != nil {
+		if errors.Is(err, storage.ErrVersionConflict) {
+			http.Error(w, err.Error(), http.StatusPreconditionFailed)
+			return
+		}
+		if errors.Is(err, service.ErrInvalidTransition) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -146,6 +271,102 @@ func (h *HTTPHandler) CompleteJob(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// ConfirmPickup marks a job as in progress once the vehicle has picked up
+func (h *HTTPHandler) ConfirmPickup(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	jobID := vars["id"]
+
+	job, err := h.jobService.ConfirmPickup(r.Context(), jobID)
+	if err != nil {
+		writeTransitionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", formatETag(job.Version))
+	json.NewEncoder(w).Encode(job)
+}
+
+// ArriveAtStop records that the vehicle has reached one of the job's stops
+func (h *HTTPHandler) ArriveAtStop(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	jobID := vars["id"]
+
+	stopIndex, err := strconv.Atoi(vars["index"])
+	if err != nil {
+		http.Error(w, "Invalid stop index", http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.jobService.ArriveAtStop(r.Context(), jobID, stopIndex)
+	if err != nil {
+		writeTransitionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", formatETag(job.Version))
+	json.NewEncoder(w).Encode(job)
+}
+
+// FailJobRequest represents a job failure report
+type FailJobRequest struct {
+	Reason string `json:"reason"`
+}
+
+// FailJob marks a dispatched job as failed
+func (h *HTTPHandler) FailJob(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	jobID := vars["id"]
+
+	var req FailJobRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+
+	job, err := h.jobService.FailJob(r.Context(), jobID, req.Reason)
+	if err != nil {
+		writeTransitionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", formatETag(job.Version))
+	json.NewEncoder(w).Encode(job)
+}
+
+// CancelJobRequest represents a job cancellation request
+type CancelJobRequest struct {
+	Reason string `json:"reason"` // "customer_cancelled" (default), "no_show" or "vehicle_fault"
+}
+
+// CancelJob cancels a job before pickup and releases its vehicle
+func (h *HTTPHandler) CancelJob(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	jobID := vars["id"]
+
+	req := CancelJobRequest{Reason: service.CancelReasonCustomer}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+
+	job, err := h.jobService.CancelJob(r.Context(), jobID, req.Reason)
+	if err != nil {
+		writeTransitionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", formatETag(job.Version))
+	json.NewEncoder(w).Encode(job)
+}
+
 // GetJobsByStatus returns jobs with specific status
 func (h *HTTPHandler) GetJobsByStatus(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -161,6 +382,158 @@ func (h *HTTPHandler) GetJobsByStatus(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(jobs)
 }
 
+// GetVehicleJobs returns the jobs assigned to a vehicle, filtered by the optional status query parameter
+func (h *HTTPHandler) GetVehicleJobs(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	vehicleID := vars["id"]
+	status := r.URL.Query().Get("status")
+
+	jobs, err := h.jobService.GetJobsByVehicle(r.Context(), vehicleID, status)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Always answer with a JSON array so pollers can decode an empty result
+	if jobs == nil {
+		jobs = []*storage.Job{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobs)
+}
+
+// GetBatch returns a delivery batch with the status of each of its orders
+func (h *HTTPHandler) GetBatch(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	batchID := vars["id"]
+
+	batch, err := h.jobService.GetBatch(r.Context(), batchID)
+	if err != nil {
+		if errors.Is(err, service.ErrBatchNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(batch)
+}
+
+// QuoteRequest describes a trip to price before booking it
+type QuoteRequest struct {
+	JobType        string  `json:"job_type"` // "ride" or "delivery"
+	Region         string  `json:"region"`
+	PickupLat      float64 `json:"pickup_lat"`
+	PickupLng      float64 `json:"pickup_lng"`
+	DestinationLat float64 `json:"destination_lat"`
+	DestinationLng float64 `json:"destination_lng"`
+	Shared         bool    `json:"shared,omitempty"`
+	Seats          int     `json:"seats,omitempty"`
+}
+
+// CreateQuote prices a trip at the current surge and issues a quote ID that books it at
+// that fare until the quote expires
+func (h *HTTPHandler) CreateQuote(w http.ResponseWriter, r *http.Request) {
+	var req QuoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if req.JobType == "" || req.Region == "" {
+		http.Error(w, "Missing required fields", http.StatusBadRequest)
+		return
+	}
+
+	quote, err := h.jobService.QuoteFare(
+		r.Context(),
+		req.JobType,
+		req.Region,
+		req.PickupLat,
+		req.PickupLng,
+		req.DestinationLat,
+		req.DestinationLng,
+		req.Shared,
+		req.Seats,
+	)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(quote)
+}
+
+// GetPricingRules returns the pricing rule set new jobs are priced with
+func (h *HTTPHandler) GetPricingRules(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.jobService.PricingRules())
+}
+
+// ReloadPricingRules rereads the pricing file, keeping the current rates if it is invalid
+func (h *HTTPHandler) ReloadPricingRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.jobService.ReloadPricingRules()
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrNoPricingFile):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, service.ErrInvalidPricingRules):
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rules)
+}
+
+// GetCustomerBookings returns a customer's upcoming bookings, soonest first
+func (h *HTTPHandler) GetCustomerBookings(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	customerID := vars["id"]
+
+	bookings, err := h.jobService.GetUpcomingBookings(r.Context(), customerID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if bookings == nil {
+		bookings = []*storage.Job{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bookings)
+}
+
+// CancelCustomerBooking cancels one of a customer's upcoming bookings
+func (h *HTTPHandler) CancelCustomerBooking(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	customerID := vars["id"]
+	jobID := vars["job_id"]
+
+	job, err := h.jobService.CancelBooking(r.Context(), customerID, jobID)
+	if err != nil {
+		if errors.Is(err, service.ErrBookingNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeTransitionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", formatETag(job.Version))
+	json.NewEncoder(w).Encode(job)
+}
+
 // ProcessPendingJobs attempts to assign all pending jobs
 func (h *HTTPHandler) ProcessPendingJobs(w http.ResponseWriter, r *http.Request) {
 	if err := h.jobService.ProcessPendingJobs(r.Context()); err != nil {
@@ -172,10 +545,29 @@ func (h *HTTPHandler) ProcessPendingJobs(w http.ResponseWriter, r *http.Request)
 	w.Write([]byte(`{"message": "Pending jobs processed"}`))
 }
 
-// GetRevenue returns revenue statistics
+// GetRevenue reports revenue over a period, by default the last day, optionally
+// grouped by a comma-separated group_by list
 func (h *HTTPHandler) GetRevenue(w http.ResponseWriter, r *http.Request) {
-	revenue, err := h.jobService.GetRevenue(r.Context())
+	var query service.RevenueQuery
+	var err error
+	if query.From, err = parseTimeParam(r.URL.Query().Get("from"), time.Time{}); err != nil {
+		http.Error(w, "Invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if query.To, err = parseTimeParam(r.URL.Query().Get("to"), time.Time{}); err != nil {
+		http.Error(w, "Invalid to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if groupBy := r.URL.Query().Get("group_by"); groupBy != "" {
+		query.GroupBy = strings.Split(groupBy, ",")
+	}
+
+	revenue, err := h.jobService.GetRevenue(r.Context(), query)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidRevenueQuery) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -183,3 +575,87 @@ func (h *HTTPHandler) GetRevenue(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(revenue)
 }
+
+// BackfillRevenue adds jobs that finished before the given before time, when revenue
+// rollups started being kept, to the rollups. Jobs already in them are skipped, so a
+// backfill that failed partway through can be run again.
+func (h *HTTPHandler) BackfillRevenue(w http.ResponseWriter, r *http.Request) {
+	before, err := parseTimeParam(r.URL.Query().Get("before"), time.Time{})
+	if err != nil {
+		http.Error(w, "Invalid before: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	backfill, err := h.jobService.BackfillRevenue(r.Context(), before)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidRevenueQuery) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(backfill)
+}
+
+// AbandonJobRequest represents a vehicle giving up a job it can no longer serve
+type AbandonJobRequest struct {
+	VehicleID string `json:"vehicle_id"`
+	Reason    string `json:"reason"`
+}
+
+// AbandonJob takes a job away from a vehicle that cannot finish it
+func (h *HTTPHandler) AbandonJob(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	jobID := vars["id"]
+
+	var req AbandonJobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.VehicleID == "" {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.jobService.AbandonJob(r.Context(), jobID, req.VehicleID, req.Reason)
+	if err != nil {
+		writeTransitionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", formatETag(job.Version))
+	json.NewEncoder(w).Encode(job)
+}
+
+// writeTransitionError maps job lifecycle errors to HTTP status codes
+func writeTransitionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidTransition), errors.Is(err, service.ErrVehicleMismatch), errors.Is(err, storage.ErrVersionConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// formatETag renders a record version as a strong ETag
+func formatETag(version int64) string {
+	return fmt.Sprintf("\"%d\"", version)
+}
+
+// parseIfMatch extracts the expected version from an If-Match header, reporting
+// whether the request is conditional at all
+func parseIfMatch(r *http.Request) (int64, bool, error) {
+	header := r.Header.Get("If-Match")
+	if header == "" || header == "*" {
+		return 0, false, nil
+	}
+
+	value := strings.Trim(strings.TrimPrefix(header, "W/"), "\"")
+	version, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid If-Match header: %s", header)
+	}
+
+	return version, true, nil
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"job-service/internal/service"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// streamKeepAliveInterval keeps idle streams open through load balancer idle timeouts
+	streamKeepAliveInterval = 15 * time.Second
+	// streamRetryMillis is how long clients wait before reconnecting a dropped stream
+	streamRetryMillis = 2000
+)
+
+// StreamVehicleJobs pushes assignment, cancellation and update events for a vehicle's
+// jobs as Server-Sent Events. A reconnecting client sends the Last-Event-ID header
+// (or last_event_id query parameter) to replay what it missed; when that isn't
+// possible it receives a resync event and should poll GET /vehicles/{id}/jobs.
+func (h *HTTPHandler) StreamVehicleJobs(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	vehicleID := vars["id"]
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	var resumeFrom int64
+	if lastEventID != "" {
+		parsed, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+		resumeFrom = parsed
+	}
+
+	sub := h.jobService.SubscribeVehicleEvents(vehicleID, resumeFrom)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetryMillis)
+
+	if sub.Resync {
+		resync := service.VehicleEvent{
+			ID:        sub.ResumeID,
+			Type:      service.VehicleEventResync,
+			VehicleID: vehicleID,
+			Timestamp: time.Now().UTC(),
+		}
+		if err := writeVehicleEvent(w, resync); err != nil {
+			return
+		}
+	}
+	for _, event := range sub.Replay {
+		if err := writeVehicleEvent(w, event); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	slog.Info("Vehicle job stream opened", "vehicle_id", vehicleID, "resume_from", resumeFrom, "resync", sub.Resync, "replayed", len(sub.Replay))
+
+	keepAlive := time.NewTicker(streamKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			slog.Info("Vehicle job stream closed", "vehicle_id", vehicleID)
+			return
+		case event, ok := <-sub.Events:
+			if !ok {
+				// Dropped for falling behind; the client resumes from its last event ID
+				slog.Warn("Vehicle job stream dropped slow subscriber", "vehicle_id", vehicleID)
+				return
+			}
+			if err := writeVehicleEvent(w, event); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeVehicleEvent writes a single Server-Sent Event
+func writeVehicleEvent(w http.ResponseWriter, event service.VehicleEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
@@ -6,11 +6,18 @@ import (
 	"log/slog"
 	"time"
 
+	"job-service/internal/metrics"
 	"job-service/internal/storage"
+	"shared/tracing"
 
 	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("job-service/internal/kinesis")
+
 type Streamer struct {
 	client     *kinesis.Client
 	streamName string
@@ -18,7 +25,7 @@ type Streamer struct {
 
 type JobEvent struct {
 	JobID      string    `json:"job_id"`
-	EventType  string    `json:"event_type"` // created, assigned, completed
+	EventType  string    `json:"event_type"` // created, assigned, picked_up, completed, failed, cancelled, requeued
 	Timestamp  time.Time `json:"timestamp"`
 	VehicleID  *string   `json:"vehicle_id,omitempty"`
 	JobType    string    `json:"job_type"`
@@ -28,6 +35,16 @@ type JobEvent struct {
 	PickupLng  float64   `json:"pickup_lng"`
 	DestLat    float64   `json:"dest_lat"`
 	DestLng    float64   `json:"dest_lng"`
+
+	// Set on cancelled events
+	CancellationReason string  `json:"cancellation_reason,omitempty"`
+	CancellationFee    float64 `json:"cancellation_fee,omitempty"`
+
+	// Set on failed events
+	FailureReason string `json:"failure_reason,omitempty"`
+
+	// Set once a job has been abandoned by a vehicle
+	Attempts int `json:"attempts,omitempty"`
 }
 
 func NewStreamer(client *kinesis.Client, streamName string) *Streamer {
@@ -37,7 +54,7 @@ func NewStreamer(client *kinesis.Client, streamName string) *Streamer {
 	}
 }
 
-func (s *Streamer) StreamJobEvent(eventType string, job *storage.Job) {
+func (s *Streamer) StreamJobEvent(ctx context.Context, eventType string, job *storage.Job) {
 	if s.client == nil {
 		return // Kinesis not enabled
 	}
@@ -54,6 +71,13 @@ func (s *Streamer) StreamJobEvent(eventType string, job *storage.Job) {
 		PickupLng:  job.PickupLng,
 		DestLat:    job.DestinationLat,
 		DestLng:    job.DestinationLng,
+
+		CancellationReason: job.CancellationReason,
+		CancellationFee:    job.CancellationFee,
+
+		FailureReason: job.FailureReason,
+
+		Attempts: job.Attempts,
 	}
 
 	data, err := json.Marshal(event)
@@ -62,13 +86,19 @@ func (s *Streamer) StreamJobEvent(eventType string, job *storage.Job) {
 		return
 	}
 
-	_, err = s.client.PutRecord(context.TODO(), &kinesis.PutRecordInput{
+	// Publishing outlives the request that changed the job, so isn't cut short with it
+	ctx, span := tracer.Start(context.WithoutCancel(ctx), "Kinesis.PutRecord",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(tracing.JobID(job.ID), attribute.String("job.event_type", eventType), attribute.String("messaging.destination.name", s.streamName)))
+	_, err = s.client.PutRecord(ctx, &kinesis.PutRecordInput{
 		StreamName:   &s.streamName,
 		Data:         data,
 		PartitionKey: &job.ID,
 	})
+	tracing.End(span, err)
 
 	if err != nil {
+		metrics.KinesisPutFailures.Inc()
 		slog.Error("Failed to stream job event", "job_id", job.ID, "event_type", eventType, "error", err)
 	} else {
 		slog.Debug("Streamed job event", "job_id", job.ID, "event_type", eventType)
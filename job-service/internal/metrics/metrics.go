@@ -0,0 +1,108 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "job_service"
+
+var (
+	// RequestDuration times HTTP requests by route template, method and status code
+	RequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time taken to serve HTTP requests, by route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	// Assignments counts attempts to assign a job to a vehicle by result: success or failure
+	Assignments = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "job_assignments_total",
+		Help:      "Attempts to assign a job to a vehicle, by result.",
+	}, []string{"result"})
+
+	// PendingJobs is how many jobs were waiting for a vehicle at the last dispatch cycle
+	PendingJobs = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "pending_jobs",
+		Help:      "Jobs waiting for a vehicle at the last dispatch cycle.",
+	})
+
+	// KinesisPutFailures counts job events that could not be written to Kinesis
+	KinesisPutFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "kinesis_put_failures_total",
+		Help:      "Job events that could not be written to Kinesis.",
+	})
+
+	// RoutingFallbacks counts road routing requests answered with straight-line estimates
+	RoutingFallbacks = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "routing_fallbacks_total",
+		Help:      "Road routing requests that failed and fell back to straight-line estimates.",
+	})
+)
+
+// ObserveAssignment counts the outcome of an attempt to assign a job
+func ObserveAssignment(err error) {
+	if err != nil {
+		Assignments.WithLabelValues("failure").Inc()
+		return
+	}
+	Assignments.WithLabelValues("success").Inc()
+}
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Middleware times each request under the template of the route it matched, so
+// requests for different IDs share a series
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := "unmatched"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(recorder, r)
+
+		RequestDuration.WithLabelValues(route, r.Method, strconv.Itoa(recorder.status)).Observe(time.Since(start).Seconds())
+	})
+}
+
+// statusRecorder remembers the status code a handler wrote
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// Flush passes flushes through for streaming responses
+func (s *statusRecorder) Flush() {
+	if flusher, ok := s.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddleware_LabelsByRouteTemplate(t *testing.T) {
+	router := mux.NewRouter()
+	router.HandleFunc("/jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}).Methods("GET")
+	router.Use(Middleware)
+
+	for _, id := range []string{"job-1", "job-2"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/jobs/"+id, nil))
+	}
+
+	// Both requests share the route's series
+	if count := testutil.CollectAndCount(RequestDuration, "job_service_http_request_duration_seconds"); count != 1 {
+		t.Errorf("Expected one series, got %d", count)
+	}
+	if _, err := RequestDuration.GetMetricWithLabelValues("/jobs/{id}", "GET", "404"); err != nil {
+		t.Errorf("Expected a series for the route template, got %v", err)
+	}
+}
+
+func TestObserveAssignment(t *testing.T) {
+	success := testutil.ToFloat64(Assignments.WithLabelValues("success"))
+	failure := testutil.ToFloat64(Assignments.WithLabelValues("failure"))
+
+	ObserveAssignment(nil)
+	ObserveAssignment(errors.New("no available vehicle"))
+	ObserveAssignment(errors.New("no available vehicle"))
+
+	if got := testutil.ToFloat64(Assignments.WithLabelValues("success")) - success; got != 1 {
+		t.Errorf("Expected 1 success, got %v", got)
+	}
+	if got := testutil.ToFloat64(Assignments.WithLabelValues("failure")) - failure; got != 2 {
+		t.Errorf("Expected 2 failures, got %v", got)
+	}
+}
@@ -0,0 +1,80 @@
+package payments
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DeclinedPaymentMethod is a payment method the fake provider always declines
+const DeclinedPaymentMethod = "fake_card_declined"
+
+// FakeProvider is an in-memory payment provider for local runs and tests. It accepts
+// every charge except to DeclinedPaymentMethod.
+type FakeProvider struct {
+	mu       sync.Mutex
+	nextID   int
+	payments map[string]*Payment // by ID
+	refunded map[string]float64  // refunded so far, by payment ID
+	byKey    map[string]*Payment // by idempotency key
+}
+
+// NewFakeProvider creates a fake payment provider with no payments
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{
+		payments: make(map[string]*Payment),
+		refunded: make(map[string]float64),
+		byKey:    make(map[string]*Payment),
+	}
+}
+
+func (f *FakeProvider) Charge(ctx context.Context, req ChargeRequest) (*Payment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if payment, ok := f.byKey[req.IdempotencyKey]; ok {
+		return payment, nil
+	}
+	if req.PaymentMethod == DeclinedPaymentMethod {
+		return nil, fmt.Errorf("%w: customer %s", ErrPaymentDeclined, req.CustomerID)
+	}
+
+	payment := f.record("pay", req.Amount, req.IdempotencyKey)
+	f.payments[payment.ID] = payment
+	return payment, nil
+}
+
+func (f *FakeProvider) Refund(ctx context.Context, paymentID string, amount float64, idempotencyKey string) (*Payment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if refund, ok := f.byKey[idempotencyKey]; ok {
+		return refund, nil
+	}
+
+	payment, ok := f.payments[paymentID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrPaymentNotFound, paymentID)
+	}
+	if f.refunded[paymentID]+amount > payment.Amount+0.005 {
+		return nil, fmt.Errorf("%w: %.2f of %.2f already refunded", ErrRefundExceedsPayment, f.refunded[paymentID], payment.Amount)
+	}
+
+	f.refunded[paymentID] += amount
+	return f.record("refund", amount, idempotencyKey), nil
+}
+
+// record creates a payment and remembers it under its idempotency key
+func (f *FakeProvider) record(prefix string, amount float64, idempotencyKey string) *Payment {
+	f.nextID++
+	payment := &Payment{
+		ID:        fmt.Sprintf("fake-%s-%d", prefix, f.nextID),
+		Amount:    amount,
+		CreatedAt: time.Now(),
+	}
+	if idempotencyKey != "" {
+		f.byKey[idempotencyKey] = payment
+	}
+	return payment
+}
@@ -0,0 +1,41 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrPaymentDeclined is returned when the customer's payment method was refused
+	ErrPaymentDeclined = errors.New("payment declined")
+	// ErrPaymentNotFound is returned when refunding a payment the provider has no record of
+	ErrPaymentNotFound = errors.New("payment not found")
+	// ErrRefundExceedsPayment is returned when refunds would add up to more than was paid
+	ErrRefundExceedsPayment = errors.New("refund exceeds payment")
+)
+
+// ChargeRequest asks the provider to take money from a customer
+type ChargeRequest struct {
+	CustomerID    string
+	PaymentMethod string // provider token for the customer's card or account
+	Amount        float64
+	Description   string
+	// Retrying with the same key returns the original payment instead of charging again
+	IdempotencyKey string
+}
+
+// Payment is money moved by the provider, to the business for a charge or back to the
+// customer for a refund
+type Payment struct {
+	ID        string    `json:"id"`
+	Amount    float64   `json:"amount"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Provider defines the interface for taking and refunding customer payments
+type Provider interface {
+	Charge(ctx context.Context, req ChargeRequest) (*Payment, error)
+	// Refund gives back some or all of a payment; idempotencyKey works as for Charge
+	Refund(ctx context.Context, paymentID string, amount float64, idempotencyKey string) (*Payment, error)
+}
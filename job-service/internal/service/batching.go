@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"job-service/internal/fleet"
+	"job-service/internal/storage"
+)
+
+const (
+	// maxBatchOrders bounds how many deliveries share one vehicle trip
+	maxBatchOrders = 4
+	// batchPickupRadiusKm is how close together orders must be collected, e.g. from
+	// the same restaurant or its neighbours
+	batchPickupRadiusKm = 0.5
+	// batchDropoffRadiusKm is how close an order's destination must be to the first
+	// order's for the trip to stay worthwhile
+	batchDropoffRadiusKm = 3.0
+)
+
+// ErrBatchNotFound is returned when no delivery was dispatched under a batch ID
+var ErrBatchNotFound = errors.New("delivery batch not found")
+
+// DeliveryBatch is a set of deliveries dispatched on one vehicle trip, with the
+// progress of each order
+type DeliveryBatch struct {
+	ID        string       `json:"batch_id"`
+	VehicleID string       `json:"vehicle_id,omitempty"`
+	Orders    []BatchOrder `json:"orders"`
+}
+
+// BatchOrder is the state of one delivery in a batch
+type BatchOrder struct {
+	JobID          string     `json:"job_id"`
+	Status         string     `json:"status"`
+	RestaurantName string     `json:"restaurant_name,omitempty"`
+	PickedUpAt     *time.Time `json:"picked_up_at,omitempty"`
+	CompletedAt    *time.Time `json:"completed_at,omitempty"`
+}
+
+// SetDeliveryBatchWindow sets how long new deliveries are held back for other orders
+// to share their trip; zero dispatches every delivery on its own
+func (j *JobService) SetDeliveryBatchWindow(window time.Duration) {
+	j.batchWindow = window
+}
+
+// batchable reports whether a job is a plain delivery the batcher may hold back
+func (j *JobService) batchable(job *storage.Job) bool {
+	return j.batchWindow > 0 &&
+		job.JobType == "delivery" &&
+		job.Status == JobStatusPending &&
+		job.BatchID == "" &&
+		job.ScheduledFor == nil &&
+		len(job.Stops) <= 2
+}
+
+// dispatchDeliveryBatches sends out deliveries whose batching window has passed,
+// grouped with compatible orders onto one vehicle each. It returns the pending jobs
+// left for regular dispatch: everything else, plus orders nothing could be paired with.
+func (j *JobService) dispatchDeliveryBatches(ctx context.Context, pendingJobs []*storage.Job) []*storage.Job {
+	if j.batchWindow <= 0 {
+		return pendingJobs
+	}
+
+	var orders, remaining []*storage.Job
+	for _, job := range pendingJobs {
+		if j.batchable(job) {
+			orders = append(orders, job)
+		} else {
+			remaining = append(remaining, job)
+		}
+	}
+
+	batches, held := planDeliveryBatches(orders, time.Now(), j.batchWindow)
+	for _, batch := range batches {
+		if len(batch) == 1 {
+			remaining = append(remaining, batch[0])
+			continue
+		}
+		if err := j.dispatchBatch(ctx, batch); err != nil {
+			fmt.Printf("Failed to dispatch batch of %d deliveries starting with %s: %v\n", len(batch), batch[0].ID, err)
+		}
+	}
+
+	if len(held) > 0 {
+		fmt.Printf("Holding %d deliveries for batching\n", len(held))
+	}
+	return remaining
+}
+
+// planDeliveryBatches groups orders, oldest first, once the oldest in a group has waited
+// out the window. Each group is the oldest order plus later ones picked up nearby and
+// heading close to it. Orders still inside the window with nothing due to join are held.
+func planDeliveryBatches(orders []*storage.Job, now time.Time, window time.Duration) (batches [][]*storage.Job, held []*storage.Job) {
+	grouped := make(map[string]bool)
+	for i, seed := range orders {
+		if grouped[seed.ID] {
+			continue
+		}
+		if now.Sub(seed.CreatedAt) < window {
+			held = append(held, seed)
+			continue
+		}
+
+		batch := []*storage.Job{seed}
+		grouped[seed.ID] = true
+		for _, order := range orders[i+1:] {
+			if len(batch) >= maxBatchOrders {
+				break
+			}
+			if !grouped[order.ID] && batchCompatible(seed, order) {
+				batch = append(batch, order)
+				grouped[order.ID] = true
+			}
+		}
+		batches = append(batches, batch)
+	}
+	return batches, held
+}
+
+// batchCompatible reports whether an order can ride along with a batch's first order
+func batchCompatible(seed, order *storage.Job) bool {
+	return seed.Region == order.Region &&
+		calculateDistance(seed.PickupLat, seed.PickupLng, order.PickupLat, order.PickupLng) <= batchPickupRadiusKm &&
+		calculateDistance(seed.DestinationLat, seed.DestinationLng, order.DestinationLat, order.DestinationLng) <= batchDropoffRadiusKm
+}
+
+// batchStops orders a batch's stops for one trip: every pickup, then every drop-off,
+// each visited nearest first starting from the first order's pickup
+func batchStops(batch []*storage.Job) []fleet.PlannedStop {
+	pickups := make([]fleet.PlannedStop, len(batch))
+	dropoffs := make([]fleet.PlannedStop, len(batch))
+	for i, order := range batch {
+		pickups[i] = fleet.PlannedStop{JobID: order.ID, Type: StopTypePickup, Lat: order.PickupLat, Lng: order.PickupLng}
+		dropoffs[i] = fleet.PlannedStop{JobID: order.ID, Type: StopTypeDropoff, Lat: order.DestinationLat, Lng: order.DestinationLng}
+	}
+
+	stops := nearestFirst(pickups[0], pickups[1:])
+	last := stops[len(stops)-1]
+	return append(stops, nearestFirst(last, dropoffs)[1:]...)
+}
+
+// nearestFirst returns a route from start through every stop, always driving to the
+// closest one not yet visited
+func nearestFirst(start fleet.PlannedStop, stops []fleet.PlannedStop) []fleet.PlannedStop {
+	route := []fleet.PlannedStop{start}
+	left := append([]fleet.PlannedStop(nil), stops...)
+	for len(left) > 0 {
+		from := route[len(route)-1]
+		closest := 0
+		for i := range left {
+			if calculateDistance(from.Lat, from.Lng, left[i].Lat, left[i].Lng) < calculateDistance(from.Lat, from.Lng, left[closest].Lat, left[closest].Lng) {
+				closest = i
+			}
+		}
+		route = append(route, left[closest])
+		left = append(left[:closest], left[closest+1:]...)
+	}
+	return route
+}
+
+// batchRouteKm estimates the road distance of driving a batch's stops in order
+func (j *JobService) batchRouteKm(ctx context.Context, planned []fleet.PlannedStop) float64 {
+	stops := make([]storage.Stop, len(planned))
+	for i, stop := range planned {
+		stops[i] = storage.Stop{Lat: stop.Lat, Lng: stop.Lng, Type: stop.Type}
+	}
+	j.routeLegs(ctx, stops)
+	return TotalDistanceKm(stops)
+}
+
+// dispatchBatch claims the vehicle nearest the first pickup for a whole batch and
+// assigns every order to it. Orders keep their own status from there on.
+func (j *JobService) dispatchBatch(ctx context.Context, batch []*storage.Job) error {
+	stops := batchStops(batch)
+	routeKm := j.batchRouteKm(ctx, stops)
+	batchID := fmt.Sprintf("batch-%d", generateJobID())
+
+	var claimedVehicles []string
+	for attempt := 0; attempt < maxAssignmentAttempts; attempt++ {
+		vehicle, err := j.fleetClient.FindNearestVehicle(ctx, batch[0].Region, stops[0].Lat, stops[0].Lng, routeKm, claimedVehicles...)
+		if err != nil {
+			return fmt.Errorf("no available vehicle found: %v", err)
+		}
+
+		if err := j.fleetClient.StartBatch(ctx, vehicle.ID, stops); err != nil {
+			if errors.Is(err, fleet.ErrVehicleUnavailable) {
+				fmt.Printf("Vehicle %s was claimed concurrently, retrying batch %s with next-best vehicle\n", vehicle.ID, batchID)
+				claimedVehicles = append(claimedVehicles, vehicle.ID)
+				continue
+			}
+			return fmt.Errorf("failed to assign batch to vehicle: %w", err)
+		}
+
+		for _, order := range batch {
+			order.BatchID = batchID
+			if err := j.recordAssignment(ctx, order, vehicle.ID); err != nil {
+				// The order was taken off the vehicle's plan; the rest of the batch goes ahead
+				fmt.Printf("Failed to assign job %s in batch %s: %v\n", order.ID, batchID, err)
+			}
+		}
+
+		fmt.Printf("Batch %s of %d deliveries (%.1fkm) assigned to vehicle %s\n", batchID, len(batch), routeKm, vehicle.ID)
+		return nil
+	}
+
+	return fmt.Errorf("failed to assign batch after %d attempts: vehicles %v were claimed concurrently", maxAssignmentAttempts, claimedVehicles)
+}
+
+// GetBatch returns the deliveries dispatched under a batch ID with each order's status
+func (j *JobService) GetBatch(ctx context.Context, batchID string) (*DeliveryBatch, error) {
+	jobs, err := j.storage.GetAllJobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(jobs, func(a, b int) bool { return jobs[a].ID < jobs[b].ID })
+
+	batch := &DeliveryBatch{ID: batchID}
+	for _, job := range jobs {
+		if job.BatchID != batchID {
+			continue
+		}
+		if job.AssignedVehicleID != nil {
+			batch.VehicleID = *job.AssignedVehicleID
+		}
+
+		order := BatchOrder{
+			JobID:       job.ID,
+			Status:      job.Status,
+			PickedUpAt:  job.PickedUpAt,
+			CompletedAt: job.CompletedAt,
+		}
+		if job.DeliveryDetails != nil {
+			order.RestaurantName = job.DeliveryDetails.RestaurantName
+		}
+		batch.Orders = append(batch.Orders, order)
+	}
+
+	if len(batch.Orders) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrBatchNotFound, batchID)
+	}
+	return batch, nil
+}
@@ -0,0 +1,236 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"job-service/internal/fleet"
+	"job-service/internal/storage"
+)
+
+// A restaurant, three customers a short drive away and a depot the vehicles wait at
+var (
+	restaurant = storage.Stop{Lat: 45.520, Lng: -122.680}
+	customers  = []storage.Stop{
+		{Lat: 45.530, Lng: -122.670},
+		{Lat: 45.532, Lng: -122.668},
+		{Lat: 45.528, Lng: -122.672},
+	}
+	depot = storage.Stop{Lat: 45.500, Lng: -122.700}
+)
+
+// setupBatching creates a job service holding deliveries for window, with idle vehicles at the depot
+func setupBatching(window time.Duration) (*JobService, *storage.MemoryJobStorage, *MockFleetClient) {
+	jobStorage := storage.NewMemoryJobStorage()
+	mockFleetClient := NewMockFleetClient()
+	jobService := NewJobService(jobStorage, mockFleetClient)
+	jobService.SetDeliveryBatchWindow(window)
+
+	for _, id := range []string{"vehicle-1", "vehicle-2", "vehicle-3"} {
+		mockFleetClient.AddVehicle(&fleet.Vehicle{
+			ID:             id,
+			Region:         "us-west-2",
+			Status:         "available",
+			BatteryLevel:   80,
+			BatteryRangeKm: 200.0,
+			LocationLat:    depot.Lat,
+			LocationLng:    depot.Lng,
+			VehicleType:    "sedan",
+		})
+	}
+	return jobService, jobStorage, mockFleetClient
+}
+
+// orderDeliveries creates a delivery from the restaurant to each customer
+func orderDeliveries(t *testing.T, jobService *JobService) []*storage.Job {
+	var orders []*storage.Job
+	for i, customer := range customers {
+		order, err := jobService.CreateDeliveryJob(context.Background(), "customer-1", "us-west-2",
+			restaurant.Lat, restaurant.Lng, customer.Lat, customer.Lng,
+			&storage.DeliveryDetails{RestaurantName: "Noodle Bar", Items: []string{"ramen"}})
+		if err != nil {
+			t.Fatalf("Failed to create order %d: %v", i, err)
+		}
+		orders = append(orders, order)
+	}
+	return orders
+}
+
+// backdate makes a job look like it was created age ago
+func backdate(t *testing.T, jobStorage *storage.MemoryJobStorage, jobID string, age time.Duration) {
+	job, err := jobStorage.GetJob(context.Background(), jobID)
+	if err != nil {
+		t.Fatalf("Failed to get job %s: %v", jobID, err)
+	}
+	updated := *job
+	updated.CreatedAt = time.Now().Add(-age)
+	if err := jobStorage.UpdateJob(context.Background(), &updated); err != nil {
+		t.Fatalf("Failed to backdate job %s: %v", jobID, err)
+	}
+}
+
+func TestPlanDeliveryBatches(t *testing.T) {
+	now := time.Now()
+	order := func(id string, age time.Duration, pickup, dest storage.Stop) *storage.Job {
+		return &storage.Job{
+			ID: id, Region: "us-west-2", CreatedAt: now.Add(-age),
+			PickupLat: pickup.Lat, PickupLng: pickup.Lng,
+			DestinationLat: dest.Lat, DestinationLng: dest.Lng,
+		}
+	}
+	farAway := storage.Stop{Lat: 45.600, Lng: -122.600}
+
+	orders := []*storage.Job{
+		order("due", time.Minute, restaurant, customers[0]),
+		order("elsewhere", time.Minute, farAway, customers[0]),
+		order("fresh-nearby", time.Second, restaurant, customers[1]),
+		order("fresh-other-way", time.Second, restaurant, farAway),
+	}
+
+	batches, held := planDeliveryBatches(orders, now, 30*time.Second)
+
+	if len(batches) != 2 {
+		t.Fatalf("Expected 2 batches, got %d", len(batches))
+	}
+	if len(batches[0]) != 2 || batches[0][0].ID != "due" || batches[0][1].ID != "fresh-nearby" {
+		t.Errorf("Expected the due order to take the fresh one going its way, got %v", batchIDs(batches[0]))
+	}
+	if len(batches[1]) != 1 || batches[1][0].ID != "elsewhere" {
+		t.Errorf("Expected the order from elsewhere to go alone, got %v", batchIDs(batches[1]))
+	}
+	if len(held) != 1 || held[0].ID != "fresh-other-way" {
+		t.Errorf("Expected only the fresh order going the other way to be held, got %v", batchIDs(held))
+	}
+}
+
+func TestBatchStops_PicksUpBeforeDroppingOff(t *testing.T) {
+	batch := []*storage.Job{
+		{ID: "a", PickupLat: restaurant.Lat, PickupLng: restaurant.Lng, DestinationLat: 45.540, DestinationLng: -122.660},
+		{ID: "b", PickupLat: restaurant.Lat, PickupLng: restaurant.Lng, DestinationLat: 45.525, DestinationLng: -122.675},
+	}
+
+	stops := batchStops(batch)
+
+	expected := []struct{ jobID, stopType string }{
+		{"a", StopTypePickup},
+		{"b", StopTypePickup},
+		{"b", StopTypeDropoff}, // closer to the restaurant
+		{"a", StopTypeDropoff},
+	}
+	for i, stop := range stops {
+		if stop.JobID != expected[i].jobID || stop.Type != expected[i].stopType {
+			t.Errorf("Expected stop %d to be %s %s, got %s %s", i, expected[i].jobID, expected[i].stopType, stop.JobID, stop.Type)
+		}
+	}
+}
+
+func TestJobService_DeliveryBatching_ReducesFleetKm(t *testing.T) {
+	ctx := context.Background()
+
+	// Without batching every order gets a vehicle of its own straight away
+	unbatchedService, _, unbatchedFleet := setupBatching(0)
+	var unbatchedKm float64
+	for _, order := range orderDeliveries(t, unbatchedService) {
+		assigned, _ := unbatchedService.GetJob(ctx, order.ID)
+		if assigned.Status != JobStatusAssigned {
+			t.Fatalf("Expected order %s assigned, got %s", order.ID, assigned.Status)
+		}
+		unbatchedKm += calculateDistance(depot.Lat, depot.Lng, order.PickupLat, order.PickupLng) + order.EstimatedDistanceKm
+	}
+	if len(unbatchedFleet.assignments) != len(customers) {
+		t.Fatalf("Expected %d vehicles used, got %d", len(customers), len(unbatchedFleet.assignments))
+	}
+
+	// With batching the orders wait out the window together...
+	batchedService, jobStorage, batchedFleet := setupBatching(time.Minute)
+	orders := orderDeliveries(t, batchedService)
+	if err := batchedService.ProcessPendingJobs(ctx); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, order := range orders {
+		if held, _ := batchedService.GetJob(ctx, order.ID); held.Status != JobStatusPending {
+			t.Fatalf("Expected order %s held inside the window, got %s", order.ID, held.Status)
+		}
+	}
+
+	// ...then leave on one vehicle
+	backdate(t, jobStorage, orders[0].ID, 2*time.Minute)
+	if err := batchedService.ProcessPendingJobs(ctx); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var batchID, vehicleID string
+	for _, order := range orders {
+		assigned, _ := batchedService.GetJob(ctx, order.ID)
+		if assigned.Status != JobStatusAssigned || assigned.BatchID == "" {
+			t.Fatalf("Expected order %s assigned in a batch, got %s %q", order.ID, assigned.Status, assigned.BatchID)
+		}
+		if batchID == "" {
+			batchID, vehicleID = assigned.BatchID, *assigned.AssignedVehicleID
+		}
+		if assigned.BatchID != batchID || *assigned.AssignedVehicleID != vehicleID {
+			t.Errorf("Expected every order on %s in %s, got %s in %s", vehicleID, batchID, *assigned.AssignedVehicleID, assigned.BatchID)
+		}
+	}
+
+	stops := batchedFleet.batches[vehicleID]
+	if len(stops) != 2*len(customers) {
+		t.Fatalf("Expected %d planned stops, got %+v", 2*len(customers), stops)
+	}
+	batchedKm := calculateDistance(depot.Lat, depot.Lng, stops[0].Lat, stops[0].Lng) + batchedService.batchRouteKm(ctx, stops)
+
+	if batchedKm >= unbatchedKm {
+		t.Errorf("Expected batching to cut fleet kilometres, got %.2fkm batched vs %.2fkm unbatched", batchedKm, unbatchedKm)
+	}
+	t.Logf("Fleet kilometres: %.2f batched vs %.2f unbatched", batchedKm, unbatchedKm)
+}
+
+func TestJobService_GetBatch_TracksEachOrder(t *testing.T) {
+	ctx := context.Background()
+	jobService, jobStorage, mockFleetClient := setupBatching(time.Minute)
+
+	orders := orderDeliveries(t, jobService)
+	backdate(t, jobStorage, orders[0].ID, 2*time.Minute)
+	if err := jobService.ProcessPendingJobs(ctx); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	assigned, _ := jobService.GetJob(ctx, orders[0].ID)
+	if _, err := jobService.ConfirmPickup(ctx, orders[0].ID); err != nil {
+		t.Fatalf("Failed to confirm pickup: %v", err)
+	}
+	if len(mockFleetClient.pickups) != 1 || mockFleetClient.pickups[0] != orders[0].ID {
+		t.Errorf("Expected the pickup to be reported to the fleet, got %v", mockFleetClient.pickups)
+	}
+
+	batch, err := jobService.GetBatch(ctx, assigned.BatchID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if batch.VehicleID != *assigned.AssignedVehicleID || len(batch.Orders) != len(orders) {
+		t.Fatalf("Expected %d orders on %s, got %+v", len(orders), *assigned.AssignedVehicleID, batch)
+	}
+	for _, order := range batch.Orders {
+		expected := JobStatusAssigned
+		if order.JobID == orders[0].ID {
+			expected = JobStatusInProgress
+		}
+		if order.Status != expected || order.RestaurantName != "Noodle Bar" {
+			t.Errorf("Expected order %s %s from Noodle Bar, got %s from %q", order.JobID, expected, order.Status, order.RestaurantName)
+		}
+	}
+
+	if _, err := jobService.GetBatch(ctx, "batch-missing"); err == nil {
+		t.Error("Expected an error for an unknown batch")
+	}
+}
+
+// batchIDs lists the job IDs of a group of orders
+func batchIDs(jobs []*storage.Job) []string {
+	ids := make([]string, len(jobs))
+	for i, job := range jobs {
+		ids[i] = job.ID
+	}
+	return ids
+}
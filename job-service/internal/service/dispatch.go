@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"job-service/internal/fleet"
+	"job-service/internal/storage"
+	"shared/routing"
+)
+
+// DispatchMode selects how pending jobs are matched to vehicles
+type DispatchMode string
+
+const (
+	// DispatchModeGreedy assigns each job to its nearest vehicle as soon as it arrives
+	DispatchModeGreedy DispatchMode = "greedy"
+	// DispatchModeBatch collects pending jobs and solves a global matching per region
+	DispatchModeBatch DispatchMode = "batch"
+)
+
+// ParseDispatchMode validates a dispatch mode name from configuration
+func ParseDispatchMode(mode string) (DispatchMode, error) {
+	switch DispatchMode(mode) {
+	case DispatchModeGreedy, DispatchModeBatch:
+		return DispatchMode(mode), nil
+	default:
+		return "", fmt.Errorf("unknown dispatch mode %q", mode)
+	}
+}
+
+// infeasibleCost marks job/vehicle pairs the matcher must never choose
+const infeasibleCost = 1e9
+
+// batchAssignment pairs a pending job with the vehicle chosen for it
+type batchAssignment struct {
+	job     *storage.Job
+	vehicle *fleet.Vehicle
+}
+
+// canServe applies the same battery rule as the fleet service: road distance to the
+// pickup plus the trip, with a 20% safety buffer, must fit in the vehicle's remaining range
+func canServe(vehicle *fleet.Vehicle, job *storage.Job, pickup routing.Estimate) bool {
+	return vehicle.BatteryRangeKm >= (pickup.DistanceKm+job.EstimatedDistanceKm)*1.2
+}
+
+// planBatchAssignments matches jobs to vehicles minimising the total pickup ETA, given
+// each vehicle's drive to each pickup indexed [vehicle][job]. Jobs without a feasible
+// vehicle are left out of the plan.
+func planBatchAssignments(jobs []*storage.Job, vehicles []*fleet.Vehicle, pickups [][]routing.Estimate) []batchAssignment {
+	if len(jobs) == 0 || len(vehicles) == 0 {
+		return nil
+	}
+
+	cost := make([][]float64, len(jobs))
+	for i, job := range jobs {
+		cost[i] = make([]float64, len(vehicles))
+		for k, vehicle := range vehicles {
+			if canServe(vehicle, job, pickups[k][i]) {
+				cost[i][k] = pickups[k][i].Minutes()
+			} else {
+				cost[i][k] = infeasibleCost
+			}
+		}
+	}
+
+	var plan []batchAssignment
+	for i, k := range solveAssignment(cost) {
+		if k < 0 || cost[i][k] >= infeasibleCost {
+			continue
+		}
+		plan = append(plan, batchAssignment{job: jobs[i], vehicle: vehicles[k]})
+	}
+
+	return plan
+}
+
+// pickupEstimates routes every vehicle to every job's pickup point, indexed [vehicle][job]
+func (j *JobService) pickupEstimates(ctx context.Context, jobs []*storage.Job, vehicles []*fleet.Vehicle) [][]routing.Estimate {
+	origins := make([]routing.Point, len(vehicles))
+	for k, vehicle := range vehicles {
+		origins[k] = routing.Point{Lat: vehicle.LocationLat, Lng: vehicle.LocationLng}
+	}
+	destinations := make([]routing.Point, len(jobs))
+	for i, job := range jobs {
+		destinations[i] = routing.Point{Lat: job.PickupLat, Lng: job.PickupLng}
+	}
+
+	estimates, err := j.router.Matrix(ctx, origins, destinations)
+	if err != nil {
+		fmt.Printf("Failed to route vehicles to pickups, using straight-line estimates: %v\n", err)
+		estimates, _ = routing.NewHaversineRouter().Matrix(ctx, origins, destinations)
+	}
+	return estimates
+}
+
+// processPendingJobsBatch assigns pending jobs region by region using a global
+// min-cost matching instead of handing each job its nearest vehicle in turn
+func (j *JobService) processPendingJobsBatch(ctx context.Context, pendingJobs []*storage.Job) error {
+	// Shared rides that fit into a vehicle already on the road don't need a free one
+	var unpooled []*storage.Job
+	for _, job := range pendingJobs {
+		if job.Shared {
+			pooled, err := j.joinPool(ctx, job)
+			if err != nil {
+				fmt.Printf("Failed to pool shared job %s: %v\n", job.ID, err)
+			}
+			if pooled {
+				continue
+			}
+		}
+		unpooled = append(unpooled, job)
+	}
+	pendingJobs = unpooled
+
+	if len(pendingJobs) == 0 {
+		return nil
+	}
+
+	vehicles, err := j.fleetClient.GetAllVehicles(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get vehicles: %v", err)
+	}
+
+	jobsByRegion := make(map[string][]*storage.Job)
+	for _, job := range pendingJobs {
+		jobsByRegion[job.Region] = append(jobsByRegion[job.Region], job)
+	}
+
+	availableByRegion := make(map[string][]*fleet.Vehicle)
+	for _, vehicle := range vehicles {
+		if vehicle.Status != "available" || (vehicle.CurrentJobID != nil && *vehicle.CurrentJobID != "") {
+			continue
+		}
+		availableByRegion[vehicle.Region] = append(availableByRegion[vehicle.Region], vehicle)
+	}
+
+	regions := make([]string, 0, len(jobsByRegion))
+	for region := range jobsByRegion {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+
+	for _, region := range regions {
+		jobs, available := jobsByRegion[region], availableByRegion[region]
+		if len(available) == 0 {
+			continue
+		}
+		plan := planBatchAssignments(jobs, available, j.pickupEstimates(ctx, jobs, available))
+		for _, assignment := range plan {
+			if err := j.commitAssignment(ctx, assignment.job, assignment.vehicle.ID); err != nil {
+				if errors.Is(err, fleet.ErrVehicleUnavailable) {
+					// Another dispatcher got there first; the job is retried next cycle
+					fmt.Printf("Vehicle %s was claimed concurrently, leaving job %s pending\n", assignment.vehicle.ID, assignment.job.ID)
+					continue
+				}
+				fmt.Printf("Failed to assign pending job %s: %v\n", assignment.job.ID, err)
+			}
+		}
+	}
+
+	return nil
+}
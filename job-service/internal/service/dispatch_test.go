@@ -0,0 +1,309 @@
+package service
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"job-service/internal/fleet"
+	"job-service/internal/storage"
+	"shared/routing"
+)
+
+// nearestFleetClient mirrors the fleet service's nearest-vehicle search so greedy
+// dispatch makes the same choices it would in production
+type nearestFleetClient struct {
+	*MockFleetClient
+}
+
+func (n *nearestFleetClient) FindNearestVehicle(ctx context.Context, region string, pickupLat, pickupLng, tripDistanceKm float64, excludeVehicleIDs ...string) (*fleet.Vehicle, error) {
+	excluded := make(map[string]bool)
+	for _, id := range excludeVehicleIDs {
+		excluded[id] = true
+	}
+
+	var best *fleet.Vehicle
+	minDistance := math.MaxFloat64
+	for _, vehicle := range n.vehicles {
+		if excluded[vehicle.ID] || vehicle.Region != region || vehicle.Status != "available" {
+			continue
+		}
+		distance := calculateDistance(vehicle.LocationLat, vehicle.LocationLng, pickupLat, pickupLng)
+		if vehicle.BatteryRangeKm < (distance+tripDistanceKm)*1.2 {
+			continue
+		}
+		if distance < minDistance {
+			minDistance = distance
+			best = vehicle
+		}
+	}
+
+	if best == nil {
+		return nil, fleet.ErrNoVehicleAvailable
+	}
+	return best, nil
+}
+
+// setupDispatchScenario creates two pending jobs and two vehicles on an east-west
+// line where handing the first job its nearest vehicle forces a long pickup for the second
+func setupDispatchScenario(t *testing.T, mode DispatchMode) (*JobService, *nearestFleetClient, map[string]*fleet.Vehicle) {
+	jobStorage := storage.NewMemoryJobStorage()
+	fleetClient := &nearestFleetClient{MockFleetClient: NewMockFleetClient()}
+	jobService := NewJobService(jobStorage, fleetClient)
+	jobService.SetDispatchMode(mode)
+	ctx := context.Background()
+
+	// Create jobs before any vehicle exists so both start out pending
+	if _, err := jobService.CreateRideJob(ctx, "customer-1", "us-west-2", 45.5, -122.689, 45.52, -122.689); err != nil {
+		t.Fatalf("Failed to create first job: %v", err)
+	}
+	if _, err := jobService.CreateRideJob(ctx, "customer-2", "us-west-2", 45.5, -122.670, 45.52, -122.670); err != nil {
+		t.Fatalf("Failed to create second job: %v", err)
+	}
+
+	original := map[string]*fleet.Vehicle{
+		"vehicle-west": {ID: "vehicle-west", Region: "us-west-2", Status: "available", BatteryRangeKm: 300, LocationLat: 45.5, LocationLng: -122.700},
+		"vehicle-east": {ID: "vehicle-east", Region: "us-west-2", Status: "available", BatteryRangeKm: 300, LocationLat: 45.5, LocationLng: -122.680},
+	}
+	for _, vehicle := range original {
+		copied := *vehicle
+		fleetClient.AddVehicle(&copied)
+	}
+
+	return jobService, fleetClient, original
+}
+
+// totalPickupDistance sums the distance from each assigned vehicle's starting point to its pickup
+func totalPickupDistance(t *testing.T, jobService *JobService, vehicles map[string]*fleet.Vehicle) (float64, int) {
+	jobs, err := jobService.GetJobsByStatus(context.Background(), "assigned")
+	if err != nil {
+		t.Fatalf("Failed to get assigned jobs: %v", err)
+	}
+
+	total := 0.0
+	for _, job := range jobs {
+		vehicle := vehicles[*job.AssignedVehicleID]
+		total += calculateDistance(vehicle.LocationLat, vehicle.LocationLng, job.PickupLat, job.PickupLng)
+	}
+	return total, len(jobs)
+}
+
+func TestBatchDispatchReducesTotalPickupDistance(t *testing.T) {
+	ctx := context.Background()
+
+	greedyService, _, greedyVehicles := setupDispatchScenario(t, DispatchModeGreedy)
+	if err := greedyService.ProcessPendingJobs(ctx); err != nil {
+		t.Fatalf("Greedy dispatch failed: %v", err)
+	}
+	greedyTotal, greedyAssigned := totalPickupDistance(t, greedyService, greedyVehicles)
+
+	batchService, _, batchVehicles := setupDispatchScenario(t, DispatchModeBatch)
+	if err := batchService.ProcessPendingJobs(ctx); err != nil {
+		t.Fatalf("Batch dispatch failed: %v", err)
+	}
+	batchTotal, batchAssigned := totalPickupDistance(t, batchService, batchVehicles)
+
+	if greedyAssigned != 2 || batchAssigned != 2 {
+		t.Fatalf("Expected both modes to assign 2 jobs, got greedy=%d batch=%d", greedyAssigned, batchAssigned)
+	}
+
+	if batchTotal >= greedyTotal {
+		t.Errorf("Expected batch total pickup distance %.3f km to beat greedy %.3f km", batchTotal, greedyTotal)
+	}
+}
+
+func TestBatchDispatchRespectsBatteryRange(t *testing.T) {
+	jobService, fleetClient, _ := setupDispatchScenario(t, DispatchModeBatch)
+	ctx := context.Background()
+
+	// The west vehicle can no longer cover any trip, so only one job can be served
+	fleetClient.vehicles["vehicle-west"].BatteryRangeKm = 1
+
+	if err := jobService.ProcessPendingJobs(ctx); err != nil {
+		t.Fatalf("Batch dispatch failed: %v", err)
+	}
+
+	assigned, _ := jobService.GetJobsByStatus(ctx, "assigned")
+	if len(assigned) != 1 {
+		t.Fatalf("Expected 1 assigned job, got %d", len(assigned))
+	}
+	if *assigned[0].AssignedVehicleID != "vehicle-east" {
+		t.Errorf("Expected job assigned to vehicle-east, got %s", *assigned[0].AssignedVehicleID)
+	}
+
+	pending, _ := jobService.GetJobsByStatus(ctx, "pending")
+	if len(pending) != 1 {
+		t.Errorf("Expected 1 job left pending, got %d", len(pending))
+	}
+}
+
+func TestBatchDispatchDefersAssignmentOnCreate(t *testing.T) {
+	jobStorage := storage.NewMemoryJobStorage()
+	mockFleetClient := NewMockFleetClient()
+	jobService := NewJobService(jobStorage, mockFleetClient)
+	jobService.SetDispatchMode(DispatchModeBatch)
+	ctx := context.Background()
+
+	mockFleetClient.AddVehicle(&fleet.Vehicle{
+		ID:             "vehicle-1",
+		Region:         "us-west-2",
+		Status:         "available",
+		BatteryRangeKm: 300,
+		LocationLat:    45.5152,
+		LocationLng:    -122.6784,
+	})
+
+	job, err := jobService.CreateRideJob(ctx, "customer-1", "us-west-2", 45.5152, -122.6784, 45.5200, -122.6800)
+	if err != nil {
+		t.Fatalf("Failed to create ride job: %v", err)
+	}
+
+	if job.Status != "pending" {
+		t.Errorf("Expected job to wait for the batch cycle, got status %s", job.Status)
+	}
+
+	if err := jobService.ProcessPendingJobs(ctx); err != nil {
+		t.Fatalf("Batch dispatch failed: %v", err)
+	}
+
+	if job.Status != "assigned" {
+		t.Errorf("Expected job to be assigned after batch cycle, got status %s", job.Status)
+	}
+}
+
+func TestParseDispatchMode(t *testing.T) {
+	for _, name := range []string{"greedy", "batch"} {
+		if _, err := ParseDispatchMode(name); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", name, err)
+		}
+	}
+
+	if _, err := ParseDispatchMode("random"); err == nil {
+		t.Error("Expected error for unknown dispatch mode")
+	}
+}
+
+func TestSolveAssignment(t *testing.T) {
+	tests := []struct {
+		name      string
+		cost      [][]float64
+		wantTotal float64
+	}{
+		{
+			name: "square",
+			cost: [][]float64{
+				{4, 1, 3},
+				{2, 0, 5},
+				{3, 2, 2},
+			},
+			wantTotal: 5,
+		},
+		{
+			name: "more vehicles than jobs",
+			cost: [][]float64{
+				{7, 3, 9, 1},
+				{2, 8, 4, 6},
+			},
+			wantTotal: 3,
+		},
+		{
+			name: "more jobs than vehicles",
+			cost: [][]float64{
+				{5, 9},
+				{1, 4},
+				{3, 2},
+			},
+			wantTotal: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := solveAssignment(tt.cost)
+			if len(result) != len(tt.cost) {
+				t.Fatalf("Expected %d rows in result, got %d", len(tt.cost), len(result))
+			}
+
+			used := make(map[int]bool)
+			total := 0.0
+			matched := 0
+			for i, j := range result {
+				if j < 0 {
+					continue
+				}
+				if used[j] {
+					t.Fatalf("Column %d assigned twice", j)
+				}
+				used[j] = true
+				total += tt.cost[i][j]
+				matched++
+			}
+
+			expectedMatched := len(tt.cost)
+			if len(tt.cost[0]) < expectedMatched {
+				expectedMatched = len(tt.cost[0])
+			}
+			if matched != expectedMatched {
+				t.Errorf("Expected %d matches, got %d", expectedMatched, matched)
+			}
+			if total != tt.wantTotal {
+				t.Errorf("Expected total cost %v, got %v", tt.wantTotal, total)
+			}
+		})
+	}
+}
+
+// fixedRouter reports every trip as the same road distance and time
+type fixedRouter struct {
+	estimate routing.Estimate
+}
+
+func (f fixedRouter) Route(ctx context.Context, from, to routing.Point) (routing.Estimate, error) {
+	return f.estimate, nil
+}
+
+func (f fixedRouter) Matrix(ctx context.Context, origins, destinations []routing.Point) ([][]routing.Estimate, error) {
+	matrix := make([][]routing.Estimate, len(origins))
+	for i := range origins {
+		matrix[i] = make([]routing.Estimate, len(destinations))
+		for k := range destinations {
+			matrix[i][k] = f.estimate
+		}
+	}
+	return matrix, nil
+}
+
+func TestJobService_TripDistanceUsesRouter(t *testing.T) {
+	jobService := NewJobService(storage.NewMemoryJobStorage(), NewMockFleetClient())
+	jobService.SetRouter(fixedRouter{estimate: routing.Estimate{DistanceKm: 12.5, Duration: 20 * time.Minute}})
+
+	job, err := jobService.CreateRideJob(context.Background(), "customer-1", "us-west-2", 45.5, -122.689, 45.52, -122.689)
+	if err != nil {
+		t.Fatalf("Failed to create job: %v", err)
+	}
+
+	if job.EstimatedDistanceKm != 12.5 {
+		t.Errorf("Expected road distance 12.5km, got %f", job.EstimatedDistanceKm)
+	}
+	if expected := 12.5 * jobService.PricingRules().Defaults.RidePerKm; job.DistanceFare != expected {
+		t.Errorf("Expected distance fare %.2f priced on road distance, got %.2f", expected, job.DistanceFare)
+	}
+}
+
+func TestBatchDispatchChecksBatteryAgainstRoadDistance(t *testing.T) {
+	jobService, _, _ := setupDispatchScenario(t, DispatchModeBatch)
+	ctx := context.Background()
+
+	// Every pickup is a long detour by road, beyond both vehicles' 300km range
+	jobService.SetRouter(fixedRouter{estimate: routing.Estimate{DistanceKm: 260, Duration: 4 * time.Hour}})
+
+	if err := jobService.ProcessPendingJobs(ctx); err != nil {
+		t.Fatalf("Batch dispatch failed: %v", err)
+	}
+
+	assigned, _ := jobService.GetJobsByStatus(ctx, JobStatusAssigned)
+	if len(assigned) != 0 {
+		t.Errorf("Expected no assignments, got %d", len(assigned))
+	}
+}
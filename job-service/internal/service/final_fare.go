@@ -0,0 +1,36 @@
+package service
+
+import (
+	"job-service/internal/storage"
+)
+
+// TripReport is what the vehicle measured of a job from pickup to drop-off. Zero values
+// mean the vehicle didn't report them.
+type TripReport struct {
+	DistanceKm      float64 `json:"actual_distance_km"`
+	DurationMinutes float64 `json:"actual_duration_minutes"`
+}
+
+// settleFare records the trip a completed job actually took and charges the final fare
+// for it. Missing measurements fall back to the estimated distance and the time between
+// pickup and completion. Quoted jobs keep the price they were quoted, and shared rides
+// are charged for no more than their direct trip.
+func (j *JobService) settleFare(job *storage.Job, trip TripReport) {
+	job.ActualDistanceKm = job.EstimatedDistanceKm
+	if trip.DistanceKm > 0 {
+		job.ActualDistanceKm = trip.DistanceKm
+	}
+
+	job.ActualDurationMinutes = job.EstimatedDurationMinutes
+	if trip.DurationMinutes > 0 {
+		job.ActualDurationMinutes = trip.DurationMinutes
+	} else if job.PickedUpAt != nil && job.CompletedAt != nil {
+		job.ActualDurationMinutes = job.CompletedAt.Sub(*job.PickedUpAt).Minutes()
+	}
+
+	if job.QuoteID != "" {
+		job.EstimatedFareAmount = job.FareAmount
+		return
+	}
+	j.PricingRules().CalculateFinalFare(job)
+}
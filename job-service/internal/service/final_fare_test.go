@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"job-service/internal/fleet"
+	"job-service/internal/storage"
+)
+
+// setupFinalFare creates a job service charging by the minute with one vehicle to dispatch
+func setupFinalFare(t *testing.T) *JobService {
+	mockFleetClient := NewMockFleetClient()
+	jobService := NewJobService(storage.NewMemoryJobStorage(), mockFleetClient)
+
+	rules := DefaultPricingRules()
+	rules.Defaults.RidePerMinute = 0.30
+	jobService.SetPricingRules(rules)
+
+	mockFleetClient.AddVehicle(&fleet.Vehicle{
+		ID:             "vehicle-1",
+		Region:         "us-west-2",
+		Status:         "available",
+		BatteryLevel:   80,
+		BatteryRangeKm: 200.0,
+		LocationLat:    45.50,
+		LocationLng:    -122.60,
+		VehicleType:    "sedan",
+	})
+	return jobService
+}
+
+// driveJob takes a job from pickup to completion, reporting trip
+func driveJob(t *testing.T, jobService *JobService, jobID string, trip TripReport) *storage.Job {
+	ctx := context.Background()
+	if _, err := jobService.ConfirmPickup(ctx, jobID); err != nil {
+		t.Fatalf("Failed to confirm pickup: %v", err)
+	}
+	if err := jobService.CompleteJobWithTrip(ctx, jobID, trip, nil); err != nil {
+		t.Fatalf("Failed to complete job: %v", err)
+	}
+	completed, err := jobService.GetJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("Failed to get job: %v", err)
+	}
+	return completed
+}
+
+func TestJobService_CompleteJobWithTrip_ChargesActualTrip(t *testing.T) {
+	jobService := setupFinalFare(t)
+
+	job, err := jobService.CreateRideJob(context.Background(), "customer-1", "us-west-2", 45.50, -122.60, 45.54, -122.60)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if job.TimeFare <= 0 {
+		t.Fatalf("Expected the booked fare to include the estimated driving time, got %.2f", job.TimeFare)
+	}
+	booked := job.FareAmount
+
+	// Traffic stretched the trip to 8km and 25 minutes
+	completed := driveJob(t, jobService, job.ID, TripReport{DistanceKm: 8, DurationMinutes: 25})
+
+	if completed.ActualDistanceKm != 8 || completed.ActualDurationMinutes != 25 {
+		t.Errorf("Expected the reported 8km in 25 minutes recorded, got %.2fkm in %.2f minutes", completed.ActualDistanceKm, completed.ActualDurationMinutes)
+	}
+	// $2.50 + 8km at $1.80 + 25 minutes at $0.30
+	if math.Abs(completed.TimeFare-7.50) > 1e-9 || math.Abs(completed.FareAmount-24.40) > 1e-9 {
+		t.Errorf("Expected a 24.40 final fare with 7.50 for time, got %.2f with %.2f", completed.FareAmount, completed.TimeFare)
+	}
+	if completed.EstimatedFareAmount != booked || completed.EstimatedDistanceKm != job.EstimatedDistanceKm {
+		t.Errorf("Expected the %.2f estimate kept for auditing, got %.2f", booked, completed.EstimatedFareAmount)
+	}
+
+	revenue, err := jobService.GetRevenue(context.Background(), RevenueQuery{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if math.Abs(revenue.TotalRevenue-24.40) > 1e-9 {
+		t.Errorf("Expected revenue from the final fare, got %.2f", revenue.TotalRevenue)
+	}
+}
+
+func TestJobService_CompleteJob_UnreportedTripUsesEstimate(t *testing.T) {
+	jobService := setupFinalFare(t)
+
+	job, err := jobService.CreateRideJob(context.Background(), "customer-1", "us-west-2", 45.50, -122.60, 45.54, -122.60)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	completed := driveJob(t, jobService, job.ID, TripReport{})
+
+	if completed.ActualDistanceKm != job.EstimatedDistanceKm {
+		t.Errorf("Expected the estimated %.2fkm, got %.2fkm", job.EstimatedDistanceKm, completed.ActualDistanceKm)
+	}
+	// The trip took no time at all between pickup and completion
+	if completed.ActualDurationMinutes > 1 || completed.FareAmount > job.FareAmount {
+		t.Errorf("Expected the time since pickup charged, got %.2f minutes for %.2f", completed.ActualDurationMinutes, completed.FareAmount)
+	}
+}
+
+func TestJobService_CompleteJobWithTrip_QuotedFareStands(t *testing.T) {
+	jobService := setupFinalFare(t)
+	jobService.SetQuoteSigningKey([]byte("test-quote-key"))
+	ctx := context.Background()
+
+	quote, err := jobService.QuoteFare(ctx, "ride", "us-west-2", 45.50, -122.60, 45.54, -122.60, false, 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	job, err := jobService.CreateQuotedJob(ctx, "customer-1", quote.ID, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	completed := driveJob(t, jobService, job.ID, TripReport{DistanceKm: 12, DurationMinutes: 40})
+
+	if completed.FareAmount != quote.FareAmount || completed.EstimatedFareAmount != quote.FareAmount {
+		t.Errorf("Expected the quoted %.2f charged, got %.2f", quote.FareAmount, completed.FareAmount)
+	}
+	if completed.ActualDistanceKm != 12 || completed.ActualDurationMinutes != 40 {
+		t.Errorf("Expected the trip recorded anyway, got %.2fkm in %.2f minutes", completed.ActualDistanceKm, completed.ActualDurationMinutes)
+	}
+}
+
+func TestJobService_CompleteJobWithTrip_SharedRidePaysDirectTrip(t *testing.T) {
+	jobService := setupFinalFare(t)
+
+	job, err := jobService.CreateSharedRideJob(context.Background(), "customer-1", "us-west-2", 45.50, -122.60, 45.54, -122.60, 1, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	booked := job.FareAmount
+
+	// The pool took the rider twice as far and long as riding direct
+	completed := driveJob(t, jobService, job.ID, TripReport{DistanceKm: 2 * job.EstimatedDistanceKm, DurationMinutes: 2*job.EstimatedDurationMinutes + 10})
+
+	if completed.ActualDistanceKm != 2*job.EstimatedDistanceKm {
+		t.Errorf("Expected the pooled %.2fkm recorded, got %.2fkm", 2*job.EstimatedDistanceKm, completed.ActualDistanceKm)
+	}
+	if math.Abs(completed.FareAmount-booked) > 1e-9 || completed.SharedDiscount <= 0 {
+		t.Errorf("Expected the direct trip's %.2f charged with the shared discount, got %.2f with %.2f off", booked, completed.FareAmount, completed.SharedDiscount)
+	}
+
+	// A shorter trip than estimated is still charged as driven
+	job, _ = jobService.CreateSharedRideJob(context.Background(), "customer-1", "us-west-2", 45.50, -122.60, 45.54, -122.60, 1, nil)
+	shorter := driveJob(t, jobService, job.ID, TripReport{DistanceKm: job.EstimatedDistanceKm / 2, DurationMinutes: 1})
+	if shorter.FareAmount >= job.FareAmount {
+		t.Errorf("Expected less than the booked %.2f for a shorter trip, got %.2f", job.FareAmount, shorter.FareAmount)
+	}
+}
@@ -2,29 +2,64 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
+	"sort"
+	"sync"
 	"time"
 
 	"job-service/internal/fleet"
 	"job-service/internal/kinesis"
+	"job-service/internal/metrics"
+	"job-service/internal/payments"
 	"job-service/internal/storage"
+	"shared/routing"
+	"shared/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("job-service/internal/service")
+
 // JobService handles job management operations
 type JobService struct {
-	storage     storage.JobStorage
-	fleetClient fleet.FleetClient
-	pricing     *PricingConfig
-	streamer    *kinesis.Streamer
+	storage       storage.JobStorage
+	fleetClient   fleet.FleetClient
+	pricingMu     sync.RWMutex
+	pricing       *PricingRuleSet
+	pricingFile   string // where the pricing rules are reloaded from, if anywhere
+	streamer      *kinesis.Streamer
+	dispatchMode  DispatchMode
+	vehicleEvents *VehicleEventHub
+	router        routing.Router
+	leadTime      time.Duration // how long before a booking's pickup it is dispatched
+	batchWindow   time.Duration // how long new deliveries wait for others to share their trip
+	surge         *SurgePricer
+	quoteKey      []byte        // signs quote IDs so quoted fares can't be altered
+	quoteTTL      time.Duration // how long a quoted fare can be booked
+	ledger        storage.LedgerStorage
+	payments      payments.Provider
+	revenue       storage.RevenueStorage
 }
 
 // NewJobService creates a new job service instance
-func NewJobService(storage storage.JobStorage, fleetClient fleet.FleetClient) *JobService {
+func NewJobService(jobStorage storage.JobStorage, fleetClient fleet.FleetClient) *JobService {
 	return &JobService{
-		storage:     storage,
-		fleetClient: fleetClient,
-		pricing:     DefaultPricingConfig(),
+		storage:       jobStorage,
+		fleetClient:   fleetClient,
+		pricing:       DefaultPricingRules(),
+		dispatchMode:  DispatchModeGreedy,
+		vehicleEvents: NewVehicleEventHub(),
+		router:        routing.NewHaversineRouter(),
+		leadTime:      DefaultScheduleLeadTime,
+		surge:         NewSurgePricer(),
+		quoteTTL:      DefaultQuoteTTL,
+		ledger:        storage.NewMemoryLedgerStorage(),
+		payments:      payments.NewFakeProvider(),
+		revenue:       storage.NewMemoryRevenueStorage(),
 	}
 }
 
@@ -33,26 +68,82 @@ func (j *JobService) SetKinesisStreamer(streamer *kinesis.Streamer) {
 	j.streamer = streamer
 }
 
+// SetRouter sets how road distances and travel times are estimated
+func (j *JobService) SetRouter(router routing.Router) {
+	j.router = router
+}
+
+// SetDispatchMode selects how pending jobs are matched to vehicles
+func (j *JobService) SetDispatchMode(mode DispatchMode) {
+	j.dispatchMode = mode
+}
+
 // CreateRideJob creates a new ride request
 func (j *JobService) CreateRideJob(ctx context.Context, customerID, region string, pickupLat, pickupLng, destLat, destLng float64) (*storage.Job, error) {
-	jobID := fmt.Sprintf("ride-%d", generateJobID())
-
-	job := &storage.Job{
-		ID:                  jobID,
-		JobType:             "ride",
-		Status:              "pending",
-		PickupLat:           pickupLat,
-		PickupLng:           pickupLng,
-		DestinationLat:      destLat,
-		DestinationLng:      destLng,
-		EstimatedDistanceKm: calculateDistance(pickupLat, pickupLng, destLat, destLng),
+	return j.submitJob(ctx, j.newRideJob(ctx, customerID, region, pickupLat, pickupLng, destLat, destLng))
+}
+
+// CreateDeliveryJob creates a new delivery request
+func (j *JobService) CreateDeliveryJob(ctx context.Context, customerID, region string, pickupLat, pickupLng, destLat, destLng float64, details *storage.DeliveryDetails) (*storage.Job, error) {
+	return j.submitJob(ctx, j.newDeliveryJob(ctx, customerID, region, pickupLat, pickupLng, destLat, destLng, details))
+}
+
+// newRideJob builds a pending ride job from a pickup to a destination
+func (j *JobService) newRideJob(ctx context.Context, customerID, region string, pickupLat, pickupLng, destLat, destLng float64) *storage.Job {
+	return j.newJob(ctx, "ride", customerID, region, directStops(pickupLat, pickupLng, destLat, destLng))
+}
+
+// newDeliveryJob builds a pending delivery job from a pickup to a destination
+func (j *JobService) newDeliveryJob(ctx context.Context, customerID, region string, pickupLat, pickupLng, destLat, destLng float64, details *storage.DeliveryDetails) *storage.Job {
+	job := j.newJob(ctx, "delivery", customerID, region, directStops(pickupLat, pickupLng, destLat, destLng))
+	job.DeliveryDetails = details
+	return job
+}
+
+// newJob builds a pending job visiting already validated stops, routing each leg
+func (j *JobService) newJob(ctx context.Context, jobType, customerID, region string, stops []storage.Stop) *storage.Job {
+	j.routeLegs(ctx, stops)
+
+	first, last := stops[0], stops[len(stops)-1]
+	return &storage.Job{
+		ID:                  fmt.Sprintf("%s-%d", jobType, generateJobID()),
+		JobType:             jobType,
+		Status:              JobStatusPending,
+		PickupLat:           first.Lat,
+		PickupLng:           first.Lng,
+		DestinationLat:      last.Lat,
+		DestinationLng:      last.Lng,
+		EstimatedDistanceKm: TotalDistanceKm(stops),
+		Stops:               stops,
 		CustomerID:          customerID,
 		Region:              region,
 		CreatedAt:           time.Now(),
+
+		EstimatedDurationMinutes: TotalDurationMinutes(stops),
 	}
+}
 
-	// Calculate pricing
-	j.pricing.CalculateFare(job)
+// directStops is the stop list of a job going straight from its pickup to its destination
+func directStops(pickupLat, pickupLng, destLat, destLng float64) []storage.Stop {
+	return []storage.Stop{
+		{Lat: pickupLat, Lng: pickupLng, Type: StopTypePickup},
+		{Lat: destLat, Lng: destLng, Type: StopTypeDropoff},
+	}
+}
+
+// submitJob prices and stores a new job, then dispatches it if it is pending
+func (j *JobService) submitJob(ctx context.Context, job *storage.Job) (*storage.Job, error) {
+	trace.SpanFromContext(ctx).SetAttributes(tracing.JobID(job.ID))
+
+	// Calculate pricing, surged where jobs already outnumber free vehicles. Bookings are
+	// priced when made, ahead of whatever demand looks like at pickup time, and quoted
+	// jobs keep the fare they were quoted.
+	if job.QuoteID == "" {
+		if job.Status == JobStatusPending {
+			job.SurgeMultiplier = j.surge.Multiplier(job.Region, job.PickupLat, job.PickupLng)
+		}
+		j.PricingRules().CalculateFare(job)
+	}
 
 	if err := j.storage.CreateJob(ctx, job); err != nil {
 		return nil, err
@@ -60,94 +151,139 @@ func (j *JobService) CreateRideJob(ctx context.Context, customerID, region strin
 
 	// Stream job creation event
 	if j.streamer != nil {
-		j.streamer.StreamJobEvent("created", job)
+		j.streamer.StreamJobEvent(ctx, "created", job)
 	}
 
-	// Try to assign immediately
-	if err := j.assignJob(ctx, job); err != nil {
-		fmt.Printf("Failed to assign job %s immediately: %v\n", jobID, err)
-		// Job remains in pending status
+	// Try to assign immediately; in batch mode the job processor matches it on its next
+	// cycle, and deliveries wait there for others to share their trip
+	if job.Status == JobStatusPending && j.dispatchMode == DispatchModeGreedy && !j.batchable(job) {
+		if err := j.assignJob(ctx, job); err != nil {
+			fmt.Printf("Failed to assign job %s immediately: %v\n", job.ID, err)
+			// Job remains in pending status
+		}
 	}
 
 	return job, nil
 }
 
-// CreateDeliveryJob creates a new delivery request
-func (j *JobService) CreateDeliveryJob(ctx context.Context, customerID, region string, pickupLat, pickupLng, destLat, destLng float64, details *storage.DeliveryDetails) (*storage.Job, error) {
-	jobID := fmt.Sprintf("delivery-%d", generateJobID())
-
-	job := &storage.Job{
-		ID:                  jobID,
-		JobType:             "delivery",
-		Status:              "pending",
-		PickupLat:           pickupLat,
-		PickupLng:           pickupLng,
-		DestinationLat:      destLat,
-		DestinationLng:      destLng,
-		EstimatedDistanceKm: calculateDistance(pickupLat, pickupLng, destLat, destLng),
-		CustomerID:          customerID,
-		Region:              region,
-		DeliveryDetails:     details,
-		CreatedAt:           time.Now(),
+// maxAssignmentAttempts bounds how many vehicles assignJob will try when other
+// dispatchers keep claiming its first choice
+const maxAssignmentAttempts = 3
+
+// assignJob attempts to assign a job to an available vehicle
+func (j *JobService) assignJob(ctx context.Context, job *storage.Job) (err error) {
+	ctx, span := tracer.Start(ctx, "JobService.assignJob", trace.WithAttributes(tracing.JobID(job.ID)))
+	defer func() {
+		tracing.End(span, err)
+		metrics.ObserveAssignment(err)
+	}()
+
+	// A shared ride first tries a vehicle already carrying riders going its way
+	if job.Shared {
+		pooled, err := j.joinPool(ctx, job)
+		if err != nil {
+			fmt.Printf("Failed to pool shared job %s, looking for a free vehicle: %v\n", job.ID, err)
+		}
+		if pooled {
+			return nil
+		}
 	}
 
-	// Calculate pricing
-	j.pricing.CalculateFare(job)
+	var claimedVehicles []string
 
-	if err := j.storage.CreateJob(ctx, job); err != nil {
-		return nil, err
-	}
+	for attempt := 0; attempt < maxAssignmentAttempts; attempt++ {
+		// Find nearest available vehicle, skipping ones we lost a race for
+		vehicle, err := j.fleetClient.FindNearestVehicle(ctx, job.Region, job.PickupLat, job.PickupLng, job.EstimatedDistanceKm, claimedVehicles...)
+		if err != nil {
+			return fmt.Errorf("no available vehicle found: %v", err)
+		}
 
-	// Stream job creation event
-	if j.streamer != nil {
-		j.streamer.StreamJobEvent("created", job)
-	}
+		if err := j.commitAssignment(ctx, job, vehicle.ID); err != nil {
+			if errors.Is(err, fleet.ErrVehicleUnavailable) {
+				fmt.Printf("Vehicle %s was claimed concurrently, retrying job %s with next-best vehicle\n", vehicle.ID, job.ID)
+				claimedVehicles = append(claimedVehicles, vehicle.ID)
+				continue
+			}
+			return err
+		}
 
-	// Try to assign immediately
-	if err := j.assignJob(ctx, job); err != nil {
-		fmt.Printf("Failed to assign job %s immediately: %v\n", jobID, err)
-		// Job remains in pending status
+		return nil
 	}
 
-	return job, nil
+	return fmt.Errorf("failed to assign job after %d attempts: vehicles %v were claimed concurrently", maxAssignmentAttempts, claimedVehicles)
 }
 
-// assignJob attempts to assign a job to an available vehicle
-func (j *JobService) assignJob(ctx context.Context, job *storage.Job) error {
-	// Find nearest available vehicle
-	vehicle, err := j.fleetClient.FindNearestVehicle(ctx, job.Region, job.PickupLat, job.PickupLng, job.EstimatedDistanceKm)
-	if err != nil {
-		return fmt.Errorf("no available vehicle found: %v", err)
+// commitAssignment claims the vehicle in the fleet service and records the assignment on the job
+func (j *JobService) commitAssignment(ctx context.Context, job *storage.Job, vehicleID string) error {
+	// Assign job to vehicle in fleet service
+	if err := j.claimVehicle(ctx, job, vehicleID); err != nil {
+		return fmt.Errorf("failed to assign job to vehicle: %w", err)
 	}
 
-	// Assign job to vehicle in fleet service
-	if err := j.fleetClient.AssignJob(ctx, vehicle.ID, job.ID); err != nil {
-		return fmt.Errorf("failed to assign job to vehicle: %v", err)
+	return j.recordAssignment(ctx, job, vehicleID)
+}
+
+// claimVehicle claims an available vehicle for a job. A shared ride starts the
+// vehicle's stop plan so other shared rides can join it later.
+func (j *JobService) claimVehicle(ctx context.Context, job *storage.Job, vehicleID string) error {
+	if job.Shared {
+		return j.fleetClient.StartPool(ctx, vehicleID, sharedRide(job))
 	}
+	return j.fleetClient.AssignJob(ctx, vehicleID, job.ID)
+}
 
+// recordAssignment records a vehicle already claimed in the fleet service on the job
+func (j *JobService) recordAssignment(ctx context.Context, job *storage.Job, vehicleID string) error {
 	// Update job status
-	if err := j.storage.UpdateJobStatus(ctx, job.ID, "assigned", &vehicle.ID); err != nil {
-		return fmt.Errorf("failed to update job status: %v", err)
+	assigned, err := j.transitionJob(ctx, job.ID, JobStatusAssigned, nil, func(updated *storage.Job) error {
+		updated.AssignedVehicleID = &vehicleID
+		updated.BatchID = job.BatchID // set for deliveries sharing the trip
+		return nil
+	})
+	if err != nil {
+		// The job moved on (e.g. it was cancelled) while we claimed the vehicle, so hand it back
+		if releaseErr := j.fleetClient.ReleaseVehicle(ctx, vehicleID, job.ID); releaseErr != nil {
+			fmt.Printf("Failed to release vehicle %s after aborted assignment of job %s: %v\n", vehicleID, job.ID, releaseErr)
+		}
+		return fmt.Errorf("failed to update job status: %w", err)
 	}
+	*job = *assigned
+	trace.SpanFromContext(ctx).SetAttributes(tracing.VehicleID(vehicleID))
 
 	// Stream job assignment event
 	if j.streamer != nil {
-		// Update job object with assigned vehicle for streaming
-		job.AssignedVehicleID = &vehicle.ID
-		job.Status = "assigned"
-		j.streamer.StreamJobEvent("assigned", job)
+		j.streamer.StreamJobEvent(ctx, "assigned", job)
 	}
+	j.notifyVehicle(job.AssignedVehicleID, "assigned", job)
 
-	fmt.Printf("Job %s assigned to vehicle %s\n", job.ID, vehicle.ID)
+	fmt.Printf("Job %s assigned to vehicle %s\n", job.ID, vehicleID)
 	return nil
 }
 
-// ProcessPendingJobs attempts to assign all pending jobs
-func (j *JobService) ProcessPendingJobs(ctx context.Context) error {
-	pendingJobs, err := j.storage.GetJobsByStatus(ctx, "pending")
+// ProcessPendingJobs attempts to assign all pending jobs, oldest first
+func (j *JobService) ProcessPendingJobs(ctx context.Context) (err error) {
+	ctx, span := tracer.Start(ctx, "JobService.ProcessPendingJobs")
+	defer func() { tracing.End(span, err) }()
+
+	pendingJobs, err := j.storage.GetJobsByStatus(ctx, JobStatusPending)
 	if err != nil {
 		return err
 	}
+	metrics.PendingJobs.Set(float64(len(pendingJobs)))
+	span.SetAttributes(attribute.Int("job.pending_count", len(pendingJobs)))
+
+	sort.Slice(pendingJobs, func(a, b int) bool {
+		if !pendingJobs[a].CreatedAt.Equal(pendingJobs[b].CreatedAt) {
+			return pendingJobs[a].CreatedAt.Before(pendingJobs[b].CreatedAt)
+		}
+		return pendingJobs[a].ID < pendingJobs[b].ID
+	})
+
+	pendingJobs = j.dispatchDeliveryBatches(ctx, pendingJobs)
+
+	if j.dispatchMode == DispatchModeBatch {
+		return j.processPendingJobsBatch(ctx, pendingJobs)
+	}
 
 	for _, job := range pendingJobs {
 		if err := j.assignJob(ctx, job); err != nil {
@@ -161,28 +297,244 @@ func (j *JobService) ProcessPendingJobs(ctx context.Context) error {
 
 // CompleteJob marks a job as completed
 func (j *JobService) CompleteJob(ctx context.Context, jobID string) error {
-	job, err := j.storage.GetJob(ctx, jobID)
+	return j.completeJob(ctx, jobID, nil, TripReport{})
+}
+
+// CompleteJobIfMatch marks a job as completed only if it is still at expectedVersion,
+// returning storage.ErrVersionConflict otherwise
+func (j *JobService) CompleteJobIfMatch(ctx context.Context, jobID string, expectedVersion int64) error {
+	return j.completeJob(ctx, jobID, &expectedVersion, TripReport{})
+}
+
+// CompleteJobWithTrip marks a job as completed with the trip the vehicle measured, which
+// its final fare is charged for. A nil expectedVersion completes the job whatever its version.
+func (j *JobService) CompleteJobWithTrip(ctx context.Context, jobID string, trip TripReport, expectedVersion *int64) error {
+	return j.completeJob(ctx, jobID, expectedVersion, trip)
+}
+
+// completeJob completes a job and settles its final fare, optionally guarded by the
+// caller's view of its version
+func (j *JobService) completeJob(ctx context.Context, jobID string, expectedVersion *int64, trip TripReport) error {
+	trace.SpanFromContext(ctx).SetAttributes(tracing.JobID(jobID))
+
+	job, err := j.transitionJob(ctx, jobID, JobStatusCompleted, expectedVersion, func(updated *storage.Job) error {
+		recordArrival(updated, len(updated.Stops)-1, *updated.CompletedAt)
+		j.settleFare(updated, trip)
+		return nil
+	})
 	if err != nil {
 		return err
 	}
 
-	if job.Status != "assigned" && job.Status != "in_progress" {
-		return fmt.Errorf("job %s is not in progress, current status: %s", jobID, job.Status)
+	// Free the vehicle, or just this rider's seats if it is still carrying others
+	if job.AssignedVehicleID != nil {
+		if err := j.fleetClient.CompleteJob(ctx, *job.AssignedVehicleID, jobID); err != nil {
+			fmt.Printf("Failed to release vehicle %s after completing job %s: %v\n", *job.AssignedVehicleID, jobID, err)
+		}
 	}
 
-	if err := j.storage.UpdateJobStatus(ctx, jobID, "completed", job.AssignedVehicleID); err != nil {
-		return err
+	j.chargeJob(ctx, job)
+	if err := j.recordRevenue(ctx, job); err != nil {
+		fmt.Printf("Failed to record revenue for job %s, will retry: %v\n", jobID, err)
 	}
 
 	// Stream job completion event
 	if j.streamer != nil {
-		job.Status = "completed"
-		j.streamer.StreamJobEvent("completed", job)
+		j.streamer.StreamJobEvent(ctx, "completed", job)
 	}
+	j.notifyVehicle(job.AssignedVehicleID, "completed", job)
 
 	return nil
 }
 
+// ConfirmPickup marks an assigned job as in progress once the vehicle has
+// collected the passenger or order
+func (j *JobService) ConfirmPickup(ctx context.Context, jobID string) (*storage.Job, error) {
+	trace.SpanFromContext(ctx).SetAttributes(tracing.JobID(jobID))
+
+	job, err := j.transitionJob(ctx, jobID, JobStatusInProgress, nil, func(updated *storage.Job) error {
+		recordArrival(updated, 0, *updated.PickedUpAt)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Tell the fleet the vehicle is loaded, which takes a shared ride's pickup off the
+	// vehicle's stop plan so its seats are counted as taken
+	if job.AssignedVehicleID != nil {
+		if err := j.fleetClient.RecordPickup(ctx, *job.AssignedVehicleID, jobID); err != nil {
+			fmt.Printf("Failed to record pickup of job %s on vehicle %s: %v\n", jobID, *job.AssignedVehicleID, err)
+		}
+	}
+
+	// Stream pickup event
+	if j.streamer != nil {
+		j.streamer.StreamJobEvent(ctx, "picked_up", job)
+	}
+	j.notifyVehicle(job.AssignedVehicleID, "picked_up", job)
+
+	return job, nil
+}
+
+// FailJob marks a job as failed after it was dispatched, e.g. because the vehicle
+// broke down, and releases the vehicle. Failed jobs are not charged.
+func (j *JobService) FailJob(ctx context.Context, jobID, reason string) (*storage.Job, error) {
+	trace.SpanFromContext(ctx).SetAttributes(tracing.JobID(jobID))
+
+	job, err := j.transitionJob(ctx, jobID, JobStatusFailed, nil, func(updated *storage.Job) error {
+		updated.FailureReason = reason
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if job.AssignedVehicleID != nil {
+		if err := j.fleetClient.ReleaseVehicle(ctx, *job.AssignedVehicleID, jobID); err != nil {
+			fmt.Printf("Failed to release vehicle %s for failed job %s: %v\n", *job.AssignedVehicleID, jobID, err)
+		}
+	}
+
+	// Stream job failure event
+	if j.streamer != nil {
+		j.streamer.StreamJobEvent(ctx, "failed", job)
+	}
+	j.notifyVehicle(job.AssignedVehicleID, "failed", job)
+
+	fmt.Printf("Job %s failed: %s\n", jobID, reason)
+	return job, nil
+}
+
+// maxJobAttempts is how many times a job may be abandoned by its vehicle before
+// it is failed instead of being dispatched again
+const maxJobAttempts = 3
+
+// ErrVehicleMismatch is returned when a vehicle reports on a job it is not assigned to
+var ErrVehicleMismatch = errors.New("job is not assigned to this vehicle")
+
+// AbandonJob is called by a vehicle that can no longer serve its job, e.g. because
+// its battery ran low. A job that was not yet picked up goes back to pending for
+// another vehicle; one with the passenger or order on board fails.
+func (j *JobService) AbandonJob(ctx context.Context, jobID, vehicleID, reason string) (*storage.Job, error) {
+	trace.SpanFromContext(ctx).SetAttributes(tracing.JobID(jobID), tracing.VehicleID(vehicleID))
+
+	job, err := j.requeueJob(ctx, jobID, vehicleID, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	// Try to find a replacement vehicle straight away
+	if job.Status == JobStatusPending && j.dispatchMode == DispatchModeGreedy {
+		if err := j.assignJob(ctx, job); err != nil {
+			fmt.Printf("Failed to reassign abandoned job %s immediately: %v\n", jobID, err)
+		}
+	}
+
+	return job, nil
+}
+
+// requeueJob takes a job away from a vehicle that abandoned it and returns it to
+// pending with its attempt counter incremented. Jobs already in progress, or that
+// have used up their attempts, are failed instead.
+func (j *JobService) requeueJob(ctx context.Context, jobID, vehicleID, reason string) (*storage.Job, error) {
+	current, err := j.storage.GetJob(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	if current.AssignedVehicleID == nil || *current.AssignedVehicleID != vehicleID {
+		return nil, fmt.Errorf("%w: job %s, vehicle %s", ErrVehicleMismatch, jobID, vehicleID)
+	}
+
+	if current.Status == JobStatusInProgress || current.Attempts+1 >= maxJobAttempts {
+		return j.FailJob(ctx, jobID, fmt.Sprintf("abandoned by vehicle %s: %s", vehicleID, reason))
+	}
+
+	requeued, err := j.transitionJob(ctx, jobID, JobStatusPending, nil, func(updated *storage.Job) error {
+		if updated.AssignedVehicleID == nil || *updated.AssignedVehicleID != vehicleID {
+			return fmt.Errorf("%w: job %s, vehicle %s", ErrVehicleMismatch, jobID, vehicleID)
+		}
+		updated.AssignedVehicleID = nil
+		updated.AssignedAt = nil
+		updated.BatchID = ""
+		updated.Attempts++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Detach the vehicle in the fleet service so it is not left holding the job
+	if err := j.fleetClient.ReleaseVehicle(ctx, vehicleID, jobID); err != nil {
+		fmt.Printf("Failed to release vehicle %s from abandoned job %s: %v\n", vehicleID, jobID, err)
+	}
+
+	// Stream job requeue event
+	if j.streamer != nil {
+		j.streamer.StreamJobEvent(ctx, "requeued", requeued)
+	}
+	j.notifyVehicle(&vehicleID, "requeued", requeued)
+
+	fmt.Printf("Job %s abandoned by vehicle %s (%s), returned to pending after %d attempt(s)\n", jobID, vehicleID, reason, requeued.Attempts)
+	return requeued, nil
+}
+
+// Cancellation reasons accepted by CancelJob
+const (
+	CancelReasonCustomer     = "customer_cancelled"
+	CancelReasonNoShow       = "no_show"
+	CancelReasonVehicleFault = "vehicle_fault"
+)
+
+// ErrInvalidCancellationReason is returned for unknown or inapplicable cancellation reasons
+var ErrInvalidCancellationReason = errors.New("invalid cancellation reason")
+
+// CancelJob cancels a job that has not been picked up yet, charging the configured
+// cancellation fee and releasing its vehicle back to the fleet
+func (j *JobService) CancelJob(ctx context.Context, jobID, reason string) (*storage.Job, error) {
+	trace.SpanFromContext(ctx).SetAttributes(tracing.JobID(jobID))
+
+	switch reason {
+	case CancelReasonCustomer, CancelReasonNoShow, CancelReasonVehicleFault:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidCancellationReason, reason)
+	}
+
+	cancelled, err := j.transitionJob(ctx, jobID, JobStatusCancelled, nil, func(updated *storage.Job) error {
+		if reason == CancelReasonNoShow && updated.AssignedVehicleID == nil {
+			return fmt.Errorf("%w: no vehicle was dispatched for job %s", ErrInvalidCancellationReason, jobID)
+		}
+		updated.CancellationReason = reason
+		j.PricingRules().ConfigFor(updated).CalculateCancellationFee(updated, reason)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Free the vehicle so it can take other work
+	if cancelled.AssignedVehicleID != nil {
+		if err := j.fleetClient.ReleaseVehicle(ctx, *cancelled.AssignedVehicleID, jobID); err != nil {
+			fmt.Printf("Failed to release vehicle %s for cancelled job %s: %v\n", *cancelled.AssignedVehicleID, jobID, err)
+		}
+	}
+
+	j.chargeJob(ctx, cancelled)
+	if err := j.recordRevenue(ctx, cancelled); err != nil {
+		fmt.Printf("Failed to record revenue for job %s, will retry: %v\n", jobID, err)
+	}
+
+	// Stream job cancellation event
+	if j.streamer != nil {
+		j.streamer.StreamJobEvent(ctx, "cancelled", cancelled)
+	}
+	j.notifyVehicle(cancelled.AssignedVehicleID, "cancelled", cancelled)
+
+	fmt.Printf("Job %s cancelled (%s), fee %.2f\n", jobID, reason, cancelled.CancellationFee)
+	return cancelled, nil
+}
+
 // GetJob retrieves a job by ID
 func (j *JobService) GetJob(ctx context.Context, jobID string) (*storage.Job, error) {
 	return j.storage.GetJob(ctx, jobID)
@@ -193,7 +545,7 @@ func (j *JobService) GetAllJobs(ctx context.Context) ([]*storage.Job, error) {
 	return j.storage.GetAllJobs(ctx)
 }
 
-// GetActiveJobCount returns the count of active jobs (pending + assigned)
+// GetActiveJobCount returns the count of active jobs (pending, assigned or in progress)
 func (j *JobService) GetActiveJobCount() (int, error) {
 	jobs, err := j.storage.GetAllJobs(context.Background())
 	if err != nil {
@@ -202,7 +554,7 @@ func (j *JobService) GetActiveJobCount() (int, error) {
 
 	activeCount := 0
 	for _, job := range jobs {
-		if job.Status == "pending" || job.Status == "assigned" {
+		if job.Status == JobStatusPending || job.Status == JobStatusAssigned || job.Status == JobStatusInProgress {
 			activeCount++
 		}
 	}
@@ -215,6 +567,40 @@ func (j *JobService) GetJobsByStatus(ctx context.Context, status string) ([]*sto
 	return j.storage.GetJobsByStatus(ctx, status)
 }
 
+// GetJobsByVehicle returns the jobs assigned to a vehicle, optionally limited to one status
+func (j *JobService) GetJobsByVehicle(ctx context.Context, vehicleID, status string) ([]*storage.Job, error) {
+	jobs, err := j.storage.GetJobsByVehicle(ctx, vehicleID)
+	if err != nil {
+		return nil, err
+	}
+
+	if status == "" {
+		return jobs, nil
+	}
+
+	var filtered []*storage.Job
+	for _, job := range jobs {
+		if job.Status == status {
+			filtered = append(filtered, job)
+		}
+	}
+
+	return filtered, nil
+}
+
+// tripEstimate estimates the road distance and driving time of a trip, falling back to
+// a straight-line estimate if the router fails
+func (j *JobService) tripEstimate(ctx context.Context, pickupLat, pickupLng, destLat, destLng float64) routing.Estimate {
+	from := routing.Point{Lat: pickupLat, Lng: pickupLng}
+	to := routing.Point{Lat: destLat, Lng: destLng}
+	estimate, err := j.router.Route(ctx, from, to)
+	if err != nil {
+		fmt.Printf("Failed to route trip, using straight-line distance: %v\n", err)
+		estimate, _ = routing.NewHaversineRouter().Route(ctx, from, to)
+	}
+	return estimate
+}
+
 // calculateDistance calculates the distance between two points using Haversine formula
 func calculateDistance(lat1, lng1, lat2, lng2 float64) float64 {
 	const earthRadius = 6371 // Earth's radius in kilometers
@@ -240,54 +626,3 @@ func generateJobID() int64 {
 	jobCounter++
 	return jobCounter
 }
-
-// GetRevenue calculates total revenue from completed jobs
-func (j *JobService) GetRevenue(ctx context.Context) (map[string]interface{}, error) {
-	jobs, err := j.storage.GetAllJobs(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	var totalRevenue float64
-	var rideRevenue float64
-	var deliveryRevenue float64
-	var completedJobs int
-	var rideCount int
-	var deliveryCount int
-
-	for _, job := range jobs {
-		if job.Status == "completed" {
-			totalRevenue += job.FareAmount
-			completedJobs++
-
-			if job.JobType == "ride" {
-				rideRevenue += job.FareAmount
-				rideCount++
-			} else {
-				deliveryRevenue += job.FareAmount
-				deliveryCount++
-			}
-		}
-	}
-
-	avgRideFare := 0.0
-	if rideCount > 0 {
-		avgRideFare = rideRevenue / float64(rideCount)
-	}
-
-	avgDeliveryFare := 0.0
-	if deliveryCount > 0 {
-		avgDeliveryFare = deliveryRevenue / float64(deliveryCount)
-	}
-
-	return map[string]interface{}{
-		"total_revenue":     totalRevenue,
-		"ride_revenue":      rideRevenue,
-		"delivery_revenue":  deliveryRevenue,
-		"completed_jobs":    completedJobs,
-		"ride_count":        rideCount,
-		"delivery_count":    deliveryCount,
-		"avg_ride_fare":     avgRideFare,
-		"avg_delivery_fare": avgDeliveryFare,
-	}, nil
-}
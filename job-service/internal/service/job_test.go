@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"job-service/internal/fleet"
@@ -11,13 +12,18 @@ import (
 // MockFleetClient implements fleet.FleetClient interface for testing
 type MockFleetClient struct {
 	vehicles    map[string]*fleet.Vehicle
-	assignments map[string]string // vehicleID -> jobID
+	assignments map[string]string   // vehicleID -> jobID
+	pools       map[string][]string // vehicleID -> shared ride job IDs
+	pickups     []string            // job IDs reported picked up
+	batches     map[string][]fleet.PlannedStop
 }
 
 func NewMockFleetClient() *MockFleetClient {
 	return &MockFleetClient{
 		vehicles:    make(map[string]*fleet.Vehicle),
 		assignments: make(map[string]string),
+		pools:       make(map[string][]string),
+		batches:     make(map[string][]fleet.PlannedStop),
 	}
 }
 
@@ -25,9 +31,17 @@ func (m *MockFleetClient) AddVehicle(vehicle *fleet.Vehicle) {
 	m.vehicles[vehicle.ID] = vehicle
 }
 
-func (m *MockFleetClient) FindNearestVehicle(ctx context.Context, region string, pickupLat, pickupLng, tripDistanceKm float64) (*fleet.Vehicle, error) {
+func (m *MockFleetClient) FindNearestVehicle(ctx context.Context, region string, pickupLat, pickupLng, tripDistanceKm float64, excludeVehicleIDs ...string) (*fleet.Vehicle, error) {
+	excluded := make(map[string]bool)
+	for _, id := range excludeVehicleIDs {
+		excluded[id] = true
+	}
+
 	// Simple mock: return first available vehicle with sufficient battery
 	for _, vehicle := range m.vehicles {
+		if excluded[vehicle.ID] {
+			continue
+		}
 		if vehicle.Region == region && vehicle.Status == "available" && vehicle.BatteryRangeKm >= tripDistanceKm*1.2 {
 			return vehicle, nil
 		}
@@ -45,6 +59,86 @@ func (m *MockFleetClient) AssignJob(ctx context.Context, vehicleID, jobID string
 	return fleet.ErrVehicleNotFound
 }
 
+func (m *MockFleetClient) ReleaseVehicle(ctx context.Context, vehicleID, jobID string) error {
+	vehicle, exists := m.vehicles[vehicleID]
+	if !exists {
+		return fleet.ErrVehicleNotFound
+	}
+
+	// A shared vehicle stays busy while it carries other riders
+	for i, id := range m.pools[vehicleID] {
+		if id != jobID {
+			continue
+		}
+		remaining := append(m.pools[vehicleID][:i:i], m.pools[vehicleID][i+1:]...)
+		if len(remaining) > 0 {
+			m.pools[vehicleID] = remaining
+			vehicle.CurrentJobID = &remaining[0]
+			return nil
+		}
+		delete(m.pools, vehicleID)
+		vehicle.CurrentJobID = &jobID
+	}
+
+	if vehicle.CurrentJobID == nil || *vehicle.CurrentJobID != jobID {
+		return fleet.ErrVehicleReassigned
+	}
+	if vehicle.Status == "busy" {
+		vehicle.Status = "available"
+	}
+	vehicle.CurrentJobID = nil
+	delete(m.assignments, vehicleID)
+	return nil
+}
+
+func (m *MockFleetClient) StartPool(ctx context.Context, vehicleID string, ride fleet.SharedRide) error {
+	if err := m.AssignJob(ctx, vehicleID, ride.JobID); err != nil {
+		return err
+	}
+	m.pools[vehicleID] = []string{ride.JobID}
+	return nil
+}
+
+func (m *MockFleetClient) StartBatch(ctx context.Context, vehicleID string, stops []fleet.PlannedStop) error {
+	if err := m.AssignJob(ctx, vehicleID, stops[0].JobID); err != nil {
+		return err
+	}
+	m.batches[vehicleID] = stops
+
+	// Orders are released one by one, like riders in a pool
+	var orders []string
+	for _, stop := range stops {
+		if stop.Type == StopTypePickup {
+			orders = append(orders, stop.JobID)
+		}
+	}
+	m.pools[vehicleID] = orders
+	return nil
+}
+
+func (m *MockFleetClient) JoinPool(ctx context.Context, ride fleet.SharedRide) (*fleet.Vehicle, error) {
+	// Simple mock: any shared vehicle in the region takes the ride
+	for vehicleID, pool := range m.pools {
+		if _, delivering := m.batches[vehicleID]; delivering {
+			continue
+		}
+		if vehicle := m.vehicles[vehicleID]; vehicle.Region == ride.Region {
+			m.pools[vehicleID] = append(pool, ride.JobID)
+			return vehicle, nil
+		}
+	}
+	return nil, fleet.ErrNoPoolMatch
+}
+
+func (m *MockFleetClient) RecordPickup(ctx context.Context, vehicleID, jobID string) error {
+	m.pickups = append(m.pickups, jobID)
+	return nil
+}
+
+func (m *MockFleetClient) CompleteJob(ctx context.Context, vehicleID, jobID string) error {
+	return m.ReleaseVehicle(ctx, vehicleID, jobID)
+}
+
 func (m *MockFleetClient) GetAllVehicles(ctx context.Context) ([]*fleet.Vehicle, error) {
 	var result []*fleet.Vehicle
 	for _, vehicle := range m.vehicles {
@@ -274,6 +368,93 @@ func TestJobService_ProcessPendingJobs(t *testing.T) {
 	}
 }
 
+// racingFleetClient simulates another dispatcher claiming vehicles between
+// FindNearestVehicle and AssignJob
+type racingFleetClient struct {
+	*MockFleetClient
+	stolen map[string]bool
+}
+
+func (r *racingFleetClient) AssignJob(ctx context.Context, vehicleID, jobID string) error {
+	if r.stolen[vehicleID] {
+		return fleet.ErrVehicleUnavailable
+	}
+	return r.MockFleetClient.AssignJob(ctx, vehicleID, jobID)
+}
+
+func TestJobService_AssignJobRetriesOnConflict(t *testing.T) {
+	jobStorage := storage.NewMemoryJobStorage()
+	mockFleetClient := &racingFleetClient{
+		MockFleetClient: NewMockFleetClient(),
+		stolen:          map[string]bool{"vehicle-1": true},
+	}
+	jobService := NewJobService(jobStorage, mockFleetClient)
+	ctx := context.Background()
+
+	for _, id := range []string{"vehicle-1", "vehicle-2"} {
+		mockFleetClient.AddVehicle(&fleet.Vehicle{
+			ID:             id,
+			Region:         "us-west-2",
+			Status:         "available",
+			BatteryLevel:   80,
+			BatteryRangeKm: 200.0,
+			LocationLat:    37.7749,
+			LocationLng:    -122.4194,
+			VehicleType:    "sedan",
+		})
+	}
+
+	job, err := jobService.CreateRideJob(
+		ctx,
+		"customer-123",
+		"us-west-2",
+		37.7749, -122.4194,
+		37.7849, -122.4094,
+	)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	updatedJob, _ := jobService.GetJob(ctx, job.ID)
+	if updatedJob.Status != "assigned" {
+		t.Fatalf("Expected status 'assigned', got %s", updatedJob.Status)
+	}
+
+	if *updatedJob.AssignedVehicleID != "vehicle-2" {
+		t.Errorf("Expected job to fall back to 'vehicle-2', got %s", *updatedJob.AssignedVehicleID)
+	}
+}
+
+func TestJobService_AssignJobGivesUpWhenAllVehiclesClaimed(t *testing.T) {
+	jobStorage := storage.NewMemoryJobStorage()
+	mockFleetClient := &racingFleetClient{
+		MockFleetClient: NewMockFleetClient(),
+		stolen:          map[string]bool{"vehicle-1": true},
+	}
+	jobService := NewJobService(jobStorage, mockFleetClient)
+	ctx := context.Background()
+
+	mockFleetClient.AddVehicle(&fleet.Vehicle{
+		ID:             "vehicle-1",
+		Region:         "us-west-2",
+		Status:         "available",
+		BatteryRangeKm: 200.0,
+	})
+
+	job, _ := jobService.CreateRideJob(
+		ctx,
+		"customer-123",
+		"us-west-2",
+		37.7749, -122.4194,
+		37.7849, -122.4094,
+	)
+
+	// Job stays pending for the background processor to pick up later
+	if job.Status != "pending" {
+		t.Errorf("Expected status 'pending', got %s", job.Status)
+	}
+}
+
 func TestJobService_CompleteJob(t *testing.T) {
 	jobStorage := storage.NewMemoryJobStorage()
 	mockFleetClient := NewMockFleetClient()
@@ -301,6 +482,11 @@ func TestJobService_CompleteJob(t *testing.T) {
 		37.7849, -122.4094,
 	)
 
+	// Vehicle picks up the passenger
+	if _, err := jobService.ConfirmPickup(ctx, job.ID); err != nil {
+		t.Fatalf("Failed to confirm pickup: %v", err)
+	}
+
 	// Complete the job
 	err := jobService.CompleteJob(ctx, job.ID)
 	if err != nil {
@@ -318,6 +504,163 @@ func TestJobService_CompleteJob(t *testing.T) {
 	}
 }
 
+func TestJobService_CompleteJobIfMatch(t *testing.T) {
+	jobStorage := storage.NewMemoryJobStorage()
+	mockFleetClient := NewMockFleetClient()
+	jobService := NewJobService(jobStorage, mockFleetClient)
+	ctx := context.Background()
+
+	mockFleetClient.AddVehicle(&fleet.Vehicle{
+		ID:             "vehicle-1",
+		Region:         "us-west-2",
+		Status:         "available",
+		BatteryRangeKm: 200.0,
+	})
+
+	job, _ := jobService.CreateRideJob(
+		ctx,
+		"customer-123",
+		"us-west-2",
+		37.7749, -122.4194,
+		37.7849, -122.4094,
+	)
+
+	// Created at version 1, assignment moved it on
+	err := jobService.CompleteJobIfMatch(ctx, job.ID, 1)
+	if !errors.Is(err, storage.ErrVersionConflict) {
+		t.Fatalf("Expected ErrVersionConflict, got %v", err)
+	}
+
+	current, _ := jobService.GetJob(ctx, job.ID)
+	if current.Status != "assigned" {
+		t.Errorf("Expected status 'assigned', got %s", current.Status)
+	}
+
+	current, err = jobService.ConfirmPickup(ctx, job.ID)
+	if err != nil {
+		t.Fatalf("Failed to confirm pickup: %v", err)
+	}
+
+	if err := jobService.CompleteJobIfMatch(ctx, job.ID, current.Version); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	completedJob, _ := jobService.GetJob(ctx, job.ID)
+	if completedJob.Status != "completed" {
+		t.Errorf("Expected status 'completed', got %s", completedJob.Status)
+	}
+	if completedJob.CompletedAt == nil {
+		t.Error("Expected CompletedAt to be set")
+	}
+}
+
+func TestJobService_CancelAssignedJob(t *testing.T) {
+	jobStorage := storage.NewMemoryJobStorage()
+	mockFleetClient := NewMockFleetClient()
+	jobService := NewJobService(jobStorage, mockFleetClient)
+	ctx := context.Background()
+
+	vehicle := &fleet.Vehicle{
+		ID:             "vehicle-1",
+		Region:         "us-west-2",
+		Status:         "available",
+		BatteryRangeKm: 200.0,
+	}
+	mockFleetClient.AddVehicle(vehicle)
+
+	job, _ := jobService.CreateRideJob(
+		ctx,
+		"customer-123",
+		"us-west-2",
+		37.7749, -122.4194,
+		37.7849, -122.4094,
+	)
+	if job.Status != "assigned" {
+		t.Fatalf("Expected job to be assigned, got %s", job.Status)
+	}
+
+	cancelled, err := jobService.CancelJob(ctx, job.ID, CancelReasonNoShow)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if cancelled.Status != "cancelled" {
+		t.Errorf("Expected status 'cancelled', got %s", cancelled.Status)
+	}
+	if cancelled.CancelledAt == nil {
+		t.Error("Expected CancelledAt to be set")
+	}
+	if cancelled.CancellationReason != CancelReasonNoShow {
+		t.Errorf("Expected reason %s, got %s", CancelReasonNoShow, cancelled.CancellationReason)
+	}
+	if cancelled.CancellationFee != jobService.PricingRules().Defaults.CancellationFee {
+		t.Errorf("Expected fee %.2f, got %.2f", jobService.PricingRules().Defaults.CancellationFee, cancelled.CancellationFee)
+	}
+
+	// Vehicle should be back in the pool
+	if vehicle.Status != "available" || vehicle.CurrentJobID != nil {
+		t.Errorf("Expected vehicle to be released, got status %s job %v", vehicle.Status, vehicle.CurrentJobID)
+	}
+
+	// A cancelled job cannot be cancelled or completed again
+	if _, err := jobService.CancelJob(ctx, job.ID, CancelReasonCustomer); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected ErrInvalidTransition, got %v", err)
+	}
+	if err := jobService.CompleteJob(ctx, job.ID); err == nil {
+		t.Error("Expected error completing a cancelled job")
+	}
+
+	revenue, _ := jobService.GetRevenue(ctx, RevenueQuery{})
+	if revenue.CancellationFees != jobService.PricingRules().Defaults.CancellationFee || revenue.CancelledJobs != 1 {
+		t.Errorf("Expected cancellation fees %.2f in revenue, got %.2f", jobService.PricingRules().Defaults.CancellationFee, revenue.CancellationFees)
+	}
+}
+
+func TestJobService_CancelPendingJob(t *testing.T) {
+	jobStorage := storage.NewMemoryJobStorage()
+	mockFleetClient := NewMockFleetClient()
+	jobService := NewJobService(jobStorage, mockFleetClient)
+	ctx := context.Background()
+
+	// No vehicles, so the job stays pending
+	job, _ := jobService.CreateRideJob(
+		ctx,
+		"customer-123",
+		"us-west-2",
+		37.7749, -122.4194,
+		37.7849, -122.4094,
+	)
+
+	if _, err := jobService.CancelJob(ctx, job.ID, CancelReasonNoShow); !errors.Is(err, ErrInvalidCancellationReason) {
+		t.Errorf("Expected ErrInvalidCancellationReason for no-show without a vehicle, got %v", err)
+	}
+	if _, err := jobService.CancelJob(ctx, job.ID, "changed_my_mind"); !errors.Is(err, ErrInvalidCancellationReason) {
+		t.Errorf("Expected ErrInvalidCancellationReason, got %v", err)
+	}
+
+	cancelled, err := jobService.CancelJob(ctx, job.ID, CancelReasonCustomer)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cancelled.CancellationFee != 0 {
+		t.Errorf("Expected no fee before dispatch, got %.2f", cancelled.CancellationFee)
+	}
+
+	// The job processor must not pick it up again
+	mockFleetClient.AddVehicle(&fleet.Vehicle{
+		ID:             "vehicle-1",
+		Region:         "us-west-2",
+		Status:         "available",
+		BatteryRangeKm: 200.0,
+	})
+	jobService.ProcessPendingJobs(ctx)
+
+	current, _ := jobService.GetJob(ctx, job.ID)
+	if current.Status != "cancelled" {
+		t.Errorf("Expected status 'cancelled', got %s", current.Status)
+	}
+}
+
 func TestJobService_CompleteJobInvalidStatus(t *testing.T) {
 	jobStorage := storage.NewMemoryJobStorage()
 	mockFleetClient := NewMockFleetClient()
@@ -412,3 +755,37 @@ func TestJobService_GetActiveJobCount(t *testing.T) {
 		t.Errorf("Expected 0 active jobs, got %d", count)
 	}
 }
+
+func TestJobService_GetJobsByVehicle(t *testing.T) {
+	jobStorage := storage.NewMemoryJobStorage()
+	jobService := NewJobService(jobStorage, NewMockFleetClient())
+	ctx := context.Background()
+
+	vehicleID := "vehicle-1"
+	otherVehicleID := "vehicle-2"
+	jobStorage.CreateJob(ctx, &storage.Job{ID: "ride-1", Status: JobStatusCompleted, AssignedVehicleID: &vehicleID})
+	jobStorage.CreateJob(ctx, &storage.Job{ID: "ride-2", Status: JobStatusAssigned, AssignedVehicleID: &vehicleID})
+	jobStorage.CreateJob(ctx, &storage.Job{ID: "ride-3", Status: JobStatusAssigned, AssignedVehicleID: &otherVehicleID})
+	jobStorage.CreateJob(ctx, &storage.Job{ID: "ride-4", Status: JobStatusPending})
+
+	all, err := jobService.GetJobsByVehicle(ctx, vehicleID, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("Expected 2 jobs for vehicle-1, got %d", len(all))
+	}
+
+	assigned, err := jobService.GetJobsByVehicle(ctx, vehicleID, JobStatusAssigned)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(assigned) != 1 || assigned[0].ID != "ride-2" {
+		t.Errorf("Expected only ride-2 to be assigned, got %v", assigned)
+	}
+
+	none, _ := jobService.GetJobsByVehicle(ctx, "vehicle-3", JobStatusAssigned)
+	if len(none) != 0 {
+		t.Errorf("Expected no jobs for vehicle-3, got %d", len(none))
+	}
+}
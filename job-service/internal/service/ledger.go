@@ -0,0 +1,322 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"job-service/internal/payments"
+	"job-service/internal/storage"
+	"shared/tracing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// ErrInvalidRefund is returned for refunds of jobs that weren't charged, or of more
+	// than is left to refund
+	ErrInvalidRefund = errors.New("invalid refund")
+	// ErrInvalidStatementPeriod is returned for statements whose period ends before it starts
+	ErrInvalidStatementPeriod = errors.New("invalid statement period")
+)
+
+// endOfLedger is later than any ledger entry, for reading a customer's whole history
+var endOfLedger = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// SetLedger sets where customer accounts and their ledger are kept
+func (j *JobService) SetLedger(ledger storage.LedgerStorage) {
+	j.ledger = ledger
+}
+
+// SetPaymentProvider sets who collects and refunds customers' payments
+func (j *JobService) SetPaymentProvider(provider payments.Provider) {
+	j.payments = provider
+}
+
+// CreateCustomer opens an account for a customer, generating an ID if none is given
+func (j *JobService) CreateCustomer(ctx context.Context, customer *storage.Customer) (*storage.Customer, error) {
+	if customer.ID == "" {
+		customer.ID = fmt.Sprintf("customer-%d", generateJobID())
+	}
+	customer.CreatedAt = time.Now()
+
+	if err := j.ledger.CreateCustomer(ctx, customer); err != nil {
+		return nil, err
+	}
+	return customer, nil
+}
+
+// GetCustomer retrieves a customer's account
+func (j *JobService) GetCustomer(ctx context.Context, customerID string) (*storage.Customer, error) {
+	return j.ledger.GetCustomer(ctx, customerID)
+}
+
+// customerAccount returns the account a job is charged to, opening one for customers
+// who booked without registering
+func (j *JobService) customerAccount(ctx context.Context, customerID string) (*storage.Customer, error) {
+	customer, err := j.ledger.GetCustomer(ctx, customerID)
+	if !errors.Is(err, storage.ErrCustomerNotFound) {
+		return customer, err
+	}
+
+	customer = &storage.Customer{ID: customerID, CreatedAt: time.Now()}
+	if err := j.ledger.CreateCustomer(ctx, customer); err != nil {
+		if errors.Is(err, storage.ErrCustomerExists) {
+			return j.ledger.GetCustomer(ctx, customerID)
+		}
+		return nil, err
+	}
+	return customer, nil
+}
+
+// chargeJob posts what a finished job owes to its customer's account, the fare of a
+// completed job or the fee for a cancelled one, and collects it. The job has already
+// finished, so failures are logged and leave the amount owing on the account.
+func (j *JobService) chargeJob(ctx context.Context, job *storage.Job) {
+	txnType, revenueAccount, amount := storage.TransactionCharge, storage.AccountFareRevenue, job.FareAmount
+	description := fmt.Sprintf("Fare for %s %s", job.JobType, job.ID)
+	if job.Status == JobStatusCancelled {
+		txnType, revenueAccount, amount = storage.TransactionCancellationFee, storage.AccountCancellationFees, job.CancellationFee
+		description = fmt.Sprintf("Cancellation fee for %s %s (%s)", job.JobType, job.ID, job.CancellationReason)
+	}
+	if amount <= 0 {
+		return
+	}
+
+	customer, err := j.customerAccount(ctx, job.CustomerID)
+	if err != nil {
+		fmt.Printf("Failed to open account for customer %s to charge job %s: %v\n", job.CustomerID, job.ID, err)
+		return
+	}
+
+	charge := &storage.LedgerTransaction{
+		ID:          txnType + ":" + job.ID,
+		CustomerID:  customer.ID,
+		Type:        txnType,
+		JobID:       job.ID,
+		Description: description,
+		CreatedAt:   time.Now(),
+		Postings: []storage.Posting{
+			{Account: storage.AccountReceivable, Amount: amount},
+			{Account: revenueAccount, Amount: -amount},
+		},
+	}
+	if err := j.ledger.RecordTransaction(ctx, charge); err != nil {
+		fmt.Printf("Failed to charge %.2f to customer %s for job %s: %v\n", amount, customer.ID, job.ID, err)
+		return
+	}
+
+	payment, err := j.payments.Charge(ctx, payments.ChargeRequest{
+		CustomerID:     customer.ID,
+		PaymentMethod:  customer.PaymentMethod,
+		Amount:         amount,
+		Description:    description,
+		IdempotencyKey: charge.ID,
+	})
+	if err != nil {
+		fmt.Printf("Failed to collect %.2f from customer %s for job %s: %v\n", amount, customer.ID, job.ID, err)
+		return
+	}
+
+	err = j.ledger.RecordTransaction(ctx, &storage.LedgerTransaction{
+		ID:          "payment:" + charge.ID,
+		CustomerID:  customer.ID,
+		Type:        storage.TransactionPayment,
+		JobID:       job.ID,
+		PaymentID:   payment.ID,
+		Description: "Payment for " + job.ID,
+		CreatedAt:   time.Now(),
+		Postings: []storage.Posting{
+			{Account: storage.AccountPaymentsClearing, Amount: amount},
+			{Account: storage.AccountReceivable, Amount: -amount},
+		},
+	})
+	if err != nil {
+		fmt.Printf("Failed to record payment %s from customer %s for job %s: %v\n", payment.ID, customer.ID, job.ID, err)
+	}
+}
+
+// RefundJob credits a customer with some or all of what a job was charged, paying it
+// back through the payment provider if the charge was collected. A zero amount refunds
+// whatever hasn't been refunded yet.
+func (j *JobService) RefundJob(ctx context.Context, jobID string, amount float64, reason string) (*storage.LedgerTransaction, error) {
+	trace.SpanFromContext(ctx).SetAttributes(tracing.JobID(jobID))
+
+	job, err := j.storage.GetJob(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := j.ledger.GetLedgerEntries(ctx, job.CustomerID, time.Time{}, endOfLedger)
+	if err != nil {
+		return nil, err
+	}
+
+	// Work out what the job was charged and paid from the customer's side of its entries
+	var charged, refunded float64
+	var paymentID string
+	refunds := 0
+	for _, entry := range entries {
+		if entry.JobID != jobID || entry.Account != storage.AccountReceivable {
+			continue
+		}
+		switch entry.Type {
+		case storage.TransactionCharge, storage.TransactionCancellationFee:
+			charged += entry.Amount
+		case storage.TransactionRefund:
+			refunded -= entry.Amount
+			refunds++
+		case storage.TransactionPayment:
+			paymentID = entry.PaymentID
+		}
+	}
+
+	remaining := charged - refunded
+	if remaining < 0.005 {
+		return nil, fmt.Errorf("%w: nothing left to refund for job %s", ErrInvalidRefund, jobID)
+	}
+	if amount == 0 {
+		amount = remaining
+	}
+	if amount < 0 || amount > remaining+0.005 {
+		return nil, fmt.Errorf("%w: %.2f requested but %.2f is refundable for job %s", ErrInvalidRefund, amount, remaining, jobID)
+	}
+
+	refund := &storage.LedgerTransaction{
+		ID:          fmt.Sprintf("refund:%s:%d", jobID, refunds+1),
+		CustomerID:  job.CustomerID,
+		Type:        storage.TransactionRefund,
+		JobID:       jobID,
+		Description: fmt.Sprintf("Refund for %s: %s", jobID, reason),
+		CreatedAt:   time.Now(),
+		Postings: []storage.Posting{
+			{Account: storage.AccountRefunds, Amount: amount},
+			{Account: storage.AccountReceivable, Amount: -amount},
+		},
+	}
+	if err := j.ledger.RecordTransaction(ctx, refund); err != nil {
+		return nil, err
+	}
+
+	// Without a payment to give back the refund stays as credit on the account
+	if paymentID == "" {
+		return refund, nil
+	}
+
+	payout, err := j.payments.Refund(ctx, paymentID, amount, refund.ID)
+	if err != nil {
+		fmt.Printf("Failed to pay out refund %s to customer %s: %v\n", refund.ID, job.CustomerID, err)
+		return refund, nil
+	}
+
+	err = j.ledger.RecordTransaction(ctx, &storage.LedgerTransaction{
+		ID:          "payout:" + refund.ID,
+		CustomerID:  job.CustomerID,
+		Type:        storage.TransactionPayout,
+		JobID:       jobID,
+		PaymentID:   payout.ID,
+		Description: "Refund paid for " + jobID,
+		CreatedAt:   time.Now(),
+		Postings: []storage.Posting{
+			{Account: storage.AccountReceivable, Amount: amount},
+			{Account: storage.AccountPaymentsClearing, Amount: -amount},
+		},
+	})
+	if err != nil {
+		fmt.Printf("Failed to record refund payout %s to customer %s: %v\n", payout.ID, job.CustomerID, err)
+	}
+
+	return refund, nil
+}
+
+// Statement is a customer's account activity over a period
+type Statement struct {
+	Customer *storage.Customer `json:"customer"`
+	From     time.Time         `json:"from"`
+	To       time.Time         `json:"to"`
+	// Owed at the start and end of the period; negative when the customer is in credit
+	OpeningBalance float64 `json:"opening_balance"`
+	ClosingBalance float64 `json:"closing_balance"`
+	// Totals over the period
+	Charges          float64         `json:"charges"`
+	CancellationFees float64         `json:"cancellation_fees"`
+	Payments         float64         `json:"payments"`
+	Refunds          float64         `json:"refunds"`
+	Payouts          float64         `json:"payouts"`
+	Lines            []StatementLine `json:"lines"`
+}
+
+// StatementLine is one movement on a customer's account
+type StatementLine struct {
+	Date          time.Time `json:"date"`
+	TransactionID string    `json:"transaction_id"`
+	Type          string    `json:"type"`
+	JobID         string    `json:"job_id,omitempty"`
+	Description   string    `json:"description,omitempty"`
+	Amount        float64   `json:"amount"`  // added to what the customer owes
+	Balance       float64   `json:"balance"` // owed after this line
+}
+
+// GetStatement lists a customer's account activity from from up to to, with what they
+// owed before and after
+func (j *JobService) GetStatement(ctx context.Context, customerID string, from, to time.Time) (*Statement, error) {
+	if !to.After(from) {
+		return nil, fmt.Errorf("%w: %s is not after %s", ErrInvalidStatementPeriod, to.Format(time.RFC3339), from.Format(time.RFC3339))
+	}
+
+	customer, err := j.ledger.GetCustomer(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := j.ledger.GetLedgerEntries(ctx, customerID, time.Time{}, to)
+	if err != nil {
+		return nil, err
+	}
+
+	statement := &Statement{
+		Customer: customer,
+		From:     from,
+		To:       to,
+		Lines:    []StatementLine{},
+	}
+
+	balance := 0.0
+	for _, entry := range entries {
+		if entry.Account != storage.AccountReceivable {
+			continue
+		}
+		balance += entry.Amount
+		if entry.CreatedAt.Before(from) {
+			statement.OpeningBalance = balance
+			continue
+		}
+
+		switch entry.Type {
+		case storage.TransactionCharge:
+			statement.Charges += entry.Amount
+		case storage.TransactionCancellationFee:
+			statement.CancellationFees += entry.Amount
+		case storage.TransactionPayment:
+			statement.Payments -= entry.Amount
+		case storage.TransactionRefund:
+			statement.Refunds -= entry.Amount
+		case storage.TransactionPayout:
+			statement.Payouts += entry.Amount
+		}
+
+		statement.Lines = append(statement.Lines, StatementLine{
+			Date:          entry.CreatedAt,
+			TransactionID: entry.TransactionID,
+			Type:          entry.Type,
+			JobID:         entry.JobID,
+			Description:   entry.Description,
+			Amount:        entry.Amount,
+			Balance:       balance,
+		})
+	}
+	statement.ClosingBalance = balance
+
+	return statement, nil
+}
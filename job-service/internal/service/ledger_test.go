@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"job-service/internal/fleet"
+	"job-service/internal/payments"
+	"job-service/internal/storage"
+)
+
+// setupLedger creates a job service with one vehicle to dispatch and a registered customer
+// paying with paymentMethod
+func setupLedger(t *testing.T, paymentMethod string) *JobService {
+	mockFleetClient := NewMockFleetClient()
+	jobService := NewJobService(storage.NewMemoryJobStorage(), mockFleetClient)
+
+	mockFleetClient.AddVehicle(&fleet.Vehicle{
+		ID:             "vehicle-1",
+		Region:         "us-west-2",
+		Status:         "available",
+		BatteryLevel:   80,
+		BatteryRangeKm: 200.0,
+		LocationLat:    45.50,
+		LocationLng:    -122.60,
+		VehicleType:    "sedan",
+	})
+
+	if _, err := jobService.CreateCustomer(context.Background(), &storage.Customer{ID: "customer-1", Name: "Ada", PaymentMethod: paymentMethod}); err != nil {
+		t.Fatalf("Failed to create customer: %v", err)
+	}
+	return jobService
+}
+
+// completeRide books, picks up and completes a ride for customerID
+func completeRide(t *testing.T, jobService *JobService, customerID string) *storage.Job {
+	ctx := context.Background()
+	job, err := jobService.CreateRideJob(ctx, customerID, "us-west-2", 45.50, -122.60, 45.54, -122.60)
+	if err != nil {
+		t.Fatalf("Failed to create job: %v", err)
+	}
+	if _, err := jobService.ConfirmPickup(ctx, job.ID); err != nil {
+		t.Fatalf("Failed to confirm pickup: %v", err)
+	}
+	if err := jobService.CompleteJob(ctx, job.ID); err != nil {
+		t.Fatalf("Failed to complete job: %v", err)
+	}
+	completed, _ := jobService.GetJob(ctx, job.ID)
+	return completed
+}
+
+// currentStatement returns a customer's statement covering everything so far
+func currentStatement(t *testing.T, jobService *JobService, customerID string) *Statement {
+	statement, err := jobService.GetStatement(context.Background(), customerID, time.Now().Add(-time.Hour), time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to get statement: %v", err)
+	}
+	return statement
+}
+
+func TestJobService_CompleteJob_ChargesAndCollects(t *testing.T) {
+	jobService := setupLedger(t, "card-1")
+	job := completeRide(t, jobService, "customer-1")
+
+	statement := currentStatement(t, jobService, "customer-1")
+
+	if math.Abs(statement.Charges-job.FareAmount) > 1e-9 || math.Abs(statement.Payments-job.FareAmount) > 1e-9 {
+		t.Errorf("Expected %.2f charged and paid, got %.2f charged and %.2f paid", job.FareAmount, statement.Charges, statement.Payments)
+	}
+	if math.Abs(statement.ClosingBalance) > 1e-9 || len(statement.Lines) != 2 {
+		t.Fatalf("Expected a settled account with 2 lines, got %.2f owed over %d lines", statement.ClosingBalance, len(statement.Lines))
+	}
+	if statement.Lines[0].Type != storage.TransactionCharge || statement.Lines[0].JobID != job.ID || statement.Lines[1].Type != storage.TransactionPayment {
+		t.Errorf("Expected the charge then the payment, got %+v", statement.Lines)
+	}
+}
+
+func TestJobService_CompleteJob_DeclinedPaymentStaysOwed(t *testing.T) {
+	jobService := setupLedger(t, payments.DeclinedPaymentMethod)
+	job := completeRide(t, jobService, "customer-1")
+
+	statement := currentStatement(t, jobService, "customer-1")
+
+	if statement.Payments != 0 || math.Abs(statement.ClosingBalance-job.FareAmount) > 1e-9 {
+		t.Errorf("Expected the %.2f fare left owing, got %.2f owed after %.2f paid", job.FareAmount, statement.ClosingBalance, statement.Payments)
+	}
+}
+
+func TestJobService_CompleteJob_OpensAccountForNewCustomers(t *testing.T) {
+	jobService := setupLedger(t, "card-1")
+	completeRide(t, jobService, "walk-in")
+
+	if _, err := jobService.GetCustomer(context.Background(), "walk-in"); err != nil {
+		t.Fatalf("Expected an account opened for the customer, got %v", err)
+	}
+	if statement := currentStatement(t, jobService, "walk-in"); statement.Charges <= 0 {
+		t.Errorf("Expected the fare charged to the new account, got %.2f", statement.Charges)
+	}
+}
+
+func TestJobService_CancelJob_ChargesFee(t *testing.T) {
+	jobService := setupLedger(t, "card-1")
+	ctx := context.Background()
+
+	job, err := jobService.CreateRideJob(ctx, "customer-1", "us-west-2", 45.50, -122.60, 45.54, -122.60)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	cancelled, err := jobService.CancelJob(ctx, job.ID, CancelReasonNoShow)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	statement := currentStatement(t, jobService, "customer-1")
+	if statement.Charges != 0 || math.Abs(statement.CancellationFees-cancelled.CancellationFee) > 1e-9 || cancelled.CancellationFee == 0 {
+		t.Errorf("Expected just the %.2f cancellation fee, got %.2f fees and %.2f charges", cancelled.CancellationFee, statement.CancellationFees, statement.Charges)
+	}
+	if math.Abs(statement.ClosingBalance) > 1e-9 {
+		t.Errorf("Expected the fee collected, got %.2f owed", statement.ClosingBalance)
+	}
+}
+
+func TestJobService_RefundJob(t *testing.T) {
+	jobService := setupLedger(t, "card-1")
+	ctx := context.Background()
+	job := completeRide(t, jobService, "customer-1")
+
+	if _, err := jobService.RefundJob(ctx, job.ID, 2.00, "detour"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := jobService.RefundJob(ctx, job.ID, job.FareAmount, "too much"); !errors.Is(err, ErrInvalidRefund) {
+		t.Errorf("Expected ErrInvalidRefund for more than was left, got %v", err)
+	}
+
+	// The rest of the fare
+	rest, err := jobService.RefundJob(ctx, job.ID, 0, "complaint")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if math.Abs(rest.Postings[0].Amount-(job.FareAmount-2.00)) > 1e-9 {
+		t.Errorf("Expected the remaining %.2f refunded, got %.2f", job.FareAmount-2.00, rest.Postings[0].Amount)
+	}
+	if _, err := jobService.RefundJob(ctx, job.ID, 0, "again"); !errors.Is(err, ErrInvalidRefund) {
+		t.Errorf("Expected ErrInvalidRefund once fully refunded, got %v", err)
+	}
+
+	statement := currentStatement(t, jobService, "customer-1")
+	if math.Abs(statement.Refunds-job.FareAmount) > 1e-9 || math.Abs(statement.Payouts-job.FareAmount) > 1e-9 {
+		t.Errorf("Expected %.2f refunded and paid out, got %.2f and %.2f", job.FareAmount, statement.Refunds, statement.Payouts)
+	}
+	if math.Abs(statement.ClosingBalance) > 1e-9 {
+		t.Errorf("Expected a settled account, got %.2f owed", statement.ClosingBalance)
+	}
+}
+
+func TestJobService_GetStatement_Period(t *testing.T) {
+	jobService := setupLedger(t, payments.DeclinedPaymentMethod)
+	ctx := context.Background()
+	job := completeRide(t, jobService, "customer-1")
+
+	later, err := jobService.GetStatement(ctx, "customer-1", time.Now().Add(time.Minute), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(later.Lines) != 0 || math.Abs(later.OpeningBalance-job.FareAmount) > 1e-9 || later.ClosingBalance != later.OpeningBalance {
+		t.Errorf("Expected the earlier fare carried in as the opening balance, got %+v", later)
+	}
+
+	if _, err := jobService.GetStatement(ctx, "customer-1", time.Now(), time.Now().Add(-time.Hour)); !errors.Is(err, ErrInvalidStatementPeriod) {
+		t.Errorf("Expected ErrInvalidStatementPeriod, got %v", err)
+	}
+	if _, err := jobService.GetStatement(ctx, "nobody", time.Time{}, time.Now()); !errors.Is(err, storage.ErrCustomerNotFound) {
+		t.Errorf("Expected ErrCustomerNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"job-service/internal/storage"
+)
+
+// Job statuses
+const (
+	JobStatusScheduled  = "scheduled"
+	JobStatusPending    = "pending"
+	JobStatusAssigned   = "assigned"
+	JobStatusInProgress = "in_progress"
+	JobStatusCompleted  = "completed"
+	JobStatusFailed     = "failed"
+	JobStatusCancelled  = "cancelled"
+)
+
+// ErrInvalidTransition is returned when a job is asked to move to a status its
+// current status does not lead to
+var ErrInvalidTransition = errors.New("invalid job status transition")
+
+// jobTransitions lists the statuses each status may move to. Completed, failed
+// and cancelled are terminal; an assigned job goes back to pending when its
+// vehicle abandons it. Scheduled bookings become pending when they are due.
+var jobTransitions = map[string][]string{
+	JobStatusScheduled:  {JobStatusPending, JobStatusCancelled},
+	JobStatusPending:    {JobStatusAssigned, JobStatusCancelled},
+	JobStatusAssigned:   {JobStatusInProgress, JobStatusFailed, JobStatusCancelled, JobStatusPending},
+	JobStatusInProgress: {JobStatusCompleted, JobStatusFailed},
+}
+
+// CanTransition reports whether a job may move from one status to another
+func CanTransition(from, to string) bool {
+	for _, next := range jobTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// maxTransitionAttempts bounds how often an unconditional transition re-reads a job
+// that keeps changing underneath it
+const maxTransitionAttempts = 3
+
+// transitionJob moves a job to a new status after validating the move against the
+// lifecycle, stamping the time of the transition. mutate may adjust the new record
+// before it is written. With expectedVersion set the write fails with
+// storage.ErrVersionConflict if the job changed; otherwise a concurrent write makes
+// it re-read the job and validate again.
+func (j *JobService) transitionJob(ctx context.Context, jobID, to string, expectedVersion *int64, mutate func(*storage.Job) error) (*storage.Job, error) {
+	for attempt := 0; ; attempt++ {
+		job, err := j.storage.GetJob(ctx, jobID)
+		if err != nil {
+			return nil, err
+		}
+
+		if expectedVersion != nil && job.Version != *expectedVersion {
+			return nil, storage.ErrVersionConflict
+		}
+
+		if !CanTransition(job.Status, to) {
+			return nil, fmt.Errorf("%w: job %s cannot move from %s to %s", ErrInvalidTransition, jobID, job.Status, to)
+		}
+
+		// Write a copy so the storage layer can reject it atomically if it went stale
+		updated := *job
+		updated.Status = to
+		stampTransition(&updated, to, time.Now())
+
+		// A finished job is owed to the revenue rollups until recordRevenue adds it
+		if to == JobStatusCompleted || to == JobStatusCancelled {
+			updated.RevenueStatus = storage.RevenueStatusPending
+		}
+
+		if mutate != nil {
+			if err := mutate(&updated); err != nil {
+				return nil, err
+			}
+		}
+
+		err = j.storage.UpdateJob(ctx, &updated)
+		if err == nil {
+			return &updated, nil
+		}
+		if !errors.Is(err, storage.ErrVersionConflict) || expectedVersion != nil || attempt+1 >= maxTransitionAttempts {
+			return nil, err
+		}
+	}
+}
+
+// stampTransition records when a job entered a status
+func stampTransition(job *storage.Job, status string, at time.Time) {
+	switch status {
+	case JobStatusAssigned:
+		job.AssignedAt = &at
+	case JobStatusInProgress:
+		job.PickedUpAt = &at
+	case JobStatusCompleted:
+		job.CompletedAt = &at
+	case JobStatusFailed:
+		job.FailedAt = &at
+	case JobStatusCancelled:
+		job.CancelledAt = &at
+	}
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"job-service/internal/fleet"
+	"job-service/internal/storage"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		allowed  bool
+	}{
+		{JobStatusScheduled, JobStatusPending, true},
+		{JobStatusScheduled, JobStatusCancelled, true},
+		{JobStatusScheduled, JobStatusAssigned, false},
+		{JobStatusPending, JobStatusAssigned, true},
+		{JobStatusPending, JobStatusCancelled, true},
+		{JobStatusPending, JobStatusInProgress, false},
+		{JobStatusPending, JobStatusCompleted, false},
+		{JobStatusAssigned, JobStatusInProgress, true},
+		{JobStatusAssigned, JobStatusFailed, true},
+		{JobStatusAssigned, JobStatusCancelled, true},
+		{JobStatusAssigned, JobStatusCompleted, false},
+		{JobStatusInProgress, JobStatusCompleted, true},
+		{JobStatusInProgress, JobStatusFailed, true},
+		{JobStatusInProgress, JobStatusCancelled, false},
+		{JobStatusCompleted, JobStatusFailed, false},
+		{JobStatusFailed, JobStatusAssigned, false},
+		{JobStatusCancelled, JobStatusAssigned, false},
+		{"bogus", JobStatusAssigned, false},
+	}
+
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.allowed {
+			t.Errorf("CanTransition(%s, %s) = %v, expected %v", tt.from, tt.to, got, tt.allowed)
+		}
+	}
+}
+
+func TestJobService_PickupAndFail(t *testing.T) {
+	jobStorage := storage.NewMemoryJobStorage()
+	mockFleetClient := NewMockFleetClient()
+	jobService := NewJobService(jobStorage, mockFleetClient)
+	ctx := context.Background()
+
+	vehicle := &fleet.Vehicle{
+		ID:             "vehicle-1",
+		Region:         "us-west-2",
+		Status:         "available",
+		BatteryRangeKm: 200.0,
+	}
+	mockFleetClient.AddVehicle(vehicle)
+
+	job, _ := jobService.CreateRideJob(ctx, "customer-123", "us-west-2", 37.7749, -122.4194, 37.7849, -122.4094)
+	if job.AssignedAt == nil {
+		t.Error("Expected AssignedAt to be set")
+	}
+
+	pickedUp, err := jobService.ConfirmPickup(ctx, job.ID)
+	if err != nil {
+		t.Fatalf("Failed to confirm pickup: %v", err)
+	}
+	if pickedUp.Status != JobStatusInProgress {
+		t.Errorf("Expected status 'in_progress', got %s", pickedUp.Status)
+	}
+	if pickedUp.PickedUpAt == nil {
+		t.Error("Expected PickedUpAt to be set")
+	}
+
+	// Picking up twice is not a valid transition
+	if _, err := jobService.ConfirmPickup(ctx, job.ID); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected ErrInvalidTransition, got %v", err)
+	}
+
+	failed, err := jobService.FailJob(ctx, job.ID, "flat tire")
+	if err != nil {
+		t.Fatalf("Failed to fail job: %v", err)
+	}
+	if failed.Status != JobStatusFailed {
+		t.Errorf("Expected status 'failed', got %s", failed.Status)
+	}
+	if failed.FailedAt == nil {
+		t.Error("Expected FailedAt to be set")
+	}
+	if failed.FailureReason != "flat tire" {
+		t.Errorf("Expected failure reason 'flat tire', got %s", failed.FailureReason)
+	}
+	if vehicle.CurrentJobID != nil {
+		t.Errorf("Expected vehicle to be released, got job %v", vehicle.CurrentJobID)
+	}
+
+	// Failed is terminal
+	if err := jobService.CompleteJob(ctx, job.ID); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected ErrInvalidTransition, got %v", err)
+	}
+}
+
+func TestJobService_PickupRequiresAssignment(t *testing.T) {
+	jobStorage := storage.NewMemoryJobStorage()
+	jobService := NewJobService(jobStorage, NewMockFleetClient())
+	ctx := context.Background()
+
+	// No vehicles, so the job stays pending
+	job, _ := jobService.CreateRideJob(ctx, "customer-123", "us-west-2", 37.7749, -122.4194, 37.7849, -122.4094)
+
+	if _, err := jobService.ConfirmPickup(ctx, job.ID); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected ErrInvalidTransition, got %v", err)
+	}
+	if _, err := jobService.FailJob(ctx, job.ID, "no vehicle"); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected ErrInvalidTransition, got %v", err)
+	}
+}
+
+// cancellingFleetClient cancels the job while the vehicle is being claimed for it
+type cancellingFleetClient struct {
+	*MockFleetClient
+	jobService *JobService
+}
+
+func (c *cancellingFleetClient) AssignJob(ctx context.Context, vehicleID, jobID string) error {
+	if _, err := c.jobService.CancelJob(ctx, jobID, CancelReasonCustomer); err != nil {
+		return err
+	}
+	return c.MockFleetClient.AssignJob(ctx, vehicleID, jobID)
+}
+
+func TestJobService_AssignmentOfCancelledJobReleasesVehicle(t *testing.T) {
+	jobStorage := storage.NewMemoryJobStorage()
+	fleetClient := &cancellingFleetClient{MockFleetClient: NewMockFleetClient()}
+	jobService := NewJobService(jobStorage, fleetClient)
+	fleetClient.jobService = jobService
+	ctx := context.Background()
+
+	vehicle := &fleet.Vehicle{
+		ID:             "vehicle-1",
+		Region:         "us-west-2",
+		Status:         "available",
+		BatteryRangeKm: 200.0,
+	}
+	fleetClient.AddVehicle(vehicle)
+
+	job, _ := jobService.CreateRideJob(ctx, "customer-123", "us-west-2", 37.7749, -122.4194, 37.7849, -122.4094)
+
+	current, _ := jobService.GetJob(ctx, job.ID)
+	if current.Status != JobStatusCancelled {
+		t.Errorf("Expected cancellation to win, got status %s", current.Status)
+	}
+	if vehicle.Status != "available" || vehicle.CurrentJobID != nil {
+		t.Errorf("Expected vehicle to be handed back, got status %s job %v", vehicle.Status, vehicle.CurrentJobID)
+	}
+}
@@ -0,0 +1,112 @@
+package service
+
+import "math"
+
+// solveAssignment finds the minimum-cost assignment of rows to columns using the
+// Hungarian algorithm (Kuhn-Munkres). The cost matrix may be rectangular; the
+// result maps each row to its column, or -1 if the row was left unmatched.
+func solveAssignment(cost [][]float64) []int {
+	rows := len(cost)
+	if rows == 0 || len(cost[0]) == 0 {
+		result := make([]int, rows)
+		for i := range result {
+			result[i] = -1
+		}
+		return result
+	}
+	cols := len(cost[0])
+
+	// The algorithm below needs at least as many columns as rows
+	if rows > cols {
+		transposed := make([][]float64, cols)
+		for j := range transposed {
+			transposed[j] = make([]float64, rows)
+			for i := 0; i < rows; i++ {
+				transposed[j][i] = cost[i][j]
+			}
+		}
+
+		colToRow := solveAssignment(transposed)
+		result := make([]int, rows)
+		for i := range result {
+			result[i] = -1
+		}
+		for j, i := range colToRow {
+			if i >= 0 {
+				result[i] = j
+			}
+		}
+		return result
+	}
+
+	// Potentials and matching are 1-indexed; index 0 is a sentinel
+	u := make([]float64, rows+1)
+	v := make([]float64, cols+1)
+	match := make([]int, cols+1) // match[j] = row assigned to column j
+	way := make([]int, cols+1)
+
+	for i := 1; i <= rows; i++ {
+		match[0] = i
+		j0 := 0
+		minv := make([]float64, cols+1)
+		used := make([]bool, cols+1)
+		for j := range minv {
+			minv[j] = math.Inf(1)
+		}
+
+		for {
+			used[j0] = true
+			i0 := match[j0]
+			delta := math.Inf(1)
+			j1 := 0
+
+			for j := 1; j <= cols; j++ {
+				if used[j] {
+					continue
+				}
+				reduced := cost[i0-1][j-1] - u[i0] - v[j]
+				if reduced < minv[j] {
+					minv[j] = reduced
+					way[j] = j0
+				}
+				if minv[j] < delta {
+					delta = minv[j]
+					j1 = j
+				}
+			}
+
+			for j := 0; j <= cols; j++ {
+				if used[j] {
+					u[match[j]] += delta
+					v[j] -= delta
+				} else {
+					minv[j] -= delta
+				}
+			}
+
+			j0 = j1
+			if match[j0] == 0 {
+				break
+			}
+		}
+
+		// Walk the augmenting path back to the sentinel
+		for j0 != 0 {
+			j1 := way[j0]
+			match[j0] = match[j1]
+			j0 = j1
+		}
+	}
+
+	result := make([]int, rows)
+	for i := range result {
+		result[i] = -1
+	}
+	for j := 1; j <= cols; j++ {
+		if match[j] != 0 {
+			result[match[j]-1] = j - 1
+		}
+	}
+
+	return result
+}
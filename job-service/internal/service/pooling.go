@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"job-service/internal/fleet"
+	"job-service/internal/storage"
+)
+
+// maxSharedSeats bounds the party size of a shared ride; larger groups fill the
+// vehicle and are better off riding privately
+const maxSharedSeats = 3
+
+// ErrInvalidSeats is returned for shared rides asking for no seats or too many
+var ErrInvalidSeats = errors.New("invalid seat count")
+
+// CreateSharedRideJob creates a ride that may share its vehicle with other riders
+// going the same way, optionally booked ahead for scheduledFor. The rider pays a
+// share of the private fare per seat.
+func (j *JobService) CreateSharedRideJob(ctx context.Context, customerID, region string, pickupLat, pickupLng, destLat, destLng float64, seats int, scheduledFor *time.Time) (*storage.Job, error) {
+	if seats < 1 || seats > maxSharedSeats {
+		return nil, fmt.Errorf("%w: shared rides take 1 to %d seats, got %d", ErrInvalidSeats, maxSharedSeats, seats)
+	}
+
+	job := j.newRideJob(ctx, customerID, region, pickupLat, pickupLng, destLat, destLng)
+	job.Shared = true
+	job.Seats = seats
+
+	if scheduledFor != nil {
+		if err := j.schedule(job, *scheduledFor); err != nil {
+			return nil, err
+		}
+	}
+
+	return j.submitJob(ctx, job)
+}
+
+// joinPool tries to fit a shared ride into a vehicle already carrying other shared
+// riders, reporting whether the ride was assigned
+func (j *JobService) joinPool(ctx context.Context, job *storage.Job) (bool, error) {
+	vehicle, err := j.fleetClient.JoinPool(ctx, sharedRide(job))
+	if errors.Is(err, fleet.ErrNoPoolMatch) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := j.recordAssignment(ctx, job, vehicle.ID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// sharedRide describes a shared ride job to the fleet service
+func sharedRide(job *storage.Job) fleet.SharedRide {
+	return fleet.SharedRide{
+		JobID:      job.ID,
+		Region:     job.Region,
+		PickupLat:  job.PickupLat,
+		PickupLng:  job.PickupLng,
+		DropoffLat: job.DestinationLat,
+		DropoffLng: job.DestinationLng,
+		Seats:      max(job.Seats, 1),
+	}
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"job-service/internal/fleet"
+	"job-service/internal/storage"
+)
+
+func TestPricingConfig_CalculateFare_SharedRide(t *testing.T) {
+	pricing := DefaultPricingConfig()
+
+	job := &storage.Job{
+		JobType:             "ride",
+		EstimatedDistanceKm: 5.0,
+		Shared:              true,
+		Seats:               1,
+	}
+	pricing.CalculateFare(job)
+
+	// One seat pays 60% of the $9.00 private distance fare
+	if math.Abs(job.DistanceFare-5.40) > 1e-9 || math.Abs(job.SharedDiscount-3.60) > 1e-9 {
+		t.Errorf("Expected distance fare 5.40 with 3.60 off, got %.2f with %.2f off", job.DistanceFare, job.SharedDiscount)
+	}
+
+	// Two seats would cost more than riding privately, so they pay the private fare
+	job.Seats = 2
+	pricing.CalculateFare(job)
+	if job.DistanceFare != 9.0 || job.SharedDiscount != 0 {
+		t.Errorf("Expected the private distance fare 9.00 with no discount, got %.2f with %.2f off", job.DistanceFare, job.SharedDiscount)
+	}
+}
+
+func TestJobService_CreateSharedRideJob_RejectsInvalidSeats(t *testing.T) {
+	jobService := NewJobService(storage.NewMemoryJobStorage(), NewMockFleetClient())
+
+	for _, seats := range []int{0, maxSharedSeats + 1} {
+		_, err := jobService.CreateSharedRideJob(context.Background(), "customer-1", "us-west-2", 45.50, -122.60, 45.54, -122.60, seats, nil)
+		if !errors.Is(err, ErrInvalidSeats) {
+			t.Errorf("Expected ErrInvalidSeats for %d seats, got %v", seats, err)
+		}
+	}
+}
+
+func TestJobService_SharedRidesPoolIntoOneVehicle(t *testing.T) {
+	jobStorage := storage.NewMemoryJobStorage()
+	mockFleetClient := NewMockFleetClient()
+	jobService := NewJobService(jobStorage, mockFleetClient)
+	ctx := context.Background()
+
+	for _, id := range []string{"vehicle-1", "vehicle-2"} {
+		mockFleetClient.AddVehicle(&fleet.Vehicle{
+			ID:             id,
+			Region:         "us-west-2",
+			Status:         "available",
+			BatteryLevel:   80,
+			BatteryRangeKm: 200.0,
+			LocationLat:    45.50,
+			LocationLng:    -122.60,
+			VehicleType:    "sedan",
+		})
+	}
+
+	first, err := jobService.CreateSharedRideJob(ctx, "customer-1", "us-west-2", 45.50, -122.60, 45.54, -122.60, 1, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	second, err := jobService.CreateSharedRideJob(ctx, "customer-2", "us-west-2", 45.51, -122.60, 45.53, -122.60, 1, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if first.AssignedVehicleID == nil || second.AssignedVehicleID == nil || *first.AssignedVehicleID != *second.AssignedVehicleID {
+		t.Fatalf("Expected both riders in one vehicle, got %v and %v", first.AssignedVehicleID, second.AssignedVehicleID)
+	}
+	vehicleID := *first.AssignedVehicleID
+
+	if _, err := jobService.ConfirmPickup(ctx, first.ID); err != nil {
+		t.Fatalf("Failed to confirm pickup: %v", err)
+	}
+	if len(mockFleetClient.pickups) != 1 || mockFleetClient.pickups[0] != first.ID {
+		t.Errorf("Expected the pickup of %s to be reported to the fleet, got %v", first.ID, mockFleetClient.pickups)
+	}
+
+	// Dropping off the first rider keeps the vehicle busy with the second
+	if err := jobService.CompleteJob(ctx, first.ID); err != nil {
+		t.Fatalf("Failed to complete job: %v", err)
+	}
+	vehicle := mockFleetClient.vehicles[vehicleID]
+	if vehicle.Status != "busy" || vehicle.CurrentJobID == nil || *vehicle.CurrentJobID != second.ID {
+		t.Errorf("Expected vehicle busy with %s, got %s %v", second.ID, vehicle.Status, vehicle.CurrentJobID)
+	}
+
+	if _, err := jobService.ConfirmPickup(ctx, second.ID); err != nil {
+		t.Fatalf("Failed to confirm pickup: %v", err)
+	}
+	if err := jobService.CompleteJob(ctx, second.ID); err != nil {
+		t.Fatalf("Failed to complete job: %v", err)
+	}
+	if vehicle.Status != "available" || vehicle.CurrentJobID != nil {
+		t.Errorf("Expected vehicle available once empty, got %s %v", vehicle.Status, vehicle.CurrentJobID)
+	}
+}
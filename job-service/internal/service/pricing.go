@@ -1,15 +1,37 @@
 package service
 
-import "job-service/internal/storage"
+import (
+	"math"
+
+	"job-service/internal/storage"
+)
 
 // PricingConfig holds pricing parameters
 type PricingConfig struct {
-	// Ride pricing (distance-based like taxi)
-	RideBaseFare float64 // Base fare for rides
-	RidePerKm    float64 // Per kilometer rate for rides
+	// Ride pricing (distance- and time-based like taxi)
+	RideBaseFare  float64 `json:"ride_base_fare"`  // Base fare for rides
+	RidePerKm     float64 `json:"ride_per_km"`     // Per kilometer rate for rides
+	RidePerMinute float64 `json:"ride_per_minute"` // Per minute of estimated driving for rides
 
 	// Delivery pricing (flat rate)
-	DeliveryFlatRate float64 // Flat rate for deliveries
+	DeliveryFlatRate float64 `json:"delivery_flat_rate"` // Flat rate for deliveries
+
+	// Multi-stop pricing
+	PerStopFee float64 `json:"per_stop_fee"` // Charged for each stop between the pickup and the final destination
+
+	// Shared ride pricing
+	SharedSeatShare float64 `json:"shared_seat_share"` // Share of a private ride's distance and time fare charged per seat on a shared ride
+
+	// Least a job costs before surge
+	MinimumFare float64 `json:"minimum_fare"`
+
+	// Cancellation pricing
+	CancellationFee float64 `json:"cancellation_fee"` // Charged when a customer cancels or no-shows after a vehicle is dispatched
+
+	// Surge pricing
+	SurgeSensitivity   float64 `json:"surge_sensitivity"`    // Multiplier added per pending job beyond each available vehicle in a zone
+	SurgeMaxMultiplier float64 `json:"surge_max_multiplier"` // Cap on the surge multiplier
+	SurgeSmoothing     float64 `json:"surge_smoothing"`      // Weight of the latest supply and demand reading in a zone's multiplier, 0-1
 }
 
 // DefaultPricingConfig returns standard Portland pricing
@@ -18,20 +40,108 @@ func DefaultPricingConfig() *PricingConfig {
 		RideBaseFare:     2.50, // $2.50 base fare
 		RidePerKm:        1.80, // $1.80 per km (similar to Portland taxi rates)
 		DeliveryFlatRate: 8.99, // $8.99 flat delivery fee
+		PerStopFee:       2.00, // $2.00 per extra stop
+		SharedSeatShare:  0.60, // Riders sharing the vehicle pay 60% per seat
+		CancellationFee:  5.00, // $5.00 once a vehicle is on its way
+
+		SurgeSensitivity:   0.25, // +0.25x per excess pending job per vehicle
+		SurgeMaxMultiplier: 2.50, // Never more than 2.5x
+		SurgeSmoothing:     0.50, // Blend half of each new reading into the multiplier
 	}
 }
 
-// CalculateFare calculates the fare for a job based on type and distance
+// CalculateFare calculates the fare for a job based on type, distance and driving time.
+// Jobs with stops are priced over the total of their legs plus a fee per extra stop.
+// Shared rides split the distance and time fare, paying a share of it per seat but
+// never more than the private fare. Fares below the minimum are topped up to it, then
+// the job's surge multiplier scales the whole fare.
 func (p *PricingConfig) CalculateFare(job *storage.Job) {
+	if len(job.Stops) > 1 {
+		job.EstimatedDistanceKm = TotalDistanceKm(job.Stops)
+		job.EstimatedDurationMinutes = TotalDurationMinutes(job.Stops)
+	}
+	p.priceTrip(job, job.EstimatedDistanceKm, job.EstimatedDurationMinutes)
+}
+
+// CalculateFinalFare reprices a finished job over the distance and driving time it
+// actually took, keeping the fare it was booked at as the estimate. A shared ride pays
+// for no more than its direct trip, since the detours to pick up and drop off other
+// riders were taken for them.
+func (p *PricingConfig) CalculateFinalFare(job *storage.Job) {
+	job.EstimatedFareAmount = job.FareAmount
+
+	distanceKm, minutes := job.ActualDistanceKm, job.ActualDurationMinutes
+	if job.Shared {
+		if job.EstimatedDistanceKm > 0 {
+			distanceKm = math.Min(distanceKm, job.EstimatedDistanceKm)
+		}
+		if job.EstimatedDurationMinutes > 0 {
+			minutes = math.Min(minutes, job.EstimatedDurationMinutes)
+		}
+	}
+	p.priceTrip(job, distanceKm, minutes)
+}
+
+// priceTrip sets a job's fare breakdown for a trip of the given distance and driving time
+func (p *PricingConfig) priceTrip(job *storage.Job, distanceKm, minutes float64) {
+	job.StopFare = float64(max(len(job.Stops)-2, 0)) * p.PerStopFee
+
 	if job.JobType == "ride" {
-		// Distance-based pricing for rides
+		// Distance- and time-based pricing for rides
 		job.BaseFare = p.RideBaseFare
-		job.DistanceFare = job.EstimatedDistanceKm * p.RidePerKm
-		job.FareAmount = job.BaseFare + job.DistanceFare
+		job.DistanceFare = distanceKm * p.RidePerKm
+		job.TimeFare = minutes * p.RidePerMinute
+		job.SharedDiscount = 0.0
+		if job.Shared {
+			share := math.Min(p.SharedSeatShare*float64(max(job.Seats, 1)), 1)
+			job.SharedDiscount = (job.DistanceFare + job.TimeFare) * (1 - share)
+			job.DistanceFare *= share
+			job.TimeFare *= share
+		}
+		job.FareAmount = job.BaseFare + job.DistanceFare + job.TimeFare + job.StopFare
 	} else {
 		// Flat rate for deliveries
 		job.BaseFare = p.DeliveryFlatRate
 		job.DistanceFare = 0.0
-		job.FareAmount = job.BaseFare
+		job.TimeFare = 0.0
+		job.FareAmount = job.BaseFare + job.StopFare
+	}
+
+	job.MinimumFareTopUp = math.Max(p.MinimumFare-job.FareAmount, 0)
+	job.FareAmount += job.MinimumFareTopUp
+
+	job.SurgeFare = 0.0
+	if job.SurgeMultiplier > 1 {
+		job.SurgeFare = job.FareAmount * (job.SurgeMultiplier - 1)
+		job.FareAmount += job.SurgeFare
+	}
+}
+
+// TotalDistanceKm sums the legs between a job's stops; the first stop has no leg
+func TotalDistanceKm(stops []storage.Stop) float64 {
+	var total float64
+	for _, stop := range stops {
+		total += stop.LegDistanceKm
 	}
+	return total
+}
+
+// TotalDurationMinutes sums the estimated driving time of the legs between a job's stops
+func TotalDurationMinutes(stops []storage.Stop) float64 {
+	var total float64
+	for _, stop := range stops {
+		total += stop.LegMinutes
+	}
+	return total
+}
+
+// CalculateCancellationFee sets the fee for cancelling a job. Nothing is charged
+// before a vehicle is dispatched or when the vehicle is at fault.
+func (p *PricingConfig) CalculateCancellationFee(job *storage.Job, reason string) {
+	if job.AssignedVehicleID == nil || reason == CancelReasonVehicleFault {
+		job.CancellationFee = 0.0
+		return
+	}
+
+	job.CancellationFee = p.CancellationFee
 }
@@ -0,0 +1,265 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"job-service/internal/storage"
+)
+
+// DefaultPricingVersion is the version of the built-in rates used when no pricing file is loaded
+const DefaultPricingVersion = "default"
+
+var (
+	// ErrInvalidPricingRules is returned for pricing files that can't be applied
+	ErrInvalidPricingRules = errors.New("invalid pricing rules")
+	// ErrNoPricingFile is returned when reloading pricing that wasn't loaded from a file
+	ErrNoPricingFile = errors.New("no pricing file configured")
+)
+
+// weekdays maps the day names pricing rules use to days of the week
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// PricingRuleSet is a versioned rate card: default rates, adjusted by every rule that
+// matches a job in the order the rules are listed
+type PricingRuleSet struct {
+	Version  string        `json:"version"`
+	Defaults PricingConfig `json:"defaults"`
+	Rules    []PricingRule `json:"rules,omitempty"`
+}
+
+// PricingRule overrides some rates for jobs matching all of its conditions. Conditions
+// left empty match every job.
+type PricingRule struct {
+	Name    string   `json:"name"`
+	Region  string   `json:"region,omitempty"`
+	JobType string   `json:"job_type,omitempty"`
+	Days    []string `json:"days,omitempty"` // "mon" to "sun"
+	// Band of the day, "HH:MM" in Timezone, wrapping past midnight when To is before From
+	From     string `json:"from,omitempty"`
+	To       string `json:"to,omitempty"`
+	Timezone string `json:"timezone,omitempty"` // IANA name, UTC if empty
+	// Rates to override, keyed like the defaults
+	Rates json.RawMessage `json:"rates"`
+
+	days     map[time.Weekday]bool
+	from, to int // minutes into the day
+	location *time.Location
+}
+
+// DefaultPricingRules returns the built-in rates with no rules
+func DefaultPricingRules() *PricingRuleSet {
+	return &PricingRuleSet{
+		Version:  DefaultPricingVersion,
+		Defaults: *DefaultPricingConfig(),
+	}
+}
+
+// LoadPricingRules reads a pricing rule set from a JSON file
+func LoadPricingRules(path string) (*PricingRuleSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePricingRules(data)
+}
+
+// ParsePricingRules decodes and validates a JSON pricing rule set. Defaults the file
+// leaves out keep their built-in values.
+func ParsePricingRules(data []byte) (*PricingRuleSet, error) {
+	rules := DefaultPricingRules()
+	rules.Version = ""
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(rules); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPricingRules, err)
+	}
+
+	if rules.Version == "" {
+		return nil, fmt.Errorf("%w: version is required", ErrInvalidPricingRules)
+	}
+	for i := range rules.Rules {
+		if err := rules.Rules[i].compile(); err != nil {
+			return nil, fmt.Errorf("%w: rule %d (%s): %v", ErrInvalidPricingRules, i, rules.Rules[i].Name, err)
+		}
+	}
+
+	return rules, nil
+}
+
+// compile checks a rule and parses its conditions for matching
+func (r *PricingRule) compile() error {
+	if r.JobType != "" && r.JobType != "ride" && r.JobType != "delivery" {
+		return fmt.Errorf("job type must be 'ride' or 'delivery', got %q", r.JobType)
+	}
+
+	if len(r.Days) > 0 {
+		r.days = make(map[time.Weekday]bool)
+		for _, day := range r.Days {
+			weekday, ok := weekdays[strings.ToLower(day)]
+			if !ok {
+				return fmt.Errorf("unknown day %q", day)
+			}
+			r.days[weekday] = true
+		}
+	}
+
+	if (r.From == "") != (r.To == "") {
+		return errors.New("from and to must be given together")
+	}
+	if r.From != "" {
+		var err error
+		if r.from, err = minuteOfDay(r.From); err != nil {
+			return err
+		}
+		if r.to, err = minuteOfDay(r.To); err != nil {
+			return err
+		}
+	}
+
+	r.location = time.UTC
+	if r.Timezone != "" {
+		location, err := time.LoadLocation(r.Timezone)
+		if err != nil {
+			return err
+		}
+		r.location = location
+	}
+
+	if len(r.Rates) == 0 {
+		return errors.New("rates are required")
+	}
+	decoder := json.NewDecoder(bytes.NewReader(r.Rates))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&PricingConfig{}); err != nil {
+		return fmt.Errorf("rates: %v", err)
+	}
+
+	return nil
+}
+
+// minuteOfDay parses an "HH:MM" time of day
+func minuteOfDay(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("time of day must be HH:MM, got %q", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// matches reports whether a rule applies to a job picked up at a given time
+func (r *PricingRule) matches(job *storage.Job, at time.Time) bool {
+	if r.Region != "" && r.Region != job.Region {
+		return false
+	}
+	if r.JobType != "" && r.JobType != job.JobType {
+		return false
+	}
+
+	local := at.In(r.location)
+	if r.days != nil && !r.days[local.Weekday()] {
+		return false
+	}
+	if r.From != "" {
+		minute := local.Hour()*60 + local.Minute()
+		if r.from <= r.to {
+			return minute >= r.from && minute < r.to
+		}
+		return minute >= r.from || minute < r.to
+	}
+	return true
+}
+
+// ConfigFor returns the rates for a job: the defaults with every matching rule applied.
+// Jobs are priced for their pickup time, the booked time for scheduled jobs.
+func (s *PricingRuleSet) ConfigFor(job *storage.Job) *PricingConfig {
+	at := job.CreatedAt
+	if job.ScheduledFor != nil {
+		at = *job.ScheduledFor
+	}
+
+	config := s.Defaults
+	for i := range s.Rules {
+		if s.Rules[i].matches(job, at) {
+			// Rates were validated when the rules were loaded
+			json.Unmarshal(s.Rules[i].Rates, &config)
+		}
+	}
+	return &config
+}
+
+// CalculateFare prices a job with the rates that apply to it and records this version
+func (s *PricingRuleSet) CalculateFare(job *storage.Job) {
+	s.ConfigFor(job).CalculateFare(job)
+	job.PricingVersion = s.Version
+}
+
+// CalculateFinalFare reprices a finished job over its actual trip with the rates that
+// apply to it, recording this version
+func (s *PricingRuleSet) CalculateFinalFare(job *storage.Job) {
+	s.ConfigFor(job).CalculateFinalFare(job)
+	job.PricingVersion = s.Version
+}
+
+// SetPricingRules replaces the rates new jobs are priced with
+func (j *JobService) SetPricingRules(rules *PricingRuleSet) {
+	j.pricingMu.Lock()
+	defer j.pricingMu.Unlock()
+	j.pricing = rules
+}
+
+// LoadPricingFile prices new jobs with the rule set in a JSON file, which
+// ReloadPricingRules then rereads
+func (j *JobService) LoadPricingFile(path string) (*PricingRuleSet, error) {
+	rules, err := LoadPricingRules(path)
+	if err != nil {
+		return nil, err
+	}
+
+	j.pricingMu.Lock()
+	defer j.pricingMu.Unlock()
+	j.pricing = rules
+	j.pricingFile = path
+	return rules, nil
+}
+
+// ReloadPricingRules rereads the pricing file. Jobs already created keep the fare they
+// were booked at, though their final fare is worked out with the rates in force when
+// they complete. If the file is invalid the current rates stay in place.
+func (j *JobService) ReloadPricingRules() (*PricingRuleSet, error) {
+	j.pricingMu.RLock()
+	path := j.pricingFile
+	j.pricingMu.RUnlock()
+
+	if path == "" {
+		return nil, ErrNoPricingFile
+	}
+
+	rules, err := j.LoadPricingFile(path)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Pricing rules reloaded from %s, version %s with %d rules\n", path, rules.Version, len(rules.Rules))
+	return rules, nil
+}
+
+// PricingRules returns the rule set new jobs are priced with
+func (j *JobService) PricingRules() *PricingRuleSet {
+	j.pricingMu.RLock()
+	defer j.pricingMu.RUnlock()
+	return j.pricing
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"job-service/internal/storage"
+)
+
+const testPricingRules = `{
+  "version": "test-1",
+  "defaults": {"ride_per_minute": 0.30, "minimum_fare": 6.00},
+  "rules": [
+    {"name": "west-1", "region": "us-west-1", "rates": {"ride_base_fare": 3.00}},
+    {"name": "weekday rush", "region": "us-west-2", "job_type": "ride", "days": ["mon", "tue", "wed", "thu", "fri"],
+     "from": "16:00", "to": "19:00", "timezone": "America/Los_Angeles", "rates": {"ride_per_minute": 0.45}},
+    {"name": "late deliveries", "job_type": "delivery", "from": "22:00", "to": "05:00", "rates": {"delivery_flat_rate": 11.99}}
+  ]
+}`
+
+func TestPricingConfig_CalculateFare_TimeAndMinimum(t *testing.T) {
+	pricing := DefaultPricingConfig()
+	pricing.RidePerMinute = 0.30
+	pricing.MinimumFare = 6.00
+
+	job := &storage.Job{
+		JobType:                  "ride",
+		EstimatedDistanceKm:      5.0,
+		EstimatedDurationMinutes: 10.0,
+	}
+	pricing.CalculateFare(job)
+
+	// $2.50 + $9.00 + 10 minutes at $0.30
+	if math.Abs(job.TimeFare-3.00) > 1e-9 || math.Abs(job.FareAmount-14.50) > 1e-9 || job.MinimumFareTopUp != 0 {
+		t.Errorf("Expected 3.00 time fare in 14.50, got %.2f in %.2f topped up %.2f", job.TimeFare, job.FareAmount, job.MinimumFareTopUp)
+	}
+
+	short := &storage.Job{JobType: "ride", EstimatedDistanceKm: 1.0, EstimatedDurationMinutes: 2.0}
+	pricing.CalculateFare(short)
+	if math.Abs(short.FareAmount-6.00) > 1e-9 || math.Abs(short.MinimumFareTopUp-1.10) > 1e-9 {
+		t.Errorf("Expected a 6.00 minimum fare topped up by 1.10, got %.2f topped up %.2f", short.FareAmount, short.MinimumFareTopUp)
+	}
+}
+
+func TestParsePricingRules(t *testing.T) {
+	rules, err := ParsePricingRules([]byte(testPricingRules))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if rules.Version != "test-1" || len(rules.Rules) != 3 {
+		t.Fatalf("Expected version test-1 with 3 rules, got %s with %d", rules.Version, len(rules.Rules))
+	}
+	if rules.Defaults.RidePerMinute != 0.30 || rules.Defaults.RidePerKm != DefaultPricingConfig().RidePerKm {
+		t.Errorf("Expected defaults left out to keep their built-in values, got %+v", rules.Defaults)
+	}
+
+	invalid := map[string]string{
+		"no version":     `{"defaults": {}}`,
+		"unknown rate":   `{"version": "x", "rules": [{"name": "typo", "rates": {"ride_per_mile": 1}}]}`,
+		"unknown day":    `{"version": "x", "rules": [{"name": "day", "days": ["someday"], "rates": {}}]}`,
+		"half a band":    `{"version": "x", "rules": [{"name": "band", "from": "10:00", "rates": {}}]}`,
+		"bad time":       `{"version": "x", "rules": [{"name": "time", "from": "25:00", "to": "26:00", "rates": {}}]}`,
+		"bad time zone":  `{"version": "x", "rules": [{"name": "zone", "timezone": "Mars/Olympus", "rates": {}}]}`,
+		"no rates":       `{"version": "x", "rules": [{"name": "empty"}]}`,
+		"unknown option": `{"version": "x", "currency": "USD"}`,
+	}
+	for name, data := range invalid {
+		if _, err := ParsePricingRules([]byte(data)); !errors.Is(err, ErrInvalidPricingRules) {
+			t.Errorf("%s: expected ErrInvalidPricingRules, got %v", name, err)
+		}
+	}
+}
+
+func TestLoadPricingRules_ShippedFile(t *testing.T) {
+	rules, err := LoadPricingRules("../../config/pricing.json")
+	if err != nil {
+		t.Fatalf("Expected the shipped pricing file to load, got %v", err)
+	}
+	if rules.Version == "" || len(rules.Rules) == 0 {
+		t.Errorf("Expected a versioned rule set, got %+v", rules)
+	}
+}
+
+func TestPricingRuleSet_ConfigFor(t *testing.T) {
+	rules, err := ParsePricingRules([]byte(testPricingRules))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	portland, _ := time.LoadLocation("America/Los_Angeles")
+
+	tests := []struct {
+		name          string
+		job           *storage.Job
+		baseFare      float64
+		perMinute     float64
+		deliveryPrice float64
+	}{
+		{
+			name:      "Portland ride on a weekday morning",
+			job:       &storage.Job{JobType: "ride", Region: "us-west-2", CreatedAt: time.Date(2026, 10, 14, 9, 0, 0, 0, portland)},
+			baseFare:  2.50,
+			perMinute: 0.30,
+		},
+		{
+			name:      "Portland ride in weekday rush hour",
+			job:       &storage.Job{JobType: "ride", Region: "us-west-2", CreatedAt: time.Date(2026, 10, 14, 17, 30, 0, 0, portland)},
+			baseFare:  2.50,
+			perMinute: 0.45,
+		},
+		{
+			name:      "Portland ride at rush hour on a Saturday",
+			job:       &storage.Job{JobType: "ride", Region: "us-west-2", CreatedAt: time.Date(2026, 10, 17, 17, 30, 0, 0, portland)},
+			baseFare:  2.50,
+			perMinute: 0.30,
+		},
+		{
+			name:      "Booking priced for its pickup time",
+			job:       &storage.Job{JobType: "ride", Region: "us-west-2", CreatedAt: time.Date(2026, 10, 14, 9, 0, 0, 0, portland), ScheduledFor: ptrTime(time.Date(2026, 10, 14, 18, 0, 0, 0, portland))},
+			baseFare:  2.50,
+			perMinute: 0.45,
+		},
+		{
+			name:      "Ride in the other region",
+			job:       &storage.Job{JobType: "ride", Region: "us-west-1", CreatedAt: time.Date(2026, 10, 14, 17, 30, 0, 0, portland)},
+			baseFare:  3.00,
+			perMinute: 0.30,
+		},
+		{
+			name:          "Delivery after midnight UTC",
+			job:           &storage.Job{JobType: "delivery", Region: "us-west-2", CreatedAt: time.Date(2026, 10, 14, 2, 0, 0, 0, time.UTC)},
+			baseFare:      2.50,
+			perMinute:     0.30,
+			deliveryPrice: 11.99,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := rules.ConfigFor(tt.job)
+			if config.RideBaseFare != tt.baseFare || config.RidePerMinute != tt.perMinute {
+				t.Errorf("Expected base fare %.2f and %.2f per minute, got %.2f and %.2f", tt.baseFare, tt.perMinute, config.RideBaseFare, config.RidePerMinute)
+			}
+			if tt.deliveryPrice != 0 && config.DeliveryFlatRate != tt.deliveryPrice {
+				t.Errorf("Expected delivery flat rate %.2f, got %.2f", tt.deliveryPrice, config.DeliveryFlatRate)
+			}
+		})
+	}
+
+	if rules.Defaults.RidePerMinute != 0.30 {
+		t.Errorf("Expected matching rules to leave the defaults alone, got %.2f per minute", rules.Defaults.RidePerMinute)
+	}
+}
+
+func TestJobService_ReloadPricingRules(t *testing.T) {
+	jobService := NewJobService(storage.NewMemoryJobStorage(), NewMockFleetClient())
+	ctx := context.Background()
+
+	if _, err := jobService.ReloadPricingRules(); !errors.Is(err, ErrNoPricingFile) {
+		t.Errorf("Expected ErrNoPricingFile before a file is loaded, got %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "pricing.json")
+	writeFile := func(data string) {
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatalf("Failed to write pricing file: %v", err)
+		}
+	}
+
+	writeFile(`{"version": "v1"}`)
+	if _, err := jobService.LoadPricingFile(path); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	before, _ := jobService.CreateRideJob(ctx, "customer-1", "us-west-2", 45.50, -122.60, 45.54, -122.60)
+
+	writeFile(`{"version": "v2", "defaults": {"ride_base_fare": 4.00}}`)
+	if _, err := jobService.ReloadPricingRules(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	after, _ := jobService.CreateRideJob(ctx, "customer-1", "us-west-2", 45.50, -122.60, 45.54, -122.60)
+
+	if before.PricingVersion != "v1" || before.BaseFare != 2.50 {
+		t.Errorf("Expected the first job priced by v1, got %.2f from %q", before.BaseFare, before.PricingVersion)
+	}
+	if after.PricingVersion != "v2" || after.BaseFare != 4.00 {
+		t.Errorf("Expected the second job priced by v2, got %.2f from %q", after.BaseFare, after.PricingVersion)
+	}
+	if stored, _ := jobService.GetJob(ctx, before.ID); stored.PricingVersion != "v1" || stored.BaseFare != 2.50 {
+		t.Errorf("Expected the reload to leave existing jobs alone, got %.2f from %q", stored.BaseFare, stored.PricingVersion)
+	}
+
+	// A broken file is rejected and the rates in force stay
+	writeFile(`{"version": "v3", "rules": [{"name": "broken", "days": ["funday"], "rates": {}}]}`)
+	if _, err := jobService.ReloadPricingRules(); !errors.Is(err, ErrInvalidPricingRules) {
+		t.Errorf("Expected ErrInvalidPricingRules, got %v", err)
+	}
+	if version := jobService.PricingRules().Version; version != "v2" {
+		t.Errorf("Expected v2 to stay in force, got %s", version)
+	}
+}
+
+// ptrTime returns a pointer to a time
+func ptrTime(t time.Time) *time.Time {
+	return &t
+}
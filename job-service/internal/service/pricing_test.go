@@ -75,3 +75,58 @@ func TestDefaultPricingConfig(t *testing.T) {
 		t.Errorf("Expected delivery flat rate 8.99, got %.2f", pricing.DeliveryFlatRate)
 	}
 }
+
+func TestPricingConfig_CalculateCancellationFee(t *testing.T) {
+	pricing := DefaultPricingConfig()
+	vehicleID := "vehicle-1"
+
+	tests := []struct {
+		name        string
+		job         *storage.Job
+		reason      string
+		expectedFee float64
+	}{
+		{"pending job is free to cancel", &storage.Job{Status: "pending"}, CancelReasonCustomer, 0.0},
+		{"customer cancels after dispatch", &storage.Job{Status: "assigned", AssignedVehicleID: &vehicleID}, CancelReasonCustomer, 5.00},
+		{"customer no-show", &storage.Job{Status: "assigned", AssignedVehicleID: &vehicleID}, CancelReasonNoShow, 5.00},
+		{"vehicle fault is not charged", &storage.Job{Status: "assigned", AssignedVehicleID: &vehicleID}, CancelReasonVehicleFault, 0.0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pricing.CalculateCancellationFee(tt.job, tt.reason)
+			if tt.job.CancellationFee != tt.expectedFee {
+				t.Errorf("Expected cancellation fee %.2f, got %.2f", tt.expectedFee, tt.job.CancellationFee)
+			}
+		})
+	}
+}
+
+func TestPricingConfig_CalculateFare_MultiStop(t *testing.T) {
+	pricing := DefaultPricingConfig()
+
+	job := &storage.Job{
+		JobType: "delivery",
+		Stops: []storage.Stop{
+			{Type: "pickup"},
+			{Type: "dropoff", LegDistanceKm: 2.0},
+			{Type: "dropoff", LegDistanceKm: 3.0},
+			{Type: "dropoff", LegDistanceKm: 1.5},
+		},
+	}
+
+	pricing.CalculateFare(job)
+
+	if job.EstimatedDistanceKm != 6.5 {
+		t.Errorf("Expected total distance 6.50km over all legs, got %.2f", job.EstimatedDistanceKm)
+	}
+
+	expectedStopFare := 2 * 2.00 // Two drop-offs before the final one
+	if job.StopFare != expectedStopFare {
+		t.Errorf("Expected stop fare %.2f, got %.2f", expectedStopFare, job.StopFare)
+	}
+
+	if expectedTotal := 8.99 + expectedStopFare; job.FareAmount != expectedTotal {
+		t.Errorf("Expected total fare %.2f, got %.2f", expectedTotal, job.FareAmount)
+	}
+}
@@ -6,7 +6,7 @@ import (
 	"time"
 )
 
-// JobProcessor handles background processing of pending jobs
+// JobProcessor handles background processing of pending and scheduled jobs
 type JobProcessor struct {
 	jobService *JobService
 	stopChan   chan struct{}
@@ -40,13 +40,34 @@ func (jp *JobProcessor) processLoop() {
 	for {
 		select {
 		case <-ticker.C:
+			jp.promoteScheduledJobs()
+			jp.updateSurge()
 			jp.processPendingJobs()
+			jp.recordPendingRevenue()
 		case <-jp.stopChan:
 			return
 		}
 	}
 }
 
+// promoteScheduledJobs releases bookings that are due so they are dispatched this cycle
+func (jp *JobProcessor) promoteScheduledJobs() {
+	ctx := context.Background()
+
+	if _, err := jp.jobService.PromoteScheduledJobs(ctx); err != nil {
+		fmt.Printf("Error promoting scheduled jobs: %v\n", err)
+	}
+}
+
+// updateSurge reprices zones from the demand and supply seen this cycle
+func (jp *JobProcessor) updateSurge() {
+	ctx := context.Background()
+
+	if err := jp.jobService.UpdateSurge(ctx); err != nil {
+		fmt.Printf("Error updating surge pricing: %v\n", err)
+	}
+}
+
 // processPendingJobs attempts to assign all pending jobs
 func (jp *JobProcessor) processPendingJobs() {
 	ctx := context.Background()
@@ -55,3 +76,12 @@ func (jp *JobProcessor) processPendingJobs() {
 		fmt.Printf("Error processing pending jobs: %v\n", err)
 	}
 }
+
+// recordPendingRevenue retries adding finished jobs to the revenue rollups
+func (jp *JobProcessor) recordPendingRevenue() {
+	ctx := context.Background()
+
+	if _, err := jp.jobService.RecordPendingRevenue(ctx); err != nil {
+		fmt.Printf("Error recording pending revenue: %v\n", err)
+	}
+}
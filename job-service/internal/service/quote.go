@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"job-service/internal/storage"
+)
+
+// DefaultQuoteTTL is how long a customer has to book at a quoted price
+const DefaultQuoteTTL = 5 * time.Minute
+
+var (
+	// ErrInvalidJobType is returned for jobs that are neither rides nor deliveries
+	ErrInvalidJobType = errors.New("invalid job type")
+	// ErrInvalidQuote is returned for quote IDs this service did not issue or that were altered
+	ErrInvalidQuote = errors.New("invalid quote")
+	// ErrQuoteExpired is returned when booking with a quote past its expiry
+	ErrQuoteExpired = errors.New("quote expired")
+	// ErrQuoteSigningKeyNotSet is returned when quoting or booking a quote before a signing key is set
+	ErrQuoteSigningKeyNotSet = errors.New("quote signing key not set")
+)
+
+// FareQuote is what a trip would cost if booked now, broken down the way the job's fare
+// is. Its ID is signed and carries the trip and locked fare, so booking with it charges
+// the quoted price whatever pricing does in the meantime.
+type FareQuote struct {
+	ID        string    `json:"quote_id,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+
+	JobType        string  `json:"job_type"`
+	Region         string  `json:"region"`
+	PickupLat      float64 `json:"pickup_lat"`
+	PickupLng      float64 `json:"pickup_lng"`
+	DestinationLat float64 `json:"destination_lat"`
+	DestinationLng float64 `json:"destination_lng"`
+	Shared         bool    `json:"shared,omitempty"`
+	Seats          int     `json:"seats,omitempty"`
+
+	EstimatedDistanceKm      float64 `json:"estimated_distance_km"`
+	EstimatedDurationMinutes float64 `json:"estimated_duration_minutes"`
+	// Drive time of the nearest suitable vehicle to the pickup; nil if none is free
+	EstimatedPickupMinutes *float64 `json:"estimated_pickup_minutes,omitempty"`
+
+	BaseFare         float64 `json:"base_fare"`
+	DistanceFare     float64 `json:"distance_fare"`
+	TimeFare         float64 `json:"time_fare,omitempty"`
+	SharedDiscount   float64 `json:"shared_discount,omitempty"`
+	MinimumFareTopUp float64 `json:"minimum_fare_top_up,omitempty"`
+	SurgeMultiplier  float64 `json:"surge_multiplier"`
+	SurgeFare        float64 `json:"surge_fare,omitempty"`
+	FareAmount       float64 `json:"fare_amount"`
+	PricingVersion   string  `json:"pricing_version"`
+}
+
+// SetQuoteSigningKey sets the key quotes are signed with. Instances sharing a key honour
+// each other's quotes, so every instance must be given the same one.
+func (j *JobService) SetQuoteSigningKey(key []byte) {
+	j.quoteKey = key
+}
+
+// SetQuoteTTL sets how long quotes stay valid
+func (j *JobService) SetQuoteTTL(ttl time.Duration) {
+	j.quoteTTL = ttl
+}
+
+// QuoteFare prices a ride or delivery at the current surge without creating it, and
+// issues a quote ID to book it at that price. Seats only apply to shared rides.
+func (j *JobService) QuoteFare(ctx context.Context, jobType, region string, pickupLat, pickupLng, destLat, destLng float64, shared bool, seats int) (*FareQuote, error) {
+	switch jobType {
+	case "ride", "delivery":
+	default:
+		return nil, fmt.Errorf("%w: must be 'ride' or 'delivery', got %q", ErrInvalidJobType, jobType)
+	}
+	if shared {
+		if jobType != "ride" {
+			return nil, fmt.Errorf("%w: only rides can be shared", ErrInvalidJobType)
+		}
+		if seats < 1 || seats > maxSharedSeats {
+			return nil, fmt.Errorf("%w: shared rides take 1 to %d seats, got %d", ErrInvalidSeats, maxSharedSeats, seats)
+		}
+	} else {
+		seats = 0
+	}
+
+	job := j.newJob(ctx, jobType, "", region, directStops(pickupLat, pickupLng, destLat, destLng))
+	job.Shared = shared
+	job.Seats = seats
+	job.SurgeMultiplier = j.surge.Multiplier(region, pickupLat, pickupLng)
+	j.PricingRules().CalculateFare(job)
+
+	quote := &FareQuote{
+		ExpiresAt:           time.Now().Add(j.quoteTTL).UTC().Truncate(time.Second),
+		JobType:             job.JobType,
+		Region:              job.Region,
+		PickupLat:           job.PickupLat,
+		PickupLng:           job.PickupLng,
+		DestinationLat:      job.DestinationLat,
+		DestinationLng:      job.DestinationLng,
+		Shared:              job.Shared,
+		Seats:               job.Seats,
+		EstimatedDistanceKm: job.EstimatedDistanceKm,
+		BaseFare:            job.BaseFare,
+		DistanceFare:        job.DistanceFare,
+		TimeFare:            job.TimeFare,
+		SharedDiscount:      job.SharedDiscount,
+		MinimumFareTopUp:    job.MinimumFareTopUp,
+		SurgeMultiplier:     job.SurgeMultiplier,
+		SurgeFare:           job.SurgeFare,
+		FareAmount:          job.FareAmount,
+		PricingVersion:      job.PricingVersion,
+
+		EstimatedDurationMinutes: job.EstimatedDurationMinutes,
+	}
+
+	id, err := j.signQuote(quote)
+	if err != nil {
+		return nil, err
+	}
+	quote.ID = id
+
+	// The pickup ETA is only an indication, so it is left out of what is signed
+	if eta, err := j.pickupETA(ctx, job); err == nil {
+		minutes := eta.Minutes()
+		quote.EstimatedPickupMinutes = &minutes
+	}
+
+	return quote, nil
+}
+
+// CreateQuotedJob books the trip a quote was issued for at the quoted fare
+func (j *JobService) CreateQuotedJob(ctx context.Context, customerID, quoteID string, details *storage.DeliveryDetails) (*storage.Job, error) {
+	quote, err := j.verifyQuote(quoteID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	var job *storage.Job
+	if quote.JobType == "delivery" {
+		job = j.newDeliveryJob(ctx, customerID, quote.Region, quote.PickupLat, quote.PickupLng, quote.DestinationLat, quote.DestinationLng, details)
+	} else {
+		job = j.newRideJob(ctx, customerID, quote.Region, quote.PickupLat, quote.PickupLng, quote.DestinationLat, quote.DestinationLng)
+	}
+	job.Shared = quote.Shared
+	job.Seats = quote.Seats
+
+	job.QuoteID = quoteID
+	job.BaseFare = quote.BaseFare
+	job.DistanceFare = quote.DistanceFare
+	job.TimeFare = quote.TimeFare
+	job.SharedDiscount = quote.SharedDiscount
+	job.MinimumFareTopUp = quote.MinimumFareTopUp
+	job.SurgeMultiplier = quote.SurgeMultiplier
+	job.SurgeFare = quote.SurgeFare
+	job.FareAmount = quote.FareAmount
+	job.PricingVersion = quote.PricingVersion
+
+	return j.submitJob(ctx, job)
+}
+
+// signQuote encodes a quote as its ID: the quote's JSON followed by an HMAC of it
+func (j *JobService) signQuote(quote *FareQuote) (string, error) {
+	if len(j.quoteKey) == 0 {
+		return "", ErrQuoteSigningKeyNotSet
+	}
+
+	payload, err := json.Marshal(quote)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode quote: %w", err)
+	}
+
+	mac := hmac.New(sha256.New, j.quoteKey)
+	mac.Write(payload)
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// verifyQuote decodes a quote ID, checking it was signed with this service's key and
+// is still valid at now
+func (j *JobService) verifyQuote(quoteID string, now time.Time) (*FareQuote, error) {
+	if len(j.quoteKey) == 0 {
+		return nil, ErrQuoteSigningKeyNotSet
+	}
+
+	encodedPayload, encodedSignature, ok := strings.Cut(quoteID, ".")
+	if !ok {
+		return nil, fmt.Errorf("%w: malformed quote ID", ErrInvalidQuote)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed quote ID", ErrInvalidQuote)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed quote ID", ErrInvalidQuote)
+	}
+
+	mac := hmac.New(sha256.New, j.quoteKey)
+	mac.Write(payload)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, fmt.Errorf("%w: signature mismatch", ErrInvalidQuote)
+	}
+
+	var quote FareQuote
+	if err := json.Unmarshal(payload, &quote); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQuote, err)
+	}
+	if now.After(quote.ExpiresAt) {
+		return nil, fmt.Errorf("%w at %s", ErrQuoteExpired, quote.ExpiresAt.Format(time.RFC3339))
+	}
+
+	return &quote, nil
+}
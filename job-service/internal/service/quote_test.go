@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"job-service/internal/fleet"
+	"job-service/internal/storage"
+)
+
+func TestJobService_QuoteFare_RejectsInvalidTrips(t *testing.T) {
+	jobService := NewJobService(storage.NewMemoryJobStorage(), NewMockFleetClient())
+	jobService.SetQuoteSigningKey([]byte("test-quote-key"))
+	ctx := context.Background()
+
+	if _, err := jobService.QuoteFare(ctx, "boat", "us-west-2", 45.52, -122.68, 45.54, -122.66, false, 0); !errors.Is(err, ErrInvalidJobType) {
+		t.Errorf("Expected ErrInvalidJobType, got %v", err)
+	}
+	if _, err := jobService.QuoteFare(ctx, "delivery", "us-west-2", 45.52, -122.68, 45.54, -122.66, true, 1); !errors.Is(err, ErrInvalidJobType) {
+		t.Errorf("Expected ErrInvalidJobType for a shared delivery, got %v", err)
+	}
+	if _, err := jobService.QuoteFare(ctx, "ride", "us-west-2", 45.52, -122.68, 45.54, -122.66, true, maxSharedSeats+1); !errors.Is(err, ErrInvalidSeats) {
+		t.Errorf("Expected ErrInvalidSeats, got %v", err)
+	}
+}
+
+func TestJobService_CreateQuotedJob_LocksPrice(t *testing.T) {
+	jobStorage := storage.NewMemoryJobStorage()
+	mockFleetClient := NewMockFleetClient()
+	jobService := NewJobService(jobStorage, mockFleetClient)
+	jobService.SetQuoteSigningKey([]byte("test-quote-key"))
+	ctx := context.Background()
+
+	mockFleetClient.AddVehicle(&fleet.Vehicle{
+		ID:             "vehicle-1",
+		Region:         "us-west-2",
+		Status:         "available",
+		BatteryLevel:   80,
+		BatteryRangeKm: 200.0,
+		LocationLat:    45.50,
+		LocationLng:    -122.68,
+		VehicleType:    "sedan",
+	})
+
+	quote, err := jobService.QuoteFare(ctx, "ride", "us-west-2", 45.52, -122.68, 45.54, -122.66, false, 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if quote.ID == "" || quote.EstimatedPickupMinutes == nil || *quote.EstimatedPickupMinutes <= 0 {
+		t.Fatalf("Expected a quote ID and pickup ETA, got %+v", quote)
+	}
+
+	// Rates go up before the customer books
+	raised := DefaultPricingRules()
+	raised.Version = "raised"
+	raised.Defaults.RidePerKm *= 2
+	jobService.SetPricingRules(raised)
+
+	job, err := jobService.CreateQuotedJob(ctx, "customer-1", quote.ID, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if job.FareAmount != quote.FareAmount || job.DistanceFare != quote.DistanceFare || job.QuoteID != quote.ID {
+		t.Errorf("Expected the quoted fare %.2f, got %.2f from quote %q", quote.FareAmount, job.FareAmount, job.QuoteID)
+	}
+	if job.PricingVersion != DefaultPricingVersion {
+		t.Errorf("Expected the quoted pricing version, got %q", job.PricingVersion)
+	}
+	if job.PickupLat != 45.52 || job.DestinationLng != -122.66 || job.Status != JobStatusAssigned {
+		t.Errorf("Expected the quoted trip dispatched, got %+v", job)
+	}
+
+	unquoted, err := jobService.CreateRideJob(ctx, "customer-2", "us-west-2", 45.52, -122.68, 45.54, -122.66)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if unquoted.FareAmount <= quote.FareAmount || unquoted.PricingVersion != "raised" {
+		t.Errorf("Expected a booking without a quote to pay the new rates, got %.2f from %q", unquoted.FareAmount, unquoted.PricingVersion)
+	}
+}
+
+func TestJobService_VerifyQuote(t *testing.T) {
+	jobService := NewJobService(storage.NewMemoryJobStorage(), NewMockFleetClient())
+	jobService.SetQuoteSigningKey([]byte("test-quote-key"))
+	ctx := context.Background()
+
+	quote, err := jobService.QuoteFare(ctx, "delivery", "us-west-2", 45.52, -122.68, 45.54, -122.66, false, 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, err := jobService.verifyQuote(quote.ID, time.Now()); err != nil {
+		t.Errorf("Expected the quote to verify, got %v", err)
+	}
+	if _, err := jobService.verifyQuote(quote.ID, time.Now().Add(DefaultQuoteTTL+time.Minute)); !errors.Is(err, ErrQuoteExpired) {
+		t.Errorf("Expected ErrQuoteExpired, got %v", err)
+	}
+
+	// A cheaper fare spliced in with the original signature
+	payload, signature, _ := strings.Cut(quote.ID, ".")
+	cheaper := *quote
+	cheaper.ID = ""
+	cheaper.EstimatedPickupMinutes = nil
+	cheaper.FareAmount = 0.01
+	forged, _ := jobService.signQuote(&cheaper)
+	forgedPayload, _, _ := strings.Cut(forged, ".")
+	if forgedPayload == payload {
+		t.Fatal("Expected the forged payload to differ")
+	}
+	if _, err := jobService.verifyQuote(forgedPayload+"."+signature, time.Now()); !errors.Is(err, ErrInvalidQuote) {
+		t.Errorf("Expected ErrInvalidQuote for an altered quote, got %v", err)
+	}
+
+	// Another service's key
+	other := NewJobService(storage.NewMemoryJobStorage(), NewMockFleetClient())
+	other.SetQuoteSigningKey([]byte("other-quote-key"))
+	if _, err := other.verifyQuote(quote.ID, time.Now()); !errors.Is(err, ErrInvalidQuote) {
+		t.Errorf("Expected ErrInvalidQuote for a quote signed elsewhere, got %v", err)
+	}
+
+	if _, err := jobService.CreateQuotedJob(ctx, "customer-1", "not-a-quote", nil); !errors.Is(err, ErrInvalidQuote) {
+		t.Errorf("Expected ErrInvalidQuote, got %v", err)
+	}
+}
+
+func TestJobService_CreateQuotedJob_SharedKeyAcrossInstances(t *testing.T) {
+	jobStorage := storage.NewMemoryJobStorage()
+	mockFleetClient := NewMockFleetClient()
+	mockFleetClient.AddVehicle(&fleet.Vehicle{
+		ID:             "vehicle-1",
+		Region:         "us-west-2",
+		Status:         "available",
+		BatteryLevel:   80,
+		BatteryRangeKm: 200.0,
+		LocationLat:    45.50,
+		LocationLng:    -122.68,
+		VehicleType:    "sedan",
+	})
+	ctx := context.Background()
+
+	// Two instances behind the load balancer, configured with the same key
+	quoting := NewJobService(jobStorage, mockFleetClient)
+	quoting.SetQuoteSigningKey([]byte("shared-quote-key"))
+	booking := NewJobService(jobStorage, mockFleetClient)
+	booking.SetQuoteSigningKey([]byte("shared-quote-key"))
+
+	quote, err := quoting.QuoteFare(ctx, "ride", "us-west-2", 45.52, -122.68, 45.54, -122.66, false, 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	job, err := booking.CreateQuotedJob(ctx, "customer-1", quote.ID, nil)
+	if err != nil {
+		t.Fatalf("Expected the other instance to honour the quote, got %v", err)
+	}
+	if job.FareAmount != quote.FareAmount || job.QuoteID != quote.ID {
+		t.Errorf("Expected the quoted fare %.2f, got %.2f from quote %q", quote.FareAmount, job.FareAmount, job.QuoteID)
+	}
+}
+
+func TestJobService_QuoteFare_RequiresSigningKey(t *testing.T) {
+	jobService := NewJobService(storage.NewMemoryJobStorage(), NewMockFleetClient())
+	ctx := context.Background()
+
+	if _, err := jobService.QuoteFare(ctx, "ride", "us-west-2", 45.52, -122.68, 45.54, -122.66, false, 0); !errors.Is(err, ErrQuoteSigningKeyNotSet) {
+		t.Errorf("Expected ErrQuoteSigningKeyNotSet, got %v", err)
+	}
+	if _, err := jobService.CreateQuotedJob(ctx, "customer-1", "payload.signature", nil); !errors.Is(err, ErrQuoteSigningKeyNotSet) {
+		t.Errorf("Expected ErrQuoteSigningKeyNotSet, got %v", err)
+	}
+}
@@ -0,0 +1,410 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"job-service/internal/storage"
+)
+
+// Ways a revenue report can be grouped
+const (
+	RevenueGroupHour    = "hour"
+	RevenueGroupDay     = "day"
+	RevenueGroupRegion  = "region"
+	RevenueGroupJobType = "job_type"
+	RevenueGroupVehicle = "vehicle"
+)
+
+const (
+	// DefaultRevenueWindow is how far back a revenue report goes when no start is given
+	DefaultRevenueWindow = 24 * time.Hour
+	// MaxRevenueWindow is the longest period a single revenue report can cover
+	MaxRevenueWindow = 366 * 24 * time.Hour
+)
+
+// ErrInvalidRevenueQuery is returned for revenue reports with a bad period or grouping
+var ErrInvalidRevenueQuery = errors.New("invalid revenue query")
+
+// RevenueQuery selects the period a revenue report covers and how it is broken down
+type RevenueQuery struct {
+	From    time.Time // zero for DefaultRevenueWindow before To
+	To      time.Time // zero for now
+	GroupBy []string  // RevenueGroup* values; none for just the totals
+}
+
+// RevenueReport is the revenue from jobs that finished within a period. Rollups are
+// hourly, so the period is widened to whole hours.
+type RevenueReport struct {
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	GroupBy []string  `json:"group_by,omitempty"`
+	RevenueSummary
+	Groups []RevenueGroup `json:"groups,omitempty"`
+}
+
+// RevenueSummary totals the revenue from a set of jobs
+type RevenueSummary struct {
+	TotalRevenue     float64 `json:"total_revenue"` // fares plus cancellation fees
+	FareRevenue      float64 `json:"fare_revenue"`
+	CancellationFees float64 `json:"cancellation_fees"`
+	SurgeRevenue     float64 `json:"surge_revenue"` // the part of fares added by surge pricing
+	RideRevenue      float64 `json:"ride_revenue"`
+	DeliveryRevenue  float64 `json:"delivery_revenue"`
+	CompletedJobs    int     `json:"completed_jobs"`
+	CancelledJobs    int     `json:"cancelled_jobs"`
+	RideCount        int     `json:"ride_count"`
+	DeliveryCount    int     `json:"delivery_count"`
+	AvgRideFare      float64 `json:"avg_ride_fare"`
+	AvgDeliveryFare  float64 `json:"avg_delivery_fare"`
+
+	FarePercentiles FarePercentiles `json:"fare_percentiles"`
+
+	// Hours vehicles spent on completed jobs, and the fares they earned per hour
+	VehicleHours          float64 `json:"vehicle_hours"`
+	RevenuePerVehicleHour float64 `json:"revenue_per_vehicle_hour"`
+}
+
+// FarePercentiles are percentiles of completed jobs' fares, to within half of
+// storage.FareBinWidth
+type FarePercentiles struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+}
+
+// RevenueGroupKey identifies a group of a revenue report. Only the fields the report
+// is grouped by are set.
+type RevenueGroupKey struct {
+	Hour      string `json:"hour,omitempty"` // RFC 3339, UTC
+	Day       string `json:"day,omitempty"`  // YYYY-MM-DD, UTC
+	Region    string `json:"region,omitempty"`
+	JobType   string `json:"job_type,omitempty"`
+	VehicleID string `json:"vehicle_id,omitempty"`
+}
+
+// RevenueGroup is the revenue of one group of a revenue report
+type RevenueGroup struct {
+	RevenueGroupKey
+	RevenueSummary
+}
+
+// SetRevenueStorage sets where the hourly revenue rollups are kept
+func (j *JobService) SetRevenueStorage(revenue storage.RevenueStorage) {
+	j.revenue = revenue
+}
+
+// recordRevenue adds a finished job to its hour's revenue rollup, then clears the job's
+// pending revenue status. A job left pending because either write failed is recorded
+// again by RecordPendingRevenue; rollups take each job once, so it is never counted twice.
+func (j *JobService) recordRevenue(ctx context.Context, job *storage.Job) error {
+	if delta := revenueDelta(job); delta != nil {
+		err := j.revenue.AddRevenue(ctx, job.ID, delta)
+		if err != nil && !errors.Is(err, storage.ErrRevenueAlreadyRecorded) {
+			return err
+		}
+	}
+
+	for attempt := 0; job.RevenueStatus == storage.RevenueStatusPending; attempt++ {
+		updated := *job
+		updated.RevenueStatus = ""
+		err := j.storage.UpdateJob(ctx, &updated)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, storage.ErrVersionConflict) || attempt+1 >= maxTransitionAttempts {
+			return err
+		}
+
+		// Written since it was read, such as by a refund; clear it on the latest copy
+		if job, err = j.storage.GetJob(ctx, job.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RecordPendingRevenue adds the finished jobs whose revenue failed to reach the rollups
+// when they finished, returning how many it recorded
+func (j *JobService) RecordPendingRevenue(ctx context.Context) (int, error) {
+	jobs, err := j.storage.GetJobsByRevenueStatus(ctx, storage.RevenueStatusPending)
+	if err != nil {
+		return 0, err
+	}
+
+	recorded := 0
+	var firstErr error
+	for _, job := range jobs {
+		if err := j.recordRevenue(ctx, job); err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to record revenue for job %s: %w", job.ID, err)
+			}
+			continue
+		}
+		recorded++
+	}
+
+	return recorded, firstErr
+}
+
+// revenueDelta returns the amounts a finished job adds to its hour's revenue rollup, or
+// nil for a job that hasn't finished
+func revenueDelta(job *storage.Job) *storage.RevenueRollup {
+	delta := &storage.RevenueRollup{
+		Region:  job.Region,
+		JobType: job.JobType,
+	}
+	if job.AssignedVehicleID != nil {
+		delta.VehicleID = *job.AssignedVehicleID
+	}
+
+	switch {
+	case job.Status == JobStatusCompleted && job.CompletedAt != nil:
+		delta.Hour = job.CompletedAt.UTC().Truncate(time.Hour)
+		delta.FareRevenue = job.FareAmount
+		delta.SurgeRevenue = job.SurgeFare
+		delta.CompletedJobs = 1
+		delta.FareHistogram = map[int]int{storage.FareBin(job.FareAmount): 1}
+		if job.AssignedAt != nil {
+			delta.VehicleHours = job.CompletedAt.Sub(*job.AssignedAt).Hours()
+		}
+	case job.Status == JobStatusCancelled && job.CancelledAt != nil:
+		delta.Hour = job.CancelledAt.UTC().Truncate(time.Hour)
+		delta.CancellationFees = job.CancellationFee
+		delta.CancelledJobs = 1
+	default:
+		return nil
+	}
+
+	return delta
+}
+
+// RevenueBackfill counts the jobs a backfill added to the revenue rollups
+type RevenueBackfill struct {
+	Before          time.Time `json:"before"`
+	CompletedJobs   int       `json:"completed_jobs"`
+	CancelledJobs   int       `json:"cancelled_jobs"`
+	AlreadyRecorded int       `json:"already_recorded"` // jobs an earlier backfill added
+}
+
+// BackfillRevenue adds the jobs that finished before the revenue rollups were kept to
+// them, so reports cover those jobs too. before is when rollups started being kept:
+// jobs finished since are already in them. Rollups take each job once, so a backfill
+// that failed partway through can simply be run again.
+func (j *JobService) BackfillRevenue(ctx context.Context, before time.Time) (*RevenueBackfill, error) {
+	if before.IsZero() || before.After(time.Now()) {
+		return nil, fmt.Errorf("%w: backfill must end at a time that has passed", ErrInvalidRevenueQuery)
+	}
+
+	jobs, err := j.storage.GetAllJobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	backfill := &RevenueBackfill{Before: before}
+	for _, job := range jobs {
+		delta := revenueDelta(job)
+		if delta == nil {
+			continue
+		}
+		finishedAt := job.CompletedAt
+		if delta.CancelledJobs > 0 {
+			finishedAt = job.CancelledAt
+		}
+		if !finishedAt.Before(before) {
+			continue
+		}
+
+		err := j.revenue.AddRevenue(ctx, job.ID, delta)
+		if errors.Is(err, storage.ErrRevenueAlreadyRecorded) {
+			backfill.AlreadyRecorded++
+			continue
+		}
+		if err != nil {
+			return backfill, fmt.Errorf("failed to backfill revenue for job %s after adding %d jobs: %w",
+				job.ID, backfill.CompletedJobs+backfill.CancelledJobs, err)
+		}
+		backfill.CompletedJobs += delta.CompletedJobs
+		backfill.CancelledJobs += delta.CancelledJobs
+	}
+
+	return backfill, nil
+}
+
+// GetRevenue reports the revenue from jobs that finished within a period. Jobs that
+// finished before revenue rollups were kept are left out until BackfillRevenue adds them.
+func (j *JobService) GetRevenue(ctx context.Context, query RevenueQuery) (*RevenueReport, error) {
+	to := query.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+	from := query.From
+	if from.IsZero() {
+		from = to.Add(-DefaultRevenueWindow)
+	}
+
+	// Widen the period to whole hours
+	from = from.UTC().Truncate(time.Hour)
+	if rounded := to.UTC().Truncate(time.Hour); rounded.Before(to) {
+		to = rounded.Add(time.Hour)
+	} else {
+		to = rounded
+	}
+
+	if !from.Before(to) {
+		return nil, fmt.Errorf("%w: period ends before it starts", ErrInvalidRevenueQuery)
+	}
+	if to.Sub(from) > MaxRevenueWindow {
+		return nil, fmt.Errorf("%w: period is longer than %s", ErrInvalidRevenueQuery, MaxRevenueWindow)
+	}
+	for _, group := range query.GroupBy {
+		switch group {
+		case RevenueGroupHour, RevenueGroupDay, RevenueGroupRegion, RevenueGroupJobType, RevenueGroupVehicle:
+		default:
+			return nil, fmt.Errorf("%w: unknown grouping %q", ErrInvalidRevenueQuery, group)
+		}
+	}
+
+	rollups, err := j.revenue.GetRevenueRollups(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &RevenueReport{
+		From:           from,
+		To:             to,
+		GroupBy:        query.GroupBy,
+		RevenueSummary: summarizeRevenue(rollups),
+	}
+	if len(query.GroupBy) == 0 {
+		return report, nil
+	}
+
+	grouped := make(map[RevenueGroupKey][]*storage.RevenueRollup)
+	for _, rollup := range rollups {
+		key := revenueGroupKey(rollup, query.GroupBy)
+		grouped[key] = append(grouped[key], rollup)
+	}
+	for key, groupRollups := range grouped {
+		report.Groups = append(report.Groups, RevenueGroup{RevenueGroupKey: key, RevenueSummary: summarizeRevenue(groupRollups)})
+	}
+	sort.Slice(report.Groups, func(a, b int) bool {
+		ka, kb := report.Groups[a].RevenueGroupKey, report.Groups[b].RevenueGroupKey
+		if ka.Hour != kb.Hour {
+			return ka.Hour < kb.Hour
+		}
+		if ka.Day != kb.Day {
+			return ka.Day < kb.Day
+		}
+		if ka.Region != kb.Region {
+			return ka.Region < kb.Region
+		}
+		if ka.JobType != kb.JobType {
+			return ka.JobType < kb.JobType
+		}
+		return ka.VehicleID < kb.VehicleID
+	})
+
+	return report, nil
+}
+
+// revenueGroupKey returns the group of a report grouped by groupBy a rollup belongs to
+func revenueGroupKey(rollup *storage.RevenueRollup, groupBy []string) RevenueGroupKey {
+	var key RevenueGroupKey
+	for _, group := range groupBy {
+		switch group {
+		case RevenueGroupHour:
+			key.Hour = rollup.Hour.UTC().Format(time.RFC3339)
+		case RevenueGroupDay:
+			key.Day = rollup.Hour.UTC().Format(time.DateOnly)
+		case RevenueGroupRegion:
+			key.Region = rollup.Region
+		case RevenueGroupJobType:
+			key.JobType = rollup.JobType
+		case RevenueGroupVehicle:
+			key.VehicleID = rollup.VehicleID
+		}
+	}
+	return key
+}
+
+// summarizeRevenue totals a set of rollups
+func summarizeRevenue(rollups []*storage.RevenueRollup) RevenueSummary {
+	var summary RevenueSummary
+	histogram := make(map[int]int)
+
+	for _, rollup := range rollups {
+		summary.FareRevenue += rollup.FareRevenue
+		summary.CancellationFees += rollup.CancellationFees
+		summary.SurgeRevenue += rollup.SurgeRevenue
+		summary.CompletedJobs += rollup.CompletedJobs
+		summary.CancelledJobs += rollup.CancelledJobs
+		summary.VehicleHours += rollup.VehicleHours
+
+		if rollup.JobType == "ride" {
+			summary.RideRevenue += rollup.FareRevenue
+			summary.RideCount += rollup.CompletedJobs
+		} else {
+			summary.DeliveryRevenue += rollup.FareRevenue
+			summary.DeliveryCount += rollup.CompletedJobs
+		}
+
+		for bin, count := range rollup.FareHistogram {
+			histogram[bin] += count
+		}
+	}
+
+	summary.TotalRevenue = summary.FareRevenue + summary.CancellationFees
+	if summary.RideCount > 0 {
+		summary.AvgRideFare = summary.RideRevenue / float64(summary.RideCount)
+	}
+	if summary.DeliveryCount > 0 {
+		summary.AvgDeliveryFare = summary.DeliveryRevenue / float64(summary.DeliveryCount)
+	}
+	if summary.VehicleHours > 0 {
+		summary.RevenuePerVehicleHour = summary.FareRevenue / summary.VehicleHours
+	}
+	summary.FarePercentiles = farePercentiles(histogram)
+
+	return summary
+}
+
+// farePercentiles estimates fare percentiles from a histogram of fares, taking the
+// middle of the bin the nearest-ranked fare falls in
+func farePercentiles(histogram map[int]int) FarePercentiles {
+	bins := make([]int, 0, len(histogram))
+	total := 0
+	for bin, count := range histogram {
+		bins = append(bins, bin)
+		total += count
+	}
+	if total == 0 {
+		return FarePercentiles{}
+	}
+	sort.Ints(bins)
+
+	percentile := func(p float64) float64 {
+		rank := int(math.Ceil(p * float64(total)))
+		seen := 0
+		for _, bin := range bins {
+			seen += histogram[bin]
+			if seen >= rank {
+				return math.Round((float64(bin)+0.5)*storage.FareBinWidth*100) / 100
+			}
+		}
+		return 0
+	}
+
+	return FarePercentiles{
+		P50: percentile(0.50),
+		P90: percentile(0.90),
+		P95: percentile(0.95),
+		P99: percentile(0.99),
+	}
+}
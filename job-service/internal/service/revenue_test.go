@@ -2,110 +2,272 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
 	"testing"
 	"time"
 
 	"job-service/internal/storage"
 )
 
+// finishedJob fabricates a job completed at completedAt after an hour on the road, or
+// cancelled then if fare is zero
+func finishedJob(id, jobType, region, vehicleID string, fare float64, completedAt time.Time) *storage.Job {
+	job := &storage.Job{ID: id, JobType: jobType, Region: region}
+	if vehicleID != "" {
+		assignedAt := completedAt.Add(-time.Hour)
+		job.AssignedVehicleID = &vehicleID
+		job.AssignedAt = &assignedAt
+	}
+	if fare == 0 {
+		job.Status = JobStatusCancelled
+		job.CancelledAt = &completedAt
+		job.CancellationFee = 5.00
+		return job
+	}
+	job.Status = JobStatusCompleted
+	job.CompletedAt = &completedAt
+	job.FareAmount = fare
+	return job
+}
+
+// failingRevenueStorage fails every write while fail is set
+type failingRevenueStorage struct {
+	*storage.MemoryRevenueStorage
+	fail error
+}
+
+func (f *failingRevenueStorage) AddRevenue(ctx context.Context, jobID string, delta *storage.RevenueRollup) error {
+	if f.fail != nil {
+		return f.fail
+	}
+	return f.MemoryRevenueStorage.AddRevenue(ctx, jobID, delta)
+}
+
 func TestJobService_GetRevenue(t *testing.T) {
-	jobStorage := storage.NewMemoryJobStorage()
-	mockFleetClient := NewMockFleetClient()
-	jobService := NewJobService(jobStorage, mockFleetClient)
+	jobService := NewJobService(storage.NewMemoryJobStorage(), NewMockFleetClient())
 	ctx := context.Background()
+	now := time.Now()
+
+	jobService.recordRevenue(ctx, finishedJob("ride-1", "ride", "us-west-2", "vehicle-1", 15.50, now.Add(-time.Hour)))
+	jobService.recordRevenue(ctx, finishedJob("delivery-1", "delivery", "us-west-2", "vehicle-2", 8.99, now))
+	jobService.recordRevenue(ctx, finishedJob("ride-2", "ride", "us-west-2", "", 0, now))
+	// Pending jobs haven't earned anything yet
+	jobService.recordRevenue(ctx, &storage.Job{ID: "ride-3", JobType: "ride", Status: JobStatusPending, FareAmount: 12.00})
 
-	// Create some completed jobs with different fares
-	completedRide := &storage.Job{
-		ID:          "ride-1",
-		JobType:     "ride",
-		Status:      "completed",
-		FareAmount:  15.50,
-		CreatedAt:   time.Now(),
-		CompletedAt: &time.Time{},
+	revenue, err := jobService.GetRevenue(ctx, RevenueQuery{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
 	}
 
-	completedDelivery := &storage.Job{
-		ID:          "delivery-1",
-		JobType:     "delivery",
-		Status:      "completed",
-		FareAmount:  8.99,
-		CreatedAt:   time.Now(),
-		CompletedAt: &time.Time{},
+	// 15.50 + 8.99 in fares and a 5.00 cancellation fee
+	if math.Abs(revenue.TotalRevenue-29.49) > 1e-9 || math.Abs(revenue.FareRevenue-24.49) > 1e-9 || revenue.CancellationFees != 5.00 {
+		t.Errorf("Expected 29.49 revenue with 24.49 in fares, got %.2f with %.2f", revenue.TotalRevenue, revenue.FareRevenue)
+	}
+	if revenue.RideRevenue != 15.50 || revenue.DeliveryRevenue != 8.99 || revenue.RideCount != 1 || revenue.DeliveryCount != 1 {
+		t.Errorf("Expected one 15.50 ride and one 8.99 delivery, got %+v", revenue.RevenueSummary)
+	}
+	if revenue.CompletedJobs != 2 || revenue.CancelledJobs != 1 {
+		t.Errorf("Expected 2 completed and 1 cancelled job, got %d and %d", revenue.CompletedJobs, revenue.CancelledJobs)
+	}
+	// Each completed job took an hour
+	if revenue.VehicleHours != 2 || math.Abs(revenue.RevenuePerVehicleHour-24.49/2) > 1e-9 {
+		t.Errorf("Expected 2 vehicle hours earning %.3f an hour, got %.2f earning %.3f", 24.49/2, revenue.VehicleHours, revenue.RevenuePerVehicleHour)
+	}
+	if revenue.To.Sub(revenue.From) < DefaultRevenueWindow || revenue.From.Minute() != 0 || revenue.To.Minute() != 0 {
+		t.Errorf("Expected the last day widened to whole hours, got %s to %s", revenue.From, revenue.To)
+	}
+}
+
+func TestJobService_GetRevenue_NoCompletedJobs(t *testing.T) {
+	jobService := NewJobService(storage.NewMemoryJobStorage(), NewMockFleetClient())
+
+	revenue, err := jobService.GetRevenue(context.Background(), RevenueQuery{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if revenue.TotalRevenue != 0 || revenue.CompletedJobs != 0 || revenue.RevenuePerVehicleHour != 0 || revenue.FarePercentiles.P50 != 0 {
+		t.Errorf("Expected an empty report, got %+v", revenue.RevenueSummary)
+	}
+}
+
+func TestJobService_GetRevenue_Window(t *testing.T) {
+	jobService := NewJobService(storage.NewMemoryJobStorage(), NewMockFleetClient())
+	ctx := context.Background()
+	day := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	jobService.recordRevenue(ctx, finishedJob("ride-1", "ride", "us-west-2", "vehicle-1", 10, day.Add(8*time.Hour+30*time.Minute)))
+	jobService.recordRevenue(ctx, finishedJob("ride-2", "ride", "us-west-2", "vehicle-1", 20, day.Add(10*time.Hour+10*time.Minute)))
+	jobService.recordRevenue(ctx, finishedJob("ride-3", "ride", "us-west-2", "vehicle-1", 40, day.Add(30*time.Hour)))
+
+	// 09:15 widens back to 09:00, and 10:05 forward to 11:00
+	revenue, err := jobService.GetRevenue(ctx, RevenueQuery{From: day.Add(9*time.Hour + 15*time.Minute), To: day.Add(10*time.Hour + 5*time.Minute)})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !revenue.From.Equal(day.Add(9*time.Hour)) || !revenue.To.Equal(day.Add(11*time.Hour)) {
+		t.Errorf("Expected 09:00 to 11:00, got %s to %s", revenue.From, revenue.To)
+	}
+	if revenue.FareRevenue != 20 {
+		t.Errorf("Expected just the 10:10 fare, got %.2f", revenue.FareRevenue)
 	}
 
-	pendingJob := &storage.Job{
-		ID:         "ride-2",
-		JobType:    "ride",
-		Status:     "pending",
-		FareAmount: 12.00,
-		CreatedAt:  time.Now(),
+	invalid := []RevenueQuery{
+		{From: day, To: day.Add(-time.Hour)},
+		{From: day, To: day.Add(MaxRevenueWindow + time.Hour)},
+		{From: day, To: day.Add(time.Hour), GroupBy: []string{"week"}},
+	}
+	for _, query := range invalid {
+		if _, err := jobService.GetRevenue(ctx, query); !errors.Is(err, ErrInvalidRevenueQuery) {
+			t.Errorf("Expected ErrInvalidRevenueQuery for %+v, got %v", query, err)
+		}
 	}
+}
 
-	// Add jobs to storage
-	jobStorage.CreateJob(ctx, completedRide)
-	jobStorage.CreateJob(ctx, completedDelivery)
-	jobStorage.CreateJob(ctx, pendingJob)
+func TestJobService_BackfillRevenue(t *testing.T) {
+	jobStorage := storage.NewMemoryJobStorage()
+	jobService := NewJobService(jobStorage, NewMockFleetClient())
+	ctx := context.Background()
+	rollout := time.Now().Add(-2 * time.Hour)
 
-	revenue, err := jobService.GetRevenue(ctx)
+	// Jobs that finished before rollups were kept, and one recorded since
+	for _, job := range []*storage.Job{
+		finishedJob("ride-1", "ride", "us-west-2", "vehicle-1", 10, rollout.Add(-3*time.Hour)),
+		finishedJob("ride-2", "ride", "us-west-2", "", 0, rollout.Add(-time.Hour)),
+		{ID: "ride-3", JobType: "ride", Region: "us-west-2", Status: JobStatusInProgress},
+	} {
+		if err := jobStorage.CreateJob(ctx, job); err != nil {
+			t.Fatalf("Failed to create job: %v", err)
+		}
+	}
+	recorded := finishedJob("ride-4", "ride", "us-west-2", "vehicle-1", 20, rollout.Add(time.Hour))
+	if err := jobStorage.CreateJob(ctx, recorded); err != nil {
+		t.Fatalf("Failed to create job: %v", err)
+	}
+	jobService.recordRevenue(ctx, recorded)
+
+	backfill, err := jobService.BackfillRevenue(ctx, rollout)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
+	if backfill.CompletedJobs != 1 || backfill.CancelledJobs != 1 {
+		t.Errorf("Expected one completed and one cancelled job backfilled, got %+v", backfill)
+	}
 
-	// Check total revenue (only completed jobs)
-	expectedTotal := 15.50 + 8.99 // $24.49
-	actualTotal := revenue["total_revenue"].(float64)
-	if actualTotal < expectedTotal-0.01 || actualTotal > expectedTotal+0.01 {
-		t.Errorf("Expected total revenue %.2f, got %.2f", expectedTotal, actualTotal)
+	// Running it again, as after a partial failure, adds nothing twice
+	again, err := jobService.BackfillRevenue(ctx, rollout)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if again.CompletedJobs != 0 || again.CancelledJobs != 0 || again.AlreadyRecorded != 2 {
+		t.Errorf("Expected both jobs already recorded, got %+v", again)
 	}
 
-	// Check ride revenue
-	expectedRideRevenue := 15.50
-	if revenue["ride_revenue"].(float64) != expectedRideRevenue {
-		t.Errorf("Expected ride revenue %.2f, got %.2f", expectedRideRevenue, revenue["ride_revenue"].(float64))
+	revenue, _ := jobService.GetRevenue(ctx, RevenueQuery{From: rollout.Add(-4 * time.Hour)})
+	if revenue.FareRevenue != 30 || revenue.CancellationFees != 5 || revenue.CompletedJobs != 2 {
+		t.Errorf("Expected the backfilled and recorded jobs counted once each, got %+v", revenue.RevenueSummary)
 	}
 
-	// Check delivery revenue
-	expectedDeliveryRevenue := 8.99
-	if revenue["delivery_revenue"].(float64) != expectedDeliveryRevenue {
-		t.Errorf("Expected delivery revenue %.2f, got %.2f", expectedDeliveryRevenue, revenue["delivery_revenue"].(float64))
+	if _, err := jobService.BackfillRevenue(ctx, time.Time{}); !errors.Is(err, ErrInvalidRevenueQuery) {
+		t.Errorf("Expected ErrInvalidRevenueQuery without a cutoff, got %v", err)
 	}
+}
 
-	// Check completed jobs count
-	expectedCompleted := 2
-	if revenue["completed_jobs"].(int) != expectedCompleted {
-		t.Errorf("Expected %d completed jobs, got %d", expectedCompleted, revenue["completed_jobs"].(int))
+func TestJobService_RecordPendingRevenue(t *testing.T) {
+	jobService := setupLedger(t, "card-1")
+	revenue := &failingRevenueStorage{MemoryRevenueStorage: storage.NewMemoryRevenueStorage(), fail: errors.New("throttled")}
+	jobService.SetRevenueStorage(revenue)
+	ctx := context.Background()
+
+	// The job still completes, owed to the rollups
+	job := completeRide(t, jobService, "customer-1")
+	if job.Status != JobStatusCompleted || job.RevenueStatus != storage.RevenueStatusPending {
+		t.Fatalf("Expected a completed job pending revenue, got %s pending %q", job.Status, job.RevenueStatus)
+	}
+	if recorded, err := jobService.RecordPendingRevenue(ctx); recorded != 0 || err == nil {
+		t.Errorf("Expected nothing recorded while the rollups fail, got %d and %v", recorded, err)
 	}
 
-	// Check ride count
-	expectedRideCount := 1
-	if revenue["ride_count"].(int) != expectedRideCount {
-		t.Errorf("Expected %d rides, got %d", expectedRideCount, revenue["ride_count"].(int))
+	revenue.fail = nil
+	for i := 0; i < 2; i++ {
+		recorded, err := jobService.RecordPendingRevenue(ctx)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if expected := 1 - i; recorded != expected {
+			t.Errorf("Expected %d jobs recorded, got %d", expected, recorded)
+		}
 	}
 
-	// Check delivery count
-	expectedDeliveryCount := 1
-	if revenue["delivery_count"].(int) != expectedDeliveryCount {
-		t.Errorf("Expected %d deliveries, got %d", expectedDeliveryCount, revenue["delivery_count"].(int))
+	updated, _ := jobService.GetJob(ctx, job.ID)
+	if updated.RevenueStatus != "" {
+		t.Errorf("Expected the pending revenue status cleared, got %q", updated.RevenueStatus)
+	}
+	summary, _ := jobService.GetRevenue(ctx, RevenueQuery{})
+	if summary.CompletedJobs != 1 || math.Abs(summary.FareRevenue-job.FareAmount) > 1e-9 {
+		t.Errorf("Expected the ride counted once, got %+v", summary.RevenueSummary)
 	}
 }
 
-func TestJobService_GetRevenue_NoCompletedJobs(t *testing.T) {
-	jobStorage := storage.NewMemoryJobStorage()
-	mockFleetClient := NewMockFleetClient()
-	jobService := NewJobService(jobStorage, mockFleetClient)
+func TestJobService_GetRevenue_GroupBy(t *testing.T) {
+	jobService := NewJobService(storage.NewMemoryJobStorage(), NewMockFleetClient())
 	ctx := context.Background()
+	day := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	jobService.recordRevenue(ctx, finishedJob("ride-1", "ride", "us-west-2", "vehicle-1", 10, day.Add(9*time.Hour)))
+	jobService.recordRevenue(ctx, finishedJob("ride-2", "ride", "eu-west-1", "vehicle-2", 20, day.Add(9*time.Hour)))
+	jobService.recordRevenue(ctx, finishedJob("delivery-1", "delivery", "us-west-2", "vehicle-1", 30, day.Add(33*time.Hour)))
+
+	query := RevenueQuery{From: day, To: day.Add(48 * time.Hour)}
 
-	revenue, err := jobService.GetRevenue(ctx)
+	query.GroupBy = []string{RevenueGroupDay}
+	byDay, err := jobService.GetRevenue(ctx, query)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
+	if len(byDay.Groups) != 2 || byDay.Groups[0].Day != "2026-10-01" || byDay.Groups[0].FareRevenue != 30 || byDay.Groups[1].FareRevenue != 30 {
+		t.Errorf("Expected 30.00 on each day, got %+v", byDay.Groups)
+	}
+	if byDay.FareRevenue != 60 {
+		t.Errorf("Expected 60.00 in total, got %.2f", byDay.FareRevenue)
+	}
 
-	// All values should be zero
-	if revenue["total_revenue"].(float64) != 0.0 {
-		t.Errorf("Expected total revenue 0.0, got %.2f", revenue["total_revenue"].(float64))
+	query.GroupBy = []string{RevenueGroupRegion, RevenueGroupJobType}
+	byRegion, _ := jobService.GetRevenue(ctx, query)
+	if len(byRegion.Groups) != 3 {
+		t.Fatalf("Expected 3 region and job type groups, got %+v", byRegion.Groups)
+	}
+	if first := byRegion.Groups[0]; first.Region != "eu-west-1" || first.JobType != "ride" || first.Day != "" || first.FareRevenue != 20 {
+		t.Errorf("Expected eu-west-1 rides first, got %+v", first)
+	}
+
+	query.GroupBy = []string{RevenueGroupVehicle, RevenueGroupHour}
+	byVehicle, _ := jobService.GetRevenue(ctx, query)
+	if len(byVehicle.Groups) != 3 || byVehicle.Groups[0].Hour != "2026-10-01T09:00:00Z" || byVehicle.Groups[0].VehicleID != "vehicle-1" {
+		t.Errorf("Expected vehicle-1 at 09:00 first, got %+v", byVehicle.Groups)
+	}
+}
+
+func TestJobService_GetRevenue_FarePercentiles(t *testing.T) {
+	jobService := NewJobService(storage.NewMemoryJobStorage(), NewMockFleetClient())
+	ctx := context.Background()
+	at := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+
+	// Fares of $1.02, $2.02, ... $100.02
+	for i := 1; i <= 100; i++ {
+		jobService.recordRevenue(ctx, finishedJob(fmt.Sprintf("ride-%d", i), "ride", "us-west-2", "vehicle-1", float64(i)+0.02, at))
+	}
+
+	revenue, err := jobService.GetRevenue(ctx, RevenueQuery{From: at, To: at.Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
 	}
 
-	if revenue["completed_jobs"].(int) != 0 {
-		t.Errorf("Expected 0 completed jobs, got %d", revenue["completed_jobs"].(int))
+	// Each fare is reported as the middle of its 10 cent bin
+	expected := FarePercentiles{P50: 50.05, P90: 90.05, P95: 95.05, P99: 99.05}
+	if revenue.FarePercentiles != expected {
+		t.Errorf("Expected percentiles %+v, got %+v", expected, revenue.FarePercentiles)
 	}
 }
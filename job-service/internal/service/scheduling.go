@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"job-service/internal/storage"
+	"shared/routing"
+	"shared/tracing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// DefaultScheduleLeadTime is how long before a booking's pickup, on top of the
+	// time a vehicle needs to get there, it is released for dispatch
+	DefaultScheduleLeadTime = 10 * time.Minute
+	// maxBookingHorizon is how far ahead customers may book
+	maxBookingHorizon = 30 * 24 * time.Hour
+	// maxScheduledPickupETA caps the pickup drive allowed for when releasing a booking,
+	// and is assumed when no vehicle is around to estimate it from
+	maxScheduledPickupETA = 45 * time.Minute
+)
+
+// ErrInvalidSchedule is returned for booking times in the past or too far ahead
+var ErrInvalidSchedule = errors.New("invalid scheduled time")
+
+// ErrBookingNotFound is returned when a customer has no such upcoming booking
+var ErrBookingNotFound = errors.New("booking not found")
+
+// SetScheduleLeadTime sets how long before pickup, plus the pickup drive, bookings are dispatched
+func (j *JobService) SetScheduleLeadTime(leadTime time.Duration) {
+	j.leadTime = leadTime
+}
+
+// ScheduleRideJob books a ride for a future pickup time
+func (j *JobService) ScheduleRideJob(ctx context.Context, customerID, region string, pickupLat, pickupLng, destLat, destLng float64, scheduledFor time.Time) (*storage.Job, error) {
+	job := j.newRideJob(ctx, customerID, region, pickupLat, pickupLng, destLat, destLng)
+	if err := j.schedule(job, scheduledFor); err != nil {
+		return nil, err
+	}
+	return j.submitJob(ctx, job)
+}
+
+// ScheduleDeliveryJob books a delivery for a future pickup time
+func (j *JobService) ScheduleDeliveryJob(ctx context.Context, customerID, region string, pickupLat, pickupLng, destLat, destLng float64, details *storage.DeliveryDetails, scheduledFor time.Time) (*storage.Job, error) {
+	job := j.newDeliveryJob(ctx, customerID, region, pickupLat, pickupLng, destLat, destLng, details)
+	if err := j.schedule(job, scheduledFor); err != nil {
+		return nil, err
+	}
+	return j.submitJob(ctx, job)
+}
+
+// schedule holds a new job back until its pickup time. A booking already inside
+// the lead time stays pending and is dispatched straight away.
+func (j *JobService) schedule(job *storage.Job, scheduledFor time.Time) error {
+	now := time.Now()
+	if scheduledFor.Before(now) {
+		return fmt.Errorf("%w: %s is in the past", ErrInvalidSchedule, scheduledFor.Format(time.RFC3339))
+	}
+	if scheduledFor.After(now.Add(maxBookingHorizon)) {
+		return fmt.Errorf("%w: bookings can be made at most %s ahead", ErrInvalidSchedule, maxBookingHorizon)
+	}
+
+	scheduledFor = scheduledFor.UTC()
+	job.ScheduledFor = &scheduledFor
+	if scheduledFor.After(now.Add(j.leadTime)) {
+		job.Status = JobStatusScheduled
+	}
+	return nil
+}
+
+// PromoteScheduledJobs moves bookings to pending once their pickup is no further off
+// than the lead time plus the estimated drive of the nearest vehicle, so a vehicle
+// is dispatched in time to arrive early. It returns how many were promoted.
+func (j *JobService) PromoteScheduledJobs(ctx context.Context) (int, error) {
+	scheduledJobs, err := j.storage.GetJobsByStatus(ctx, JobStatusScheduled)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	promoted := 0
+	for _, job := range scheduledJobs {
+		if job.ScheduledFor != nil {
+			// Only bookings this close can be due, so the others aren't worth routing
+			releaseBy := job.ScheduledFor.Add(-j.leadTime)
+			if now.Before(releaseBy.Add(-maxScheduledPickupETA)) {
+				continue
+			}
+			if now.Before(releaseBy.Add(-j.scheduledPickupETA(ctx, job))) {
+				continue
+			}
+		}
+
+		released, err := j.transitionJob(ctx, job.ID, JobStatusPending, nil, nil)
+		if err != nil {
+			// Most likely cancelled in the meantime
+			fmt.Printf("Failed to promote scheduled job %s: %v\n", job.ID, err)
+			continue
+		}
+
+		// Stream job release event
+		if j.streamer != nil {
+			j.streamer.StreamJobEvent(ctx, "released", released)
+		}
+
+		fmt.Printf("Scheduled job %s released for dispatch, pickup at %s\n", job.ID, released.ScheduledFor.Format(time.RFC3339))
+		promoted++
+	}
+
+	return promoted, nil
+}
+
+// scheduledPickupETA estimates how long the nearest suitable vehicle would take to
+// reach a booking's pickup right now
+func (j *JobService) scheduledPickupETA(ctx context.Context, job *storage.Job) time.Duration {
+	eta, err := j.pickupETA(ctx, job)
+	if err != nil {
+		return maxScheduledPickupETA
+	}
+	return min(eta, maxScheduledPickupETA)
+}
+
+// pickupETA estimates the drive of the nearest suitable vehicle to a job's pickup
+func (j *JobService) pickupETA(ctx context.Context, job *storage.Job) (time.Duration, error) {
+	vehicle, err := j.fleetClient.FindNearestVehicle(ctx, job.Region, job.PickupLat, job.PickupLng, job.EstimatedDistanceKm)
+	if err != nil {
+		return 0, err
+	}
+
+	from := routing.Point{Lat: vehicle.LocationLat, Lng: vehicle.LocationLng}
+	pickup := routing.Point{Lat: job.PickupLat, Lng: job.PickupLng}
+	estimate, err := j.router.Route(ctx, from, pickup)
+	if err != nil {
+		estimate, _ = routing.NewHaversineRouter().Route(ctx, from, pickup)
+	}
+
+	return estimate.Duration, nil
+}
+
+// GetUpcomingBookings returns a customer's bookings that have not been picked up
+// yet, soonest first
+func (j *JobService) GetUpcomingBookings(ctx context.Context, customerID string) ([]*storage.Job, error) {
+	jobs, err := j.storage.GetJobsByCustomer(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	var upcoming []*storage.Job
+	for _, job := range jobs {
+		if isUpcomingBooking(job) {
+			upcoming = append(upcoming, job)
+		}
+	}
+
+	sort.Slice(upcoming, func(a, b int) bool {
+		return upcoming[a].ScheduledFor.Before(*upcoming[b].ScheduledFor)
+	})
+
+	return upcoming, nil
+}
+
+// CancelBooking cancels one of a customer's upcoming bookings. Bookings not yet
+// dispatched are cancelled free of charge.
+func (j *JobService) CancelBooking(ctx context.Context, customerID, jobID string) (*storage.Job, error) {
+	trace.SpanFromContext(ctx).SetAttributes(tracing.JobID(jobID))
+
+	job, err := j.storage.GetJob(ctx, jobID)
+	if err != nil || job.CustomerID != customerID || !isUpcomingBooking(job) {
+		return nil, fmt.Errorf("%w: %s for customer %s", ErrBookingNotFound, jobID, customerID)
+	}
+
+	return j.CancelJob(ctx, jobID, CancelReasonCustomer)
+}
+
+// isUpcomingBooking reports whether a job was booked ahead and is still to be picked up
+func isUpcomingBooking(job *storage.Job) bool {
+	if job.ScheduledFor == nil {
+		return false
+	}
+	switch job.Status {
+	case JobStatusScheduled, JobStatusPending, JobStatusAssigned:
+		return true
+	default:
+		return false
+	}
+}
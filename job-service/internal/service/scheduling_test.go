@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"job-service/internal/fleet"
+	"job-service/internal/storage"
+)
+
+func TestJobService_ScheduleRideJob(t *testing.T) {
+	jobStorage := storage.NewMemoryJobStorage()
+	mockFleetClient := NewMockFleetClient()
+	jobService := NewJobService(jobStorage, mockFleetClient)
+	ctx := context.Background()
+
+	mockFleetClient.AddVehicle(&fleet.Vehicle{
+		ID:             "vehicle-1",
+		Region:         "us-west-2",
+		Status:         "available",
+		BatteryRangeKm: 200.0,
+		LocationLat:    37.7749,
+		LocationLng:    -122.4194,
+	})
+
+	// A booking for tomorrow is held back even though a vehicle is free
+	tomorrow := time.Now().Add(24 * time.Hour)
+	job, err := jobService.ScheduleRideJob(ctx, "customer-123", "us-west-2", 37.7749, -122.4194, 37.7849, -122.4094, tomorrow)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if job.Status != JobStatusScheduled {
+		t.Errorf("Expected status 'scheduled', got %s", job.Status)
+	}
+	if job.AssignedVehicleID != nil {
+		t.Errorf("Expected no vehicle for a booking, got %s", *job.AssignedVehicleID)
+	}
+	if job.FareAmount <= 0 {
+		t.Error("Expected the booking to be priced up front")
+	}
+
+	// One inside the lead time is dispatched straight away
+	soon, err := jobService.ScheduleRideJob(ctx, "customer-123", "us-west-2", 37.7749, -122.4194, 37.7849, -122.4094, time.Now().Add(5*time.Minute))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if soon.Status != JobStatusAssigned || soon.ScheduledFor == nil {
+		t.Errorf("Expected an assigned booking, got status %s", soon.Status)
+	}
+
+	if _, err := jobService.ScheduleRideJob(ctx, "customer-123", "us-west-2", 37.7749, -122.4194, 37.7849, -122.4094, time.Now().Add(-time.Minute)); !errors.Is(err, ErrInvalidSchedule) {
+		t.Errorf("Expected ErrInvalidSchedule for a past time, got %v", err)
+	}
+	if _, err := jobService.ScheduleRideJob(ctx, "customer-123", "us-west-2", 37.7749, -122.4194, 37.7849, -122.4094, time.Now().Add(60*24*time.Hour)); !errors.Is(err, ErrInvalidSchedule) {
+		t.Errorf("Expected ErrInvalidSchedule beyond the booking horizon, got %v", err)
+	}
+}
+
+func TestJobService_PromoteScheduledJobs(t *testing.T) {
+	jobStorage := storage.NewMemoryJobStorage()
+	mockFleetClient := NewMockFleetClient()
+	jobService := NewJobService(jobStorage, mockFleetClient)
+	ctx := context.Background()
+
+	// The only vehicle is ~20km from the pickup, about 40 minutes away
+	mockFleetClient.AddVehicle(&fleet.Vehicle{
+		ID:             "vehicle-1",
+		Region:         "us-west-2",
+		Status:         "available",
+		BatteryRangeKm: 200.0,
+		LocationLat:    37.9549,
+		LocationLng:    -122.4194,
+	})
+
+	pickupIn := func(d time.Duration) *storage.Job {
+		job, err := jobService.ScheduleRideJob(ctx, "customer-123", "us-west-2", 37.7749, -122.4194, 37.7849, -122.4094, time.Now().Add(d))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return job
+	}
+	dueSoon := pickupIn(30 * time.Minute) // inside lead time plus the drive
+	later := pickupIn(2 * time.Hour)      // not yet due
+	tomorrow := pickupIn(24 * time.Hour)  // nowhere near due
+
+	promoted, err := jobService.PromoteScheduledJobs(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if promoted != 1 {
+		t.Errorf("Expected 1 booking promoted, got %d", promoted)
+	}
+
+	for _, tt := range []struct {
+		job    *storage.Job
+		status string
+	}{
+		{dueSoon, JobStatusPending},
+		{later, JobStatusScheduled},
+		{tomorrow, JobStatusScheduled},
+	} {
+		current, _ := jobService.GetJob(ctx, tt.job.ID)
+		if current.Status != tt.status {
+			t.Errorf("Expected job %s to be %s, got %s", tt.job.ID, tt.status, current.Status)
+		}
+	}
+
+	// With a vehicle right at the pickup, the same booking waits for the lead time
+	mockFleetClient.vehicles["vehicle-1"].LocationLat = 37.7749
+	nearby := pickupIn(30 * time.Minute)
+	jobService.PromoteScheduledJobs(ctx)
+	if current, _ := jobService.GetJob(ctx, nearby.ID); current.Status != JobStatusScheduled {
+		t.Errorf("Expected booking with a nearby vehicle to stay scheduled, got %s", current.Status)
+	}
+}
+
+func TestJobService_UpcomingBookings(t *testing.T) {
+	jobStorage := storage.NewMemoryJobStorage()
+	mockFleetClient := NewMockFleetClient()
+	jobService := NewJobService(jobStorage, mockFleetClient)
+	ctx := context.Background()
+
+	dayAfter, _ := jobService.ScheduleRideJob(ctx, "customer-123", "us-west-2", 37.7749, -122.4194, 37.7849, -122.4094, time.Now().Add(48*time.Hour))
+	tomorrow, _ := jobService.ScheduleDeliveryJob(ctx, "customer-123", "us-west-2", 37.7749, -122.4194, 37.7849, -122.4094, &storage.DeliveryDetails{RestaurantName: "Deli"}, time.Now().Add(24*time.Hour))
+	jobService.ScheduleRideJob(ctx, "customer-456", "us-west-2", 37.7749, -122.4194, 37.7849, -122.4094, time.Now().Add(24*time.Hour))
+	jobService.CreateRideJob(ctx, "customer-123", "us-west-2", 37.7749, -122.4194, 37.7849, -122.4094) // not a booking
+
+	bookings, err := jobService.GetUpcomingBookings(ctx, "customer-123")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(bookings) != 2 || bookings[0].ID != tomorrow.ID || bookings[1].ID != dayAfter.ID {
+		t.Fatalf("Expected customer's two bookings soonest first, got %v", bookings)
+	}
+
+	// Customers can only cancel their own bookings
+	if _, err := jobService.CancelBooking(ctx, "customer-456", tomorrow.ID); !errors.Is(err, ErrBookingNotFound) {
+		t.Errorf("Expected ErrBookingNotFound for another customer's booking, got %v", err)
+	}
+
+	cancelled, err := jobService.CancelBooking(ctx, "customer-123", tomorrow.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cancelled.Status != JobStatusCancelled || cancelled.CancellationFee != 0 {
+		t.Errorf("Expected a free cancellation, got status %s fee %.2f", cancelled.Status, cancelled.CancellationFee)
+	}
+
+	bookings, _ = jobService.GetUpcomingBookings(ctx, "customer-123")
+	if len(bookings) != 1 || bookings[0].ID != dayAfter.ID {
+		t.Errorf("Expected only the remaining booking, got %v", bookings)
+	}
+	if _, err := jobService.CancelBooking(ctx, "customer-123", tomorrow.ID); !errors.Is(err, ErrBookingNotFound) {
+		t.Errorf("Expected ErrBookingNotFound once cancelled, got %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"job-service/internal/storage"
+	"shared/tracing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Stop types
+const (
+	StopTypePickup   = "pickup"   // collect the passenger or order
+	StopTypeWaypoint = "waypoint" // pass through, e.g. an intermediate stop on a ride
+	StopTypeDropoff  = "dropoff"  // drop off the passenger or an order
+)
+
+// maxStops bounds how many stops a single job may have
+const maxStops = 10
+
+// ErrInvalidStops is returned for stop lists a vehicle cannot serve
+var ErrInvalidStops = errors.New("invalid stops")
+
+// CreateMultiStopJob creates a ride or delivery visiting stops in order, optionally
+// booked ahead for scheduledFor. Stops without a type are taken to be the pickup
+// when first, the final drop-off when last and waypoints in between.
+func (j *JobService) CreateMultiStopJob(ctx context.Context, jobType, customerID, region string, stops []storage.Stop, details *storage.DeliveryDetails, scheduledFor *time.Time) (*storage.Job, error) {
+	if jobType != "ride" && jobType != "delivery" {
+		return nil, fmt.Errorf("invalid job type %q", jobType)
+	}
+
+	stops, err := normalizeStops(stops)
+	if err != nil {
+		return nil, err
+	}
+
+	job := j.newJob(ctx, jobType, customerID, region, stops)
+	job.DeliveryDetails = details
+
+	if scheduledFor != nil {
+		if err := j.schedule(job, *scheduledFor); err != nil {
+			return nil, err
+		}
+	}
+
+	return j.submitJob(ctx, job)
+}
+
+// normalizeStops validates a stop list and fills in missing stop types
+func normalizeStops(stops []storage.Stop) ([]storage.Stop, error) {
+	if len(stops) < 2 {
+		return nil, fmt.Errorf("%w: a job needs a pickup and a destination", ErrInvalidStops)
+	}
+	if len(stops) > maxStops {
+		return nil, fmt.Errorf("%w: at most %d stops are allowed, got %d", ErrInvalidStops, maxStops, len(stops))
+	}
+
+	normalized := make([]storage.Stop, len(stops))
+	for i, stop := range stops {
+		if stop.Type == "" {
+			switch i {
+			case 0:
+				stop.Type = StopTypePickup
+			case len(stops) - 1:
+				stop.Type = StopTypeDropoff
+			default:
+				stop.Type = StopTypeWaypoint
+			}
+		}
+
+		switch stop.Type {
+		case StopTypePickup, StopTypeWaypoint, StopTypeDropoff:
+		default:
+			return nil, fmt.Errorf("%w: unknown stop type %q", ErrInvalidStops, stop.Type)
+		}
+
+		// Progress is reported by the vehicle, never by the customer
+		stop.LegDistanceKm = 0
+		stop.ArrivedAt = nil
+		normalized[i] = stop
+	}
+
+	if normalized[0].Type != StopTypePickup {
+		return nil, fmt.Errorf("%w: the first stop must be a pickup", ErrInvalidStops)
+	}
+	if normalized[len(normalized)-1].Type != StopTypeDropoff {
+		return nil, fmt.Errorf("%w: the last stop must be a drop-off", ErrInvalidStops)
+	}
+
+	return normalized, nil
+}
+
+// routeLegs estimates the road distance and driving time of each leg between consecutive stops
+func (j *JobService) routeLegs(ctx context.Context, stops []storage.Stop) {
+	for i := 1; i < len(stops); i++ {
+		estimate := j.tripEstimate(ctx, stops[i-1].Lat, stops[i-1].Lng, stops[i].Lat, stops[i].Lng)
+		stops[i].LegDistanceKm = estimate.DistanceKm
+		stops[i].LegMinutes = estimate.Minutes()
+	}
+}
+
+// ArriveAtStop records that the vehicle serving a job has reached one of its stops.
+// The pickup and final stop are also recorded when the pickup is confirmed and the
+// job completed; reporting a stop twice keeps the first arrival time.
+func (j *JobService) ArriveAtStop(ctx context.Context, jobID string, stopIndex int) (*storage.Job, error) {
+	trace.SpanFromContext(ctx).SetAttributes(tracing.JobID(jobID))
+
+	for attempt := 0; ; attempt++ {
+		job, err := j.storage.GetJob(ctx, jobID)
+		if err != nil {
+			return nil, err
+		}
+
+		if job.Status != JobStatusAssigned && job.Status != JobStatusInProgress {
+			return nil, fmt.Errorf("%w: job %s is %s", ErrInvalidTransition, jobID, job.Status)
+		}
+		if stopIndex < 0 || stopIndex >= len(job.Stops) {
+			return nil, fmt.Errorf("%w: job %s has no stop %d", ErrInvalidStops, jobID, stopIndex)
+		}
+		if job.Stops[stopIndex].ArrivedAt != nil {
+			return job, nil
+		}
+
+		// Write a copy so the storage layer can reject it atomically if it went stale
+		updated := *job
+		recordArrival(&updated, stopIndex, time.Now())
+
+		err = j.storage.UpdateJob(ctx, &updated)
+		if err == nil {
+			// Stream stop arrival event
+			if j.streamer != nil {
+				j.streamer.StreamJobEvent(ctx, "stop_arrived", &updated)
+			}
+			return &updated, nil
+		}
+		if !errors.Is(err, storage.ErrVersionConflict) || attempt+1 >= maxTransitionAttempts {
+			return nil, err
+		}
+	}
+}
+
+// recordArrival stamps a stop's arrival time unless it was already reported. The
+// stops are copied first so the stored job is never modified in place.
+func recordArrival(job *storage.Job, stopIndex int, at time.Time) {
+	if stopIndex < 0 || stopIndex >= len(job.Stops) || job.Stops[stopIndex].ArrivedAt != nil {
+		return
+	}
+
+	stops := make([]storage.Stop, len(job.Stops))
+	copy(stops, job.Stops)
+	stops[stopIndex].ArrivedAt = &at
+	job.Stops = stops
+}
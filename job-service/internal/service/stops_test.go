@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"job-service/internal/fleet"
+	"job-service/internal/storage"
+	"shared/routing"
+)
+
+func TestNormalizeStops(t *testing.T) {
+	stops, err := normalizeStops([]storage.Stop{
+		{Lat: 45.50, Lng: -122.68},
+		{Lat: 45.51, Lng: -122.67},
+		{Lat: 45.52, Lng: -122.66, Type: StopTypeDropoff},
+		{Lat: 45.53, Lng: -122.65, ArrivedAt: &time.Time{}},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []string{StopTypePickup, StopTypeWaypoint, StopTypeDropoff, StopTypeDropoff}
+	for i, stop := range stops {
+		if stop.Type != expected[i] {
+			t.Errorf("Expected stop %d to be a %s, got %s", i, expected[i], stop.Type)
+		}
+	}
+	if stops[3].ArrivedAt != nil {
+		t.Error("Expected customer-supplied arrival times to be dropped")
+	}
+
+	invalid := [][]storage.Stop{
+		{{Lat: 45.50, Lng: -122.68}},
+		{{Lat: 45.50, Lng: -122.68, Type: StopTypeDropoff}, {Lat: 45.52, Lng: -122.66}},
+		{{Lat: 45.50, Lng: -122.68}, {Lat: 45.52, Lng: -122.66, Type: StopTypeWaypoint}},
+		{{Lat: 45.50, Lng: -122.68}, {Lat: 45.51, Lng: -122.67, Type: "detour"}, {Lat: 45.52, Lng: -122.66}},
+		make([]storage.Stop, maxStops+1),
+	}
+	for i, stops := range invalid {
+		if _, err := normalizeStops(stops); !errors.Is(err, ErrInvalidStops) {
+			t.Errorf("Case %d: expected ErrInvalidStops, got %v", i, err)
+		}
+	}
+}
+
+func TestJobService_CreateMultiStopJob(t *testing.T) {
+	jobService := NewJobService(storage.NewMemoryJobStorage(), NewMockFleetClient())
+	jobService.SetRouter(fixedRouter{estimate: routing.Estimate{DistanceKm: 4, Duration: 8 * time.Minute}})
+	ctx := context.Background()
+
+	job, err := jobService.CreateMultiStopJob(ctx, "ride", "customer-1", "us-west-2", []storage.Stop{
+		{Lat: 45.50, Lng: -122.68},
+		{Lat: 45.51, Lng: -122.67, Note: "pick up a friend"},
+		{Lat: 45.52, Lng: -122.66},
+	}, nil, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if job.PickupLat != 45.50 || job.DestinationLat != 45.52 {
+		t.Errorf("Expected pickup and destination to mirror the first and last stops, got %f and %f", job.PickupLat, job.DestinationLat)
+	}
+	if job.Stops[0].LegDistanceKm != 0 || job.Stops[1].LegDistanceKm != 4 || job.Stops[2].LegDistanceKm != 4 {
+		t.Errorf("Expected each leg routed at 4km, got %+v", job.Stops)
+	}
+	if job.EstimatedDistanceKm != 8 {
+		t.Errorf("Expected 8km over both legs, got %f", job.EstimatedDistanceKm)
+	}
+
+	pricing := jobService.PricingRules().Defaults
+	expected := pricing.RideBaseFare + 8*pricing.RidePerKm + pricing.PerStopFee
+	if job.StopFare != pricing.PerStopFee || job.FareAmount != expected {
+		t.Errorf("Expected fare %.2f with one extra stop, got %.2f (stop fare %.2f)", expected, job.FareAmount, job.StopFare)
+	}
+
+	if _, err := jobService.CreateMultiStopJob(ctx, "ride", "customer-1", "us-west-2", job.Stops[:1], nil, nil); !errors.Is(err, ErrInvalidStops) {
+		t.Errorf("Expected ErrInvalidStops for a single stop, got %v", err)
+	}
+}
+
+func TestJobService_StopArrivals(t *testing.T) {
+	mockFleetClient := NewMockFleetClient()
+	jobService := NewJobService(storage.NewMemoryJobStorage(), mockFleetClient)
+	ctx := context.Background()
+
+	mockFleetClient.AddVehicle(&fleet.Vehicle{
+		ID:             "vehicle-1",
+		Region:         "us-west-2",
+		Status:         "available",
+		BatteryRangeKm: 200.0,
+	})
+
+	job, err := jobService.CreateMultiStopJob(ctx, "delivery", "customer-1", "us-west-2", []storage.Stop{
+		{Lat: 45.50, Lng: -122.68},
+		{Lat: 45.51, Lng: -122.67, Type: StopTypeDropoff},
+		{Lat: 45.52, Lng: -122.66},
+	}, &storage.DeliveryDetails{RestaurantName: "Deli"}, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if job.Status != JobStatusAssigned {
+		t.Fatalf("Expected job to be assigned, got %s", job.Status)
+	}
+
+	if _, err := jobService.ConfirmPickup(ctx, job.ID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	arrived, err := jobService.ArriveAtStop(ctx, job.ID, 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	first := *arrived.Stops[1].ArrivedAt
+
+	// A repeated report keeps the original time
+	again, _ := jobService.ArriveAtStop(ctx, job.ID, 1)
+	if !again.Stops[1].ArrivedAt.Equal(first) {
+		t.Errorf("Expected arrival time %v to be kept, got %v", first, again.Stops[1].ArrivedAt)
+	}
+	if _, err := jobService.ArriveAtStop(ctx, job.ID, 3); !errors.Is(err, ErrInvalidStops) {
+		t.Errorf("Expected ErrInvalidStops for a missing stop, got %v", err)
+	}
+
+	if err := jobService.CompleteJob(ctx, job.ID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	completed, _ := jobService.GetJob(ctx, job.ID)
+	for i, stop := range completed.Stops {
+		if stop.ArrivedAt == nil {
+			t.Errorf("Expected arrival recorded at stop %d", i)
+		}
+	}
+	if !completed.Stops[2].ArrivedAt.Equal(*completed.CompletedAt) {
+		t.Errorf("Expected the final stop reached at completion")
+	}
+
+	if _, err := jobService.ArriveAtStop(ctx, job.ID, 1); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected ErrInvalidTransition once completed, got %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+)
+
+const (
+	// surgeZoneDegrees is the side of the square zones supply and demand are compared
+	// over, roughly 2km
+	surgeZoneDegrees = 0.02
+	// minSurgeMultiplier is the multiplier below which a zone counts as not surging
+	minSurgeMultiplier = 1.01
+)
+
+// SurgePricer tracks a smoothed surge multiplier per zone from the jobs waiting there
+// and the vehicles free to take them
+type SurgePricer struct {
+	mu          sync.RWMutex
+	multipliers map[string]float64 // zone -> multiplier, only for zones that are surging
+}
+
+// NewSurgePricer creates a surge pricer with no zone surging
+func NewSurgePricer() *SurgePricer {
+	return &SurgePricer{multipliers: make(map[string]float64)}
+}
+
+// surgeZone names the zone of a region a point falls in
+func surgeZone(region string, lat, lng float64) string {
+	return fmt.Sprintf("%s:%d:%d", region, int(math.Floor(lat/surgeZoneDegrees)), int(math.Floor(lng/surgeZoneDegrees)))
+}
+
+// Multiplier returns the surge multiplier for a pickup, 1 where there is no surge
+func (s *SurgePricer) Multiplier(region string, lat, lng float64) float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if multiplier, ok := s.multipliers[surgeZone(region, lat, lng)]; ok {
+		return math.Round(multiplier*100) / 100
+	}
+	return 1.0
+}
+
+// Update blends a new count of pending jobs and available vehicles per zone into the
+// multipliers. Zones with more jobs than vehicles surge in proportion to the excess,
+// up to the configured cap; zones that calmed down drift back to 1.
+func (s *SurgePricer) Update(demand, supply map[string]int, pricing *PricingConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zones := make(map[string]bool)
+	for zone := range demand {
+		zones[zone] = true
+	}
+	for zone := range s.multipliers {
+		zones[zone] = true
+	}
+
+	for zone := range zones {
+		reading := surgeReading(demand[zone], supply[zone], pricing)
+
+		previous, ok := s.multipliers[zone]
+		if !ok {
+			previous = 1.0
+		}
+		multiplier := previous + pricing.SurgeSmoothing*(reading-previous)
+
+		if multiplier < minSurgeMultiplier {
+			delete(s.multipliers, zone)
+		} else {
+			s.multipliers[zone] = multiplier
+		}
+	}
+}
+
+// surgeReading is the multiplier a single count of pending jobs and available vehicles calls for
+func surgeReading(pending, available int, pricing *PricingConfig) float64 {
+	if pending <= available {
+		return 1.0
+	}
+
+	excessPerVehicle := float64(pending-available) / float64(max(available, 1))
+	return math.Min(1+pricing.SurgeSensitivity*excessPerVehicle, pricing.SurgeMaxMultiplier)
+}
+
+// UpdateSurge recounts pending jobs and available vehicles per zone and updates the
+// surge multipliers new jobs are priced with
+func (j *JobService) UpdateSurge(ctx context.Context) error {
+	pendingJobs, err := j.storage.GetJobsByStatus(ctx, JobStatusPending)
+	if err != nil {
+		return err
+	}
+
+	vehicles, err := j.fleetClient.GetAllVehicles(ctx)
+	if err != nil {
+		return err
+	}
+
+	demand := make(map[string]int)
+	for _, job := range pendingJobs {
+		demand[surgeZone(job.Region, job.PickupLat, job.PickupLng)]++
+	}
+
+	supply := make(map[string]int)
+	for _, vehicle := range vehicles {
+		if vehicle.Status == "available" {
+			supply[surgeZone(vehicle.Region, vehicle.LocationLat, vehicle.LocationLng)]++
+		}
+	}
+
+	j.surge.Update(demand, supply, &j.PricingRules().Defaults)
+	return nil
+}
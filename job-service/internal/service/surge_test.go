@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"job-service/internal/fleet"
+	"job-service/internal/storage"
+)
+
+func TestPricingConfig_CalculateFare_Surge(t *testing.T) {
+	pricing := DefaultPricingConfig()
+
+	job := &storage.Job{
+		JobType:             "ride",
+		EstimatedDistanceKm: 5.0,
+		SurgeMultiplier:     1.5,
+	}
+	pricing.CalculateFare(job)
+
+	// Half as much again on top of the $11.50 fare
+	if math.Abs(job.SurgeFare-5.75) > 1e-9 || math.Abs(job.FareAmount-17.25) > 1e-9 {
+		t.Errorf("Expected 5.75 surge for a 17.25 fare, got %.2f for %.2f", job.SurgeFare, job.FareAmount)
+	}
+
+	job.SurgeMultiplier = 0
+	pricing.CalculateFare(job)
+	if job.SurgeFare != 0 || job.FareAmount != 11.50 {
+		t.Errorf("Expected no surge on an 11.50 fare, got %.2f for %.2f", job.SurgeFare, job.FareAmount)
+	}
+}
+
+func TestSurgePricer_Update(t *testing.T) {
+	pricing := DefaultPricingConfig()
+	surge := NewSurgePricer()
+	zone := surgeZone("us-west-2", 45.52, -122.68)
+
+	// Three jobs waiting for one vehicle reads as 1.5x, half of which is blended in
+	surge.Update(map[string]int{zone: 3}, map[string]int{zone: 1}, pricing)
+	if got := surge.Multiplier("us-west-2", 45.52, -122.68); got != 1.25 {
+		t.Errorf("Expected 1.25x after one reading, got %.2fx", got)
+	}
+	if got := surge.Multiplier("us-west-2", 45.60, -122.68); got != 1 {
+		t.Errorf("Expected no surge in a quiet zone, got %.2fx", got)
+	}
+
+	// A swamped zone climbs towards the cap but never past it
+	for i := 0; i < 10; i++ {
+		surge.Update(map[string]int{zone: 100}, nil, pricing)
+	}
+	if got := surge.Multiplier("us-west-2", 45.52, -122.68); got != pricing.SurgeMaxMultiplier {
+		t.Errorf("Expected the %.2fx cap, got %.2fx", pricing.SurgeMaxMultiplier, got)
+	}
+
+	// Once vehicles catch up the surge decays back to nothing
+	for i := 0; i < 10; i++ {
+		surge.Update(nil, map[string]int{zone: 2}, pricing)
+	}
+	if got := surge.Multiplier("us-west-2", 45.52, -122.68); got != 1 {
+		t.Errorf("Expected the surge to have decayed, got %.2fx", got)
+	}
+}
+
+func TestJobService_UpdateSurge_PricesNewJobs(t *testing.T) {
+	jobStorage := storage.NewMemoryJobStorage()
+	mockFleetClient := NewMockFleetClient()
+	jobService := NewJobService(jobStorage, mockFleetClient)
+	jobService.SetQuoteSigningKey([]byte("test-quote-key"))
+	ctx := context.Background()
+
+	// The only vehicle nearby is busy, so riders queue up
+	mockFleetClient.AddVehicle(&fleet.Vehicle{
+		ID:             "vehicle-1",
+		Region:         "us-west-2",
+		Status:         "busy",
+		BatteryLevel:   80,
+		BatteryRangeKm: 200.0,
+		LocationLat:    45.52,
+		LocationLng:    -122.68,
+		VehicleType:    "sedan",
+	})
+	for i := 0; i < 4; i++ {
+		queued, err := jobService.CreateRideJob(ctx, "customer-1", "us-west-2", 45.52, -122.68, 45.54, -122.66)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if queued.SurgeMultiplier != 1 || queued.SurgeFare != 0 {
+			t.Errorf("Expected no surge before the zone was priced, got %.2fx", queued.SurgeMultiplier)
+		}
+	}
+
+	if err := jobService.UpdateSurge(ctx); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	quote, err := jobService.QuoteFare(ctx, "ride", "us-west-2", 45.52, -122.68, 45.54, -122.66, false, 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	job, err := jobService.CreateRideJob(ctx, "customer-2", "us-west-2", 45.52, -122.68, 45.54, -122.66)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Four jobs and no free vehicle read as 2x, half of which is blended in
+	if job.SurgeMultiplier != 1.5 || job.SurgeFare <= 0 {
+		t.Errorf("Expected a 1.5x surge, got %.2fx adding %.2f", job.SurgeMultiplier, job.SurgeFare)
+	}
+	if quote.SurgeMultiplier != job.SurgeMultiplier || math.Abs(quote.FareAmount-job.FareAmount) > 1e-9 {
+		t.Errorf("Expected the quote to match the booked fare %.2f at %.2fx, got %.2f at %.2fx",
+			job.FareAmount, job.SurgeMultiplier, quote.FareAmount, quote.SurgeMultiplier)
+	}
+
+	stored, _ := jobService.GetJob(ctx, job.ID)
+	if stored.SurgeMultiplier != 1.5 {
+		t.Errorf("Expected the surge recorded on the job, got %.2fx", stored.SurgeMultiplier)
+	}
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"job-service/internal/storage"
+)
+
+// Vehicle event types pushed to subscribed vehicles
+const (
+	VehicleEventAssigned  = "assigned"
+	VehicleEventCancelled = "cancelled"
+	VehicleEventUpdated   = "updated"
+	// VehicleEventResync tells a vehicle it may have missed events and should
+	// poll its jobs instead of relying on the stream alone
+	VehicleEventResync = "resync"
+)
+
+const (
+	// vehicleEventHistorySize is how many recent events are kept per vehicle for resuming streams
+	vehicleEventHistorySize = 32
+	// vehicleEventBufferSize bounds how far a subscriber may fall behind before it is dropped
+	vehicleEventBufferSize = 16
+)
+
+// VehicleEvent is a change to one of a vehicle's jobs. IDs increase monotonically
+// across all vehicles, so a reconnecting vehicle can resume after the last ID it saw.
+// The high 32 bits of an ID are the epoch of the hub that published it, so IDs from
+// before a restart, or from another instance, are never mistaken for this hub's.
+type VehicleEvent struct {
+	ID        int64        `json:"id"`
+	Type      string       `json:"type"`             // assigned, cancelled, updated, resync
+	Change    string       `json:"change,omitempty"` // job event behind an update: picked_up, completed, failed, requeued
+	VehicleID string       `json:"vehicle_id"`
+	Job       *storage.Job `json:"job,omitempty"`
+	Timestamp time.Time    `json:"timestamp"`
+}
+
+// VehicleEventHub fans job changes out to the vehicles they concern. Events only
+// reach vehicles subscribed to this instance; vehicles keep polling as a fallback.
+type VehicleEventHub struct {
+	mu          sync.Mutex
+	epoch       int64 // identifies this hub's event IDs
+	lastID      int64
+	history     map[string][]VehicleEvent
+	evictedUpTo map[string]int64 // highest event ID dropped from each vehicle's history
+	subscribers map[string]map[chan VehicleEvent]struct{}
+}
+
+// NewVehicleEventHub creates an empty vehicle event hub, whose epoch is the time it
+// was created
+func NewVehicleEventHub() *VehicleEventHub {
+	epoch := max(time.Now().UnixMilli()&math.MaxInt32, 1)
+	return &VehicleEventHub{
+		epoch:       epoch,
+		lastID:      epoch << 32,
+		history:     make(map[string][]VehicleEvent),
+		evictedUpTo: make(map[string]int64),
+		subscribers: make(map[string]map[chan VehicleEvent]struct{}),
+	}
+}
+
+// Publish records an event for a vehicle and delivers it to its subscribers.
+// A subscriber too slow to keep up is disconnected so it resumes from history.
+func (h *VehicleEventHub) Publish(vehicleID, eventType, change string, job *storage.Job) VehicleEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastID++
+	event := VehicleEvent{
+		ID:        h.lastID,
+		Type:      eventType,
+		Change:    change,
+		VehicleID: vehicleID,
+		Timestamp: time.Now().UTC(),
+	}
+	if job != nil {
+		snapshot := *job
+		event.Job = &snapshot
+	}
+
+	history := append(h.history[vehicleID], event)
+	if len(history) > vehicleEventHistorySize {
+		h.evictedUpTo[vehicleID] = history[0].ID
+		history = history[1:]
+	}
+	h.history[vehicleID] = history
+
+	for ch := range h.subscribers[vehicleID] {
+		select {
+		case ch <- event:
+		default:
+			delete(h.subscribers[vehicleID], ch)
+			close(ch)
+		}
+	}
+
+	return event
+}
+
+// VehicleSubscription is a vehicle's live feed of job events
+type VehicleSubscription struct {
+	// Replay holds the events missed since the ID the vehicle resumed from
+	Replay []VehicleEvent
+	// Resync is set when missed events could not be replayed, either because the
+	// vehicle is connecting fresh or because they are no longer retained
+	Resync bool
+	// ResumeID is the latest event ID at the time of subscribing
+	ResumeID int64
+	// Events delivers new events; it is closed when the subscriber is dropped or closed
+	Events <-chan VehicleEvent
+
+	close func()
+}
+
+// Close stops delivery to the subscription
+func (s *VehicleSubscription) Close() {
+	s.close()
+}
+
+// Subscribe registers a listener for a vehicle's events, replaying anything
+// published after lastEventID that is still retained
+func (h *VehicleEventHub) Subscribe(vehicleID string, lastEventID int64) *VehicleSubscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sub := &VehicleSubscription{ResumeID: h.lastID}
+
+	// An ID from another epoch, such as before a restart, or older than the retained
+	// history, can't be resumed from
+	if lastEventID>>32 == h.epoch && lastEventID <= h.lastID && lastEventID >= h.evictedUpTo[vehicleID] {
+		for _, event := range h.history[vehicleID] {
+			if event.ID > lastEventID {
+				sub.Replay = append(sub.Replay, event)
+			}
+		}
+	} else {
+		sub.Resync = true
+	}
+
+	ch := make(chan VehicleEvent, vehicleEventBufferSize)
+	if h.subscribers[vehicleID] == nil {
+		h.subscribers[vehicleID] = make(map[chan VehicleEvent]struct{})
+	}
+	h.subscribers[vehicleID][ch] = struct{}{}
+	sub.Events = ch
+
+	sub.close = func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		if _, ok := h.subscribers[vehicleID][ch]; ok {
+			delete(h.subscribers[vehicleID], ch)
+			close(ch)
+		}
+		if len(h.subscribers[vehicleID]) == 0 {
+			delete(h.subscribers, vehicleID)
+		}
+	}
+
+	return sub
+}
+
+// SubscribeVehicleEvents subscribes to changes to a vehicle's jobs
+func (j *JobService) SubscribeVehicleEvents(vehicleID string, lastEventID int64) *VehicleSubscription {
+	return j.vehicleEvents.Subscribe(vehicleID, lastEventID)
+}
+
+// notifyVehicle pushes a job change to the vehicle it concerns
+func (j *JobService) notifyVehicle(vehicleID *string, change string, job *storage.Job) {
+	if vehicleID == nil {
+		return
+	}
+
+	eventType := VehicleEventUpdated
+	switch change {
+	case "assigned":
+		eventType = VehicleEventAssigned
+	case "cancelled":
+		eventType = VehicleEventCancelled
+	}
+
+	j.vehicleEvents.Publish(*vehicleID, eventType, change, job)
+}
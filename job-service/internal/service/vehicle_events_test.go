@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"job-service/internal/fleet"
+	"job-service/internal/storage"
+)
+
+func TestVehicleEventHub_DeliversAndReplays(t *testing.T) {
+	hub := NewVehicleEventHub()
+
+	sub := hub.Subscribe("vehicle-1", 0)
+	defer sub.Close()
+	if !sub.Resync {
+		t.Error("Expected a fresh subscription to require a resync")
+	}
+
+	first := hub.Publish("vehicle-1", VehicleEventAssigned, "assigned", &storage.Job{ID: "ride-1"})
+	hub.Publish("vehicle-2", VehicleEventAssigned, "assigned", &storage.Job{ID: "ride-2"})
+	hub.Publish("vehicle-1", VehicleEventCancelled, "cancelled", &storage.Job{ID: "ride-1"})
+
+	received := <-sub.Events
+	if received.ID != first.ID || received.Job.ID != "ride-1" {
+		t.Errorf("Expected first event for ride-1, got %+v", received)
+	}
+	received = <-sub.Events
+	if received.Type != VehicleEventCancelled {
+		t.Errorf("Expected cancellation, got %s", received.Type)
+	}
+	select {
+	case event := <-sub.Events:
+		t.Errorf("Expected no events for other vehicles, got %+v", event)
+	default:
+	}
+
+	// Reconnecting after the first event replays only what came after it
+	resumed := hub.Subscribe("vehicle-1", first.ID)
+	defer resumed.Close()
+	if resumed.Resync {
+		t.Error("Expected resume to be possible")
+	}
+	if len(resumed.Replay) != 1 || resumed.Replay[0].Type != VehicleEventCancelled {
+		t.Errorf("Expected the cancellation to be replayed, got %+v", resumed.Replay)
+	}
+}
+
+func TestVehicleEventHub_ResyncWhenHistoryLost(t *testing.T) {
+	hub := NewVehicleEventHub()
+
+	first := hub.Publish("vehicle-1", VehicleEventUpdated, "picked_up", nil)
+	for i := 0; i < vehicleEventHistorySize+1; i++ {
+		hub.Publish("vehicle-1", VehicleEventUpdated, "picked_up", nil)
+	}
+
+	sub := hub.Subscribe("vehicle-1", first.ID)
+	defer sub.Close()
+	if !sub.Resync {
+		t.Error("Expected a resync once missed events were evicted")
+	}
+
+	// IDs from before a restart are ahead of this hub
+	restarted := NewVehicleEventHub()
+	restarted.epoch, restarted.lastID = hub.epoch+1, (hub.epoch+1)<<32
+	sub = restarted.Subscribe("vehicle-1", first.ID)
+	defer sub.Close()
+	if !sub.Resync {
+		t.Error("Expected a resync for an unknown event ID")
+	}
+
+	// Even once the restarted hub has published more events than the old one had
+	for i := 0; i < vehicleEventHistorySize+2; i++ {
+		restarted.Publish("vehicle-1", VehicleEventUpdated, "picked_up", nil)
+	}
+	sub = restarted.Subscribe("vehicle-1", first.ID+1)
+	defer sub.Close()
+	if !sub.Resync || len(sub.Replay) != 0 {
+		t.Errorf("Expected a resync for an event ID from before the restart, got %d replayed", len(sub.Replay))
+	}
+}
+
+func TestVehicleEventHub_DropsSlowSubscriber(t *testing.T) {
+	hub := NewVehicleEventHub()
+	sub := hub.Subscribe("vehicle-1", 0)
+
+	for i := 0; i < vehicleEventBufferSize+1; i++ {
+		hub.Publish("vehicle-1", VehicleEventUpdated, "picked_up", nil)
+	}
+
+	count := 0
+	for range sub.Events {
+		count++
+	}
+	if count != vehicleEventBufferSize {
+		t.Errorf("Expected %d buffered events before the drop, got %d", vehicleEventBufferSize, count)
+	}
+
+	// Closing an already dropped subscription is harmless
+	sub.Close()
+}
+
+func TestJobService_NotifiesVehicleOfAssignmentAndCancellation(t *testing.T) {
+	jobStorage := storage.NewMemoryJobStorage()
+	mockFleetClient := NewMockFleetClient()
+	jobService := NewJobService(jobStorage, mockFleetClient)
+	ctx := context.Background()
+
+	mockFleetClient.AddVehicle(&fleet.Vehicle{
+		ID:             "vehicle-1",
+		Region:         "us-west-2",
+		Status:         "available",
+		BatteryRangeKm: 200.0,
+	})
+
+	sub := jobService.SubscribeVehicleEvents("vehicle-1", 0)
+	defer sub.Close()
+
+	job, _ := jobService.CreateRideJob(ctx, "customer-123", "us-west-2", 37.7749, -122.4194, 37.7849, -122.4094)
+
+	assigned := <-sub.Events
+	if assigned.Type != VehicleEventAssigned || assigned.Job.ID != job.ID {
+		t.Errorf("Expected assignment of %s, got %+v", job.ID, assigned)
+	}
+
+	if _, err := jobService.CancelJob(ctx, job.ID, CancelReasonCustomer); err != nil {
+		t.Fatalf("Failed to cancel job: %v", err)
+	}
+
+	cancelled := <-sub.Events
+	if cancelled.Type != VehicleEventCancelled || cancelled.Job.CancellationReason != CancelReasonCustomer {
+		t.Errorf("Expected cancellation event, got %+v", cancelled)
+	}
+}
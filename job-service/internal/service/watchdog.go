@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"job-service/internal/fleet"
+	"job-service/internal/storage"
+)
+
+// JobWatchdog periodically looks for dispatched jobs whose vehicle can no longer
+// serve them and puts them back up for dispatch
+type JobWatchdog struct {
+	jobService *JobService
+	interval   time.Duration
+	staleAfter time.Duration
+	stopChan   chan struct{}
+}
+
+// NewJobWatchdog creates a watchdog that runs every interval and treats vehicles
+// that have not reported for staleAfter as gone
+func NewJobWatchdog(jobService *JobService, interval, staleAfter time.Duration) *JobWatchdog {
+	return &JobWatchdog{
+		jobService: jobService,
+		interval:   interval,
+		staleAfter: staleAfter,
+		stopChan:   make(chan struct{}),
+	}
+}
+
+// Start begins watching for abandoned jobs
+func (w *JobWatchdog) Start() {
+	go w.watchLoop()
+	fmt.Println("Job watchdog started")
+}
+
+// Stop stops the watchdog
+func (w *JobWatchdog) Stop() {
+	close(w.stopChan)
+	fmt.Println("Job watchdog stopped")
+}
+
+// watchLoop runs the watchdog check on every tick
+func (w *JobWatchdog) watchLoop() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := w.jobService.RequeueAbandonedJobs(context.Background(), w.staleAfter); err != nil {
+				fmt.Printf("Error checking for abandoned jobs: %v\n", err)
+			}
+		case <-w.stopChan:
+			return
+		}
+	}
+}
+
+// RequeueAbandonedJobs finds assigned jobs whose vehicle has gone to charge, is in
+// maintenance or offline, has left the fleet, or has not reported for staleAfter,
+// and takes the jobs away from those vehicles. It returns the affected jobs.
+func (j *JobService) RequeueAbandonedJobs(ctx context.Context, staleAfter time.Duration) ([]*storage.Job, error) {
+	var dispatched []*storage.Job
+	for _, status := range []string{JobStatusAssigned, JobStatusInProgress} {
+		jobs, err := j.storage.GetJobsByStatus(ctx, status)
+		if err != nil {
+			return nil, err
+		}
+		dispatched = append(dispatched, jobs...)
+	}
+
+	if len(dispatched) == 0 {
+		return nil, nil
+	}
+
+	vehicles, err := j.fleetClient.GetAllVehicles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get vehicles: %v", err)
+	}
+
+	vehiclesByID := make(map[string]*fleet.Vehicle, len(vehicles))
+	for _, vehicle := range vehicles {
+		vehiclesByID[vehicle.ID] = vehicle
+	}
+
+	now := time.Now()
+	var requeued []*storage.Job
+	for _, job := range dispatched {
+		if job.AssignedVehicleID == nil {
+			continue
+		}
+		vehicleID := *job.AssignedVehicleID
+
+		reason := abandonmentReason(vehiclesByID[vehicleID], now, staleAfter)
+		if reason == "" {
+			continue
+		}
+
+		updated, err := j.requeueJob(ctx, job.ID, vehicleID, reason)
+		if err != nil {
+			fmt.Printf("Failed to requeue abandoned job %s: %v\n", job.ID, err)
+			continue
+		}
+		requeued = append(requeued, updated)
+	}
+
+	return requeued, nil
+}
+
+// abandonmentReason explains why a vehicle can no longer serve its job, or returns
+// an empty string if it still can
+func abandonmentReason(vehicle *fleet.Vehicle, now time.Time, staleAfter time.Duration) string {
+	if vehicle == nil {
+		return "vehicle no longer registered"
+	}
+
+	switch vehicle.Status {
+	case "charging", "maintenance", "offline":
+		return fmt.Sprintf("vehicle is %s", vehicle.Status)
+	}
+
+	if !vehicle.LastUpdated.IsZero() && now.Sub(vehicle.LastUpdated) > staleAfter {
+		return fmt.Sprintf("vehicle has not reported for %s", now.Sub(vehicle.LastUpdated).Round(time.Second))
+	}
+
+	return ""
+}
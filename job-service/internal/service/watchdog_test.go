@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"job-service/internal/fleet"
+	"job-service/internal/storage"
+)
+
+func setupWatchdogTest(t *testing.T) (*JobService, *MockFleetClient, *fleet.Vehicle, *storage.Job) {
+	jobStorage := storage.NewMemoryJobStorage()
+	mockFleetClient := NewMockFleetClient()
+	jobService := NewJobService(jobStorage, mockFleetClient)
+
+	vehicle := &fleet.Vehicle{
+		ID:             "vehicle-1",
+		Region:         "us-west-2",
+		Status:         "available",
+		BatteryRangeKm: 200.0,
+		LastUpdated:    time.Now(),
+	}
+	mockFleetClient.AddVehicle(vehicle)
+
+	job, err := jobService.CreateRideJob(context.Background(), "customer-123", "us-west-2", 37.7749, -122.4194, 37.7849, -122.4094)
+	if err != nil {
+		t.Fatalf("Failed to create job: %v", err)
+	}
+	if job.Status != JobStatusAssigned {
+		t.Fatalf("Expected job to be assigned, got %s", job.Status)
+	}
+
+	return jobService, mockFleetClient, vehicle, job
+}
+
+func TestJobService_RequeueAbandonedJobs_ChargingVehicle(t *testing.T) {
+	jobService, _, vehicle, job := setupWatchdogTest(t)
+	ctx := context.Background()
+
+	// Vehicle dropped the job to go and charge
+	vehicle.Status = "charging"
+
+	requeued, err := jobService.RequeueAbandonedJobs(ctx, time.Minute)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(requeued) != 1 {
+		t.Fatalf("Expected 1 requeued job, got %d", len(requeued))
+	}
+
+	current, _ := jobService.GetJob(ctx, job.ID)
+	if current.Status != JobStatusPending {
+		t.Errorf("Expected status 'pending', got %s", current.Status)
+	}
+	if current.AssignedVehicleID != nil {
+		t.Errorf("Expected no assigned vehicle, got %s", *current.AssignedVehicleID)
+	}
+	if current.Attempts != 1 {
+		t.Errorf("Expected 1 attempt, got %d", current.Attempts)
+	}
+
+	// Fleet service no longer ties the vehicle to the job, and it keeps charging
+	if vehicle.CurrentJobID != nil {
+		t.Errorf("Expected vehicle to be released, got job %s", *vehicle.CurrentJobID)
+	}
+	if vehicle.Status != "charging" {
+		t.Errorf("Expected vehicle to keep charging, got %s", vehicle.Status)
+	}
+}
+
+func TestJobService_RequeueAbandonedJobs_StaleVehicle(t *testing.T) {
+	jobService, _, vehicle, job := setupWatchdogTest(t)
+	ctx := context.Background()
+
+	// A healthy, recently reporting vehicle keeps its job
+	requeued, _ := jobService.RequeueAbandonedJobs(ctx, time.Minute)
+	if len(requeued) != 0 {
+		t.Fatalf("Expected no requeued jobs, got %d", len(requeued))
+	}
+
+	// Vehicle stops reporting
+	vehicle.LastUpdated = time.Now().Add(-5 * time.Minute)
+
+	requeued, _ = jobService.RequeueAbandonedJobs(ctx, time.Minute)
+	if len(requeued) != 1 || requeued[0].ID != job.ID {
+		t.Fatalf("Expected job %s to be requeued, got %v", job.ID, requeued)
+	}
+}
+
+func TestJobService_RequeueAbandonedJobs_InProgressFails(t *testing.T) {
+	jobService, _, vehicle, job := setupWatchdogTest(t)
+	ctx := context.Background()
+
+	if _, err := jobService.ConfirmPickup(ctx, job.ID); err != nil {
+		t.Fatalf("Failed to confirm pickup: %v", err)
+	}
+
+	vehicle.Status = "maintenance"
+
+	jobService.RequeueAbandonedJobs(ctx, time.Minute)
+
+	current, _ := jobService.GetJob(ctx, job.ID)
+	if current.Status != JobStatusFailed {
+		t.Errorf("Expected status 'failed', got %s", current.Status)
+	}
+	if current.FailureReason == "" {
+		t.Error("Expected failure reason to be set")
+	}
+}
+
+func TestJobService_AbandonJob(t *testing.T) {
+	jobService, mockFleetClient, vehicle, job := setupWatchdogTest(t)
+	ctx := context.Background()
+
+	// Only the assigned vehicle may abandon the job
+	if _, err := jobService.AbandonJob(ctx, job.ID, "vehicle-2", "low battery"); !errors.Is(err, ErrVehicleMismatch) {
+		t.Errorf("Expected ErrVehicleMismatch, got %v", err)
+	}
+
+	replacement := &fleet.Vehicle{
+		ID:             "vehicle-2",
+		Region:         "us-west-2",
+		Status:         "available",
+		BatteryRangeKm: 200.0,
+		LastUpdated:    time.Now(),
+	}
+	mockFleetClient.AddVehicle(replacement)
+
+	vehicle.Status = "charging"
+	abandoned, err := jobService.AbandonJob(ctx, job.ID, "vehicle-1", "low battery")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The job goes straight to the next available vehicle
+	if abandoned.Status != JobStatusAssigned {
+		t.Errorf("Expected job to be reassigned, got status %s", abandoned.Status)
+	}
+	if abandoned.AssignedVehicleID == nil || *abandoned.AssignedVehicleID != "vehicle-2" {
+		t.Errorf("Expected job assigned to vehicle-2, got %v", abandoned.AssignedVehicleID)
+	}
+	if abandoned.Attempts != 1 {
+		t.Errorf("Expected 1 attempt, got %d", abandoned.Attempts)
+	}
+}
+
+func TestJobService_AbandonJob_FailsAfterMaxAttempts(t *testing.T) {
+	jobService, _, vehicle, job := setupWatchdogTest(t)
+	ctx := context.Background()
+
+	for attempt := 1; attempt < maxJobAttempts; attempt++ {
+		abandoned, err := jobService.AbandonJob(ctx, job.ID, "vehicle-1", "low battery")
+		if err != nil {
+			t.Fatalf("Attempt %d: expected no error, got %v", attempt, err)
+		}
+		if abandoned.Status != JobStatusAssigned {
+			t.Fatalf("Attempt %d: expected job to be reassigned, got %s", attempt, abandoned.Status)
+		}
+	}
+
+	failed, err := jobService.AbandonJob(ctx, job.ID, "vehicle-1", "low battery")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if failed.Status != JobStatusFailed {
+		t.Errorf("Expected status 'failed' after %d attempts, got %s", maxJobAttempts, failed.Status)
+	}
+	if vehicle.CurrentJobID != nil {
+		t.Errorf("Expected vehicle to be released, got job %s", *vehicle.CurrentJobID)
+	}
+}
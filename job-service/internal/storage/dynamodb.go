@@ -2,7 +2,9 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
@@ -32,6 +34,7 @@ func NewDynamoDBJobStorage(client DynamoDBAPI, tableName string) *DynamoDBJobSto
 }
 
 func (d *DynamoDBJobStorage) CreateJob(ctx context.Context, job *Job) error {
+	job.Version = 1
 	item, err := attributevalue.MarshalMap(job)
 	if err != nil {
 		return fmt.Errorf("failed to marshal job: %w", err)
@@ -73,19 +76,42 @@ func (d *DynamoDBJobStorage) GetJob(ctx context.Context, jobID string) (*Job, er
 }
 
 func (d *DynamoDBJobStorage) UpdateJob(ctx context.Context, job *Job) error {
-	item, err := attributevalue.MarshalMap(job)
+	expectedVersion := job.Version
+
+	updated := *job
+	updated.Version = expectedVersion + 1
+
+	item, err := attributevalue.MarshalMap(&updated)
 	if err != nil {
 		return fmt.Errorf("failed to marshal job: %w", err)
 	}
 
+	// Records written before versioning have no version attribute yet
+	condition := "#version = :expected"
+	if expectedVersion == 0 {
+		condition = "attribute_not_exists(#version) OR #version = :expected"
+	}
+
 	_, err = d.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(d.tableName),
-		Item:      item,
+		TableName:           aws.String(d.tableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_exists(id) AND (" + condition + ")"),
+		ExpressionAttributeNames: map[string]string{
+			"#version": "version",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":expected": &types.AttributeValueMemberN{Value: strconv.FormatInt(expectedVersion, 10)},
+		},
 	})
 	if err != nil {
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return ErrVersionConflict
+		}
 		return fmt.Errorf("failed to update job: %w", err)
 	}
 
+	*job = updated
 	return nil
 }
 
@@ -93,12 +119,14 @@ func (d *DynamoDBJobStorage) UpdateJobStatus(ctx context.Context, jobID, status
 	updateExpression := "SET #status = :status"
 	expressionAttributeValues := map[string]types.AttributeValue{
 		":status": &types.AttributeValueMemberS{Value: status},
+		":one":    &types.AttributeValueMemberN{Value: "1"},
 	}
 
 	if vehicleID != nil {
 		updateExpression += ", assigned_vehicle_id = :vehicleID"
 		expressionAttributeValues[":vehicleID"] = &types.AttributeValueMemberS{Value: *vehicleID}
 	}
+	updateExpression += " ADD #version :one"
 
 	_, err := d.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
 		TableName: aws.String(d.tableName),
@@ -107,7 +135,8 @@ func (d *DynamoDBJobStorage) UpdateJobStatus(ctx context.Context, jobID, status
 		},
 		UpdateExpression: aws.String(updateExpression),
 		ExpressionAttributeNames: map[string]string{
-			"#status": "status",
+			"#status":  "status",
+			"#version": "version",
 		},
 		ExpressionAttributeValues: expressionAttributeValues,
 	})
@@ -147,6 +176,32 @@ func (d *DynamoDBJobStorage) GetJobsByStatus(ctx context.Context, status string)
 	return jobs, nil
 }
 
+func (d *DynamoDBJobStorage) GetJobsByRevenueStatus(ctx context.Context, revenueStatus string) ([]*Job, error) {
+	result, err := d.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(d.tableName),
+		IndexName:              aws.String("revenue-status-index"),
+		KeyConditionExpression: aws.String("revenue_status = :revenueStatus"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":revenueStatus": &types.AttributeValueMemberS{Value: revenueStatus},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query jobs by revenue status: %w", err)
+	}
+
+	var jobs []*Job
+	for _, item := range result.Items {
+		var job Job
+		err = attributevalue.UnmarshalMap(item, &job)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal job: %w", err)
+		}
+		jobs = append(jobs, &job)
+	}
+
+	return jobs, nil
+}
+
 func (d *DynamoDBJobStorage) GetAllJobs(ctx context.Context) ([]*Job, error) {
 	result, err := d.client.Scan(ctx, &dynamodb.ScanInput{
 		TableName: aws.String(d.tableName),
@@ -193,3 +248,29 @@ func (d *DynamoDBJobStorage) GetJobsByVehicle(ctx context.Context, vehicleID str
 
 	return jobs, nil
 }
+
+func (d *DynamoDBJobStorage) GetJobsByCustomer(ctx context.Context, customerID string) ([]*Job, error) {
+	result, err := d.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(d.tableName),
+		IndexName:              aws.String("customer-index"),
+		KeyConditionExpression: aws.String("customer_id = :customerID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":customerID": &types.AttributeValueMemberS{Value: customerID},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query jobs by customer: %w", err)
+	}
+
+	var jobs []*Job
+	for _, item := range result.Items {
+		var job Job
+		err = attributevalue.UnmarshalMap(item, &job)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal job: %w", err)
+		}
+		jobs = append(jobs, &job)
+	}
+
+	return jobs, nil
+}
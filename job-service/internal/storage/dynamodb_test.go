@@ -41,6 +41,11 @@ func (m *MockDynamoDBClient) Scan(ctx context.Context, params *dynamodb.ScanInpu
 	return args.Get(0).(*dynamodb.ScanOutput), args.Error(1)
 }
 
+func (m *MockDynamoDBClient) TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(*dynamodb.TransactWriteItemsOutput), args.Error(1)
+}
+
 func TestDynamoDBJobStorage_CreateJob(t *testing.T) {
 	mockClient := new(MockDynamoDBClient)
 	storage := &DynamoDBJobStorage{
@@ -186,6 +191,37 @@ func TestDynamoDBJobStorage_GetJobsByStatus(t *testing.T) {
 	mockClient.AssertExpectations(t)
 }
 
+func TestDynamoDBJobStorage_GetJobsByRevenueStatus(t *testing.T) {
+	mockClient := new(MockDynamoDBClient)
+	storage := &DynamoDBJobStorage{
+		client:    mockClient,
+		tableName: "test-jobs",
+	}
+
+	mockClient.On("Query", mock.Anything, mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+		return *input.TableName == "test-jobs" && *input.IndexName == "revenue-status-index" &&
+			input.ExpressionAttributeValues[":revenueStatus"].(*types.AttributeValueMemberS).Value == RevenueStatusPending
+	})).Return(&dynamodb.QueryOutput{
+		Items: []map[string]types.AttributeValue{
+			{
+				"id":             &types.AttributeValueMemberS{Value: "test-job-1"},
+				"job_type":       &types.AttributeValueMemberS{Value: "ride"},
+				"status":         &types.AttributeValueMemberS{Value: "completed"},
+				"revenue_status": &types.AttributeValueMemberS{Value: RevenueStatusPending},
+				"region":         &types.AttributeValueMemberS{Value: "us-west-2"},
+			},
+		},
+	}, nil)
+
+	jobs, err := storage.GetJobsByRevenueStatus(context.Background(), RevenueStatusPending)
+
+	assert.NoError(t, err)
+	if assert.Len(t, jobs, 1) {
+		assert.Equal(t, RevenueStatusPending, jobs[0].RevenueStatus)
+	}
+	mockClient.AssertExpectations(t)
+}
+
 func TestDynamoDBJobStorage_GetAllJobs(t *testing.T) {
 	mockClient := new(MockDynamoDBClient)
 	storage := &DynamoDBJobStorage{
@@ -263,3 +299,53 @@ func TestDynamoDBJobStorage_GetJobsByVehicle(t *testing.T) {
 	assert.Equal(t, "vehicle-1", *jobs[0].AssignedVehicleID)
 	mockClient.AssertExpectations(t)
 }
+
+func TestDynamoDBJobStorage_UpdateJob_VersionConflict(t *testing.T) {
+	mockClient := new(MockDynamoDBClient)
+	storage := &DynamoDBJobStorage{
+		client:    mockClient,
+		tableName: "test-jobs",
+	}
+
+	mockClient.On("PutItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+		return input.ConditionExpression != nil &&
+			input.ExpressionAttributeValues[":expected"].(*types.AttributeValueMemberN).Value == "2"
+	})).Return((*dynamodb.PutItemOutput)(nil), &types.ConditionalCheckFailedException{})
+
+	job := &Job{ID: "test-job-1", Status: "completed", Version: 2}
+	err := storage.UpdateJob(context.Background(), job)
+
+	assert.ErrorIs(t, err, ErrVersionConflict)
+	assert.Equal(t, int64(2), job.Version)
+	mockClient.AssertExpectations(t)
+}
+
+func TestDynamoDBJobStorage_GetJobsByCustomer(t *testing.T) {
+	mockClient := new(MockDynamoDBClient)
+	storage := &DynamoDBJobStorage{
+		client:    mockClient,
+		tableName: "test-jobs",
+	}
+
+	mockClient.On("Query", mock.Anything, mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+		return *input.TableName == "test-jobs" && *input.IndexName == "customer-index"
+	})).Return(&dynamodb.QueryOutput{
+		Items: []map[string]types.AttributeValue{
+			{
+				"id":            &types.AttributeValueMemberS{Value: "test-job-1"},
+				"job_type":      &types.AttributeValueMemberS{Value: "ride"},
+				"status":        &types.AttributeValueMemberS{Value: "scheduled"},
+				"customer_id":   &types.AttributeValueMemberS{Value: "customer-1"},
+				"scheduled_for": &types.AttributeValueMemberS{Value: "2030-01-01T09:00:00Z"},
+			},
+		},
+	}, nil)
+
+	jobs, err := storage.GetJobsByCustomer(context.Background(), "customer-1")
+
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 1)
+	assert.Equal(t, "customer-1", jobs[0].CustomerID)
+	assert.NotNil(t, jobs[0].ScheduledFor)
+	mockClient.AssertExpectations(t)
+}
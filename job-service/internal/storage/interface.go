@@ -2,9 +2,17 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
+// ErrVersionConflict is returned when a versioned update was based on a stale copy of the job
+var ErrVersionConflict = errors.New("job was modified concurrently")
+
+// RevenueStatusPending marks a finished job whose amounts have not been added to the
+// revenue rollups yet
+const RevenueStatusPending = "pending"
+
 // Job represents a ride or delivery job
 type Job struct {
 	ID                  string           `json:"id" dynamodbav:"id"`
@@ -18,15 +26,78 @@ type Job struct {
 	EstimatedDistanceKm float64          `json:"estimated_distance_km" dynamodbav:"estimated_distance_km"`
 	CreatedAt           time.Time        `json:"created_at" dynamodbav:"created_at"`
 	AssignedAt          *time.Time       `json:"assigned_at,omitempty" dynamodbav:"assigned_at,omitempty"`
+	PickedUpAt          *time.Time       `json:"picked_up_at,omitempty" dynamodbav:"picked_up_at,omitempty"`
 	CompletedAt         *time.Time       `json:"completed_at,omitempty" dynamodbav:"completed_at,omitempty"`
+	FailedAt            *time.Time       `json:"failed_at,omitempty" dynamodbav:"failed_at,omitempty"`
+	FailureReason       string           `json:"failure_reason,omitempty" dynamodbav:"failure_reason,omitempty"`
+	CancelledAt         *time.Time       `json:"cancelled_at,omitempty" dynamodbav:"cancelled_at,omitempty"`
+	CancellationReason  string           `json:"cancellation_reason,omitempty" dynamodbav:"cancellation_reason,omitempty"`
 	CustomerID          string           `json:"customer_id" dynamodbav:"customer_id"`
 	Region              string           `json:"region" dynamodbav:"region"`
 	DeliveryDetails     *DeliveryDetails `json:"delivery_details,omitempty" dynamodbav:"delivery_details,omitempty"`
 
+	// Estimated driving time over the whole trip
+	EstimatedDurationMinutes float64 `json:"estimated_duration_minutes,omitempty" dynamodbav:"estimated_duration_minutes,omitempty"`
+
+	// Distance and driving time from pickup to drop-off, as reported by the vehicle on completion
+	ActualDistanceKm      float64 `json:"actual_distance_km,omitempty" dynamodbav:"actual_distance_km,omitempty"`
+	ActualDurationMinutes float64 `json:"actual_duration_minutes,omitempty" dynamodbav:"actual_duration_minutes,omitempty"`
+
+	// Stops in the order they are visited, from the pickup to the final destination.
+	// PickupLat/Lng and DestinationLat/Lng mirror the first and last stop.
+	Stops []Stop `json:"stops,omitempty" dynamodbav:"stops,omitempty"`
+
+	// Shared rides may pool with other riders going the same way
+	Shared bool `json:"shared,omitempty" dynamodbav:"shared,omitempty"`
+	Seats  int  `json:"seats,omitempty" dynamodbav:"seats,omitempty"` // seats a shared ride needs
+
+	// Deliveries dispatched together on one vehicle trip share a batch ID
+	BatchID string `json:"batch_id,omitempty" dynamodbav:"batch_id,omitempty"`
+
+	// Pickup time requested for a booking made in advance; nil for immediate jobs
+	ScheduledFor *time.Time `json:"scheduled_for,omitempty" dynamodbav:"scheduled_for,omitempty"`
+
+	// Dispatch attempts that ended with the vehicle abandoning the job
+	Attempts int `json:"attempts" dynamodbav:"attempts"`
+
 	// Revenue tracking
 	FareAmount   float64 `json:"fare_amount" dynamodbav:"fare_amount"`
 	BaseFare     float64 `json:"base_fare" dynamodbav:"base_fare"`
 	DistanceFare float64 `json:"distance_fare" dynamodbav:"distance_fare"`
+	TimeFare     float64 `json:"time_fare,omitempty" dynamodbav:"time_fare,omitempty"` // for the estimated driving time
+	StopFare     float64 `json:"stop_fare,omitempty" dynamodbav:"stop_fare,omitempty"` // for stops between pickup and destination
+	// Taken off the distance and time fare of a shared ride for splitting the vehicle
+	SharedDiscount float64 `json:"shared_discount,omitempty" dynamodbav:"shared_discount,omitempty"`
+	// Added to bring the fare up to the minimum
+	MinimumFareTopUp float64 `json:"minimum_fare_top_up,omitempty" dynamodbav:"minimum_fare_top_up,omitempty"`
+	// Demand multiplier in the pickup zone when the job was requested, and what it added
+	SurgeMultiplier float64 `json:"surge_multiplier,omitempty" dynamodbav:"surge_multiplier,omitempty"`
+	SurgeFare       float64 `json:"surge_fare,omitempty" dynamodbav:"surge_fare,omitempty"`
+	// Version of the pricing rules the fare was calculated with
+	PricingVersion string `json:"pricing_version,omitempty" dynamodbav:"pricing_version,omitempty"`
+	// Quote the fare was locked in with, if the customer booked from one
+	QuoteID string `json:"quote_id,omitempty" dynamodbav:"quote_id,omitempty"`
+	// Fare the job was booked at, kept once the final fare replaces it on completion
+	EstimatedFareAmount float64 `json:"estimated_fare_amount,omitempty" dynamodbav:"estimated_fare_amount,omitempty"`
+	// Charged instead of the fare when a job is cancelled
+	CancellationFee float64 `json:"cancellation_fee,omitempty" dynamodbav:"cancellation_fee,omitempty"`
+	// RevenueStatusPending from the write that finishes the job until its amounts are in
+	// the revenue rollups, then cleared, so only jobs still owed are indexed
+	RevenueStatus string `json:"revenue_status,omitempty" dynamodbav:"revenue_status,omitempty"`
+
+	// Optimistic concurrency control, incremented on every write
+	Version int64 `json:"version" dynamodbav:"version"`
+}
+
+// Stop is one place a job calls at
+type Stop struct {
+	Lat           float64    `json:"lat" dynamodbav:"lat"`
+	Lng           float64    `json:"lng" dynamodbav:"lng"`
+	Type          string     `json:"type" dynamodbav:"type"`                                   // pickup, waypoint, dropoff
+	Note          string     `json:"note,omitempty" dynamodbav:"note,omitempty"`               // e.g. who or what to collect or drop off
+	LegDistanceKm float64    `json:"leg_distance_km" dynamodbav:"leg_distance_km"`             // road distance from the previous stop
+	LegMinutes    float64    `json:"leg_minutes,omitempty" dynamodbav:"leg_minutes,omitempty"` // driving time from the previous stop
+	ArrivedAt     *time.Time `json:"arrived_at,omitempty" dynamodbav:"arrived_at,omitempty"`   // when the vehicle reached the stop
 }
 
 // DeliveryDetails contains delivery-specific information
@@ -44,15 +115,22 @@ type JobStorage interface {
 	// GetJob retrieves a job by ID
 	GetJob(ctx context.Context, jobID string) (*Job, error)
 
-	// UpdateJob updates an existing job
+	// UpdateJob replaces an existing job if its Version still matches the stored
+	// record, returning ErrVersionConflict otherwise; on success Version is incremented
 	UpdateJob(ctx context.Context, job *Job) error
 
 	// GetJobsByStatus finds jobs by status
 	GetJobsByStatus(ctx context.Context, status string) ([]*Job, error)
 
+	// GetJobsByRevenueStatus finds finished jobs by revenue status
+	GetJobsByRevenueStatus(ctx context.Context, revenueStatus string) ([]*Job, error)
+
 	// GetJobsByVehicle finds jobs assigned to a specific vehicle
 	GetJobsByVehicle(ctx context.Context, vehicleID string) ([]*Job, error)
 
+	// GetJobsByCustomer finds jobs requested by a specific customer
+	GetJobsByCustomer(ctx context.Context, customerID string) ([]*Job, error)
+
 	// GetAllJobs returns all jobs (for dashboard)
 	GetAllJobs(ctx context.Context) ([]*Job, error)
 
@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var (
+	// ErrCustomerNotFound is returned for customers without a record
+	ErrCustomerNotFound = errors.New("customer not found")
+	// ErrCustomerExists is returned when creating a customer whose ID is taken
+	ErrCustomerExists = errors.New("customer already exists")
+	// ErrDuplicateTransaction is returned when a ledger transaction was already recorded
+	ErrDuplicateTransaction = errors.New("ledger transaction already recorded")
+	// ErrUnbalancedTransaction is returned for ledger transactions whose postings don't sum to zero
+	ErrUnbalancedTransaction = errors.New("ledger transaction does not balance")
+)
+
+// Ledger accounts. Debits are positive and credits negative, so a customer's
+// receivable balance is what they owe.
+const (
+	AccountReceivable       = "customer_receivable"      // owed by customers
+	AccountFareRevenue      = "fare_revenue"             // earned from completed jobs
+	AccountCancellationFees = "cancellation_fee_revenue" // earned from cancelled jobs
+	AccountRefunds          = "refunds"                  // given back, offsetting revenue
+	AccountPaymentsClearing = "payments_clearing"        // collected through the payment provider
+)
+
+// Ledger transaction types
+const (
+	TransactionCharge          = "charge"           // fare of a completed job
+	TransactionCancellationFee = "cancellation_fee" // fee for a cancelled job
+	TransactionPayment         = "payment"          // money collected from the customer
+	TransactionRefund          = "refund"           // money owed back to the customer
+	TransactionPayout          = "payout"           // refund paid back to the customer
+)
+
+// Customer is someone who books rides and deliveries, and whose jobs are charged to
+// their account
+type Customer struct {
+	ID    string `json:"id" dynamodbav:"id"`
+	Name  string `json:"name,omitempty" dynamodbav:"name,omitempty"`
+	Email string `json:"email,omitempty" dynamodbav:"email,omitempty"`
+	// Token for the card or account the payment provider charges
+	PaymentMethod string    `json:"payment_method,omitempty" dynamodbav:"payment_method,omitempty"`
+	CreatedAt     time.Time `json:"created_at" dynamodbav:"created_at"`
+}
+
+// LedgerTransaction is a balanced set of postings recorded together for one customer
+type LedgerTransaction struct {
+	ID          string    `json:"id"` // unique, so recording the same event twice is rejected
+	CustomerID  string    `json:"customer_id"`
+	Type        string    `json:"type"`
+	JobID       string    `json:"job_id,omitempty"`
+	PaymentID   string    `json:"payment_id,omitempty"` // payment or refund at the payment provider
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	Postings    []Posting `json:"postings"`
+}
+
+// Posting is an amount debited (positive) or credited (negative) to an account
+type Posting struct {
+	Account string  `json:"account"`
+	Amount  float64 `json:"amount"`
+}
+
+// LedgerEntry is one posting of a recorded transaction, as stored in a customer's ledger
+type LedgerEntry struct {
+	CustomerID    string    `json:"customer_id" dynamodbav:"customer_id"`
+	EntryID       string    `json:"entry_id" dynamodbav:"entry_id"` // orders a customer's entries by time
+	TransactionID string    `json:"transaction_id" dynamodbav:"transaction_id"`
+	Type          string    `json:"type" dynamodbav:"type"`
+	Account       string    `json:"account" dynamodbav:"account"`
+	Amount        float64   `json:"amount" dynamodbav:"amount"`
+	JobID         string    `json:"job_id,omitempty" dynamodbav:"job_id,omitempty"`
+	PaymentID     string    `json:"payment_id,omitempty" dynamodbav:"payment_id,omitempty"`
+	Description   string    `json:"description,omitempty" dynamodbav:"description,omitempty"`
+	CreatedAt     time.Time `json:"created_at" dynamodbav:"created_at"`
+}
+
+// LedgerStorage defines the interface for customer and ledger data operations
+type LedgerStorage interface {
+	// CreateCustomer adds a new customer, returning ErrCustomerExists if the ID is taken
+	CreateCustomer(ctx context.Context, customer *Customer) error
+
+	// GetCustomer retrieves a customer by ID, returning ErrCustomerNotFound if there is none
+	GetCustomer(ctx context.Context, customerID string) (*Customer, error)
+
+	// RecordTransaction writes all of a transaction's postings or none of them. It
+	// returns ErrUnbalancedTransaction if they don't sum to zero and
+	// ErrDuplicateTransaction if a transaction with the same ID was already recorded.
+	RecordTransaction(ctx context.Context, txn *LedgerTransaction) error
+
+	// GetLedgerEntries returns a customer's entries created in [from, to), oldest first
+	GetLedgerEntries(ctx context.Context, customerID string, from, to time.Time) ([]*LedgerEntry, error)
+}
+
+// entryTimeFormat renders entry times at a fixed width so entry IDs sort by time
+const entryTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+// ledgerEntries checks a transaction balances and splits it into the entries to store
+func ledgerEntries(txn *LedgerTransaction) ([]*LedgerEntry, error) {
+	if len(txn.Postings) < 2 {
+		return nil, fmt.Errorf("%w: transaction %s has %d postings", ErrUnbalancedTransaction, txn.ID, len(txn.Postings))
+	}
+
+	var sum float64
+	for _, posting := range txn.Postings {
+		sum += posting.Amount
+	}
+	// Amounts are in dollars, so anything under half a cent is rounding
+	if math.Abs(sum) >= 0.005 {
+		return nil, fmt.Errorf("%w: transaction %s is off by %.2f", ErrUnbalancedTransaction, txn.ID, sum)
+	}
+
+	entries := make([]*LedgerEntry, len(txn.Postings))
+	for i, posting := range txn.Postings {
+		entries[i] = &LedgerEntry{
+			CustomerID:    txn.CustomerID,
+			EntryID:       fmt.Sprintf("%s#%s#%d", txn.CreatedAt.UTC().Format(entryTimeFormat), txn.ID, i),
+			TransactionID: txn.ID,
+			Type:          txn.Type,
+			Account:       posting.Account,
+			Amount:        posting.Amount,
+			JobID:         txn.JobID,
+			PaymentID:     txn.PaymentID,
+			Description:   txn.Description,
+			CreatedAt:     txn.CreatedAt,
+		}
+	}
+	return entries, nil
+}
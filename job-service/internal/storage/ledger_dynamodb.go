@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// DynamoDBLedgerAPI is the DynamoDB API the ledger needs, which writes each
+// transaction's entries atomically
+type DynamoDBLedgerAPI interface {
+	DynamoDBAPI
+	TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+}
+
+// transactionMarkerPrefix keys the item recording that a transaction was written. It
+// sorts after every entry ID, so time range queries never return markers.
+const transactionMarkerPrefix = "txn#"
+
+// DynamoDBLedgerStorage keeps customers in one table and their ledger entries in
+// another, keyed by customer ID and entry ID
+type DynamoDBLedgerStorage struct {
+	client         DynamoDBLedgerAPI
+	customersTable string
+	ledgerTable    string
+}
+
+func NewDynamoDBLedgerStorage(client DynamoDBLedgerAPI, customersTable, ledgerTable string) *DynamoDBLedgerStorage {
+	return &DynamoDBLedgerStorage{
+		client:         client,
+		customersTable: customersTable,
+		ledgerTable:    ledgerTable,
+	}
+}
+
+func (d *DynamoDBLedgerStorage) CreateCustomer(ctx context.Context, customer *Customer) error {
+	item, err := attributevalue.MarshalMap(customer)
+	if err != nil {
+		return fmt.Errorf("failed to marshal customer: %w", err)
+	}
+
+	_, err = d.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(d.customersTable),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	})
+	if err != nil {
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return fmt.Errorf("%w: %s", ErrCustomerExists, customer.ID)
+		}
+		return fmt.Errorf("failed to put customer: %w", err)
+	}
+
+	return nil
+}
+
+func (d *DynamoDBLedgerStorage) GetCustomer(ctx context.Context, customerID string) (*Customer, error) {
+	result, err := d.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(d.customersTable),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: customerID},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get customer: %w", err)
+	}
+
+	if result.Item == nil {
+		return nil, fmt.Errorf("%w: %s", ErrCustomerNotFound, customerID)
+	}
+
+	var customer Customer
+	if err := attributevalue.UnmarshalMap(result.Item, &customer); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal customer: %w", err)
+	}
+
+	return &customer, nil
+}
+
+func (d *DynamoDBLedgerStorage) RecordTransaction(ctx context.Context, txn *LedgerTransaction) error {
+	entries, err := ledgerEntries(txn)
+	if err != nil {
+		return err
+	}
+
+	// The marker fails the whole write if the transaction was already recorded
+	items := []types.TransactWriteItem{{
+		Put: &types.Put{
+			TableName: aws.String(d.ledgerTable),
+			Item: map[string]types.AttributeValue{
+				"customer_id": &types.AttributeValueMemberS{Value: txn.CustomerID},
+				"entry_id":    &types.AttributeValueMemberS{Value: transactionMarkerPrefix + txn.ID},
+			},
+			ConditionExpression: aws.String("attribute_not_exists(entry_id)"),
+		},
+	}}
+	for _, entry := range entries {
+		item, err := attributevalue.MarshalMap(entry)
+		if err != nil {
+			return fmt.Errorf("failed to marshal ledger entry: %w", err)
+		}
+		items = append(items, types.TransactWriteItem{
+			Put: &types.Put{
+				TableName: aws.String(d.ledgerTable),
+				Item:      item,
+			},
+		})
+	}
+
+	_, err = d.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: items,
+	})
+	if err != nil {
+		var cancelled *types.TransactionCanceledException
+		if errors.As(err, &cancelled) && len(cancelled.CancellationReasons) > 0 &&
+			aws.ToString(cancelled.CancellationReasons[0].Code) == "ConditionalCheckFailed" {
+			return fmt.Errorf("%w: %s", ErrDuplicateTransaction, txn.ID)
+		}
+		return fmt.Errorf("failed to write ledger transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (d *DynamoDBLedgerStorage) GetLedgerEntries(ctx context.Context, customerID string, from, to time.Time) ([]*LedgerEntry, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(d.ledgerTable),
+		KeyConditionExpression: aws.String("customer_id = :customerID AND entry_id BETWEEN :from AND :to"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":customerID": &types.AttributeValueMemberS{Value: customerID},
+			":from":       &types.AttributeValueMemberS{Value: from.UTC().Format(entryTimeFormat)},
+			":to":         &types.AttributeValueMemberS{Value: to.UTC().Format(entryTimeFormat)},
+		},
+	}
+
+	// A customer's ledger grows without bound, so read every page
+	var entries []*LedgerEntry
+	for {
+		result, err := d.client.Query(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query ledger entries: %w", err)
+		}
+
+		for _, item := range result.Items {
+			var entry LedgerEntry
+			if err := attributevalue.UnmarshalMap(item, &entry); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal ledger entry: %w", err)
+			}
+			// BETWEEN includes entries created at exactly to
+			if entry.CreatedAt.Before(to) {
+				entries = append(entries, &entry)
+			}
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			return entries, nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDynamoDBLedgerStorage_CreateCustomer_Exists(t *testing.T) {
+	mockClient := new(MockDynamoDBClient)
+	storage := NewDynamoDBLedgerStorage(mockClient, "test-customers", "test-ledger")
+
+	mockClient.On("PutItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+		return *input.TableName == "test-customers" && *input.ConditionExpression == "attribute_not_exists(id)"
+	})).Return((*dynamodb.PutItemOutput)(nil), &types.ConditionalCheckFailedException{})
+
+	err := storage.CreateCustomer(context.Background(), &Customer{ID: "customer-1"})
+
+	assert.ErrorIs(t, err, ErrCustomerExists)
+	mockClient.AssertExpectations(t)
+}
+
+func TestDynamoDBLedgerStorage_GetCustomer_NotFound(t *testing.T) {
+	mockClient := new(MockDynamoDBClient)
+	storage := NewDynamoDBLedgerStorage(mockClient, "test-customers", "test-ledger")
+
+	mockClient.On("GetItem", mock.Anything, mock.Anything).Return(&dynamodb.GetItemOutput{Item: nil}, nil)
+
+	customer, err := storage.GetCustomer(context.Background(), "customer-1")
+
+	assert.ErrorIs(t, err, ErrCustomerNotFound)
+	assert.Nil(t, customer)
+}
+
+func TestDynamoDBLedgerStorage_RecordTransaction(t *testing.T) {
+	mockClient := new(MockDynamoDBClient)
+	storage := NewDynamoDBLedgerStorage(mockClient, "test-customers", "test-ledger")
+
+	mockClient.On("TransactWriteItems", mock.Anything, mock.MatchedBy(func(input *dynamodb.TransactWriteItemsInput) bool {
+		if len(input.TransactItems) != 3 {
+			return false
+		}
+		marker := input.TransactItems[0].Put
+		entryID := marker.Item["entry_id"].(*types.AttributeValueMemberS).Value
+		return entryID == "txn#charge:job-1" && *marker.ConditionExpression == "attribute_not_exists(entry_id)" &&
+			*input.TransactItems[1].Put.TableName == "test-ledger"
+	})).Return(&dynamodb.TransactWriteItemsOutput{}, nil).Once()
+
+	err := storage.RecordTransaction(context.Background(), testCharge("charge:job-1", "customer-1", 12.50, time.Now()))
+	assert.NoError(t, err)
+
+	// The marker already exists on a retry
+	mockClient.On("TransactWriteItems", mock.Anything, mock.Anything).Return((*dynamodb.TransactWriteItemsOutput)(nil), &types.TransactionCanceledException{
+		CancellationReasons: []types.CancellationReason{{Code: aws.String("ConditionalCheckFailed")}, {Code: aws.String("None")}, {Code: aws.String("None")}},
+	})
+
+	err = storage.RecordTransaction(context.Background(), testCharge("charge:job-1", "customer-1", 12.50, time.Now()))
+	assert.ErrorIs(t, err, ErrDuplicateTransaction)
+	mockClient.AssertExpectations(t)
+}
+
+func TestDynamoDBLedgerStorage_RecordTransaction_Unbalanced(t *testing.T) {
+	mockClient := new(MockDynamoDBClient)
+	storage := NewDynamoDBLedgerStorage(mockClient, "test-customers", "test-ledger")
+
+	txn := testCharge("charge:job-1", "customer-1", 12.50, time.Now())
+	txn.Postings = txn.Postings[:1]
+
+	err := storage.RecordTransaction(context.Background(), txn)
+
+	assert.ErrorIs(t, err, ErrUnbalancedTransaction)
+	mockClient.AssertNotCalled(t, "TransactWriteItems", mock.Anything, mock.Anything)
+}
+
+func TestDynamoDBLedgerStorage_GetLedgerEntries_ReadsEveryPage(t *testing.T) {
+	mockClient := new(MockDynamoDBClient)
+	storage := NewDynamoDBLedgerStorage(mockClient, "test-customers", "test-ledger")
+
+	from := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	item := func(id string, at time.Time) map[string]types.AttributeValue {
+		entry, _ := attributevalue.MarshalMap(&LedgerEntry{CustomerID: "customer-1", EntryID: at.Format(entryTimeFormat) + "#" + id, TransactionID: id, CreatedAt: at})
+		return entry
+	}
+	lastKey := map[string]types.AttributeValue{"entry_id": &types.AttributeValueMemberS{Value: "page-1"}}
+
+	mockClient.On("Query", mock.Anything, mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+		return input.ExclusiveStartKey == nil && strings.Contains(*input.KeyConditionExpression, "BETWEEN") &&
+			input.ExpressionAttributeValues[":from"].(*types.AttributeValueMemberS).Value == "2026-10-01T00:00:00.000000000Z"
+	})).Return(&dynamodb.QueryOutput{
+		Items:            []map[string]types.AttributeValue{item("a", from.Add(time.Hour))},
+		LastEvaluatedKey: lastKey,
+	}, nil)
+	mockClient.On("Query", mock.Anything, mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+		return input.ExclusiveStartKey != nil
+	})).Return(&dynamodb.QueryOutput{
+		Items: []map[string]types.AttributeValue{item("b", from.Add(48*time.Hour)), item("c", to)},
+	}, nil)
+
+	entries, err := storage.GetLedgerEntries(context.Background(), "customer-1", from, to)
+
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "a", entries[0].TransactionID)
+		assert.Equal(t, "b", entries[1].TransactionID)
+	}
+	mockClient.AssertExpectations(t)
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryLedgerStorage implements LedgerStorage using in-memory maps
+type MemoryLedgerStorage struct {
+	customers    map[string]*Customer
+	entries      map[string][]*LedgerEntry // by customer ID, in entry ID order
+	transactions map[string]bool           // IDs of recorded transactions
+	mu           sync.RWMutex
+}
+
+// NewMemoryLedgerStorage creates a new in-memory ledger
+func NewMemoryLedgerStorage() *MemoryLedgerStorage {
+	return &MemoryLedgerStorage{
+		customers:    make(map[string]*Customer),
+		entries:      make(map[string][]*LedgerEntry),
+		transactions: make(map[string]bool),
+	}
+}
+
+func (m *MemoryLedgerStorage) CreateCustomer(ctx context.Context, customer *Customer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.customers[customer.ID]; exists {
+		return fmt.Errorf("%w: %s", ErrCustomerExists, customer.ID)
+	}
+
+	stored := *customer
+	m.customers[customer.ID] = &stored
+	return nil
+}
+
+func (m *MemoryLedgerStorage) GetCustomer(ctx context.Context, customerID string) (*Customer, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	customer, exists := m.customers[customerID]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrCustomerNotFound, customerID)
+	}
+
+	found := *customer
+	return &found, nil
+}
+
+func (m *MemoryLedgerStorage) RecordTransaction(ctx context.Context, txn *LedgerTransaction) error {
+	entries, err := ledgerEntries(txn)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.transactions[txn.ID] {
+		return fmt.Errorf("%w: %s", ErrDuplicateTransaction, txn.ID)
+	}
+	m.transactions[txn.ID] = true
+
+	ledger := append(m.entries[txn.CustomerID], entries...)
+	sort.SliceStable(ledger, func(a, b int) bool {
+		return ledger[a].EntryID < ledger[b].EntryID
+	})
+	m.entries[txn.CustomerID] = ledger
+	return nil
+}
+
+func (m *MemoryLedgerStorage) GetLedgerEntries(ctx context.Context, customerID string, from, to time.Time) ([]*LedgerEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result []*LedgerEntry
+	for _, entry := range m.entries[customerID] {
+		if !entry.CreatedAt.Before(from) && entry.CreatedAt.Before(to) {
+			found := *entry
+			result = append(result, &found)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// testCharge is a balanced charge of amount to a customer for a job
+func testCharge(id, customerID string, amount float64, at time.Time) *LedgerTransaction {
+	return &LedgerTransaction{
+		ID:         id,
+		CustomerID: customerID,
+		Type:       TransactionCharge,
+		JobID:      "job-" + id,
+		CreatedAt:  at,
+		Postings: []Posting{
+			{Account: AccountReceivable, Amount: amount},
+			{Account: AccountFareRevenue, Amount: -amount},
+		},
+	}
+}
+
+func TestMemoryLedgerStorage_Customers(t *testing.T) {
+	storage := NewMemoryLedgerStorage()
+	ctx := context.Background()
+
+	if _, err := storage.GetCustomer(ctx, "customer-1"); !errors.Is(err, ErrCustomerNotFound) {
+		t.Errorf("Expected ErrCustomerNotFound, got %v", err)
+	}
+
+	customer := &Customer{ID: "customer-1", Name: "Ada", PaymentMethod: "card-1"}
+	if err := storage.CreateCustomer(ctx, customer); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := storage.CreateCustomer(ctx, &Customer{ID: "customer-1"}); !errors.Is(err, ErrCustomerExists) {
+		t.Errorf("Expected ErrCustomerExists, got %v", err)
+	}
+
+	found, err := storage.GetCustomer(ctx, "customer-1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if found.Name != "Ada" || found.PaymentMethod != "card-1" {
+		t.Errorf("Expected the stored customer, got %+v", found)
+	}
+}
+
+func TestMemoryLedgerStorage_RecordTransaction(t *testing.T) {
+	storage := NewMemoryLedgerStorage()
+	ctx := context.Background()
+	now := time.Now()
+
+	if err := storage.RecordTransaction(ctx, testCharge("charge-1", "customer-1", 12.50, now)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := storage.RecordTransaction(ctx, testCharge("charge-1", "customer-1", 12.50, now)); !errors.Is(err, ErrDuplicateTransaction) {
+		t.Errorf("Expected ErrDuplicateTransaction, got %v", err)
+	}
+
+	unbalanced := testCharge("charge-2", "customer-1", 12.50, now)
+	unbalanced.Postings[1].Amount = -10.00
+	if err := storage.RecordTransaction(ctx, unbalanced); !errors.Is(err, ErrUnbalancedTransaction) {
+		t.Errorf("Expected ErrUnbalancedTransaction, got %v", err)
+	}
+
+	entries, _ := storage.GetLedgerEntries(ctx, "customer-1", time.Time{}, now.Add(time.Hour))
+	if len(entries) != 2 {
+		t.Fatalf("Expected only the first charge's 2 entries, got %d", len(entries))
+	}
+	if entries[0].TransactionID != "charge-1" || entries[0].JobID != "job-charge-1" {
+		t.Errorf("Expected entries to carry the transaction details, got %+v", entries[0])
+	}
+}
+
+func TestMemoryLedgerStorage_GetLedgerEntries(t *testing.T) {
+	storage := NewMemoryLedgerStorage()
+	ctx := context.Background()
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	// Recorded out of order, as concurrent completions may be
+	storage.RecordTransaction(ctx, testCharge("c", "customer-1", 3, start.Add(72*time.Hour)))
+	storage.RecordTransaction(ctx, testCharge("a", "customer-1", 1, start))
+	storage.RecordTransaction(ctx, testCharge("b", "customer-1", 2, start.Add(24*time.Hour)))
+	storage.RecordTransaction(ctx, testCharge("other", "customer-2", 9, start.Add(24*time.Hour)))
+
+	entries, err := storage.GetLedgerEntries(ctx, "customer-1", start.Add(time.Hour), start.Add(72*time.Hour))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(entries) != 2 || entries[0].TransactionID != "b" {
+		t.Fatalf("Expected just charge b in the range, got %d entries", len(entries))
+	}
+
+	all, _ := storage.GetLedgerEntries(ctx, "customer-1", time.Time{}, start.Add(96*time.Hour))
+	var order []string
+	for i := 0; i < len(all); i += 2 {
+		order = append(order, all[i].TransactionID)
+	}
+	if len(order) != 3 || order[0] != "a" || order[1] != "b" || order[2] != "c" {
+		t.Errorf("Expected entries oldest first, got %v", order)
+	}
+}
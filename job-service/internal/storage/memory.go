@@ -29,6 +29,7 @@ func (m *MemoryJobStorage) CreateJob(ctx context.Context, job *Job) error {
 	}
 
 	job.CreatedAt = time.Now()
+	job.Version = 1
 	m.jobs[job.ID] = job
 	return nil
 }
@@ -49,10 +50,16 @@ func (m *MemoryJobStorage) UpdateJob(ctx context.Context, job *Job) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if _, exists := m.jobs[job.ID]; !exists {
+	existing, exists := m.jobs[job.ID]
+	if !exists {
 		return fmt.Errorf("job %s not found", job.ID)
 	}
 
+	if existing.Version != job.Version {
+		return ErrVersionConflict
+	}
+
+	job.Version++
 	m.jobs[job.ID] = job
 	return nil
 }
@@ -71,6 +78,20 @@ func (m *MemoryJobStorage) GetJobsByStatus(ctx context.Context, status string) (
 	return result, nil
 }
 
+func (m *MemoryJobStorage) GetJobsByRevenueStatus(ctx context.Context, revenueStatus string) ([]*Job, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result []*Job
+	for _, job := range m.jobs {
+		if job.RevenueStatus == revenueStatus {
+			result = append(result, job)
+		}
+	}
+
+	return result, nil
+}
+
 func (m *MemoryJobStorage) GetJobsByVehicle(ctx context.Context, vehicleID string) ([]*Job, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -85,6 +106,20 @@ func (m *MemoryJobStorage) GetJobsByVehicle(ctx context.Context, vehicleID strin
 	return result, nil
 }
 
+func (m *MemoryJobStorage) GetJobsByCustomer(ctx context.Context, customerID string) ([]*Job, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result []*Job
+	for _, job := range m.jobs {
+		if job.CustomerID == customerID {
+			result = append(result, job)
+		}
+	}
+
+	return result, nil
+}
+
 func (m *MemoryJobStorage) GetAllJobs(ctx context.Context) ([]*Job, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -108,13 +143,18 @@ func (m *MemoryJobStorage) UpdateJobStatus(ctx context.Context, jobID, status st
 
 	job.Status = status
 	job.AssignedVehicleID = vehicleID
+	job.Version++
 
 	now := time.Now()
 	switch status {
 	case "assigned":
 		job.AssignedAt = &now
+	case "in_progress":
+		job.PickedUpAt = &now
 	case "completed":
 		job.CompletedAt = &now
+	case "failed":
+		job.FailedAt = &now
 	}
 
 	return nil
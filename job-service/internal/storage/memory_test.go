@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"testing"
 )
 
@@ -175,6 +176,23 @@ func TestMemoryJobStorage_GetJobsByStatus(t *testing.T) {
 	}
 }
 
+func TestMemoryJobStorage_GetJobsByRevenueStatus(t *testing.T) {
+	storage := NewMemoryJobStorage()
+	ctx := context.Background()
+
+	storage.CreateJob(ctx, &Job{ID: "job1", JobType: "ride", Status: "completed", RevenueStatus: RevenueStatusPending, Region: "us-west-2"})
+	storage.CreateJob(ctx, &Job{ID: "job2", JobType: "ride", Status: "completed", Region: "us-west-2"})
+
+	jobs, err := storage.GetJobsByRevenueStatus(ctx, RevenueStatusPending)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(jobs) != 1 || jobs[0].ID != "job1" {
+		t.Errorf("Expected only job1 to be pending revenue, got %v", jobs)
+	}
+}
+
 func TestMemoryJobStorage_GetJobsByVehicle(t *testing.T) {
 	storage := NewMemoryJobStorage()
 	ctx := context.Background()
@@ -210,3 +228,68 @@ func TestMemoryJobStorage_GetJobsByVehicle(t *testing.T) {
 		t.Error("Expected jobs job1 and job3 to be returned")
 	}
 }
+
+func TestMemoryJobStorage_UpdateJob_VersionConflict(t *testing.T) {
+	storage := NewMemoryJobStorage()
+	ctx := context.Background()
+
+	storage.CreateJob(ctx, &Job{
+		ID:         "test-job-1",
+		JobType:    "ride",
+		Status:     "pending",
+		CustomerID: "customer-123",
+		Region:     "us-west-2",
+	})
+
+	stored, _ := storage.GetJob(ctx, "test-job-1")
+	if stored.Version != 1 {
+		t.Fatalf("Expected new job at version 1, got %d", stored.Version)
+	}
+	stale := *stored
+
+	// Another writer advances the job first
+	vehicleID := "vehicle-123"
+	storage.UpdateJobStatus(ctx, "test-job-1", "assigned", &vehicleID)
+
+	stale.Status = "completed"
+	if err := storage.UpdateJob(ctx, &stale); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("Expected ErrVersionConflict, got %v", err)
+	}
+
+	current, _ := storage.GetJob(ctx, "test-job-1")
+	if current.Status != "assigned" {
+		t.Errorf("Expected status 'assigned' to survive, got '%s'", current.Status)
+	}
+
+	fresh := *current
+	fresh.Status = "completed"
+	if err := storage.UpdateJob(ctx, &fresh); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if fresh.Version != 3 {
+		t.Errorf("Expected version 3, got %d", fresh.Version)
+	}
+}
+
+func TestMemoryJobStorage_GetJobsByCustomer(t *testing.T) {
+	storage := NewMemoryJobStorage()
+	ctx := context.Background()
+
+	storage.CreateJob(ctx, &Job{ID: "job1", JobType: "ride", Status: "scheduled", CustomerID: "customer1", Region: "us-west-2"})
+	storage.CreateJob(ctx, &Job{ID: "job2", JobType: "ride", Status: "pending", CustomerID: "customer2", Region: "us-west-2"})
+	storage.CreateJob(ctx, &Job{ID: "job3", JobType: "delivery", Status: "completed", CustomerID: "customer1", Region: "us-west-2"})
+
+	customerJobs, err := storage.GetJobsByCustomer(ctx, "customer1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(customerJobs) != 2 {
+		t.Errorf("Expected 2 jobs for customer, got %d", len(customerJobs))
+	}
+	for _, j := range customerJobs {
+		if j.CustomerID != "customer1" {
+			t.Errorf("Expected only customer1's jobs, got job %s for %s", j.ID, j.CustomerID)
+		}
+	}
+}
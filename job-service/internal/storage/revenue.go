@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+// FareBinWidth is the width in dollars of the fare histogram bins rollups keep
+const FareBinWidth = 0.10
+
+// ErrRevenueAlreadyRecorded is returned when adding a job the revenue rollups already have
+var ErrRevenueAlreadyRecorded = errors.New("job revenue already recorded")
+
+// FareBin returns the histogram bin a fare falls in
+func FareBin(fare float64) int {
+	return int(math.Floor(fare / FareBinWidth))
+}
+
+// RevenueRollup is the revenue from jobs finished within one hour in one region, of one
+// job type, served by one vehicle. Rollups are added to as jobs finish, so reports
+// never scan the jobs themselves.
+type RevenueRollup struct {
+	Hour      time.Time `json:"hour" dynamodbav:"hour_start"`
+	Region    string    `json:"region" dynamodbav:"region"`
+	JobType   string    `json:"job_type" dynamodbav:"job_type"`
+	VehicleID string    `json:"vehicle_id,omitempty" dynamodbav:"vehicle_id"` // empty for jobs cancelled before dispatch
+
+	FareRevenue      float64 `json:"fare_revenue" dynamodbav:"fare_revenue"`
+	CancellationFees float64 `json:"cancellation_fees" dynamodbav:"cancellation_fees"`
+	SurgeRevenue     float64 `json:"surge_revenue" dynamodbav:"surge_revenue"`
+	CompletedJobs    int     `json:"completed_jobs" dynamodbav:"completed_jobs"`
+	CancelledJobs    int     `json:"cancelled_jobs" dynamodbav:"cancelled_jobs"`
+	// Hours vehicles spent on the completed jobs, from assignment to drop-off
+	VehicleHours float64 `json:"vehicle_hours" dynamodbav:"vehicle_hours"`
+	// Completed jobs by fare, keyed by FareBin
+	FareHistogram map[int]int `json:"fare_histogram,omitempty" dynamodbav:"-"`
+}
+
+// Add merges another rollup's amounts into this one
+func (r *RevenueRollup) Add(other *RevenueRollup) {
+	r.FareRevenue += other.FareRevenue
+	r.CancellationFees += other.CancellationFees
+	r.SurgeRevenue += other.SurgeRevenue
+	r.CompletedJobs += other.CompletedJobs
+	r.CancelledJobs += other.CancelledJobs
+	r.VehicleHours += other.VehicleHours
+
+	if len(other.FareHistogram) > 0 && r.FareHistogram == nil {
+		r.FareHistogram = make(map[int]int)
+	}
+	for bin, count := range other.FareHistogram {
+		r.FareHistogram[bin] += count
+	}
+}
+
+// RevenueStorage defines the interface for revenue rollup operations
+type RevenueStorage interface {
+	// AddRevenue adds a finished job's amounts to the rollup for its hour, region,
+	// job type and vehicle, creating the rollup if needed. Each job is added once:
+	// adding it again changes nothing and returns ErrRevenueAlreadyRecorded, so a
+	// failed add can always be retried. delta.Hour must be truncated to the hour.
+	AddRevenue(ctx context.Context, jobID string, delta *RevenueRollup) error
+
+	// GetRevenueRollups returns the rollups for hours in [from, to)
+	GetRevenueRollups(ctx context.Context, from, to time.Time) ([]*RevenueRollup, error)
+}
@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// fareBinPrefix names the attributes holding a rollup's fare histogram. DynamoDB can
+// only add to top-level numbers, so each bin is an attribute of its own.
+const fareBinPrefix = "fare_bin_"
+
+// DynamoDBRevenueStorage keeps revenue rollups partitioned by day, sorted by hour
+// within the day, so a report reads one partition per day it covers. Each rollup holds
+// the IDs of the jobs added to it, and an add is conditional on its job not being one.
+type DynamoDBRevenueStorage struct {
+	client    DynamoDBAPI
+	tableName string
+}
+
+func NewDynamoDBRevenueStorage(client DynamoDBAPI, tableName string) *DynamoDBRevenueStorage {
+	return &DynamoDBRevenueStorage{
+		client:    client,
+		tableName: tableName,
+	}
+}
+
+// revenueKey returns the partition and sort key of a rollup
+func revenueKey(hour time.Time, region, jobType, vehicleID string) map[string]types.AttributeValue {
+	hour = hour.UTC()
+	return map[string]types.AttributeValue{
+		"day":    &types.AttributeValueMemberS{Value: hour.Format(time.DateOnly)},
+		"bucket": &types.AttributeValueMemberS{Value: fmt.Sprintf("%02d#%s#%s#%s", hour.Hour(), region, jobType, vehicleID)},
+	}
+}
+
+// numberValue renders a number attribute value
+func numberValue(value float64) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: strconv.FormatFloat(value, 'f', -1, 64)}
+}
+
+func (d *DynamoDBRevenueStorage) AddRevenue(ctx context.Context, jobID string, delta *RevenueRollup) error {
+	names := map[string]string{"#region": "region"}
+	values := map[string]types.AttributeValue{
+		":jobID":            &types.AttributeValueMemberS{Value: jobID},
+		":jobIDs":           &types.AttributeValueMemberSS{Value: []string{jobID}},
+		":hour":             &types.AttributeValueMemberS{Value: delta.Hour.UTC().Format(time.RFC3339)},
+		":region":           &types.AttributeValueMemberS{Value: delta.Region},
+		":jobType":          &types.AttributeValueMemberS{Value: delta.JobType},
+		":vehicleID":        &types.AttributeValueMemberS{Value: delta.VehicleID},
+		":fareRevenue":      numberValue(delta.FareRevenue),
+		":cancellationFees": numberValue(delta.CancellationFees),
+		":surgeRevenue":     numberValue(delta.SurgeRevenue),
+		":completedJobs":    numberValue(float64(delta.CompletedJobs)),
+		":cancelledJobs":    numberValue(float64(delta.CancelledJobs)),
+		":vehicleHours":     numberValue(delta.VehicleHours),
+	}
+
+	update := "SET hour_start = :hour, #region = :region, job_type = :jobType, vehicle_id = :vehicleID " +
+		"ADD fare_revenue :fareRevenue, cancellation_fees :cancellationFees, surge_revenue :surgeRevenue, " +
+		"completed_jobs :completedJobs, cancelled_jobs :cancelledJobs, vehicle_hours :vehicleHours, job_ids :jobIDs"
+	for bin, count := range delta.FareHistogram {
+		name := fmt.Sprintf("#bin%d", len(names))
+		value := fmt.Sprintf(":bin%d", len(names))
+		names[name] = fareBinPrefix + strconv.Itoa(bin)
+		values[value] = numberValue(float64(count))
+		update += fmt.Sprintf(", %s %s", name, value)
+	}
+
+	_, err := d.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(d.tableName),
+		Key:                       revenueKey(delta.Hour, delta.Region, delta.JobType, delta.VehicleID),
+		UpdateExpression:          aws.String(update),
+		ConditionExpression:       aws.String("NOT contains(job_ids, :jobID)"),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	})
+	if err != nil {
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return fmt.Errorf("%w: %s", ErrRevenueAlreadyRecorded, jobID)
+		}
+		return fmt.Errorf("failed to add revenue: %w", err)
+	}
+
+	return nil
+}
+
+func (d *DynamoDBRevenueStorage) GetRevenueRollups(ctx context.Context, from, to time.Time) ([]*RevenueRollup, error) {
+	var rollups []*RevenueRollup
+
+	for day := from.UTC().Truncate(24 * time.Hour); day.Before(to); day = day.Add(24 * time.Hour) {
+		input := &dynamodb.QueryInput{
+			TableName:              aws.String(d.tableName),
+			KeyConditionExpression: aws.String("#day = :day"),
+			ExpressionAttributeNames: map[string]string{
+				"#day": "day",
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":day": &types.AttributeValueMemberS{Value: day.Format(time.DateOnly)},
+			},
+		}
+
+		for {
+			result, err := d.client.Query(ctx, input)
+			if err != nil {
+				return nil, fmt.Errorf("failed to query revenue rollups: %w", err)
+			}
+
+			for _, item := range result.Items {
+				rollup, err := unmarshalRevenueRollup(item)
+				if err != nil {
+					return nil, err
+				}
+				if !rollup.Hour.Before(from) && rollup.Hour.Before(to) {
+					rollups = append(rollups, rollup)
+				}
+			}
+
+			if len(result.LastEvaluatedKey) == 0 {
+				break
+			}
+			input.ExclusiveStartKey = result.LastEvaluatedKey
+		}
+	}
+
+	return rollups, nil
+}
+
+// unmarshalRevenueRollup decodes a rollup item, collecting its fare bin attributes
+func unmarshalRevenueRollup(item map[string]types.AttributeValue) (*RevenueRollup, error) {
+	var rollup RevenueRollup
+	if err := attributevalue.UnmarshalMap(item, &rollup); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal revenue rollup: %w", err)
+	}
+
+	for name, value := range item {
+		if !strings.HasPrefix(name, fareBinPrefix) {
+			continue
+		}
+		bin, err := strconv.Atoi(strings.TrimPrefix(name, fareBinPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal revenue rollup: invalid fare bin %q", name)
+		}
+		var count int
+		if err := attributevalue.Unmarshal(value, &count); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal revenue rollup: %w", err)
+		}
+		if rollup.FareHistogram == nil {
+			rollup.FareHistogram = make(map[int]int)
+		}
+		rollup.FareHistogram[bin] = count
+	}
+
+	return &rollup, nil
+}
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDynamoDBRevenueStorage_AddRevenue(t *testing.T) {
+	mockClient := new(MockDynamoDBClient)
+	storage := NewDynamoDBRevenueStorage(mockClient, "test-revenue")
+
+	mockClient.On("UpdateItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.UpdateItemInput) bool {
+		day := input.Key["day"].(*types.AttributeValueMemberS).Value
+		bucket := input.Key["bucket"].(*types.AttributeValueMemberS).Value
+		binName := input.ExpressionAttributeNames["#bin1"]
+		binCount := input.ExpressionAttributeValues[":bin1"].(*types.AttributeValueMemberN).Value
+		fare := input.ExpressionAttributeValues[":fareRevenue"].(*types.AttributeValueMemberN).Value
+		return *input.TableName == "test-revenue" && day == "2026-10-01" && bucket == "09#us-west-2#ride#vehicle-1" &&
+			strings.Contains(*input.UpdateExpression, "ADD fare_revenue :fareRevenue") &&
+			binName == "fare_bin_123" && binCount == "1" && fare == "12.34" &&
+			*input.ConditionExpression == "NOT contains(job_ids, :jobID)" &&
+			input.ExpressionAttributeValues[":jobID"].(*types.AttributeValueMemberS).Value == "job-1"
+	})).Return(&dynamodb.UpdateItemOutput{}, nil)
+
+	err := storage.AddRevenue(context.Background(), "job-1", &RevenueRollup{
+		Hour:          time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC),
+		Region:        "us-west-2",
+		JobType:       "ride",
+		VehicleID:     "vehicle-1",
+		FareRevenue:   12.34,
+		CompletedJobs: 1,
+		FareHistogram: map[int]int{123: 1},
+	})
+
+	assert.NoError(t, err)
+	mockClient.AssertExpectations(t)
+}
+
+func TestDynamoDBRevenueStorage_AddRevenue_AlreadyRecorded(t *testing.T) {
+	mockClient := new(MockDynamoDBClient)
+	storage := NewDynamoDBRevenueStorage(mockClient, "test-revenue")
+
+	mockClient.On("UpdateItem", mock.Anything, mock.Anything).
+		Return((*dynamodb.UpdateItemOutput)(nil), &types.ConditionalCheckFailedException{})
+
+	err := storage.AddRevenue(context.Background(), "job-1", &RevenueRollup{
+		Hour:          time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC),
+		Region:        "us-west-2",
+		JobType:       "ride",
+		FareRevenue:   12.34,
+		CompletedJobs: 1,
+	})
+
+	assert.ErrorIs(t, err, ErrRevenueAlreadyRecorded)
+}
+
+func TestDynamoDBRevenueStorage_GetRevenueRollups_QueriesEachDay(t *testing.T) {
+	mockClient := new(MockDynamoDBClient)
+	storage := NewDynamoDBRevenueStorage(mockClient, "test-revenue")
+
+	from := time.Date(2026, 10, 1, 22, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 10, 2, 2, 0, 0, 0, time.UTC)
+	item := func(hour time.Time, fare string) map[string]types.AttributeValue {
+		return map[string]types.AttributeValue{
+			"day":            &types.AttributeValueMemberS{Value: hour.Format(time.DateOnly)},
+			"hour_start":     &types.AttributeValueMemberS{Value: hour.Format(time.RFC3339)},
+			"region":         &types.AttributeValueMemberS{Value: "us-west-2"},
+			"job_type":       &types.AttributeValueMemberS{Value: "ride"},
+			"fare_revenue":   &types.AttributeValueMemberN{Value: fare},
+			"completed_jobs": &types.AttributeValueMemberN{Value: "1"},
+			"fare_bin_50":    &types.AttributeValueMemberN{Value: "1"},
+		}
+	}
+	dayQuery := func(day string) interface{} {
+		return mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+			return input.ExpressionAttributeValues[":day"].(*types.AttributeValueMemberS).Value == day
+		})
+	}
+
+	mockClient.On("Query", mock.Anything, dayQuery("2026-10-01")).Return(&dynamodb.QueryOutput{
+		Items: []map[string]types.AttributeValue{item(from.Add(-time.Hour), "1"), item(from, "5")},
+	}, nil)
+	mockClient.On("Query", mock.Anything, dayQuery("2026-10-02")).Return(&dynamodb.QueryOutput{
+		Items: []map[string]types.AttributeValue{item(to.Add(-time.Hour), "7"), item(to, "9")},
+	}, nil)
+
+	rollups, err := storage.GetRevenueRollups(context.Background(), from, to)
+
+	assert.NoError(t, err)
+	if assert.Len(t, rollups, 2) {
+		assert.Equal(t, 5.0, rollups[0].FareRevenue)
+		assert.Equal(t, 7.0, rollups[1].FareRevenue)
+		assert.Equal(t, map[int]int{50: 1}, rollups[0].FareHistogram)
+	}
+	mockClient.AssertExpectations(t)
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// revenueRollupKey identifies a rollup
+type revenueRollupKey struct {
+	hour      int64 // Unix time of the start of the hour
+	region    string
+	jobType   string
+	vehicleID string
+}
+
+// MemoryRevenueStorage implements RevenueStorage using an in-memory map
+type MemoryRevenueStorage struct {
+	rollups  map[revenueRollupKey]*RevenueRollup
+	recorded map[string]bool // jobs added to the rollups
+	mu       sync.RWMutex
+}
+
+// NewMemoryRevenueStorage creates a new in-memory revenue rollup store
+func NewMemoryRevenueStorage() *MemoryRevenueStorage {
+	return &MemoryRevenueStorage{
+		rollups:  make(map[revenueRollupKey]*RevenueRollup),
+		recorded: make(map[string]bool),
+	}
+}
+
+func (m *MemoryRevenueStorage) AddRevenue(ctx context.Context, jobID string, delta *RevenueRollup) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.recorded[jobID] {
+		return fmt.Errorf("%w: %s", ErrRevenueAlreadyRecorded, jobID)
+	}
+
+	key := revenueRollupKey{
+		hour:      delta.Hour.Unix(),
+		region:    delta.Region,
+		jobType:   delta.JobType,
+		vehicleID: delta.VehicleID,
+	}
+	rollup, exists := m.rollups[key]
+	if !exists {
+		rollup = &RevenueRollup{
+			Hour:      delta.Hour.UTC(),
+			Region:    delta.Region,
+			JobType:   delta.JobType,
+			VehicleID: delta.VehicleID,
+		}
+		m.rollups[key] = rollup
+	}
+
+	rollup.Add(delta)
+	m.recorded[jobID] = true
+	return nil
+}
+
+func (m *MemoryRevenueStorage) GetRevenueRollups(ctx context.Context, from, to time.Time) ([]*RevenueRollup, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result []*RevenueRollup
+	for _, rollup := range m.rollups {
+		if !rollup.Hour.Before(from) && rollup.Hour.Before(to) {
+			found := *rollup
+			found.FareHistogram = make(map[int]int, len(rollup.FareHistogram))
+			for bin, count := range rollup.FareHistogram {
+				found.FareHistogram[bin] = count
+			}
+			result = append(result, &found)
+		}
+	}
+
+	return result, nil
+}
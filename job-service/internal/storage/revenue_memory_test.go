@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryRevenueStorage_AddRevenue_Accumulates(t *testing.T) {
+	storage := NewMemoryRevenueStorage()
+	ctx := context.Background()
+	hour := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+
+	ride := func(fare float64) *RevenueRollup {
+		return &RevenueRollup{Hour: hour, Region: "us-west-2", JobType: "ride", VehicleID: "vehicle-1",
+			FareRevenue: fare, CompletedJobs: 1, VehicleHours: 0.5, FareHistogram: map[int]int{FareBin(fare): 1}}
+	}
+	assert.NoError(t, storage.AddRevenue(ctx, "job-1", ride(12.34)))
+	assert.NoError(t, storage.AddRevenue(ctx, "job-2", ride(12.30)))
+	assert.NoError(t, storage.AddRevenue(ctx, "job-3", &RevenueRollup{Hour: hour, Region: "us-west-2", JobType: "ride", CancellationFees: 5, CancelledJobs: 1}))
+
+	rollups, err := storage.GetRevenueRollups(ctx, hour, hour.Add(time.Hour))
+
+	assert.NoError(t, err)
+	assert.Len(t, rollups, 2)
+	for _, rollup := range rollups {
+		if rollup.VehicleID == "vehicle-1" {
+			assert.InDelta(t, 24.64, rollup.FareRevenue, 1e-9)
+			assert.Equal(t, 2, rollup.CompletedJobs)
+			assert.InDelta(t, 1.0, rollup.VehicleHours, 1e-9)
+			assert.Equal(t, map[int]int{123: 2}, rollup.FareHistogram)
+		} else {
+			assert.Equal(t, 5.0, rollup.CancellationFees)
+			assert.Equal(t, 1, rollup.CancelledJobs)
+		}
+	}
+}
+
+func TestMemoryRevenueStorage_AddRevenue_OncePerJob(t *testing.T) {
+	storage := NewMemoryRevenueStorage()
+	ctx := context.Background()
+	hour := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	delta := &RevenueRollup{Hour: hour, Region: "us-west-2", JobType: "ride", FareRevenue: 10, CompletedJobs: 1}
+
+	assert.NoError(t, storage.AddRevenue(ctx, "job-1", delta))
+	err := storage.AddRevenue(ctx, "job-1", delta)
+
+	assert.ErrorIs(t, err, ErrRevenueAlreadyRecorded)
+	rollups, _ := storage.GetRevenueRollups(ctx, hour, hour.Add(time.Hour))
+	if assert.Len(t, rollups, 1) {
+		assert.Equal(t, 10.0, rollups[0].FareRevenue)
+		assert.Equal(t, 1, rollups[0].CompletedJobs)
+	}
+}
+
+func TestMemoryRevenueStorage_GetRevenueRollups_Window(t *testing.T) {
+	storage := NewMemoryRevenueStorage()
+	ctx := context.Background()
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	for hour := 0; hour < 4; hour++ {
+		assert.NoError(t, storage.AddRevenue(ctx, fmt.Sprintf("job-%d", hour), &RevenueRollup{Hour: start.Add(time.Duration(hour) * time.Hour), Region: "us-west-2", JobType: "ride", FareRevenue: 10}))
+	}
+
+	rollups, err := storage.GetRevenueRollups(ctx, start.Add(time.Hour), start.Add(3*time.Hour))
+
+	assert.NoError(t, err)
+	assert.Len(t, rollups, 2)
+
+	// Rollups are copies
+	rollups[0].FareRevenue = 0
+	again, _ := storage.GetRevenueRollups(ctx, start, start.Add(4*time.Hour))
+	for _, rollup := range again {
+		assert.Equal(t, 10.0, rollup.FareRevenue)
+	}
+}
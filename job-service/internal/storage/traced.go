@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"shared/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("job-service/internal/storage")
+
+// startSpan starts a client span for a storage operation
+func startSpan(ctx context.Context, store, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, store+"."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(append(attrs, attribute.String("db.operation.name", operation))...))
+}
+
+// endListSpan ends a span for an operation returning count items
+func endListSpan(span trace.Span, count int, err error) {
+	span.SetAttributes(attribute.Int("db.response.returned_rows", count))
+	tracing.End(span, err)
+}
+
+// TracedJobStorage records a span for every call to the job storage it wraps
+type TracedJobStorage struct {
+	next JobStorage
+}
+
+// NewTracedJobStorage wraps next so its calls show up in request traces
+func NewTracedJobStorage(next JobStorage) *TracedJobStorage {
+	return &TracedJobStorage{next: next}
+}
+
+func (s *TracedJobStorage) CreateJob(ctx context.Context, job *Job) (err error) {
+	ctx, span := startSpan(ctx, "JobStorage", "CreateJob", tracing.JobID(job.ID))
+	defer func() { tracing.End(span, err) }()
+	return s.next.CreateJob(ctx, job)
+}
+
+func (s *TracedJobStorage) GetJob(ctx context.Context, jobID string) (job *Job, err error) {
+	ctx, span := startSpan(ctx, "JobStorage", "GetJob", tracing.JobID(jobID))
+	defer func() { tracing.End(span, err) }()
+	return s.next.GetJob(ctx, jobID)
+}
+
+func (s *TracedJobStorage) UpdateJob(ctx context.Context, job *Job) (err error) {
+	ctx, span := startSpan(ctx, "JobStorage", "UpdateJob", tracing.JobID(job.ID), attribute.String("job.status", job.Status))
+	defer func() { tracing.End(span, err) }()
+	return s.next.UpdateJob(ctx, job)
+}
+
+func (s *TracedJobStorage) GetJobsByStatus(ctx context.Context, status string) (jobs []*Job, err error) {
+	ctx, span := startSpan(ctx, "JobStorage", "GetJobsByStatus", attribute.String("job.status", status))
+	defer func() { endListSpan(span, len(jobs), err) }()
+	return s.next.GetJobsByStatus(ctx, status)
+}
+
+func (s *TracedJobStorage) GetJobsByRevenueStatus(ctx context.Context, revenueStatus string) (jobs []*Job, err error) {
+	ctx, span := startSpan(ctx, "JobStorage", "GetJobsByRevenueStatus", attribute.String("job.revenue_status", revenueStatus))
+	defer func() { endListSpan(span, len(jobs), err) }()
+	return s.next.GetJobsByRevenueStatus(ctx, revenueStatus)
+}
+
+func (s *TracedJobStorage) GetJobsByVehicle(ctx context.Context, vehicleID string) (jobs []*Job, err error) {
+	ctx, span := startSpan(ctx, "JobStorage", "GetJobsByVehicle", tracing.VehicleID(vehicleID))
+	defer func() { endListSpan(span, len(jobs), err) }()
+	return s.next.GetJobsByVehicle(ctx, vehicleID)
+}
+
+func (s *TracedJobStorage) GetJobsByCustomer(ctx context.Context, customerID string) (jobs []*Job, err error) {
+	ctx, span := startSpan(ctx, "JobStorage", "GetJobsByCustomer", attribute.String("customer.id", customerID))
+	defer func() { endListSpan(span, len(jobs), err) }()
+	return s.next.GetJobsByCustomer(ctx, customerID)
+}
+
+func (s *TracedJobStorage) GetAllJobs(ctx context.Context) (jobs []*Job, err error) {
+	ctx, span := startSpan(ctx, "JobStorage", "GetAllJobs")
+	defer func() { endListSpan(span, len(jobs), err) }()
+	return s.next.GetAllJobs(ctx)
+}
+
+func (s *TracedJobStorage) UpdateJobStatus(ctx context.Context, jobID, status string, vehicleID *string) (err error) {
+	ctx, span := startSpan(ctx, "JobStorage", "UpdateJobStatus", tracing.JobID(jobID), attribute.String("job.status", status))
+	if vehicleID != nil {
+		span.SetAttributes(tracing.VehicleID(*vehicleID))
+	}
+	defer func() { tracing.End(span, err) }()
+	return s.next.UpdateJobStatus(ctx, jobID, status, vehicleID)
+}
+
+// TracedLedgerStorage records a span for every call to the ledger storage it wraps
+type TracedLedgerStorage struct {
+	next LedgerStorage
+}
+
+// NewTracedLedgerStorage wraps next so its calls show up in request traces
+func NewTracedLedgerStorage(next LedgerStorage) *TracedLedgerStorage {
+	return &TracedLedgerStorage{next: next}
+}
+
+func (s *TracedLedgerStorage) CreateCustomer(ctx context.Context, customer *Customer) (err error) {
+	ctx, span := startSpan(ctx, "LedgerStorage", "CreateCustomer", attribute.String("customer.id", customer.ID))
+	defer func() { tracing.End(span, err) }()
+	return s.next.CreateCustomer(ctx, customer)
+}
+
+func (s *TracedLedgerStorage) GetCustomer(ctx context.Context, customerID string) (customer *Customer, err error) {
+	ctx, span := startSpan(ctx, "LedgerStorage", "GetCustomer", attribute.String("customer.id", customerID))
+	defer func() { tracing.End(span, err) }()
+	return s.next.GetCustomer(ctx, customerID)
+}
+
+func (s *TracedLedgerStorage) RecordTransaction(ctx context.Context, txn *LedgerTransaction) (err error) {
+	ctx, span := startSpan(ctx, "LedgerStorage", "RecordTransaction",
+		attribute.String("customer.id", txn.CustomerID), attribute.String("ledger.transaction_type", txn.Type))
+	if txn.JobID != "" {
+		span.SetAttributes(tracing.JobID(txn.JobID))
+	}
+	defer func() { tracing.End(span, err) }()
+	return s.next.RecordTransaction(ctx, txn)
+}
+
+func (s *TracedLedgerStorage) GetLedgerEntries(ctx context.Context, customerID string, from, to time.Time) (entries []*LedgerEntry, err error) {
+	ctx, span := startSpan(ctx, "LedgerStorage", "GetLedgerEntries", attribute.String("customer.id", customerID))
+	defer func() { endListSpan(span, len(entries), err) }()
+	return s.next.GetLedgerEntries(ctx, customerID, from, to)
+}
+
+// TracedRevenueStorage records a span for every call to the revenue storage it wraps
+type TracedRevenueStorage struct {
+	next RevenueStorage
+}
+
+// NewTracedRevenueStorage wraps next so its calls show up in request traces
+func NewTracedRevenueStorage(next RevenueStorage) *TracedRevenueStorage {
+	return &TracedRevenueStorage{next: next}
+}
+
+func (s *TracedRevenueStorage) AddRevenue(ctx context.Context, jobID string, delta *RevenueRollup) (err error) {
+	ctx, span := startSpan(ctx, "RevenueStorage", "AddRevenue", tracing.JobID(jobID),
+		attribute.String("region", delta.Region), attribute.String("job.type", delta.JobType))
+	defer func() { tracing.End(span, err) }()
+	return s.next.AddRevenue(ctx, jobID, delta)
+}
+
+func (s *TracedRevenueStorage) GetRevenueRollups(ctx context.Context, from, to time.Time) (rollups []*RevenueRollup, err error) {
+	ctx, span := startSpan(ctx, "RevenueStorage", "GetRevenueRollups")
+	defer func() { endListSpan(span, len(rollups), err) }()
+	return s.next.GetRevenueRollups(ctx, from, to)
+}
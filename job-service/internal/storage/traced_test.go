@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"shared/tracing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracedJobStorage_RecordsSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	storage := NewTracedJobStorage(NewMemoryJobStorage())
+	ctx := context.Background()
+
+	assert.NoError(t, storage.CreateJob(ctx, &Job{ID: "job-1", Status: "pending"}))
+	_, err := storage.GetJob(ctx, "job-2")
+	assert.Error(t, err)
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 2)
+
+	assert.Equal(t, "JobStorage.CreateJob", spans[0].Name())
+	assert.Contains(t, spans[0].Attributes(), tracing.JobID("job-1"))
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+
+	// A failed call marks its span failed
+	assert.Equal(t, "JobStorage.GetJob", spans[1].Name())
+	assert.Contains(t, spans[1].Attributes(), tracing.JobID("job-2"))
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+}
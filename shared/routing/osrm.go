@@ -0,0 +1,140 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OSRMRouter gets road distances and travel times from an OSRM server
+type OSRMRouter struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewOSRMRouter creates a router for the OSRM server at baseURL
+func NewOSRMRouter(baseURL string) *OSRMRouter {
+	return &OSRMRouter{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client: &http.Client{
+			Timeout: 2 * time.Second,
+		},
+	}
+}
+
+// osrmRouteResponse is the subset of the OSRM route service response we use
+type osrmRouteResponse struct {
+	Code   string `json:"code"`
+	Routes []struct {
+		Distance float64 `json:"distance"` // meters
+		Duration float64 `json:"duration"` // seconds
+	} `json:"routes"`
+}
+
+// osrmTableResponse is the subset of the OSRM table service response we use
+type osrmTableResponse struct {
+	Code      string       `json:"code"`
+	Distances [][]*float64 `json:"distances"` // meters, null when unreachable
+	Durations [][]*float64 `json:"durations"` // seconds, null when unreachable
+}
+
+// Route asks the OSRM route service for the fastest driving route
+func (o *OSRMRouter) Route(ctx context.Context, from, to Point) (Estimate, error) {
+	url := fmt.Sprintf("%s/route/v1/driving/%s?overview=false", o.baseURL, coordinates([]Point{from, to}))
+
+	var resp osrmRouteResponse
+	if err := o.get(ctx, url, &resp); err != nil {
+		return Estimate{}, err
+	}
+	if resp.Code != "Ok" || len(resp.Routes) == 0 {
+		return Estimate{}, fmt.Errorf("osrm found no route: %s", resp.Code)
+	}
+
+	return Estimate{
+		DistanceKm: resp.Routes[0].Distance / 1000,
+		Duration:   time.Duration(resp.Routes[0].Duration * float64(time.Second)),
+	}, nil
+}
+
+// Matrix asks the OSRM table service for all origin to destination trips in one request
+func (o *OSRMRouter) Matrix(ctx context.Context, origins, destinations []Point) ([][]Estimate, error) {
+	if len(origins) == 0 || len(destinations) == 0 {
+		return make([][]Estimate, len(origins)), nil
+	}
+
+	points := append(append([]Point{}, origins...), destinations...)
+	sources := make([]string, len(origins))
+	for i := range origins {
+		sources[i] = strconv.Itoa(i)
+	}
+	targets := make([]string, len(destinations))
+	for k := range destinations {
+		targets[k] = strconv.Itoa(len(origins) + k)
+	}
+
+	url := fmt.Sprintf("%s/table/v1/driving/%s?sources=%s&destinations=%s&annotations=distance,duration",
+		o.baseURL, coordinates(points), strings.Join(sources, ";"), strings.Join(targets, ";"))
+
+	var resp osrmTableResponse
+	if err := o.get(ctx, url, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Code != "Ok" || len(resp.Distances) != len(origins) || len(resp.Durations) != len(origins) {
+		return nil, fmt.Errorf("osrm returned no table: %s", resp.Code)
+	}
+
+	matrix := make([][]Estimate, len(origins))
+	for i := range origins {
+		if len(resp.Distances[i]) != len(destinations) || len(resp.Durations[i]) != len(destinations) {
+			return nil, fmt.Errorf("osrm returned a malformed table")
+		}
+		matrix[i] = make([]Estimate, len(destinations))
+		for k := range destinations {
+			distance, duration := resp.Distances[i][k], resp.Durations[i][k]
+			if distance == nil || duration == nil {
+				// Unreachable by road; make it too far to ever be chosen
+				matrix[i][k] = Estimate{DistanceKm: Unreachable, Duration: time.Duration(1<<63 - 1)}
+				continue
+			}
+			matrix[i][k] = Estimate{
+				DistanceKm: *distance / 1000,
+				Duration:   time.Duration(*duration * float64(time.Second)),
+			}
+		}
+	}
+
+	return matrix, nil
+}
+
+func (o *OSRMRouter) get(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// OSRM answers 400 for NoRoute and similar, with the code in the body
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusBadRequest {
+		return fmt.Errorf("osrm returned status %d", resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// coordinates formats points as OSRM's semicolon separated lng,lat list
+func coordinates(points []Point) string {
+	parts := make([]string, len(points))
+	for i, p := range points {
+		parts[i] = fmt.Sprintf("%f,%f", p.Lng, p.Lat)
+	}
+	return strings.Join(parts, ";")
+}
@@ -0,0 +1,228 @@
+package routing
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+)
+
+// Point is a geographic coordinate
+type Point struct {
+	Lat float64
+	Lng float64
+}
+
+// Estimate is the road distance and driving time between two points
+type Estimate struct {
+	DistanceKm float64
+	Duration   time.Duration
+}
+
+// Unreachable is the distance reported for points with no road between them
+const Unreachable = 1e9
+
+// Minutes returns the driving time in minutes
+func (e Estimate) Minutes() float64 {
+	return e.Duration.Minutes()
+}
+
+// Router estimates road distance and travel time
+type Router interface {
+	// Route estimates the trip from one point to another
+	Route(ctx context.Context, from, to Point) (Estimate, error)
+	// Matrix estimates the trip from every origin to every destination, indexed [origin][destination]
+	Matrix(ctx context.Context, origins, destinations []Point) ([][]Estimate, error)
+}
+
+// DefaultSpeedKmh is the assumed average city driving speed for straight-line estimates
+const DefaultSpeedKmh = 30.0
+
+// HaversineRouter estimates trips as the great-circle distance driven at a constant speed.
+// It needs no map data, so it is the fallback when road routing is unavailable.
+type HaversineRouter struct {
+	SpeedKmh float64
+}
+
+// NewHaversineRouter creates a straight-line router at the default city speed
+func NewHaversineRouter() HaversineRouter {
+	return HaversineRouter{SpeedKmh: DefaultSpeedKmh}
+}
+
+// Route estimates a straight-line trip
+func (h HaversineRouter) Route(ctx context.Context, from, to Point) (Estimate, error) {
+	distance := Haversine(from, to)
+	return Estimate{
+		DistanceKm: distance,
+		Duration:   time.Duration(distance / h.SpeedKmh * float64(time.Hour)),
+	}, nil
+}
+
+// Matrix estimates straight-line trips between all origins and destinations
+func (h HaversineRouter) Matrix(ctx context.Context, origins, destinations []Point) ([][]Estimate, error) {
+	matrix := make([][]Estimate, len(origins))
+	for i, origin := range origins {
+		matrix[i] = make([]Estimate, len(destinations))
+		for k, destination := range destinations {
+			matrix[i][k], _ = h.Route(ctx, origin, destination)
+		}
+	}
+	return matrix, nil
+}
+
+// Haversine calculates the great-circle distance between two points in kilometers
+func Haversine(from, to Point) float64 {
+	const earthRadius = 6371 // Earth's radius in kilometers
+
+	lat1Rad := from.Lat * math.Pi / 180
+	lng1Rad := from.Lng * math.Pi / 180
+	lat2Rad := to.Lat * math.Pi / 180
+	lng2Rad := to.Lng * math.Pi / 180
+
+	dlat := lat2Rad - lat1Rad
+	dlng := lng2Rad - lng1Rad
+
+	a := math.Sin(dlat/2)*math.Sin(dlat/2) + math.Cos(lat1Rad)*math.Cos(lat2Rad)*math.Sin(dlng/2)*math.Sin(dlng/2)
+	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+
+	return earthRadius * c
+}
+
+// cachePrecision rounds coordinates to ~100m before caching, so nearby requests share results
+const cachePrecision = 1e3
+
+// DefaultCacheSize is the number of coordinate pairs kept by NewRouter
+const DefaultCacheSize = 10000
+
+// pairKey identifies a cached trip by its rounded endpoints
+type pairKey [4]int64
+
+func keyFor(from, to Point) pairKey {
+	return pairKey{
+		int64(math.Round(from.Lat * cachePrecision)),
+		int64(math.Round(from.Lng * cachePrecision)),
+		int64(math.Round(to.Lat * cachePrecision)),
+		int64(math.Round(to.Lng * cachePrecision)),
+	}
+}
+
+// CachedRouter remembers a primary router's estimates by rounded coordinate pair and
+// falls back to straight-line estimates when the primary router fails. Fallback
+// estimates are not cached, so the primary router is retried on the next request.
+type CachedRouter struct {
+	primary    Router
+	fallback   Router
+	maxEntries int
+	onFallback func() // called for every fallback, such as to count them
+
+	mu      sync.RWMutex
+	entries map[pairKey]Estimate
+}
+
+// NewCachedRouter wraps a router with a cache of up to maxEntries trips. onFallback,
+// if not nil, is called whenever a straight-line estimate stands in for the primary
+// router's.
+func NewCachedRouter(primary Router, maxEntries int, onFallback func()) *CachedRouter {
+	return &CachedRouter{
+		primary:    primary,
+		fallback:   NewHaversineRouter(),
+		maxEntries: maxEntries,
+		onFallback: onFallback,
+		entries:    make(map[pairKey]Estimate),
+	}
+}
+
+// Route returns the cached estimate for a trip, asking the primary router on a miss
+func (c *CachedRouter) Route(ctx context.Context, from, to Point) (Estimate, error) {
+	key := keyFor(from, to)
+	if estimate, ok := c.lookup(key); ok {
+		return estimate, nil
+	}
+
+	estimate, err := c.primary.Route(ctx, from, to)
+	if err != nil {
+		slog.Warn("Road routing failed, using straight-line estimate", "error", err)
+		c.fellBack()
+		return c.fallback.Route(ctx, from, to)
+	}
+
+	c.store(map[pairKey]Estimate{key: estimate})
+	return estimate, nil
+}
+
+// Matrix returns cached estimates when every pair is known, otherwise it asks the
+// primary router for the whole matrix in one request
+func (c *CachedRouter) Matrix(ctx context.Context, origins, destinations []Point) ([][]Estimate, error) {
+	matrix := make([][]Estimate, len(origins))
+	complete := true
+
+	c.mu.RLock()
+	for i, origin := range origins {
+		matrix[i] = make([]Estimate, len(destinations))
+		for k, destination := range destinations {
+			estimate, ok := c.entries[keyFor(origin, destination)]
+			if !ok {
+				complete = false
+			}
+			matrix[i][k] = estimate
+		}
+	}
+	c.mu.RUnlock()
+
+	if complete {
+		return matrix, nil
+	}
+
+	matrix, err := c.primary.Matrix(ctx, origins, destinations)
+	if err != nil {
+		slog.Warn("Road routing failed, using straight-line estimates", "error", err)
+		c.fellBack()
+		return c.fallback.Matrix(ctx, origins, destinations)
+	}
+
+	fresh := make(map[pairKey]Estimate, len(origins)*len(destinations))
+	for i, origin := range origins {
+		for k, destination := range destinations {
+			fresh[keyFor(origin, destination)] = matrix[i][k]
+		}
+	}
+	c.store(fresh)
+
+	return matrix, nil
+}
+
+func (c *CachedRouter) fellBack() {
+	if c.onFallback != nil {
+		c.onFallback()
+	}
+}
+
+func (c *CachedRouter) lookup(key pairKey) (Estimate, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	estimate, ok := c.entries[key]
+	return estimate, ok
+}
+
+func (c *CachedRouter) store(estimates map[pairKey]Estimate) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Start over rather than track recency; road distances are cheap to refetch
+	if len(c.entries)+len(estimates) > c.maxEntries {
+		c.entries = make(map[pairKey]Estimate)
+	}
+	for key, estimate := range estimates {
+		c.entries[key] = estimate
+	}
+}
+
+// NewRouter creates the router for a service: cached OSRM road routing when osrmURL
+// is set, straight-line estimates otherwise. onFallback is passed to NewCachedRouter.
+func NewRouter(osrmURL string, onFallback func()) Router {
+	if osrmURL == "" {
+		return NewHaversineRouter()
+	}
+	return NewCachedRouter(NewOSRMRouter(osrmURL), DefaultCacheSize, onFallback)
+}
@@ -0,0 +1,153 @@
+package routing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Downtown Portland and the airport, across the Willamette
+var (
+	downtown = Point{Lat: 45.5152, Lng: -122.6784}
+	airport  = Point{Lat: 45.5898, Lng: -122.5951}
+)
+
+func newOSRMServer(t *testing.T, requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/route/v1/driving/"):
+			fmt.Fprint(w, `{"code":"Ok","routes":[{"distance":16500,"duration":1260}]}`)
+		case strings.HasPrefix(r.URL.Path, "/table/v1/driving/"):
+			if !strings.Contains(r.URL.RawQuery, "sources=0;1&destinations=2") {
+				t.Errorf("Unexpected table query %s", r.URL.RawQuery)
+			}
+			fmt.Fprint(w, `{"code":"Ok","distances":[[16500],[null]],"durations":[[1260],[null]]}`)
+		default:
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+	}))
+}
+
+func TestOSRMRouter_Route(t *testing.T) {
+	var requests int32
+	server := newOSRMServer(t, &requests)
+	defer server.Close()
+
+	estimate, err := NewOSRMRouter(server.URL).Route(context.Background(), downtown, airport)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if estimate.DistanceKm != 16.5 {
+		t.Errorf("Expected 16.5km, got %f", estimate.DistanceKm)
+	}
+	if estimate.Duration != 21*time.Minute {
+		t.Errorf("Expected 21 minutes, got %v", estimate.Duration)
+	}
+}
+
+func TestOSRMRouter_Matrix(t *testing.T) {
+	var requests int32
+	server := newOSRMServer(t, &requests)
+	defer server.Close()
+
+	island := Point{Lat: 45.6, Lng: -122.9}
+	matrix, err := NewOSRMRouter(server.URL).Matrix(context.Background(), []Point{downtown, island}, []Point{airport})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if matrix[0][0].DistanceKm != 16.5 {
+		t.Errorf("Expected 16.5km, got %f", matrix[0][0].DistanceKm)
+	}
+	if matrix[1][0].DistanceKm != Unreachable {
+		t.Errorf("Expected unreachable pair, got %f", matrix[1][0].DistanceKm)
+	}
+}
+
+func TestCachedRouter_CachesByRoundedCoordinates(t *testing.T) {
+	var requests int32
+	server := newOSRMServer(t, &requests)
+	defer server.Close()
+
+	router := NewCachedRouter(NewOSRMRouter(server.URL), DefaultCacheSize, nil)
+	ctx := context.Background()
+
+	router.Route(ctx, downtown, airport)
+	// A few meters away rounds to the same pair
+	nearby := Point{Lat: downtown.Lat + 0.00005, Lng: downtown.Lng + 0.00005}
+	estimate, _ := router.Route(ctx, nearby, airport)
+
+	if requests != 1 {
+		t.Errorf("Expected 1 request to OSRM, got %d", requests)
+	}
+	if estimate.DistanceKm != 16.5 {
+		t.Errorf("Expected cached 16.5km, got %f", estimate.DistanceKm)
+	}
+
+	// Matrix lookups for pairs already known don't hit OSRM
+	matrix, _ := router.Matrix(ctx, []Point{downtown}, []Point{airport})
+	if requests != 1 || matrix[0][0].DistanceKm != 16.5 {
+		t.Errorf("Expected matrix from cache, got %d requests and %v", requests, matrix)
+	}
+}
+
+func TestCachedRouter_FallsBackToHaversine(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	var fallbacks int32
+	router := NewCachedRouter(NewOSRMRouter(server.URL), DefaultCacheSize, func() { atomic.AddInt32(&fallbacks, 1) })
+	ctx := context.Background()
+
+	estimate, err := router.Route(ctx, downtown, airport)
+	if err != nil {
+		t.Fatalf("Expected straight-line fallback, got %v", err)
+	}
+	if want := Haversine(downtown, airport); estimate.DistanceKm != want {
+		t.Errorf("Expected %f km, got %f", want, estimate.DistanceKm)
+	}
+
+	matrix, err := router.Matrix(ctx, []Point{downtown}, []Point{airport})
+	if err != nil || matrix[0][0].DistanceKm != Haversine(downtown, airport) {
+		t.Errorf("Expected straight-line matrix, got %v, %v", matrix, err)
+	}
+
+	// Fallback results aren't cached, so OSRM is tried again once it recovers
+	router.Route(ctx, downtown, airport)
+	if requests != 3 {
+		t.Errorf("Expected OSRM to be retried, got %d requests", requests)
+	}
+	if fallbacks != 3 {
+		t.Errorf("Expected 3 fallbacks counted, got %d", fallbacks)
+	}
+}
+
+func TestHaversineRouter(t *testing.T) {
+	estimate, _ := NewHaversineRouter().Route(context.Background(), downtown, airport)
+
+	// Distance should be approximately 10 km, driven at 30 km/h
+	if estimate.DistanceKm < 8 || estimate.DistanceKm > 12 {
+		t.Errorf("Expected distance between 8-12 km, got %.2f", estimate.DistanceKm)
+	}
+	if want := estimate.DistanceKm / DefaultSpeedKmh * 60; estimate.Minutes()-want > 1e-9 || want-estimate.Minutes() > 1e-9 {
+		t.Errorf("Expected %.2f minutes, got %.2f", want, estimate.Minutes())
+	}
+}
+
+func TestNewRouter(t *testing.T) {
+	if _, ok := NewRouter("", nil).(HaversineRouter); !ok {
+		t.Error("Expected straight-line router without an OSRM URL")
+	}
+	if _, ok := NewRouter("http://osrm:5000", nil).(*CachedRouter); !ok {
+		t.Error("Expected cached OSRM router with an OSRM URL")
+	}
+}
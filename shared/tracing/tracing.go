@@ -0,0 +1,113 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exporters spans can be sent to
+const (
+	ExporterNone   = "none"   // spans are not recorded, but trace context still propagates
+	ExporterOTLP   = "otlp"   // OTLP over HTTP, to OTEL_EXPORTER_OTLP_ENDPOINT (localhost:4318 by default)
+	ExporterStdout = "stdout" // pretty-printed JSON on stdout, for local debugging
+)
+
+// Attribute keys every service labels its spans with, so one ride's spans can be found
+// across the job service, fleet service and simulator
+const (
+	JobIDKey     = attribute.Key("job.id")
+	VehicleIDKey = attribute.Key("vehicle.id")
+)
+
+// JobID labels a span with the job it is working on
+func JobID(jobID string) attribute.KeyValue {
+	return JobIDKey.String(jobID)
+}
+
+// VehicleID labels a span with the vehicle it is working on
+func VehicleID(vehicleID string) attribute.KeyValue {
+	return VehicleIDKey.String(vehicleID)
+}
+
+// Setup installs the global tracer provider, sending spans to exporter, and the W3C
+// trace context propagator. The returned function flushes buffered spans and stops the
+// provider.
+func Setup(ctx context.Context, serviceName, exporter string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var spanExporter sdktrace.SpanExporter
+	var err error
+	switch exporter {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		spanExporter, err = otlptracehttp.New(ctx)
+	case ExporterStdout:
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s trace exporter: %w", exporter, err)
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the defaults
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithFromEnv(),
+		resource.WithHost(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// End ends span, marking it failed if err is set
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Middleware continues the trace a request carries, or starts one, with a server span
+// named by the route the request matched
+func Middleware(service string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		named := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if route := mux.CurrentRoute(r); route != nil {
+				if template, err := route.GetPathTemplate(); err == nil {
+					span := trace.SpanFromContext(r.Context())
+					span.SetName(r.Method + " " + template)
+					span.SetAttributes(semconv.HTTPRoute(template))
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+		return otelhttp.NewHandler(named, service)
+	}
+}
@@ -0,0 +1,58 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+func TestMiddleware_ContinuesCallerTrace(t *testing.T) {
+	if _, err := Setup(context.Background(), "fleet-service", ExporterNone); err != nil {
+		t.Fatalf("Failed to set up tracing: %v", err)
+	}
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	router := mux.NewRouter()
+	router.HandleFunc("/vehicles/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}).Methods("GET")
+	router.Use(Middleware("fleet-service"))
+
+	// A request from the job service in the middle of a trace
+	req := httptest.NewRequest("GET", "/vehicles/v1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("Expected one span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "GET /vehicles/{id}" {
+		t.Errorf("Expected the span named by route, got %q", span.Name())
+	}
+	if span.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || span.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("Expected the caller's trace continued, got trace %s under %s", span.SpanContext().TraceID(), span.Parent().SpanID())
+	}
+	found := false
+	for _, attr := range span.Attributes() {
+		found = found || attr == semconv.HTTPRoute("/vehicles/{id}")
+	}
+	if !found {
+		t.Errorf("Expected the route recorded, got %v", span.Attributes())
+	}
+}
+
+func TestSetup_UnknownExporter(t *testing.T) {
+	if _, err := Setup(context.Background(), "fleet-service", "zipkin"); err == nil {
+		t.Error("Expected an error for an unknown exporter")
+	}
+}
@@ -18,6 +18,11 @@ resource "aws_dynamodb_table" "vehicles" {
     type = "S"
   }
 
+  attribute {
+    name = "cell"
+    type = "S"
+  }
+
   global_secondary_index {
     name            = "region-status-index"
     hash_key        = "region"
@@ -25,6 +30,14 @@ resource "aws_dynamodb_table" "vehicles" {
     projection_type = "ALL"
   }
 
+  # Geohash cell of each vehicle's location, for nearest-vehicle lookups
+  global_secondary_index {
+    name            = "cell-status-index"
+    hash_key        = "cell"
+    range_key       = "status"
+    projection_type = "ALL"
+  }
+
   point_in_time_recovery {
     enabled = true
   }
@@ -65,6 +78,16 @@ resource "aws_dynamodb_table" "jobs" {
     type = "S"
   }
 
+  attribute {
+    name = "customer_id"
+    type = "S"
+  }
+
+  attribute {
+    name = "revenue_status"
+    type = "S"
+  }
+
   global_secondary_index {
     name            = "status-index"
     hash_key        = "status"
@@ -77,6 +100,20 @@ resource "aws_dynamodb_table" "jobs" {
     projection_type = "ALL"
   }
 
+  # Customers' bookings and history
+  global_secondary_index {
+    name            = "customer-index"
+    hash_key        = "customer_id"
+    projection_type = "ALL"
+  }
+
+  # Finished jobs not yet in the revenue rollups; sparse, as the status is cleared once they are
+  global_secondary_index {
+    name            = "revenue-status-index"
+    hash_key        = "revenue_status"
+    projection_type = "ALL"
+  }
+
   point_in_time_recovery {
     enabled = true
   }
@@ -96,3 +133,158 @@ resource "aws_dynamodb_table" "jobs" {
     Name = "${var.project_name}-jobs"
   }
 }
+
+resource "aws_dynamodb_table" "customers" {
+  name         = "${var.project_name}-customers"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "id"
+
+  attribute {
+    name = "id"
+    type = "S"
+  }
+
+  point_in_time_recovery {
+    enabled = true
+  }
+
+  server_side_encryption {
+    enabled = true
+  }
+
+  # Streams are required for the cross-region replica
+  stream_enabled   = true
+  stream_view_type = "NEW_AND_OLD_IMAGES"
+
+  replica {
+    region_name = "us-west-1"
+  }
+
+  tags = {
+    Name = "${var.project_name}-customers"
+  }
+}
+
+# Double-entry ledger of customer charges, payments and refunds. Entry IDs start with
+# the entry time, so a customer's entries over a period are one range query.
+resource "aws_dynamodb_table" "ledger" {
+  name         = "${var.project_name}-ledger"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "customer_id"
+  range_key    = "entry_id"
+
+  attribute {
+    name = "customer_id"
+    type = "S"
+  }
+
+  attribute {
+    name = "entry_id"
+    type = "S"
+  }
+
+  point_in_time_recovery {
+    enabled = true
+  }
+
+  server_side_encryption {
+    enabled = true
+  }
+
+  # Streams are required for the cross-region replica
+  stream_enabled   = true
+  stream_view_type = "NEW_AND_OLD_IMAGES"
+
+  replica {
+    region_name = "us-west-1"
+  }
+
+  tags = {
+    Name = "${var.project_name}-ledger"
+  }
+}
+
+# Hourly revenue rollups, added to as jobs finish. Rollups are partitioned by day so a
+# revenue report reads one partition per day it covers.
+resource "aws_dynamodb_table" "revenue" {
+  name         = "${var.project_name}-revenue"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "day"
+  range_key    = "bucket"
+
+  attribute {
+    name = "day"
+    type = "S"
+  }
+
+  attribute {
+    name = "bucket"
+    type = "S"
+  }
+
+  point_in_time_recovery {
+    enabled = true
+  }
+
+  server_side_encryption {
+    enabled = true
+  }
+
+  tags = {
+    Name = "${var.project_name}-revenue"
+  }
+}
+
+# Hourly fleet KPI buckets per vehicle, added to by every fleet service instance.
+# Buckets are partitioned by day so a KPI report reads one partition per day it covers,
+# and expire once they fall out of the week KPIs are kept for.
+resource "aws_dynamodb_table" "kpis" {
+  name         = "${var.project_name}-kpis"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "day"
+  range_key    = "bucket"
+
+  attribute {
+    name = "day"
+    type = "S"
+  }
+
+  attribute {
+    name = "bucket"
+    type = "S"
+  }
+
+  ttl {
+    attribute_name = "expires_at"
+    enabled        = true
+  }
+
+  server_side_encryption {
+    enabled = true
+  }
+
+  tags = {
+    Name = "${var.project_name}-kpis"
+  }
+}
+
+# Where each vehicle was last seen and what it was doing, which the next report from
+# it is measured against when adding to the KPI buckets
+resource "aws_dynamodb_table" "kpi_states" {
+  name         = "${var.project_name}-kpi-states"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "vehicle_id"
+
+  attribute {
+    name = "vehicle_id"
+    type = "S"
+  }
+
+  server_side_encryption {
+    enabled = true
+  }
+
+  tags = {
+    Name = "${var.project_name}-kpi-states"
+  }
+}
@@ -123,6 +123,17 @@ resource "aws_ecs_task_definition" "job_service_secondary" {
         {
           name  = "DYNAMODB_TABLE_VEHICLES"
           value = aws_dynamodb_table.vehicles.name
+        },
+        {
+          name  = "PRICING_RULES_FILE"
+          value = "config/pricing.json"
+        }
+      ]
+
+      secrets = [
+        {
+          name      = "QUOTE_SIGNING_KEY"
+          valueFrom = local.quote_signing_key_secondary_arn
         }
       ]
 
@@ -81,6 +81,14 @@ resource "aws_ecs_task_definition" "fleet_service" {
           name  = "DYNAMODB_VEHICLES_TABLE"
           value = aws_dynamodb_table.vehicles.name
         },
+        {
+          name  = "DYNAMODB_KPI_TABLE"
+          value = aws_dynamodb_table.kpis.name
+        },
+        {
+          name  = "DYNAMODB_KPI_STATE_TABLE"
+          value = aws_dynamodb_table.kpi_states.name
+        },
         {
           name  = "AWS_REGION"
           value = var.aws_region
@@ -92,6 +100,14 @@ resource "aws_ecs_task_definition" "fleet_service" {
         {
           name  = "KINESIS_VEHICLE_TELEMETRY_STREAM"
           value = aws_kinesis_stream.vehicle_telemetry.name
+        },
+        {
+          name  = "KINESIS_VEHICLE_EVENTS_STREAM"
+          value = aws_kinesis_stream.vehicle_events.name
+        },
+        {
+          name  = "ROUTING_OSRM_URL"
+          value = var.osrm_url
         }
       ]
 
@@ -147,6 +163,18 @@ resource "aws_ecs_task_definition" "job_service" {
           name  = "DYNAMODB_JOBS_TABLE"
           value = aws_dynamodb_table.jobs.name
         },
+        {
+          name  = "DYNAMODB_CUSTOMERS_TABLE"
+          value = aws_dynamodb_table.customers.name
+        },
+        {
+          name  = "DYNAMODB_LEDGER_TABLE"
+          value = aws_dynamodb_table.ledger.name
+        },
+        {
+          name  = "DYNAMODB_REVENUE_TABLE"
+          value = aws_dynamodb_table.revenue.name
+        },
         {
           name  = "FLEET_SERVICE_URL"
           value = "http://${aws_lb.main.dns_name}/fleet"
@@ -170,6 +198,21 @@ resource "aws_ecs_task_definition" "job_service" {
         {
           name  = "KINESIS_JOB_EVENTS_STREAM"
           value = aws_kinesis_stream.job_events.name
+        },
+        {
+          name  = "ROUTING_OSRM_URL"
+          value = var.osrm_url
+        },
+        {
+          name  = "PRICING_RULES_FILE"
+          value = "config/pricing.json"
+        }
+      ]
+
+      secrets = [
+        {
+          name      = "QUOTE_SIGNING_KEY"
+          valueFrom = aws_secretsmanager_secret.quote_signing_key.arn
         }
       ]
 
@@ -20,6 +20,27 @@ resource "aws_iam_role_policy_attachment" "ecs_task_execution" {
   policy_arn = "arn:aws:iam::aws:policy/service-role/AmazonECSTaskExecutionRolePolicy"
 }
 
+resource "aws_iam_role_policy" "ecs_task_execution_secrets" {
+  name = "${var.project_name}-ecs-task-execution-secrets"
+  role = aws_iam_role.ecs_task_execution.id
+
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [
+      {
+        Effect = "Allow"
+        Action = [
+          "secretsmanager:GetSecretValue"
+        ]
+        Resource = [
+          aws_secretsmanager_secret.quote_signing_key.arn,
+          local.quote_signing_key_secondary_arn
+        ]
+      }
+    ]
+  })
+}
+
 resource "aws_iam_role" "ecs_task" {
   name = "${var.project_name}-ecs-task"
 
@@ -58,7 +79,12 @@ resource "aws_iam_role_policy" "ecs_task_dynamodb" {
           aws_dynamodb_table.vehicles.arn,
           "${aws_dynamodb_table.vehicles.arn}/index/*",
           aws_dynamodb_table.jobs.arn,
-          "${aws_dynamodb_table.jobs.arn}/index/*"
+          "${aws_dynamodb_table.jobs.arn}/index/*",
+          aws_dynamodb_table.customers.arn,
+          aws_dynamodb_table.ledger.arn,
+          aws_dynamodb_table.revenue.arn,
+          aws_dynamodb_table.kpis.arn,
+          aws_dynamodb_table.kpi_states.arn
         ]
       }
     ]
@@ -84,7 +110,8 @@ resource "aws_iam_role_policy" "ecs_task_kinesis" {
         ]
         Resource = [
           aws_kinesis_stream.vehicle_telemetry.arn,
-          aws_kinesis_stream.job_events.arn
+          aws_kinesis_stream.job_events.arn,
+          aws_kinesis_stream.vehicle_events.arn
         ]
       }
     ]
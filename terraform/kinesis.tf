@@ -29,3 +29,18 @@ resource "aws_kinesis_stream" "job_events" {
     Name = "${var.project_name}-job-events"
   }
 }
+
+resource "aws_kinesis_stream" "vehicle_events" {
+  name             = "${var.project_name}-vehicle-events"
+  shard_count      = 1
+  retention_period = 24
+
+  shard_level_metrics = [
+    "IncomingRecords",
+    "OutgoingRecords",
+  ]
+
+  tags = {
+    Name = "${var.project_name}-vehicle-events"
+  }
+}
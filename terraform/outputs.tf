@@ -38,6 +38,31 @@ output "dynamodb_jobs_table" {
   value       = aws_dynamodb_table.jobs.name
 }
 
+output "dynamodb_customers_table" {
+  description = "Name of the customers DynamoDB table"
+  value       = aws_dynamodb_table.customers.name
+}
+
+output "dynamodb_ledger_table" {
+  description = "Name of the customer ledger DynamoDB table"
+  value       = aws_dynamodb_table.ledger.name
+}
+
+output "dynamodb_revenue_table" {
+  description = "Name of the hourly revenue rollups DynamoDB table"
+  value       = aws_dynamodb_table.revenue.name
+}
+
+output "dynamodb_kpi_table" {
+  description = "Name of the hourly fleet KPI buckets DynamoDB table"
+  value       = aws_dynamodb_table.kpis.name
+}
+
+output "dynamodb_kpi_state_table" {
+  description = "Name of the vehicle KPI state DynamoDB table"
+  value       = aws_dynamodb_table.kpi_states.name
+}
+
 output "dashboard_url" {
   description = "URL for the dashboard"
   value       = "http://${aws_lb.main.dns_name}"
@@ -62,3 +87,13 @@ output "kinesis_job_events_stream" {
   description = "Name of the job events Kinesis stream"
   value       = aws_kinesis_stream.job_events.name
 }
+
+output "kinesis_vehicle_events_stream" {
+  description = "Name of the vehicle status events Kinesis stream"
+  value       = aws_kinesis_stream.vehicle_events.name
+}
+
+output "quote_signing_key_secret_arn" {
+  description = "ARN of the secret holding the key job-service signs fare quotes with"
+  value       = aws_secretsmanager_secret.quote_signing_key.arn
+}
@@ -45,8 +45,14 @@ for service in "${SERVICES[@]}"; do
     echo ""
     echo "🔨 Building $service..."
     
+    # Go services build from the repository root, so they can copy the shared module
+    context="./${service}/"
+    if [ -f "./${service}/go.mod" ]; then
+        context="."
+    fi
+
     # Build image for amd64 architecture
-    finch build --platform linux/amd64 -t "${service}:${TAG}" "./${service}/"
+    finch build --platform linux/amd64 -f "./${service}/Dockerfile" -t "${service}:${TAG}" "$context"
     
     # Tag for ECR
     ecr_url="${ACCOUNT_ID}.dkr.ecr.${REGION}.amazonaws.com/${PROJECT_NAME}-${service}"
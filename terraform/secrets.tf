@@ -0,0 +1,37 @@
+# Secrets injected into service containers by ECS at startup
+
+# Key job-service signs fare quotes with. Every task has to share it, or a quote issued
+# by one task is rejected by the others and by the tasks of the next deploy.
+resource "aws_secretsmanager_secret" "quote_signing_key" {
+  name        = "${var.project_name}-quote-signing-key"
+  description = "HMAC key job-service signs fare quotes with"
+
+  # Standby tasks in the secondary region read their own copy
+  replica {
+    region = "us-west-1"
+  }
+
+  tags = {
+    Name = "${var.project_name}-quote-signing-key"
+  }
+}
+
+data "aws_secretsmanager_random_password" "quote_signing_key" {
+  password_length     = 64
+  exclude_punctuation = true
+}
+
+resource "aws_secretsmanager_secret_version" "quote_signing_key" {
+  secret_id     = aws_secretsmanager_secret.quote_signing_key.id
+  secret_string = data.aws_secretsmanager_random_password.quote_signing_key.random_password
+
+  # Generated once: a new key would invalidate every outstanding quote
+  lifecycle {
+    ignore_changes = [secret_string]
+  }
+}
+
+locals {
+  # The replica keeps the secret's name, so its ARN differs only in the region
+  quote_signing_key_secondary_arn = replace(aws_secretsmanager_secret.quote_signing_key.arn, ":${var.aws_region}:", ":us-west-1:")
+}
@@ -63,3 +63,9 @@ variable "desired_count" {
   type        = number
   default     = 2
 }
+
+variable "osrm_url" {
+  description = "Base URL of an OSRM server for road distances and ETAs; straight-line estimates are used when empty"
+  type        = string
+  default     = ""
+}